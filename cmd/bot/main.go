@@ -1,17 +1,37 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"os"
 	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/GeorgeTyupin/labguard/internal/bot/app"
 	"github.com/GeorgeTyupin/labguard/internal/bot/config"
+	"github.com/GeorgeTyupin/labguard/pkg/logging"
+	"github.com/GeorgeTyupin/labguard/pkg/tracing"
 )
 
+// tracingShutdownTimeout сколько ждать отправки оставшихся спанов при завершении
+const tracingShutdownTimeout = 5 * time.Second
+
 func main() {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	cfg := config.MustLoad(logger)
+	cfg, src := config.MustLoad(logger)
+	logger = logging.New(string(cfg.Env), os.Stdout)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, "labguard-bot")
+	if err != nil {
+		logger.Error("Не удалось настроить трассировку", slog.String("error", err.Error()))
+		return
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		shutdownTracing(ctx)
+	}()
 
 	app, err := app.NewBot(logger, cfg)
 	if err != nil {
@@ -19,8 +39,13 @@ func main() {
 		return
 	}
 
+	// По SIGHUP поля конфига с reload:"live" применяются без перезапуска
+	reloader := config.NewReloader(src, cfg, app.Reload, logger)
+	reloader.Start()
+	defer reloader.Stop()
+
 	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, os.Interrupt)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
 
 	go app.Bot.Start()
 	logger.Info("Бот успешно запустился")
@@ -0,0 +1,163 @@
+// labguard утилиты администратора. trace ищет метки в утекшей копии материалов
+// и по ним находит покупателя, которому копия была выдана.
+//
+//	go run ./cmd/labguard trace leaked.zip
+//	LABGUARD_TOKEN=... go run ./cmd/labguard trace -server https://labguard.example.com leaked.zip
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/watermark"
+)
+
+// requestTimeout сколько ждать ответа сервера на поиск одной метки
+const requestTimeout = 10 * time.Second
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "trace" {
+		fmt.Fprintln(os.Stderr, "Использование: labguard trace [-server URL] <файл>")
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("trace", flag.ExitOnError)
+	server := flags.String("server", "", "адрес сервера, без него только выводятся найденные метки")
+	flags.Parse(os.Args[2:])
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Использование: labguard trace [-server URL] <файл>")
+		os.Exit(2)
+	}
+
+	if err := trace(os.Stdout, flags.Arg(0), *server, os.Getenv("LABGUARD_TOKEN")); err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка:", err)
+		os.Exit(1)
+	}
+}
+
+type deliveryTrace struct {
+	Watermark   string    `json:"watermark"`
+	Channel     string    `json:"channel"`
+	DeliveredAt time.Time `json:"delivered_at"`
+	LicenseID   int64     `json:"license_id"`
+	TelegramID  int64     `json:"telegram_id"`
+	UserName    string    `json:"user_name"`
+	UserGroup   string    `json:"user_group"`
+	Bound       bool      `json:"bound"`
+	Artifact    struct {
+		ProductID int64  `json:"product_id"`
+		Version   int    `json:"version"`
+		FileName  string `json:"file_name"`
+	} `json:"artifact"`
+}
+
+var errNoMarks = errors.New("меток не найдено")
+
+func trace(w io.Writer, path, server, token string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	marks, err := watermark.Extract(f, info.Size())
+	if err != nil {
+		return err
+	}
+	if len(marks) == 0 {
+		return errNoMarks
+	}
+
+	// Код и места, где он найден: в копии может оказаться несколько меток, если
+	// ее собрали из файлов разных покупателей
+	var codes []string
+	places := make(map[string][]string)
+	for _, m := range marks {
+		if places[m.Code] == nil {
+			codes = append(codes, m.Code)
+		}
+		place := m.Place
+		if m.Hidden {
+			place += " (пробелы в концах строк)"
+		}
+		places[m.Code] = append(places[m.Code], place)
+	}
+
+	if server != "" && token == "" {
+		return fmt.Errorf("для запроса к серверу нужен токен администратора в LABGUARD_TOKEN")
+	}
+
+	client := &http.Client{Timeout: requestTimeout}
+	for _, code := range codes {
+		fmt.Fprintf(w, "%s%s\n", watermark.Prefix, code)
+		for _, place := range places[code] {
+			fmt.Fprintf(w, "  найдена: %s\n", place)
+		}
+
+		if server == "" {
+			continue
+		}
+
+		delivery, err := lookup(client, server, token, code)
+		if err != nil {
+			fmt.Fprintf(w, "  покупатель: %v\n", err)
+			continue
+		}
+		fmt.Fprintf(w, "  покупатель: %s (%s), telegram_id %d, лицензия %d\n",
+			delivery.UserName, delivery.UserGroup, delivery.TelegramID, delivery.LicenseID)
+		fmt.Fprintf(w, "  выдано: %s, %s, продукт %d, версия %d (%s)\n",
+			delivery.DeliveredAt.Local().Format("02.01.2006 15:04"), delivery.Channel,
+			delivery.Artifact.ProductID, delivery.Artifact.Version, delivery.Artifact.FileName)
+		if !delivery.Bound {
+			fmt.Fprintln(w, "  подпись: код не подписан по этой лицензии, проверьте журнал выдач")
+		}
+	}
+
+	return nil
+}
+
+// lookup покупатель по коду выдачи из журнала на сервере
+func lookup(client *http.Client, server, token, code string) (*deliveryTrace, error) {
+	endpoint := strings.TrimSuffix(server, "/") + "/api/v1/admin/artifacts/trace?watermark=" + url.QueryEscape(code)
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return nil, errors.New(apiErr.Error)
+		}
+		return nil, fmt.Errorf("сервер ответил %s", resp.Status)
+	}
+
+	var delivery deliveryTrace
+	if err := json.NewDecoder(resp.Body).Decode(&delivery); err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
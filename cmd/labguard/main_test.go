@@ -0,0 +1,223 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/watermark"
+)
+
+const testToken = "admin-token"
+
+// markedArchive архив с исходником, помеченный watermark.Apply, как его выдает сервер
+func markedArchive(t *testing.T, code string) []byte {
+	t.Helper()
+
+	var src bytes.Buffer
+	zw := zip.NewWriter(&src)
+	w, err := zw.Create("lab/main.go")
+	if err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	w.Write([]byte("package main\n\nfunc main() {\n"))
+	for i := range 30 {
+		fmt.Fprintf(w, "\tprintln(%d)\n", i)
+	}
+	w.Write([]byte("}\n"))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+
+	var dst bytes.Buffer
+	if err := watermark.Apply(&dst, bytes.NewReader(src.Bytes()), int64(src.Len()), watermark.FormatZIP, code); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	return dst.Bytes()
+}
+
+// unzipFile содержимое одного файла архива, как его выкладывают без остального архива
+func unzipFile(t *testing.T, archive []byte, name string) []byte {
+	t.Helper()
+
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	rc, err := zr.Open(name)
+	if err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	defer rc.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(rc); err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func writeTemp(t *testing.T, name string, content []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	return path
+}
+
+// traceServer отвечает на поиск метки так же, как /api/v1/admin/artifacts/trace
+func traceServer(t *testing.T, code string, bound bool) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/admin/artifacts/trace" || r.Header.Get("Authorization") != "Bearer "+testToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Нет доступа"})
+			return
+		}
+		if r.URL.Query().Get("watermark") != code {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Метка не найдена"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"watermark":    code,
+			"channel":      "link",
+			"delivered_at": "2026-03-01T10:00:00Z",
+			"license_id":   7,
+			"telegram_id":  1001,
+			"user_name":    "Иван",
+			"user_group":   "ИУ7-61Б",
+			"bound":        bound,
+			"artifact":     map[string]any{"product_id": 3, "version": 2, "file_name": "lab.zip"},
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestTrace(t *testing.T) {
+	code, err := watermark.NewCode([]byte("ключ"), 7)
+	if err != nil {
+		t.Fatalf("NewCode: %v", err)
+	}
+	archive := markedArchive(t, code)
+
+	tests := []struct {
+		name    string
+		file    string
+		content []byte
+		server  bool
+		token   string
+		bound   bool
+		want    []string
+		notWant []string
+		wantErr error
+	}{
+		{
+			name:    "архив без сервера",
+			file:    "leaked.zip",
+			content: archive,
+			want: []string{
+				watermark.Prefix + code,
+				"найдена: " + watermark.PlaceArchiveComment,
+				"найдена: LABGUARD.txt",
+				"найдена: lab/main.go (пробелы в концах строк)",
+			},
+			notWant: []string{"покупатель:"},
+		},
+		{
+			name:    "отдельный исходник",
+			file:    "main.go",
+			content: unzipFile(t, archive, "lab/main.go"),
+			want:    []string{watermark.Prefix + code, "найдена: " + watermark.PlaceFile + " (пробелы в концах строк)"},
+		},
+		{
+			name:    "покупатель с сервера",
+			file:    "leaked.zip",
+			content: archive,
+			server:  true,
+			token:   testToken,
+			bound:   true,
+			want:    []string{"покупатель: Иван (ИУ7-61Б), telegram_id 1001, лицензия 7", "продукт 3, версия 2 (lab.zip)"},
+			notWant: []string{"подпись:"},
+		},
+		{
+			name:    "подпись не сходится с журналом",
+			file:    "leaked.zip",
+			content: archive,
+			server:  true,
+			token:   testToken,
+			want:    []string{"лицензия 7", "подпись: код не подписан по этой лицензии"},
+		},
+		{
+			name:    "ошибка сервера выводится у метки",
+			file:    "leaked.zip",
+			content: archive,
+			server:  true,
+			token:   "чужой токен",
+			want:    []string{"покупатель: Нет доступа"},
+		},
+		{
+			name:    "сервер без токена",
+			file:    "leaked.zip",
+			content: archive,
+			server:  true,
+			wantErr: errors.New("для запроса к серверу нужен токен администратора в LABGUARD_TOKEN"),
+		},
+		{
+			name:    "меток нет",
+			file:    "clean.go",
+			content: []byte("package main\n"),
+			wantErr: errNoMarks,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTemp(t, tt.file, tt.content)
+			server := ""
+			if tt.server {
+				server = traceServer(t, code, tt.bound).URL
+			}
+
+			var out bytes.Buffer
+			err := trace(&out, path, server, tt.token)
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Fatalf("trace = %v, ожидалось %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("trace: %v", err)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Fatalf("в выводе нет %q:\n%s", want, out.String())
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(out.String(), notWant) {
+					t.Fatalf("в выводе есть %q:\n%s", notWant, out.String())
+				}
+			}
+		})
+	}
+}
@@ -1,27 +1,53 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/GeorgeTyupin/labguard/internal/server/app"
 	"github.com/GeorgeTyupin/labguard/internal/server/config"
 	"github.com/GeorgeTyupin/labguard/internal/server/repository/postgres"
+	"github.com/GeorgeTyupin/labguard/pkg/logging"
+	"github.com/GeorgeTyupin/labguard/pkg/tracing"
 )
 
+// tracingShutdownTimeout сколько ждать отправки оставшихся спанов при завершении
+const tracingShutdownTimeout = 5 * time.Second
+
 func main() {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
-	cfg := config.MustLoad(logger)
+	cfg, src := config.MustLoad(logger)
+	logger = logging.New(string(cfg.Env), os.Stdout)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, "labguard-server")
+	if err != nil {
+		logger.Error("Не удалось настроить трассировку", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		shutdownTracing(ctx)
+	}()
 
 	db := postgres.MustDBPoolInit(logger, cfg.PostgresConfig)
 	defer db.Close()
 
+	postgres.MustMigrate(logger, db)
+
 	application := app.NewServerApp(logger, cfg, db)
 
+	// По SIGHUP поля конфига с reload:"live" применяются без перезапуска
+	reloader := config.NewReloader(src, cfg, application.Reload, logger)
+	reloader.Start()
+	defer reloader.Stop()
+
 	signalCh := make(chan os.Signal, 2)
 	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
 
@@ -0,0 +1,398 @@
+// tgfake поддельный Telegram Bot API для локальной проверки бота без Telegram.
+// Понимает getMe, getUpdates, setWebhook, deleteWebhook, getWebhookInfo и отправку
+// фото, документов и альбомов с выдачей file_id, на остальные
+// методы отвечает успехом и печатает их параметры. Обновления от пользователя
+// добавляются запросом к /_update и уходят на зарегистрированный webhook с секретом
+// или в очередь getUpdates.
+//
+//	go run ./cmd/tgfake -addr :8081
+//	curl -X POST 'localhost:8081/_update?user=42&text=/catalog'
+//	curl -X POST 'localhost:8081/_update?user=42&data=product_1'
+//	curl -X POST 'localhost:8081/_update?user=42&lang=en&text=/start'
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileIDPrefix начало file_id, которые выдает tgfake
+const fileIDPrefix = "fake-file-"
+
+// secretHeader заголовок, в котором Telegram передает secret_token webhook
+const secretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+type fake struct {
+	mu        sync.Mutex
+	webhook   string
+	secret    string
+	updates   []map[string]any
+	nextID    int
+	messageID int
+	fileID    int
+	files     map[string]bool // Выданные file_id
+	notify    chan struct{}   // Закрывается и пересоздается при каждом новом обновлении
+	client    *http.Client
+}
+
+func main() {
+	addr := flag.String("addr", ":8081", "адрес, на котором слушает поддельный Bot API")
+	flag.Parse()
+
+	f := &fake{nextID: 1, files: map[string]bool{}, notify: make(chan struct{}), client: &http.Client{Timeout: 30 * time.Second}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /_update", f.inject)
+	mux.HandleFunc("/{bot}/{method}", f.method)
+
+	log.Printf("Поддельный Bot API слушает %s, укажите bot.api_url: http://localhost%s", *addr, *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// method обрабатывает вызов /bot<token>/<method>
+func (f *fake) method(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.PathValue("bot"), "bot") {
+		http.NotFound(w, r)
+		return
+	}
+
+	params, err := readParams(r)
+	if err != nil {
+		reply(w, http.StatusBadRequest, map[string]any{"ok": false, "error_code": 400, "description": err.Error()})
+		return
+	}
+
+	method := r.PathValue("method")
+	switch method {
+	case "getMe":
+		ok(w, map[string]any{"id": 1, "is_bot": true, "first_name": "LabGuard", "username": "labguard_fake_bot"})
+
+	case "setWebhook":
+		f.mu.Lock()
+		f.webhook, f.secret = params["url"], params["secret_token"]
+		f.mu.Unlock()
+		log.Printf("setWebhook url=%s секрет задан=%t", params["url"], params["secret_token"] != "")
+		ok(w, true)
+
+	case "deleteWebhook":
+		f.mu.Lock()
+		f.webhook, f.secret = "", ""
+		f.mu.Unlock()
+		log.Print("deleteWebhook")
+		ok(w, true)
+
+	case "getWebhookInfo":
+		f.mu.Lock()
+		info := map[string]any{"url": f.webhook, "pending_update_count": len(f.updates)}
+		f.mu.Unlock()
+		ok(w, info)
+
+	case "getUpdates":
+		f.getUpdates(w, r, params)
+
+	case "sendPhoto", "sendDocument", "sendMediaGroup":
+		f.sendMedia(w, r, method, params)
+
+	default:
+		log.Printf("%s %v", method, params)
+		if strings.HasPrefix(method, "send") || strings.HasPrefix(method, "edit") {
+			ok(w, f.message(params["chat_id"]))
+			return
+		}
+		ok(w, true)
+	}
+}
+
+// sendMedia отвечает сообщениями с файлами. Загруженному файлу выдается новый file_id,
+// как это делает Telegram, а отправленный по file_id возвращается с ним же.
+func (f *fake) sendMedia(w http.ResponseWriter, r *http.Request, method string, params map[string]string) {
+	chatID, _ := strconv.ParseInt(params["chat_id"], 10, 64)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch method {
+	case "sendPhoto":
+		if f.staleLocked(w, params["photo"]) {
+			return
+		}
+		log.Printf("%s chat_id=%d %s", method, chatID, f.describeFile(r, "photo", params["photo"]))
+		msg := f.messageLocked(chatID)
+		msg["photo"] = []map[string]any{f.fileLocked(params["photo"])}
+		ok(w, msg)
+
+	case "sendDocument":
+		if f.staleLocked(w, params["document"]) {
+			return
+		}
+		log.Printf("%s chat_id=%d %s caption=%q", method, chatID, f.describeFile(r, "document", params["document"]), params["caption"])
+		msg := f.messageLocked(chatID)
+		msg["document"] = f.fileLocked(params["document"])
+		msg["caption"] = params["caption"]
+		ok(w, msg)
+
+	case "sendMediaGroup":
+		var media []struct {
+			Type  string `json:"type"`
+			Media string `json:"media"`
+		}
+		if err := json.Unmarshal([]byte(params["media"]), &media); err != nil || len(media) < 2 || len(media) > 10 {
+			reply(w, http.StatusBadRequest, map[string]any{"ok": false, "error_code": 400, "description": "Bad Request: media must contain 2-10 items"})
+			return
+		}
+
+		for _, item := range media {
+			if _, ref := attachField(r, item.Media); f.staleLocked(w, ref) {
+				return
+			}
+		}
+
+		messages := make([]map[string]any, 0, len(media))
+		for _, item := range media {
+			field, ref := attachField(r, item.Media)
+			log.Printf("%s chat_id=%d %s %s", method, chatID, item.Type, f.describeFile(r, field, ref))
+			file := f.fileLocked(ref)
+
+			msg := f.messageLocked(chatID)
+			if item.Type == "photo" {
+				msg["photo"] = []map[string]any{file}
+			} else {
+				msg["document"] = file
+			}
+			messages = append(messages, msg)
+		}
+		ok(w, messages)
+	}
+}
+
+// staleLocked отвечает ошибкой на file_id, выданный до перезапуска tgfake, как Telegram
+// отвечает на file_id другого бота. Так проверяется повторная загрузка файлов ботом.
+func (f *fake) staleLocked(w http.ResponseWriter, ref string) bool {
+	if !strings.HasPrefix(ref, fileIDPrefix) || f.files[ref] {
+		return false
+	}
+
+	log.Printf("неизвестный file_id %s", ref)
+	reply(w, http.StatusBadRequest, map[string]any{"ok": false, "error_code": 400, "description": "Bad Request: wrong file identifier/HTTP URL specified"})
+
+	return true
+}
+
+// fileLocked описание файла в ответе по значению параметра ref.
+// Известный file_id возвращается как есть, все остальное считается загрузкой.
+func (f *fake) fileLocked(ref string) map[string]any {
+	fileID := ref
+	if !f.files[ref] {
+		f.fileID++
+		fileID = fmt.Sprintf("%s%d", fileIDPrefix, f.fileID)
+		f.files[fileID] = true
+	}
+
+	return map[string]any{"file_id": fileID, "file_unique_id": fileID, "width": 800, "height": 600}
+}
+
+// attachField поле формы для ссылки attach://name из sendMediaGroup
+func attachField(r *http.Request, media string) (field, ref string) {
+	if name, ok := strings.CutPrefix(media, "attach://"); ok {
+		return name, r.FormValue(name)
+	}
+
+	return "", media
+}
+
+// describeFile telebot отправляет картинки без имени файла, и такая часть формы
+// читается как обычное поле, поэтому загрузку отличаем по неизвестному file_id
+func (f *fake) describeFile(r *http.Request, field, ref string) string {
+	if r.MultipartForm != nil && len(r.MultipartForm.File[field]) > 0 {
+		header := r.MultipartForm.File[field][0]
+		return fmt.Sprintf("загружен %s (%d байт)", header.Filename, header.Size)
+	}
+	if !f.files[ref] {
+		return fmt.Sprintf("загружен файл без имени (%d байт)", len(ref))
+	}
+
+	return "file_id=" + ref
+}
+
+func (f *fake) getUpdates(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	offset, _ := strconv.Atoi(params["offset"])
+	timeout, _ := strconv.Atoi(params["timeout"])
+	deadline := time.After(time.Duration(timeout) * time.Second)
+
+	for {
+		f.mu.Lock()
+		if f.webhook != "" {
+			f.mu.Unlock()
+			reply(w, http.StatusConflict, map[string]any{"ok": false, "error_code": 409, "description": "Conflict: can't use getUpdates method while webhook is active"})
+			return
+		}
+
+		// Как и Telegram, offset подтверждает все обновления до него
+		pending := f.updates[:0]
+		for _, update := range f.updates {
+			if update["update_id"].(int) >= offset {
+				pending = append(pending, update)
+			}
+		}
+		f.updates = pending
+		result := append([]map[string]any{}, pending...)
+		notify := f.notify
+		f.mu.Unlock()
+
+		if len(result) > 0 {
+			ok(w, result)
+			return
+		}
+
+		select {
+		case <-notify:
+		case <-deadline:
+			ok(w, result)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// inject создает обновление от пользователя: сообщение text или нажатие кнопки data
+func (f *fake) inject(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.URL.Query().Get("user"), 10, 64)
+	if err != nil {
+		http.Error(w, "нужен параметр user с telegram id", http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	update := map[string]any{"update_id": f.nextID}
+	f.nextID++
+	user := map[string]any{"id": userID, "is_bot": false, "first_name": "Test", "username": "user" + strconv.FormatInt(userID, 10)}
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		user["language_code"] = lang
+	}
+	message := f.messageLocked(userID)
+	message["from"] = user
+
+	if data := r.URL.Query().Get("data"); data != "" {
+		message["from"] = map[string]any{"id": 1, "is_bot": true, "first_name": "LabGuard"}
+		update["callback_query"] = map[string]any{"id": strconv.Itoa(update["update_id"].(int)), "from": user, "message": message, "data": data, "chat_instance": "fake"}
+	} else {
+		message["text"] = r.URL.Query().Get("text")
+		if strings.HasPrefix(message["text"].(string), "/") {
+			command, _, _ := strings.Cut(message["text"].(string), " ")
+			message["entities"] = []map[string]any{{"type": "bot_command", "offset": 0, "length": len(command)}}
+		}
+		update["message"] = message
+	}
+
+	webhook, secret := f.webhook, f.secret
+	if webhook == "" {
+		f.updates = append(f.updates, update)
+		close(f.notify)
+		f.notify = make(chan struct{})
+	}
+	f.mu.Unlock()
+
+	if webhook == "" {
+		fmt.Fprintf(w, "обновление %d в очереди getUpdates\n", update["update_id"])
+		return
+	}
+
+	status, err := f.deliver(webhook, secret, update)
+	if err != nil {
+		http.Error(w, "webhook недоступен: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	fmt.Fprintf(w, "обновление %d доставлено на webhook, ответ %d\n", update["update_id"], status)
+}
+
+func (f *fake) deliver(url, secret string, update map[string]any) (int, error) {
+	body, err := json.Marshal(update)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(secretHeader, secret)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+func (f *fake) message(chatID string) map[string]any {
+	id, _ := strconv.ParseInt(chatID, 10, 64)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.messageLocked(id)
+}
+
+func (f *fake) messageLocked(chatID int64) map[string]any {
+	f.messageID++
+
+	return map[string]any{
+		"message_id": f.messageID,
+		"date":       time.Now().Unix(),
+		"chat":       map[string]any{"id": chatID, "type": "private"},
+	}
+}
+
+// readParams параметры метода из JSON, формы или строки запроса, все значения строками
+func readParams(r *http.Request) (map[string]string, error) {
+	params := map[string]string{}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var raw map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			return nil, fmt.Errorf("неверный JSON: %w", err)
+		}
+		for key, value := range raw {
+			if s, isString := value.(string); isString {
+				params[key] = s
+				continue
+			}
+			data, _ := json.Marshal(value)
+			params[key] = string(data)
+		}
+		return params, nil
+	}
+
+	if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
+		return nil, fmt.Errorf("неверная форма: %w", err)
+	}
+	for key := range r.Form {
+		params[key] = r.Form.Get(key)
+	}
+
+	return params, nil
+}
+
+func ok(w http.ResponseWriter, result any) {
+	reply(w, http.StatusOK, map[string]any{"ok": true, "result": result})
+}
+
+func reply(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,96 @@
+// token выпускает jwt для администраторов и клиентов сервера.
+//
+//	JWT_SECRET=... go run ./cmd/token -role admin -sub alice -ttl 24h
+//	go run ./cmd/token -role admin -sub alice -key admin.pem -kid admin-2026-10
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/pkg/auth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func main() {
+	role := flag.String("role", auth.RoleAdmin, "роль владельца токена: admin, client или bot")
+	subject := flag.String("sub", "", "владелец токена (логин администратора, id клиента)")
+	scopes := flag.String("scopes", "", "права через запятую, по умолчанию все права роли")
+	audience := flag.String("aud", auth.DefaultAudience, "сервис, для которого выпускается токен")
+	ttl := flag.Duration("ttl", 24*time.Hour, "время жизни токена")
+	keyFile := flag.String("key", "", "закрытый ключ Ed25519 или RSA в PEM, без него токен подписывается JWT_SECRET")
+	keyID := flag.String("kid", "", "идентификатор ключа, под которым открытый ключ добавлен на сервере")
+	flag.Parse()
+
+	key, err := signingKey(*keyFile, *keyID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка:", err)
+		os.Exit(1)
+	}
+
+	token, err := newToken(key, *role, *subject, *scopes, *audience, *ttl)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка:", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(token)
+}
+
+func signingKey(keyFile, keyID string) (*auth.SigningKey, error) {
+	if keyFile != "" {
+		if keyID == "" {
+			return nil, fmt.Errorf("для ключа из файла нужен -kid")
+		}
+		return auth.LoadSigningKey(keyID, keyFile)
+	}
+
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return nil, fmt.Errorf("не задана переменная JWT_SECRET и не указан -key")
+	}
+
+	return auth.NewHMACSigningKey(secret), nil
+}
+
+func newToken(key *auth.SigningKey, role, subject, scopes, audience string, ttl time.Duration) (string, error) {
+	if !auth.KnownRole(role) {
+		return "", fmt.Errorf("неизвестная роль %q", role)
+	}
+
+	if subject == "" {
+		return "", fmt.Errorf("не указан владелец токена (-sub)")
+	}
+
+	tokenScopes := auth.DefaultScopes(role)
+	if scopes != "" {
+		requested := strings.Split(scopes, ",")
+		tokenScopes = auth.AllowedScopes(role, requested)
+		if len(tokenScopes) != len(requested) {
+			return "", fmt.Errorf("роли %s доступны только права: %s", role, strings.Join(auth.DefaultScopes(role), ", "))
+		}
+	}
+
+	issuer := auth.IssuerAdmin
+	if role == auth.RoleBot {
+		issuer = auth.IssuerBot
+	}
+
+	now := time.Now()
+	claims := auth.Claims{
+		Role:   role,
+		Scopes: tokenScopes,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			Issuer:    issuer,
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	return key.Sign(claims)
+}
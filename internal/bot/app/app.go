@@ -1,35 +1,79 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"time"
 
 	"github.com/GeorgeTyupin/labguard/internal/bot/config"
 	"github.com/GeorgeTyupin/labguard/internal/bot/handlers"
+	"github.com/GeorgeTyupin/labguard/internal/bot/i18n"
+	"github.com/GeorgeTyupin/labguard/internal/bot/jwt"
 	"github.com/GeorgeTyupin/labguard/internal/bot/keyboards"
+	"github.com/GeorgeTyupin/labguard/internal/bot/metrics"
+	"github.com/GeorgeTyupin/labguard/internal/bot/middleware/antiflood"
 	"github.com/GeorgeTyupin/labguard/internal/bot/middleware/loggers"
+	"github.com/GeorgeTyupin/labguard/internal/bot/middleware/tracing"
 	"github.com/GeorgeTyupin/labguard/internal/bot/models"
 	"github.com/GeorgeTyupin/labguard/internal/bot/services/api"
-	"github.com/GeorgeTyupin/labguard/pkg/cache"
+	"github.com/GeorgeTyupin/labguard/internal/bot/services/notifier"
+	"github.com/GeorgeTyupin/labguard/internal/bot/services/outbound"
+	"github.com/GeorgeTyupin/labguard/internal/bot/state"
+	"github.com/GeorgeTyupin/labguard/internal/bot/webhook"
+	"github.com/GeorgeTyupin/labguard/pkg/health"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	tele "gopkg.in/telebot.v4"
 )
 
+// opsShutdownTimeout сколько ждать завершения служебного listener
+const opsShutdownTimeout = 5 * time.Second
+
 type BotApp struct {
 	AppName string
 	Bot     *tele.Bot
 	Config  *config.Config
 	Logger  *slog.Logger
+	metrics *metrics.Metrics
 	cleanup []func()
+
+	// Компоненты, которые принимают новые значения при перезагрузке конфига
+	throttler *antiflood.Throttler
+	pacer     *outbound.Pacer
+	notifier  *notifier.Notifier
+
+	state state.Store // Общее для реплик состояние: кеши и шаги диалогов
 }
 
 func NewBot(logger *slog.Logger, cfg *config.Config) (*BotApp, error) {
 	appName := "Телеграмм бот"
 	logger = logger.With(slog.String("app", appName))
 
+	pacer := outbound.NewPacer(cfg.Outbound.PerSecond, cfg.Outbound.Burst, cfg.Outbound.MaxWait)
+
+	shared, err := newSharedState(cfg.State)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сконфигурировать приложение %s, возникла ошибка %w", appName, err)
+	}
+
 	pref := tele.Settings{
-		Token:  cfg.BotToken,
-		Poller: &tele.LongPoller{Timeout: 10 * time.Second},
+		Token:     cfg.BotToken,
+		URL:       cfg.APIURL,
+		ParseMode: i18n.ParseMode, // Тексты из i18n размечены, данные в них экранированы
+		Client: &http.Client{
+			Timeout:   time.Minute,
+			Transport: outbound.NewTransport(http.DefaultTransport, pacer, logger),
+		},
+	}
+
+	var hook *webhook.Poller
+	if cfg.Updates.Mode == config.UpdatesWebhook {
+		hook = webhook.New(cfg.Updates.Webhook, state.NewUpdates(shared.store, cfg.State.DedupTTL), logger)
+		pref.Poller = hook
+	} else {
+		pref.Poller = &tele.LongPoller{Timeout: cfg.Updates.Polling.Timeout}
 	}
 
 	bot, err := tele.NewBot(pref)
@@ -37,56 +81,197 @@ func NewBot(logger *slog.Logger, cfg *config.Config) (*BotApp, error) {
 		return nil, fmt.Errorf("не удалось сконфигурировать приложение %s, возникла ошибка %w", appName, err)
 	}
 
-	bot.Use(loggers.MessageLogger(logger))
+	// Пока у бота зарегистрирован webhook, getUpdates отвечает ошибкой. Снимаем его,
+	// чтобы вернуться к long polling можно было одной правкой конфига.
+	if hook == nil {
+		if err := bot.RemoveWebhook(false); err != nil {
+			return nil, fmt.Errorf("не удалось снять webhook перед запуском long polling: %w", err)
+		}
+	}
+
+	tokens, err := jwt.NewIssuer(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сконфигурировать приложение %s, возникла ошибка %w", appName, err)
+	}
+
+	bundle, err := i18n.Load(cfg.I18n.DefaultLocale)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сконфигурировать приложение %s, возникла ошибка %w", appName, err)
+	}
+	languages := state.NewValues[string](shared.store, "language", languageTTL)
+
+	// Язык выбирается до antiflood, чтобы предупреждение о флуде пришло на языке пользователя
+	throttler := antiflood.NewThrottler(shared.antiflood, antiFloodLimits(cfg), logger)
+	bot.Use(
+		loggers.RequestID(),
+		loggers.MessageLogger(logger),
+		i18n.Middleware(bundle, languages, logger),
+		throttler.Middleware(),
+	)
 
 	application := &BotApp{
-		Bot:     bot,
-		AppName: appName,
-		Config:  cfg,
-		Logger:  logger,
+		Bot:       bot,
+		AppName:   appName,
+		Config:    cfg,
+		Logger:    logger,
+		metrics:   metrics.New(),
+		throttler: throttler,
+		pacer:     pacer,
+		state:     shared.store,
 	}
 
-	application.registerHandlers()
+	apiTransport := otelhttp.NewTransport(application.metrics.Transport(http.DefaultTransport))
+	apiClient := api.NewHttpClient(cfg, tokens, apiTransport)
+	application.registerHandlers(apiClient, bundle, languages)
+	application.serveOps(application.readinessChecker(apiClient.Ping, shared.ping))
+
+	// Хранилище закрывается последним, после остановки всех, кто в него пишет
+	application.cleanup = append(application.cleanup, shared.close)
+
+	// Webhook регистрируется последним: с этого момента Telegram начинает слать обновления
+	if hook != nil {
+		if err := hook.Start(bot); err != nil {
+			application.runCleanup()
+			return nil, fmt.Errorf("не удалось сконфигурировать приложение %s, возникла ошибка %w", appName, err)
+		}
+	}
 
 	return application, nil
 }
 
-func (app *BotApp) registerHandlers() {
-	apiClient := api.NewHttpClient()
+// handle регистрирует обработчик с подсчетом метрик и спаном трассировки
+func (app *BotApp) handle(endpoint any, handler tele.HandlerFunc) {
+	name := metrics.HandlerName(endpoint)
+	app.Bot.Handle(endpoint, app.metrics.Instrument(name, tracing.Handler(name, handler)))
+}
 
-	// Приложение для регистрации
-	startHandler := handlers.NewStartHandler(apiClient, app.Logger)
-	app.Bot.Handle(handlers.StartEndpoint, startHandler.Handle)
-	app.Bot.Handle(tele.OnText, startHandler.HandleMessage)
+// serveOps запускает служебный listener с метриками и проверками живости и готовности,
+// если задан его адрес
+func (app *BotApp) serveOps(ready *health.Checker) {
+	address := app.Config.Metrics.Address
+	if address == "" {
+		return
+	}
 
-	productCache := cache.NewCacheWithTTL[int64, []*models.Product](time.Duration(10 * time.Minute)) // Кеш неоплаченных продуктов
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", app.metrics.Handler())
+	mux.HandleFunc("GET /health/live", health.LiveHandler)
+	mux.Handle("GET /health/ready", ready.ReadyHandler())
+
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			app.Logger.Error("Ошибка служебного listener", slog.String("error", err.Error()))
+		}
+	}()
+	app.Logger.Info("Метрики и проверки готовности доступны", slog.String("address", address))
 
-	// Приложение для получения списка доступных продуктов
-	catalogHandler := handlers.NewCatalogHandler(apiClient, app.Logger, productCache)
 	app.cleanup = append(app.cleanup, func() {
-		catalogHandler.Cache.Stop()
+		ctx, cancel := context.WithTimeout(context.Background(), opsShutdownTimeout)
+		defer cancel()
+
+		if err := server.Shutdown(ctx); err != nil {
+			app.Logger.Warn("Служебный listener не успел завершиться", slog.String("error", err.Error()))
+		}
 	})
-	app.Bot.Handle(handlers.CatalogEndpoint, catalogHandler.Handle)
+}
+
+func (app *BotApp) registerHandlers(apiClient *api.HttpClient, bundle *i18n.Bundle, languages handlers.UserValues[string]) {
+	// Приложение для регистрации
+	registerStates := state.NewValues[*handlers.RegisterState](app.state, "register", dialogTTL)
+	startHandler := handlers.NewStartHandler(apiClient, app.Logger, registerStates)
+	app.handle(handlers.StartEndpoint, startHandler.Handle)
+
+	productCache := state.NewValues[[]*models.Product](app.state, "catalog", productsCacheTTL) // Кеш неоплаченных продуктов
+	productCache.Observe(app.metrics.CacheObserver("catalog"))
+	promoStates := state.NewValues[int64](app.state, "promo", dialogTTL)
+
+	mediaFiles := state.NewValues[string](app.state, "media_file", mediaFileTTL) // file_id обложек, скриншотов и примеров
+	mediaFiles.Observe(app.metrics.CacheObserver("media_file"))
+	mediaSender := handlers.NewMediaSender(apiClient, mediaFiles, app.Logger)
+
+	// Приложение для получения списка доступных продуктов
+	catalogHandler := handlers.NewCatalogHandler(apiClient, mediaSender, app.Logger, productCache, promoStates)
+	app.handle(handlers.CatalogEndpoint, catalogHandler.Handle)
 	productBtn := &tele.Btn{Unique: keyboards.CatalogUniqueCallback}
-	app.Bot.Handle(productBtn, catalogHandler.HandleCatalogCallbacks)
+	app.handle(productBtn, catalogHandler.HandleCatalogCallbacks)
 	buyBtn := &tele.Btn{Unique: keyboards.BuyUniqueCallback}
-	app.Bot.Handle(buyBtn, catalogHandler.HandleBuyCallbacks)
+	app.handle(buyBtn, catalogHandler.HandleBuyCallbacks)
+	buyBalanceBtn := &tele.Btn{Unique: keyboards.BuyBalanceCallback}
+	app.handle(buyBalanceBtn, catalogHandler.HandleBuyCallbacks)
+	promoBtn := &tele.Btn{Unique: keyboards.PromoUniqueCallback}
+	app.handle(promoBtn, catalogHandler.HandlePromoCallbacks)
+	app.handle(&tele.Btn{Unique: keyboards.PayConfirmCallback}, catalogHandler.HandlePayConfirmCallbacks)
+
+	// Текстовый ввод: регистрация и промокоды
+	textRouter := handlers.NewTextRouter(startHandler, catalogHandler)
+	app.handle(tele.OnText, textRouter.Handle)
 
 	// Приложение для получения списка купленных продуктов
-	myProductsCache := cache.NewCacheWithTTL[int64, []*models.Product](time.Duration(10 * time.Minute))
+	myProductsCache := state.NewValues[[]*models.Product](app.state, "my_products", productsCacheTTL)
+	myProductsCache.Observe(app.metrics.CacheObserver("my_products"))
 	myHandler := handlers.NewMyHandler(apiClient, app.Logger, myProductsCache)
-	app.cleanup = append(app.cleanup, func() {
-		myHandler.Cache.Stop()
-	})
-	app.Bot.Handle(handlers.MyEndpoint, myHandler.Handle)
+	app.handle(handlers.MyEndpoint, myHandler.Handle)
 	myProductBtn := &tele.Btn{Unique: keyboards.MyUniqueCallback}
-	app.Bot.Handle(myProductBtn, myHandler.HandleCallbacks)
+	app.handle(myProductBtn, myHandler.HandleCallbacks)
+	app.handle(&tele.Btn{Unique: keyboards.RefundUniqueCallback}, myHandler.HandleRefundCallbacks)
+	app.handle(&tele.Btn{Unique: keyboards.RefundConfirmCallback}, myHandler.HandleRefundConfirmCallbacks)
+	app.handle(&tele.Btn{Unique: keyboards.ArtifactUniqueCallback}, myHandler.HandleArtifactCallbacks)
+	app.handle(&tele.Btn{Unique: keyboards.ArtifactLinkCallback}, myHandler.HandleArtifactLinkCallbacks)
+	app.handle(&tele.Btn{Unique: keyboards.RotateKeyCallback}, myHandler.HandleRotateKeyCallbacks)
+	app.handle(&tele.Btn{Unique: keyboards.RotateKeyConfirmCallback}, myHandler.HandleRotateKeyConfirmCallbacks)
+
+	// Приложение для групповых лицензий
+	groupHandler := handlers.NewGroupHandler(apiClient, app.Logger, app.Bot.Me.Username)
+	app.handle(handlers.GroupEndpoint, groupHandler.Handle)
+	app.handle(&tele.Btn{Unique: keyboards.GroupBuyUniqueCallback}, groupHandler.HandleBuyCallbacks)
+	app.handle(&tele.Btn{Unique: keyboards.GroupSeatsUniqueCallback}, groupHandler.HandleSeatsCallbacks)
+	app.handle(&tele.Btn{Unique: keyboards.GroupPoolUniqueCallback}, groupHandler.HandlePoolCallbacks)
+	app.handle(&tele.Btn{Unique: keyboards.GroupReleaseUniqueCallback}, groupHandler.HandleReleaseCallbacks)
+	app.handle(&tele.Btn{Unique: keyboards.GroupPayConfirmCallback}, groupHandler.HandlePayConfirmCallbacks)
+
+	// Приложение для реферальной программы
+	referralHandler := handlers.NewReferralHandler(apiClient, app.Logger, app.Bot.Me.Username)
+	app.handle(handlers.ReferralEndpoint, referralHandler.Handle)
+
+	// Приложение для внутреннего баланса
+	balanceHandler := handlers.NewBalanceHandler(apiClient, app.Logger)
+	app.handle(handlers.BalanceEndpoint, balanceHandler.Handle)
+
+	// Выбор языка бота
+	languageHandler := handlers.NewLanguageHandler(bundle, app.Logger, languages)
+	app.handle(handlers.LanguageEndpoint, languageHandler.Handle)
+	app.handle(&tele.Btn{Unique: keyboards.LanguageUniqueCallback}, languageHandler.HandleCallbacks)
+
+	// Доставка уведомлений с сервера (напоминания о продлении подписки и т.п.)
+	app.notifier = notifier.New(apiClient, app.Bot, app.Logger, app.Config.Notifier.PollInterval)
+	app.notifier.Start()
+	app.cleanup = append(app.cleanup, app.notifier.Stop)
 }
 
+// Reload применяет поля конфига, которые меняются без перезапуска (reload:"live")
+func (app *BotApp) Reload(cfg *config.Config) {
+	app.throttler.SetLimits(antiFloodLimits(cfg))
+	app.pacer.SetRate(cfg.Outbound.PerSecond, cfg.Outbound.Burst, cfg.Outbound.MaxWait)
+	app.notifier.SetInterval(cfg.Notifier.PollInterval)
+}
+
+func antiFloodLimits(cfg *config.Config) antiflood.Limits {
+	return antiflood.Limits{Burst: cfg.AntiFlood.Burst, Refill: cfg.AntiFlood.Refill, Cooldown: cfg.AntiFlood.Cooldown}
+}
+
+// Shutdown сначала прекращает прием обновлений, затем останавливает фоновые компоненты
 func (app *BotApp) Shutdown() {
+	app.Bot.Stop()
+	app.runCleanup()
+}
+
+func (app *BotApp) runCleanup() {
 	for _, cleanFunc := range app.cleanup {
 		cleanFunc()
 	}
-
-	app.Bot.Stop()
 }
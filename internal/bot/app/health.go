@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/pkg/health"
+)
+
+// readyTimeout сколько ждать все проверки готовности вместе
+const readyTimeout = 5 * time.Second
+
+// readinessChecker проверки готовности бота: Telegram Bot API, сервер и хранилище
+// общего состояния, если оно внешнее
+func (app *BotApp) readinessChecker(server, state health.CheckFunc) *health.Checker {
+	checker := health.NewChecker(readyTimeout)
+
+	checker.Add("telegram", telegramCheck(app.Bot.URL, app.Bot.Token))
+	checker.Add("server", server)
+	if state != nil {
+		checker.Add("state", state)
+	}
+
+	return checker
+}
+
+// telegramCheck вызывает getMe отдельным клиентом: запросы бота идут через ограничитель
+// исходящих сообщений, и проверка не должна занимать в нем место или ждать очереди
+func telegramCheck(apiURL, token string) health.CheckFunc {
+	client := &http.Client{}
+	endpoint := apiURL + "/bot" + token + "/getMe"
+
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return errors.New("не удалось создать запрос к Telegram")
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			// В url.Error лежит адрес с токеном бота, наружу отдаем только причину
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			return fmt.Errorf("Telegram недоступен: %w", err)
+		}
+		defer resp.Body.Close()
+
+		var body struct {
+			OK          bool   `json:"ok"`
+			Description string `json:"description"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return fmt.Errorf("не удалось разобрать ответ Telegram: %w", err)
+		}
+
+		if !body.OK {
+			return fmt.Errorf("Telegram вернул %d: %s", resp.StatusCode, body.Description)
+		}
+
+		return nil
+	}
+}
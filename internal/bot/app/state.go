@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/config"
+	"github.com/GeorgeTyupin/labguard/internal/bot/middleware/antiflood"
+	"github.com/GeorgeTyupin/labguard/internal/bot/state"
+	"github.com/GeorgeTyupin/labguard/pkg/health"
+)
+
+const (
+	productsCacheTTL = 10 * time.Minute     // Сколько кнопки списка продуктов остаются рабочими
+	dialogTTL        = 24 * time.Hour       // Через сутки незаконченный диалог забывается
+	languageTTL      = 180 * 24 * time.Hour // Выбранный язык забывается через полгода без обновления
+	mediaFileTTL     = 30 * 24 * time.Hour  // file_id файлов продуктов, Telegram хранит файлы дольше
+	stateConnTimeout = 5 * time.Second
+)
+
+// sharedState хранилища состояния бота, выбранные по bot.state.backend
+type sharedState struct {
+	store     state.Store
+	antiflood antiflood.Store
+	ping      health.CheckFunc // nil, если состояние в памяти и проверять нечего
+	close     func()
+}
+
+// newSharedState открывает хранилище состояния. Redis проверяется сразу, чтобы
+// реплика с неверным адресом не запустилась со своим отдельным состоянием.
+func newSharedState(conf config.StateConf) (*sharedState, error) {
+	const op = "app.newSharedState"
+
+	if conf.Backend != config.StateRedis {
+		store := state.NewMemoryStore()
+		floods := antiflood.NewMemoryStore()
+
+		return &sharedState{
+			store:     store,
+			antiflood: floods,
+			close: func() {
+				store.Stop()
+				floods.Stop()
+			},
+		}, nil
+	}
+
+	client := state.NewRedisClient(conf.Redis)
+	store := state.NewRedisStore(client, conf.Redis.Prefix)
+
+	ctx, cancel := context.WithTimeout(context.Background(), stateConnTimeout)
+	defer cancel()
+
+	if err := store.Ping(ctx); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("%s: хранилище состояния %s недоступно: %w", op, conf.Redis.Address, err)
+	}
+
+	return &sharedState{
+		store:     store,
+		antiflood: antiflood.NewRedisStore(client, conf.Redis.Prefix),
+		ping:      store.Ping,
+		close: func() {
+			client.Close()
+		},
+	}, nil
+}
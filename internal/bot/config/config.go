@@ -1,72 +1,283 @@
 package config
 
 import (
-	"fmt"
 	"log/slog"
-	"os"
+	"net/url"
+	"regexp"
+	"strings"
 	"time"
 
-	"github.com/ilyakaznacheev/cleanenv"
-	"github.com/joho/godotenv"
+	"github.com/GeorgeTyupin/labguard/pkg/appconfig"
+	"github.com/GeorgeTyupin/labguard/pkg/auth"
+	"github.com/GeorgeTyupin/labguard/pkg/tracing"
 )
 
+var secretTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// Пути по умолчанию относительно рабочей папки, их можно заменить флагами -config и -env-file
 const (
-	yamlPath = "/configs/bot/bot.yaml"
-	envPath  = "/configs/bot/bot.env"
+	yamlPath = "configs/bot/bot.yaml"
+	envPath  = "configs/bot/bot.env"
 )
 
 type Config struct {
-	BotConf `yaml:"bot"`
+	appconfig.Base `yaml:",inline"`
+	BotConf        `yaml:"bot"`
 }
 
 type BotConf struct {
-	BotName  string        `yaml:"name"  env-default:"bot"`
-	BotToken string        `env-required:"true" env:"BOT_TOKEN"`
-	Client   BotClientConf `yaml:"client"`
+	BotName   string         `yaml:"name"  env-default:"bot"`
+	BotToken  string         `env:"BOT_TOKEN" secret:"true"`
+	APIURL    string         `yaml:"api_url" env:"TELEGRAM_API_URL"` // Адрес Bot API, по умолчанию api.telegram.org
+	Updates   UpdatesConf    `yaml:"updates"`
+	State     StateConf      `yaml:"state"`
+	I18n      I18nConf       `yaml:"i18n"`
+	Client    BotClientConf  `yaml:"client"`
+	Notifier  NotifierConf   `yaml:"notifier" reload:"live"`
+	AntiFlood AntiFloodConf  `yaml:"antiflood" reload:"live"`
+	Outbound  OutboundConf   `yaml:"outbound" reload:"live"`
+	Metrics   MetricsConf    `yaml:"metrics"`
+	Tracing   tracing.Config `yaml:"tracing"`
+}
+
+// Способы получения обновлений от Telegram
+const (
+	UpdatesPolling = "polling"
+	UpdatesWebhook = "webhook"
+)
+
+// UpdatesConf как бот получает обновления: long polling или webhook
+type UpdatesConf struct {
+	Mode    string      `yaml:"mode" env:"UPDATES_MODE" env-default:"polling"`
+	Polling PollingConf `yaml:"polling"`
+	Webhook WebhookConf `yaml:"webhook"`
+}
+
+type PollingConf struct {
+	Timeout time.Duration `yaml:"timeout" env-default:"10s"` // Сколько Telegram держит getUpdates без новых обновлений
+}
+
+// WebhookConf прием обновлений через webhook. Telegram шлет их на PublicURL,
+// прокси передает запросы на Listen и Path.
+type WebhookConf struct {
+	Listen         string `yaml:"listen" env-default:":8443"`
+	Path           string `yaml:"path" env-default:"/telegram/webhook"`
+	PublicURL      string `yaml:"public_url" env:"WEBHOOK_PUBLIC_URL"`
+	SecretToken    string `env:"WEBHOOK_SECRET" secret:"true"` // Telegram присылает его в каждом запросе
+	MaxConnections int    `yaml:"max_connections" env-default:"40"`
+	DropPending    bool   `yaml:"drop_pending"`     // Отбросить обновления, накопившиеся до регистрации
+	KeepOnShutdown bool   `yaml:"keep_on_shutdown"` // Не снимать webhook при остановке, если реплик несколько
+}
+
+// I18nConf языки бота. Тексты лежат в internal/bot/i18n/locales, DefaultLocale
+// получают пользователи, язык которых бот не знает.
+type I18nConf struct {
+	DefaultLocale string `yaml:"default_locale" env:"BOT_DEFAULT_LOCALE" env-default:"ru"`
 }
 
 type BotClientConf struct {
-	ServerAddress string  `yaml:"server_address" env-default:"http://localhost:8080"`
+	ServerAddress string  `yaml:"server_address"` // По умолчанию http://localhost:8080 в local, иначе http://server:8000
 	JWT           JWTConf `yaml:"jwt"`
 }
 
 type JWTConf struct {
-	TokenTTL time.Duration `yaml:"token_ttl" env-default:"30min"`
-	Secret   string        `env:"JWT_SECRET"`
+	TokenTTL time.Duration `yaml:"token_ttl" env-default:"30m"`
+	Audience string        `yaml:"audience" env-default:"labguard-api"`
+	// Algorithm hs256 — подпись общим секретом JWT_SECRET, asymmetric — закрытым ключом из KeyFile
+	Algorithm string `yaml:"algorithm" env-default:"hs256"`
+	KeyID     string `yaml:"key_id"`
+	KeyFile   string `yaml:"key_file" env:"JWT_KEY_FILE"`
+	Secret    string `env:"JWT_SECRET" secret:"true"`
+}
+
+type NotifierConf struct {
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"30s"`
+}
+
+// AntiFloodConf ограничение частоты обновлений от одного пользователя
+type AntiFloodConf struct {
+	Burst    int           `yaml:"burst" env-default:"5"`      // Сколько сообщений подряд можно прислать
+	Refill   time.Duration `yaml:"refill" env-default:"2s"`    // Раз в сколько восстанавливается одно сообщение
+	Cooldown time.Duration `yaml:"cooldown" env-default:"10s"` // Сколько игнорировать пользователя после превышения
+}
+
+// OutboundConf ограничение исходящих сообщений бота в целом
+type OutboundConf struct {
+	PerSecond float64       `yaml:"per_second" env-default:"25"` // Запас до лимита Telegram в 30 сообщений в секунду
+	Burst     int           `yaml:"burst" env-default:"25"`
+	MaxWait   time.Duration `yaml:"max_wait" env-default:"15s"` // Дольше в очереди сообщение не ждет
+}
+
+// Хранилища общего состояния бота
+const (
+	StateMemory = "memory"
+	StateRedis  = "redis"
+)
+
+// StateConf где хранятся кеши продуктов, шаги диалогов, счетчики antiflood и
+// обработанные обновления. memory годится для одной реплики, несколько реплик
+// должны делить одно хранилище redis.
+type StateConf struct {
+	Backend  string        `yaml:"backend" env:"STATE_BACKEND" env-default:"memory"`
+	Redis    RedisConf     `yaml:"redis"`
+	DedupTTL time.Duration `yaml:"dedup_ttl" env-default:"24h"` // Сколько помнить обработанные update_id
+}
+
+// RedisConf сервер с протоколом Redis
+type RedisConf struct {
+	Address  string `yaml:"address" env:"REDIS_ADDRESS"`
+	Password string `env:"REDIS_PASSWORD" secret:"true"`
+	DB       int    `yaml:"db"`
+	Prefix   string `yaml:"prefix" env-default:"labguard:bot:"` // Общий для всех реплик одного бота
+}
+
+// MetricsConf служебный listener: метрики Prometheus и проверки /health/live, /health/ready.
+// Пустой адрес отключает его.
+type MetricsConf struct {
+	Address string `yaml:"address" env:"METRICS_ADDRESS"`
+}
+
+func MustLoad(logger *slog.Logger) (*Config, *appconfig.Source) {
+	var cfg Config
+	src := appconfig.MustLoad(logger, appconfig.Options{Name: "bot", ConfigPath: yamlPath, EnvFile: envPath}, &cfg)
+
+	return &cfg, src
+}
+
+// NewReloader перечитывает конфиг по SIGHUP и передает в apply поля, которые меняются на ходу
+func NewReloader(src *appconfig.Source, cfg *Config, apply func(*Config), logger *slog.Logger) *appconfig.Reloader {
+	return appconfig.NewReloader(src, cfg,
+		func() appconfig.Config { return &Config{} },
+		func(c appconfig.Config) { apply(c.(*Config)) },
+		logger,
+	)
 }
 
-func MustLoad(logger *slog.Logger) *Config {
-	const op = "bot.config.MustLoad"
-	logger = logger.With(slog.String("op", op))
-	cfg, err := newBotConf()
-	if err != nil {
-		logger.Error("Ошибка загрузки конфига бота", slog.String("error", err.Error()))
-		os.Exit(1)
+// ApplyProfile значения по умолчанию, которые отличаются между окружениями
+func (c *Config) ApplyProfile(p appconfig.Profile) {
+	// Вне local бот и сервер запускаются в одной сети compose
+	if c.Client.ServerAddress == "" {
+		c.Client.ServerAddress = "http://server:8000"
+		if p == appconfig.ProfileLocal {
+			c.Client.ServerAddress = "http://localhost:8080"
+		}
 	}
 
-	return cfg
+	c.Tracing.ApplyProfile(p)
 }
 
-func newBotConf() (*Config, error) {
-	file, err := os.Open(yamlPath)
-	if err != nil {
-		return nil, fmt.Errorf("возникла ошибка с открытием файла конфига по пути %s, возникла ошибка %w", yamlPath, err)
+// Validate проверяет связи между полями конфига
+func (c *Config) Validate(p *appconfig.Problems) {
+	if c.BotToken == "" {
+		p.Addf("BOT_TOKEN", "не задан токен бота")
 	}
-	defer file.Close()
 
-	var cfg Config
+	if u, err := url.Parse(c.Client.ServerAddress); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		p.Addf("bot.client.server_address", "ожидается адрес вида http://host:port, получено %q", c.Client.ServerAddress)
+	}
+
+	c.Client.JWT.validate(p)
+	c.Updates.validate(p)
+	c.State.validate(p)
+
+	// keep_on_shutdown нужен, когда реплик несколько, а им нужно общее состояние
+	if c.Updates.Mode == UpdatesWebhook && c.Updates.Webhook.KeepOnShutdown && c.State.Backend == StateMemory {
+		p.Addf("bot.state.backend", "с keep_on_shutdown реплик несколько, состояние в памяти у них разойдется, нужен %s", StateRedis)
+	}
+
+	if c.APIURL != "" {
+		if u, err := url.Parse(c.APIURL); err != nil || u.Scheme == "" || u.Host == "" {
+			p.Addf("bot.api_url", "ожидается адрес вида https://host, получено %q", c.APIURL)
+		}
+	}
+
+	if c.Notifier.PollInterval <= 0 {
+		p.Addf("bot.notifier.poll_interval", "интервал должен быть положительным")
+	}
+	if c.AntiFlood.Burst < 1 || c.AntiFlood.Refill <= 0 || c.AntiFlood.Cooldown < 0 {
+		p.Addf("bot.antiflood", "burst и refill должны быть положительными, cooldown не отрицательным")
+	}
+	if c.Outbound.PerSecond <= 0 || c.Outbound.Burst < 1 || c.Outbound.MaxWait <= 0 {
+		p.Addf("bot.outbound", "per_second, burst и max_wait должны быть положительными")
+	}
+
+	c.Tracing.Validate(p, "bot.tracing")
+}
+
+func (c UpdatesConf) validate(p *appconfig.Problems) {
+	switch c.Mode {
+	case UpdatesPolling:
+		if c.Polling.Timeout <= 0 {
+			p.Addf("bot.updates.polling.timeout", "таймаут должен быть положительным")
+		}
+	case UpdatesWebhook:
+		c.Webhook.validate(p)
+	default:
+		p.Addf("bot.updates.mode", "неизвестный режим %q, ожидается %s или %s", c.Mode, UpdatesPolling, UpdatesWebhook)
+	}
+}
 
-	if err := godotenv.Load(envPath); err != nil {
-		return nil, fmt.Errorf("не удалось прочитать env. Возникла ошибка %w", err)
+func (c StateConf) validate(p *appconfig.Problems) {
+	switch c.Backend {
+	case StateMemory:
+	case StateRedis:
+		if c.Redis.Address == "" {
+			p.Addf("bot.state.redis.address", "не задан адрес сервера, укажите его в конфиге или REDIS_ADDRESS")
+		}
+	default:
+		p.Addf("bot.state.backend", "неизвестное хранилище %q, ожидается %s или %s", c.Backend, StateMemory, StateRedis)
 	}
 
-	if err := cleanenv.ParseYAML(file, &cfg); err != nil {
-		return nil, fmt.Errorf("не удалось прочитать конфиг. Возникла ошибка %w", err)
+	if c.DedupTTL <= 0 {
+		p.Addf("bot.state.dedup_ttl", "время должно быть положительным")
+	}
+}
+
+func (c WebhookConf) validate(p *appconfig.Problems) {
+	if c.Listen == "" {
+		p.Addf("bot.updates.webhook.listen", "не задан адрес listener")
+	}
+	if !strings.HasPrefix(c.Path, "/") {
+		p.Addf("bot.updates.webhook.path", "путь должен начинаться с /")
 	}
 
-	if err := cleanenv.ReadEnv(&cfg); err != nil {
-		return nil, fmt.Errorf("не удалось прочитать env переменные. Возникла ошибка %w", err)
+	// Telegram принимает только https, http годится для локальной проверки с tgfake
+	u, err := url.Parse(c.PublicURL)
+	switch {
+	case c.PublicURL == "":
+		p.Addf("bot.updates.webhook.public_url", "не задан публичный адрес, укажите его в конфиге или WEBHOOK_PUBLIC_URL")
+	case err != nil || u.Host == "":
+		p.Addf("bot.updates.webhook.public_url", "неверный адрес %q", c.PublicURL)
+	case u.Scheme != "https" && !(u.Scheme == "http" && p.Profile() == appconfig.ProfileLocal):
+		p.Addf("bot.updates.webhook.public_url", "Telegram принимает только https адреса")
 	}
 
-	return &cfg, nil
+	// Ограничения Telegram: 1-256 символов A-Z, a-z, 0-9, _ и -
+	if !secretTokenPattern.MatchString(c.SecretToken) {
+		p.Addf("WEBHOOK_SECRET", "нужен секрет из 1-256 символов A-Z, a-z, 0-9, _ и -")
+	}
+	if c.MaxConnections < 1 || c.MaxConnections > 100 {
+		p.Addf("bot.updates.webhook.max_connections", "значение %d вне диапазона 1-100", c.MaxConnections)
+	}
+}
+
+func (c JWTConf) validate(p *appconfig.Problems) {
+	if c.TokenTTL <= 0 {
+		p.Addf("bot.client.jwt.token_ttl", "время жизни токена должно быть положительным")
+	}
+
+	switch c.Algorithm {
+	case auth.AlgorithmHS256:
+		if c.Secret == "" {
+			p.Addf("JWT_SECRET", "в режиме %s нужен общий секрет", auth.AlgorithmHS256)
+		} else if p.Profile() == appconfig.ProfileProd && len(c.Secret) < appconfig.MinSecretLength {
+			p.Addf("JWT_SECRET", "в prod секрет должен быть не короче %d символов", appconfig.MinSecretLength)
+		}
+	case auth.AlgorithmAsymmetric:
+		if c.KeyID == "" || c.KeyFile == "" {
+			p.Addf("bot.client.jwt", "в режиме %s нужны key_id и key_file", auth.AlgorithmAsymmetric)
+		}
+	default:
+		p.Addf("bot.client.jwt.algorithm", "неизвестный алгоритм подписи токенов %q", c.Algorithm)
+	}
 }
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/i18n"
+	"github.com/GeorgeTyupin/labguard/internal/bot/middleware/loggers"
+	"github.com/GeorgeTyupin/labguard/internal/bot/models"
+	tele "gopkg.in/telebot.v4"
+)
+
+type BalanceAPIClient interface {
+	GetWallet(ctx context.Context, telegramID int64) (*models.Wallet, error)
+}
+
+// BalanceHandler показывает внутренний баланс и последние операции
+type BalanceHandler struct {
+	*BaseHandler
+	client BalanceAPIClient
+}
+
+func NewBalanceHandler(apiClient BalanceAPIClient, logger *slog.Logger) *BalanceHandler {
+	baseHandler := NewBaseHandler(logger)
+
+	handler := &BalanceHandler{
+		BaseHandler: baseHandler,
+		client:      apiClient,
+	}
+
+	return handler
+}
+
+func (h *BalanceHandler) Handle(c tele.Context) error {
+	const op = "balance.Handle"
+	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
+
+	wallet, err := h.client.GetWallet(ctx, c.Sender().ID)
+	if err != nil {
+		if msg, ok := clientErrorMessage(err); ok {
+			return c.Send(loc.T("error.server_register", i18n.Args{"Message": msg, "Start": StartEndpoint}))
+		}
+		logger.ErrorContext(ctx, "Ошибка получения баланса", slog.String("error", err.Error()))
+		return c.Send(loc.T("balance.error"))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(loc.T("balance.header", i18n.Args{"Balance": wallet.Balance}))
+
+	if len(wallet.Transactions) == 0 {
+		sb.WriteString(loc.T("balance.empty"))
+		return c.Send(sb.String())
+	}
+
+	sb.WriteString(loc.T("balance.history"))
+	for _, t := range wallet.Transactions {
+		sign := "+"
+		amount := t.Amount
+		if amount < 0 {
+			sign = "−"
+			amount = -amount
+		}
+
+		sb.WriteString(loc.T("balance.operation", i18n.Args{
+			"Date":        t.CreatedAt,
+			"Sign":        sign,
+			"Amount":      amount,
+			"Description": t.Description,
+		}))
+	}
+
+	return c.Send(sb.String())
+}
@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"log/slog"
+	"net/http"
 	"sync"
 
+	"github.com/GeorgeTyupin/labguard/internal/bot/i18n"
 	"github.com/GeorgeTyupin/labguard/internal/bot/models"
+	"github.com/GeorgeTyupin/labguard/internal/bot/services/api"
 	tele "gopkg.in/telebot.v4"
 )
 
@@ -12,9 +17,17 @@ const (
 	msgTypeSuccess = "success"
 	msgTypeError   = "error"
 
-	StartEndpoint   = "/start"
-	MyEndpoint      = "/my"
-	CatalogEndpoint = "/catalog"
+	StartEndpoint    = "/start"
+	MyEndpoint       = "/my"
+	CatalogEndpoint  = "/catalog"
+	GroupEndpoint    = "/group"
+	ReferralEndpoint = "/referral"
+	BalanceEndpoint  = "/balance"
+	LanguageEndpoint = "/language"
+
+	// Префиксы параметра /start в ссылках-приглашениях
+	SeatsStartPrefix    = "seats_"
+	ReferralStartPrefix = "ref_"
 )
 
 type BaseHandler struct {
@@ -35,26 +48,49 @@ func NewBaseHandler(logger *slog.Logger) *BaseHandler {
 }
 
 func (h *BaseHandler) setSendOptions() {
+	// Опции заменяют настройки бота целиком, поэтому разметку текстов указываем явно
 	opt := make(map[string]*tele.SendOptions)
 	opt[msgTypeSuccess] = &tele.SendOptions{
-		ParseMode:   tele.ModeMarkdown,
+		ParseMode:   i18n.ParseMode,
 		ReplyMarkup: &tele.ReplyMarkup{RemoveKeyboard: true},
 	}
 
 	opt[msgTypeError] = &tele.SendOptions{
+		ParseMode:   i18n.ParseMode,
 		ReplyMarkup: &tele.ReplyMarkup{RemoveKeyboard: true},
 	}
 
 	h.sendOptions = opt
 }
 
-type ProductsCache interface {
-	Get(int64) ([]*models.Product, error)
-	Set(int64, []*models.Product)
-	Delete(int64)
-	Stop()
+// clientErrorMessage возвращает текст ошибки сервера, если ее можно показать пользователю
+func clientErrorMessage(err error) (string, bool) {
+	var statusErr *api.StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode < http.StatusInternalServerError {
+		return statusErr.Message, true
+	}
+
+	return "", false
+}
+
+// isNotFound сервер ответил, что запрошенного нет
+func isNotFound(err error) bool {
+	var statusErr *api.StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+// UserValues значения по telegram_id: кеши и шаги диалогов. Хранилище общее для всех
+// реплик бота, поэтому значение, измененное в обработчике, нужно сохранить через Set.
+type UserValues[V any] interface {
+	Get(ctx context.Context, telegramID int64) (V, bool, error)
+	Set(ctx context.Context, telegramID int64, value V) error
+	Delete(ctx context.Context, telegramID int64) error
 }
 
+// ProductsCache продукты, которые пользователь видел в последнем списке.
+// Кнопки списка ссылаются на продукт по индексу в нем.
+type ProductsCache = UserValues[[]*models.Product]
+
 type BaseProductsHandler struct {
 	*BaseHandler
 	Cache     ProductsCache
@@ -72,3 +108,45 @@ func NewBaseProductsHandler(logger *slog.Logger, cache ProductsCache, purchased
 
 	return productsHandler
 }
+
+// listProducts список продуктов пользователя: сначала из кеша, при промахе с сервера.
+// Список сохраняется в кеш, чтобы кнопки могли найти продукт по индексу.
+func (h *BaseProductsHandler) listProducts(
+	ctx context.Context,
+	telegramID int64,
+	fetch func(ctx context.Context, telegramID int64) ([]*models.Product, error),
+) ([]*models.Product, error) {
+	products, ok, err := h.Cache.Get(ctx, telegramID)
+	if err != nil {
+		h.logger.WarnContext(ctx, "Не удалось прочитать кеш продуктов", slog.String("error", err.Error()))
+	}
+
+	if !ok {
+		products, err = fetch(ctx, telegramID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := h.Cache.Set(ctx, telegramID, products); err != nil {
+		h.logger.WarnContext(ctx, "Не удалось сохранить продукты в кеш", slog.String("error", err.Error()))
+	}
+
+	return products, nil
+}
+
+// cachedProduct продукт из последнего показанного списка по индексу кнопки
+func (h *BaseProductsHandler) cachedProduct(ctx context.Context, telegramID int64, idx int) (*models.Product, bool) {
+	products, ok, err := h.Cache.Get(ctx, telegramID)
+	if err != nil {
+		h.logger.WarnContext(ctx, "Не удалось прочитать кеш продуктов", slog.String("error", err.Error()))
+		return nil, false
+	}
+
+	if !ok || idx < 0 || idx >= len(products) {
+		h.logger.InfoContext(ctx, "Продукта нет в кеше", slog.Int("index", idx))
+		return nil, false
+	}
+
+	return products[idx], true
+}
@@ -1,32 +1,42 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"strconv"
+	"strings"
 
+	"github.com/GeorgeTyupin/labguard/internal/bot/i18n"
 	"github.com/GeorgeTyupin/labguard/internal/bot/keyboards"
+	"github.com/GeorgeTyupin/labguard/internal/bot/middleware/loggers"
 	"github.com/GeorgeTyupin/labguard/internal/bot/models"
 	tele "gopkg.in/telebot.v4"
 )
 
 type CatalogAPIClient interface {
-	CheckUserExists(telegramID int64) (bool, error)
-	GetProducts(telegramID int64) ([]*models.Product, error)
-	BuyProduct(telegramID int64, productID int64) error
+	CheckUserExists(ctx context.Context, telegramID int64) (bool, error)
+	GetProducts(ctx context.Context, telegramID int64) ([]*models.Product, error)
+	BuyProduct(ctx context.Context, telegramID int64, productID int64, promoCode string, useBalance bool) (*models.Purchase, error)
+	ConfirmPurchase(ctx context.Context, telegramID, purchaseID int64) (*models.Purchase, error)
+	QuotePromo(ctx context.Context, telegramID, productID int64, code string) (*models.PromoQuote, error)
 }
 
 type CatalogHandler struct {
 	*BaseProductsHandler
-	client CatalogAPIClient
+	client      CatalogAPIClient
+	media       *MediaSender
+	promoStates UserValues[int64] // telegram_id -> id продукта, для которого ждем промокод
 }
 
-func NewCatalogHandler(apiClient CatalogAPIClient, logger *slog.Logger, cache ProductsCache) *CatalogHandler {
+func NewCatalogHandler(apiClient CatalogAPIClient, media *MediaSender, logger *slog.Logger, cache ProductsCache, promoStates UserValues[int64]) *CatalogHandler {
 	baseHandler := NewBaseProductsHandler(logger, cache, false)
 
 	handler := &CatalogHandler{
 		BaseProductsHandler: baseHandler,
 		client:              apiClient,
+		media:               media,
+		promoStates:         promoStates,
 	}
 
 	return handler
@@ -35,48 +45,44 @@ func NewCatalogHandler(apiClient CatalogAPIClient, logger *slog.Logger, cache Pr
 func (h *CatalogHandler) Handle(c tele.Context) error {
 	const op = "catalog.Handle"
 	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
 
 	telegramID := c.Sender().ID
 
 	// Проверяем регистрацию пользователя
-	_, err := h.client.CheckUserExists(telegramID)
+	exists, err := h.client.CheckUserExists(ctx, telegramID)
 	if err != nil {
-		logger.Warn("нет метода проверки зарегистрированного пользователя", slog.String("error", err.Error()))
-		return c.Send("❌ Ошибка при проверке регистрации")
+		logger.WarnContext(ctx, "Ошибка проверки зарегистрированного пользователя", slog.String("error", err.Error()))
+		return c.Send(loc.T("error.registration_check"))
 	}
 
-	// TODO: расскоментировать после реализации
-	// if !exists {
-	// 	return c.Send(fmt.Sprintf("Вы еще не зарегистрированы! Используйте %s для регистрации", StartEndpoint))
-	// }
-
-	var products []*models.Product
+	if !exists {
+		return c.Send(loc.T("error.not_registered", i18n.Args{"Start": StartEndpoint}))
+	}
 
-	products, err = h.Cache.Get(telegramID) // Сначала пробуем получить из кеша
+	products, err := h.listProducts(ctx, telegramID, h.client.GetProducts)
 	if err != nil {
-		products, err = h.client.GetProducts(telegramID)
-		if err != nil {
-			logger.Warn("нет метода получения списка продуктов", slog.String("error", err.Error()))
-			return c.Send("❌ Ошибка при попытке получить список продуктов")
-		}
+		logger.WarnContext(ctx, "нет метода получения списка продуктов", slog.String("error", err.Error()))
+		return c.Send(loc.T("catalog.fetch_error"))
 	}
 
-	h.Cache.Set(telegramID, products)
-
-	productsMenu := keyboards.NewProductsMenu(products, h.purchased)
+	productsMenu := keyboards.NewProductsMenu(loc, products, h.purchased)
 
-	return c.Send("Список всех некупленных продуктов:\n", productsMenu)
+	return c.Send(loc.T("catalog.title"), productsMenu)
 }
 
 func (h *CatalogHandler) HandleCatalogCallbacks(c tele.Context) error {
 	const op = "catalog.HandleCatalogCallbacks"
 	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
 
 	defer c.Respond()
 
 	// Проверяем, что это callback для продуктов
 	if c.Callback().Unique != keyboards.CatalogUniqueCallback {
-		logger.Warn(
+		logger.WarnContext(ctx,
 			fmt.Sprintf("Unique не совпадает с %s", keyboards.CatalogUniqueCallback),
 			slog.String("unique", c.Callback().Unique))
 		return nil
@@ -85,34 +91,36 @@ func (h *CatalogHandler) HandleCatalogCallbacks(c tele.Context) error {
 	// Извлекаем индекс продукта
 	productIdx, err := strconv.Atoi(c.Callback().Data)
 	if err != nil {
-		logger.Error(
+		logger.ErrorContext(ctx,
 			"Не удалось конвертировать индекс продукта из строки в число",
 			slog.String("data", c.Callback().Data),
 		)
-		return c.Send(fmt.Sprintf("❌ Возникла внутренняя ошибка. Попробуйте ввести %s еще раз", CatalogEndpoint))
+		return c.Send(loc.T("error.internal_retry", i18n.Args{"Command": CatalogEndpoint}))
 	}
 
-	telegramID := c.Sender().ID
+	product, ok := h.cachedProduct(ctx, c.Sender().ID, productIdx)
+	if !ok {
+		return c.Send(loc.T("catalog.not_found", i18n.Args{"Command": CatalogEndpoint}))
+	}
 
-	products, err := h.Cache.Get(telegramID)
-	if err != nil || productIdx < 0 || productIdx >= len(products) {
-		logger.Info("Ошибка получения элемента из кеша", slog.String("error", err.Error()))
-		return c.Send(fmt.Sprintf("❌ Продукт не найден. Попробуйте вызвать %s еще раз", CatalogEndpoint))
+	logger.InfoContext(ctx, "Успешно получили продукт через callback", slog.Any("product", product))
+
+	periodDays := 0
+	if product.IsSubscription() {
+		periodDays = product.PeriodDays
 	}
-	product := products[productIdx]
 
-	logger.Info("Успешно получили продукт через callback", slog.Any("product", product))
+	message := loc.T("catalog.card", i18n.Args{
+		"Name":        product.Name,
+		"Description": product.Description,
+		"Price":       product.Price,
+		"PeriodDays":  periodDays,
+	})
 
-	message := fmt.Sprintf(
-		"*📦 %s*\n\n"+
-			"_%s_\n\n"+
-			"💰 *Цена:* %.0f₽\n",
-		product.Name,
-		product.Description,
-		product.Price,
-	)
+	buyMenu := keyboards.NewBuyMenu(loc, product.ID)
 
-	buyMenu := keyboards.NewBuyMenu(product.ID)
+	// Обложка и скриншоты идут перед карточкой, чтобы кнопки покупки оказались внизу
+	h.media.Send(c, product.ID)
 
 	return c.Send(message, h.sendOptions[msgTypeSuccess], buyMenu)
 }
@@ -120,35 +128,157 @@ func (h *CatalogHandler) HandleCatalogCallbacks(c tele.Context) error {
 func (h *CatalogHandler) HandleBuyCallbacks(c tele.Context) error {
 	const op = "catalog.HandleBuyCallbacks"
 	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
 	defer c.Respond()
 
-	// Проверяем, что это callback для продуктов
-	if c.Callback().Unique != keyboards.BuyUniqueCallback {
-		logger.Warn(
+	// Проверяем, что это callback покупки: обычной или с оплатой с баланса
+	unique := c.Callback().Unique
+	if unique != keyboards.BuyUniqueCallback && unique != keyboards.BuyBalanceCallback {
+		logger.WarnContext(ctx,
 			fmt.Sprintf("Unique не совпадает с %s", keyboards.BuyUniqueCallback),
-			slog.String("unique", c.Callback().Unique))
+			slog.String("unique", unique))
 		return nil
 	}
+	useBalance := unique == keyboards.BuyBalanceCallback
 
-	// Извлекаем id продукта
-	productID, err := strconv.Atoi(c.Callback().Data)
+	// Извлекаем id продукта и примененный промокод, если он есть: "id" или "id|code"
+	rawID, promoCode, _ := strings.Cut(c.Callback().Data, "|")
+	productID, err := strconv.Atoi(rawID)
 	if err != nil {
-		logger.Error(
+		logger.ErrorContext(ctx,
 			"Не удалось конвертировать индекс продукта из строки в число",
 			slog.String("data", c.Callback().Data),
 		)
-		return c.Send(fmt.Sprintf("❌ Возникла внутренняя ошибка. Попробуйте ввести %s еще раз", CatalogEndpoint))
+		return c.Send(loc.T("error.internal_retry", i18n.Args{"Command": CatalogEndpoint}))
 	}
 
-	// TODO добавить логику покупки, подключить платежную систему
-
-	if err := h.client.BuyProduct(c.Sender().ID, int64(productID)); err != nil {
-		logger.Error(
+	purchase, err := h.client.BuyProduct(ctx, c.Sender().ID, int64(productID), promoCode, useBalance)
+	if err != nil {
+		if msg, ok := clientErrorMessage(err); ok {
+			return c.Send(loc.T("error.server", i18n.Args{"Message": msg}))
+		}
+		logger.ErrorContext(ctx,
 			"Ошибка при покупке продукта",
 			slog.String("error", err.Error()),
 		)
-		return c.Send("❌ Ошибка при попытке купить продукт")
+		return c.Send(loc.T("catalog.buy_error"))
+	}
+
+	if !purchase.IsPaid() {
+		return c.Send(loc.T("catalog.pay", i18n.Args{
+			"BalanceUsed": purchase.BalanceUsed,
+			"Paid":        purchase.Amount - purchase.BalanceUsed,
+		}), keyboards.NewPayMenu(loc, keyboards.PayConfirmCallback, purchase.ID, purchase.PaymentURL))
+	}
+
+	return h.sendBought(ctx, c, purchase)
+}
+
+// HandlePayConfirmCallbacks проверяет оплату покупки, когда пользователь нажал "Я оплатил"
+func (h *CatalogHandler) HandlePayConfirmCallbacks(c tele.Context) error {
+	const op = "catalog.HandlePayConfirmCallbacks"
+	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
+	defer c.Respond()
+
+	purchaseID, err := strconv.ParseInt(c.Callback().Data, 10, 64)
+	if err != nil {
+		logger.ErrorContext(ctx, "Не удалось конвертировать id покупки из строки в число", slog.String("data", c.Callback().Data))
+		return c.Send(loc.T("error.internal_retry", i18n.Args{"Command": CatalogEndpoint}))
+	}
+
+	purchase, err := h.client.ConfirmPurchase(ctx, c.Sender().ID, purchaseID)
+	if err != nil {
+		if msg, ok := clientErrorMessage(err); ok {
+			return c.Send(loc.T("error.server", i18n.Args{"Message": msg}))
+		}
+		logger.ErrorContext(ctx, "Ошибка при проверке оплаты", slog.String("error", err.Error()))
+		return c.Send(loc.T("catalog.buy_error"))
+	}
+
+	return h.sendBought(ctx, c, purchase)
+}
+
+func (h *CatalogHandler) sendBought(ctx context.Context, c tele.Context, purchase *models.Purchase) error {
+	// Купленный продукт должен пропасть из каталога
+	if err := h.Cache.Delete(ctx, c.Sender().ID); err != nil {
+		h.logger.WarnContext(ctx, "Не удалось сбросить кеш каталога", slog.String("error", err.Error()))
+	}
+
+	return c.Send(i18n.From(c).T("catalog.bought", i18n.Args{
+		"BalanceUsed": purchase.BalanceUsed,
+		"Paid":        purchase.Amount - purchase.BalanceUsed,
+	}))
+}
+
+func (h *CatalogHandler) HandlePromoCallbacks(c tele.Context) error {
+	const op = "catalog.HandlePromoCallbacks"
+	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
+
+	defer c.Respond()
+
+	productID, err := strconv.ParseInt(c.Callback().Data, 10, 64)
+	if err != nil {
+		logger.ErrorContext(ctx, "Не удалось конвертировать id продукта из строки в число", slog.String("data", c.Callback().Data))
+		return c.Send(loc.T("error.internal_retry", i18n.Args{"Command": CatalogEndpoint}))
 	}
 
-	return c.Send("✅ Продукт успешно куплен")
+	if err := h.promoStates.Set(ctx, c.Sender().ID, productID); err != nil {
+		logger.ErrorContext(ctx, "Не удалось сохранить ожидание промокода", slog.String("error", err.Error()))
+		return c.Send(loc.T("error.internal"))
+	}
+
+	return c.Send(loc.T("promo.enter"))
+}
+
+// Awaits сообщает, что пользователь должен ввести промокод
+func (h *CatalogHandler) Awaits(ctx context.Context, telegramID int64) bool {
+	_, ok, err := h.promoStates.Get(ctx, telegramID)
+	if err != nil {
+		h.logger.WarnContext(ctx, "Не удалось прочитать ожидание промокода", slog.String("error", err.Error()))
+	}
+
+	return ok
+}
+
+// HandleMessage применяет введенный промокод и показывает цену со скидкой
+func (h *CatalogHandler) HandleMessage(c tele.Context) error {
+	const op = "catalog.HandleMessage"
+	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
+
+	telegramID := c.Sender().ID
+
+	productID, ok, err := h.promoStates.Get(ctx, telegramID)
+	if err != nil || !ok {
+		return nil
+	}
+	if err := h.promoStates.Delete(ctx, telegramID); err != nil {
+		logger.WarnContext(ctx, "Не удалось сбросить ожидание промокода", slog.String("error", err.Error()))
+	}
+
+	code := strings.TrimSpace(c.Text())
+
+	quote, err := h.client.QuotePromo(ctx, telegramID, productID, code)
+	if err != nil {
+		if msg, ok := clientErrorMessage(err); ok {
+			return c.Send(loc.T("promo.invalid", i18n.Args{"Message": msg}), keyboards.NewBuyMenu(loc, productID))
+		}
+		logger.ErrorContext(ctx, "Ошибка применения промокода", slog.String("error", err.Error()))
+		return c.Send(loc.T("promo.error"))
+	}
+
+	message := loc.T("promo.applied", i18n.Args{
+		"Code":     quote.Code,
+		"Price":    quote.OriginalPrice,
+		"Discount": quote.Discount,
+		"Total":    quote.Price,
+	})
+
+	return c.Send(message, keyboards.NewPromoBuyMenu(loc, productID, quote.Code))
 }
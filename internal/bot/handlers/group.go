@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/i18n"
+	"github.com/GeorgeTyupin/labguard/internal/bot/keyboards"
+	"github.com/GeorgeTyupin/labguard/internal/bot/middleware/loggers"
+	"github.com/GeorgeTyupin/labguard/internal/bot/models"
+	tele "gopkg.in/telebot.v4"
+)
+
+type GroupAPIClient interface {
+	BuySeats(ctx context.Context, telegramID, productID int64, seats int) (*models.Purchase, error)
+	ConfirmPurchase(ctx context.Context, telegramID, purchaseID int64) (*models.Purchase, error)
+	GetSeatPools(ctx context.Context, telegramID int64) ([]*models.SeatPool, error)
+	GetSeatPool(ctx context.Context, telegramID, poolID int64) (*models.SeatPool, error)
+	ReleaseSeat(ctx context.Context, telegramID, poolID, memberTelegramID int64) error
+}
+
+// GroupHandler покупка групповых лицензий и управление местами в них
+type GroupHandler struct {
+	*BaseHandler
+	client      GroupAPIClient
+	botUsername string
+}
+
+func NewGroupHandler(apiClient GroupAPIClient, logger *slog.Logger, botUsername string) *GroupHandler {
+	baseHandler := NewBaseHandler(logger)
+
+	handler := &GroupHandler{
+		BaseHandler: baseHandler,
+		client:      apiClient,
+		botUsername: botUsername,
+	}
+
+	return handler
+}
+
+func (h *GroupHandler) Handle(c tele.Context) error {
+	const op = "group.Handle"
+	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
+
+	pools, err := h.client.GetSeatPools(ctx, c.Sender().ID)
+	if err != nil {
+		if msg, ok := clientErrorMessage(err); ok {
+			return c.Send(loc.T("error.server", i18n.Args{"Message": msg}))
+		}
+		logger.ErrorContext(ctx, "Ошибка получения групповых лицензий", slog.String("error", err.Error()))
+		return c.Send(loc.T("group.fetch_error"))
+	}
+
+	if len(pools) == 0 {
+		return c.Send(loc.T("group.empty", i18n.Args{"Catalog": CatalogEndpoint}))
+	}
+
+	return c.Send(loc.T("group.title"), keyboards.NewSeatPoolsMenu(loc, pools))
+}
+
+func (h *GroupHandler) HandleBuyCallbacks(c tele.Context) error {
+	const op = "group.HandleBuyCallbacks"
+	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
+
+	defer c.Respond()
+
+	productID, err := strconv.ParseInt(c.Callback().Data, 10, 64)
+	if err != nil {
+		logger.ErrorContext(ctx, "Не удалось конвертировать id продукта из строки в число", slog.String("data", c.Callback().Data))
+		return c.Send(loc.T("error.internal_retry", i18n.Args{"Command": CatalogEndpoint}))
+	}
+
+	return c.Send(loc.T("group.ask_seats"), keyboards.NewSeatsCountMenu(loc, productID))
+}
+
+func (h *GroupHandler) HandleSeatsCallbacks(c tele.Context) error {
+	const op = "group.HandleSeatsCallbacks"
+	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
+
+	defer c.Respond()
+
+	ids, err := parseCallbackIDs(c.Callback().Data, 2)
+	if err != nil {
+		logger.ErrorContext(ctx, "Неверные данные callback", slog.String("data", c.Callback().Data))
+		return c.Send(loc.T("error.internal_retry", i18n.Args{"Command": CatalogEndpoint}))
+	}
+	productID, seats := ids[0], int(ids[1])
+
+	purchase, err := h.client.BuySeats(ctx, c.Sender().ID, productID, seats)
+	if err != nil {
+		if msg, ok := clientErrorMessage(err); ok {
+			return c.Send(loc.T("error.server", i18n.Args{"Message": msg}))
+		}
+		logger.ErrorContext(ctx, "Ошибка покупки групповой лицензии", slog.String("error", err.Error()))
+		return c.Send(loc.T("group.buy_error"))
+	}
+
+	if !purchase.IsPaid() {
+		return c.Send(loc.T("group.pay", i18n.Args{
+			"Paid":  purchase.Amount,
+			"Seats": purchase.Seats,
+		}), keyboards.NewPayMenu(loc, keyboards.GroupPayConfirmCallback, purchase.ID, purchase.PaymentURL))
+	}
+
+	return h.sendCreated(ctx, c, purchase)
+}
+
+// HandlePayConfirmCallbacks проверяет оплату мест, когда староста нажал "Я оплатил"
+func (h *GroupHandler) HandlePayConfirmCallbacks(c tele.Context) error {
+	const op = "group.HandlePayConfirmCallbacks"
+	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
+
+	defer c.Respond()
+
+	purchaseID, err := strconv.ParseInt(c.Callback().Data, 10, 64)
+	if err != nil {
+		logger.ErrorContext(ctx, "Не удалось конвертировать id покупки из строки в число", slog.String("data", c.Callback().Data))
+		return c.Send(loc.T("error.internal_retry", i18n.Args{"Command": GroupEndpoint}))
+	}
+
+	purchase, err := h.client.ConfirmPurchase(ctx, c.Sender().ID, purchaseID)
+	if err != nil {
+		if msg, ok := clientErrorMessage(err); ok {
+			return c.Send(loc.T("error.server", i18n.Args{"Message": msg}))
+		}
+		logger.ErrorContext(ctx, "Ошибка при проверке оплаты мест", slog.String("error", err.Error()))
+		return c.Send(loc.T("group.buy_error"))
+	}
+
+	return h.sendCreated(ctx, c, purchase)
+}
+
+// sendCreated присылает ссылку-приглашение в оплаченную групповую лицензию
+func (h *GroupHandler) sendCreated(ctx context.Context, c tele.Context, purchase *models.Purchase) error {
+	loc := i18n.From(c)
+
+	if purchase.SeatPoolID == nil {
+		h.logger.ErrorContext(ctx, "Покупка мест без групповой лицензии", slog.Int64("purchase_id", purchase.ID))
+		return c.Send(loc.T("group.buy_error"))
+	}
+
+	pool, err := h.client.GetSeatPool(ctx, c.Sender().ID, *purchase.SeatPoolID)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Ошибка получения оплаченной групповой лицензии", slog.String("error", err.Error()))
+		return c.Send(loc.T("group.pool_error"))
+	}
+
+	message := loc.T("group.created", i18n.Args{
+		"Product": pool.ProductName,
+		"Seats":   pool.Seats,
+		"Link":    h.inviteLink(pool),
+		"Group":   GroupEndpoint,
+	})
+
+	return c.Send(message)
+}
+
+func (h *GroupHandler) HandlePoolCallbacks(c tele.Context) error {
+	const op = "group.HandlePoolCallbacks"
+	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
+
+	defer c.Respond()
+
+	poolID, err := strconv.ParseInt(c.Callback().Data, 10, 64)
+	if err != nil {
+		logger.ErrorContext(ctx, "Не удалось конвертировать id пула из строки в число", slog.String("data", c.Callback().Data))
+		return c.Send(loc.T("error.internal_retry", i18n.Args{"Command": GroupEndpoint}))
+	}
+
+	return h.sendPool(c, poolID)
+}
+
+func (h *GroupHandler) HandleReleaseCallbacks(c tele.Context) error {
+	const op = "group.HandleReleaseCallbacks"
+	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
+
+	defer c.Respond()
+
+	ids, err := parseCallbackIDs(c.Callback().Data, 2)
+	if err != nil {
+		logger.ErrorContext(ctx, "Неверные данные callback", slog.String("data", c.Callback().Data))
+		return c.Send(loc.T("error.internal_retry", i18n.Args{"Command": GroupEndpoint}))
+	}
+	poolID, memberID := ids[0], ids[1]
+
+	if err := h.client.ReleaseSeat(ctx, c.Sender().ID, poolID, memberID); err != nil {
+		if msg, ok := clientErrorMessage(err); ok {
+			return c.Send(loc.T("error.server", i18n.Args{"Message": msg}))
+		}
+		logger.ErrorContext(ctx, "Ошибка освобождения места", slog.String("error", err.Error()))
+		return c.Send(loc.T("group.release_error"))
+	}
+
+	if err := c.Send(loc.T("group.released")); err != nil {
+		return err
+	}
+
+	return h.sendPool(c, poolID)
+}
+
+func (h *GroupHandler) sendPool(c tele.Context, poolID int64) error {
+	const op = "group.sendPool"
+	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
+
+	pool, err := h.client.GetSeatPool(ctx, c.Sender().ID, poolID)
+	if err != nil {
+		if msg, ok := clientErrorMessage(err); ok {
+			return c.Send(loc.T("error.server", i18n.Args{"Message": msg}))
+		}
+		logger.ErrorContext(ctx, "Ошибка получения групповой лицензии", slog.String("error", err.Error()))
+		return c.Send(loc.T("group.pool_error"))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(loc.T("group.pool.product", i18n.Args{"Product": pool.ProductName}))
+	if pool.Group != "" {
+		sb.WriteString(loc.T("group.pool.group", i18n.Args{"Group": pool.Group}))
+	}
+	sb.WriteString(loc.T("group.pool.seats", i18n.Args{"Used": pool.Used, "Seats": pool.Seats}))
+	sb.WriteString(loc.T("group.pool.invite", i18n.Args{"Link": h.inviteLink(pool)}))
+
+	if len(pool.Members) > 0 {
+		sb.WriteString(loc.T("group.pool.members"))
+		for i, member := range pool.Members {
+			sb.WriteString(loc.T("group.pool.member", i18n.Args{"N": i + 1, "Name": member.Name, "Since": member.AssignedAt}))
+		}
+	}
+
+	return c.Send(sb.String(), keyboards.NewSeatMembersMenu(loc, pool))
+}
+
+func (h *GroupHandler) inviteLink(pool *models.SeatPool) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s%s", h.botUsername, SeatsStartPrefix, pool.InviteCode)
+}
+
+// parseCallbackIDs разбирает данные callback вида "1|2" в n чисел
+func parseCallbackIDs(data string, n int) ([]int64, error) {
+	parts := strings.Split(data, "|")
+	if len(parts) != n {
+		return nil, fmt.Errorf("ожидалось %d значений, получено %d", n, len(parts))
+	}
+
+	ids := make([]int64, 0, n)
+	for _, part := range parts {
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
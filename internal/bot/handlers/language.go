@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"log/slog"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/i18n"
+	"github.com/GeorgeTyupin/labguard/internal/bot/keyboards"
+	"github.com/GeorgeTyupin/labguard/internal/bot/middleware/loggers"
+	tele "gopkg.in/telebot.v4"
+)
+
+// LanguageHandler выбор языка, на котором бот отвечает пользователю
+type LanguageHandler struct {
+	*BaseHandler
+	bundle    *i18n.Bundle
+	languages UserValues[string] // telegram_id -> выбранный язык
+}
+
+func NewLanguageHandler(bundle *i18n.Bundle, logger *slog.Logger, languages UserValues[string]) *LanguageHandler {
+	baseHandler := NewBaseHandler(logger)
+
+	handler := &LanguageHandler{
+		BaseHandler: baseHandler,
+		bundle:      bundle,
+		languages:   languages,
+	}
+
+	return handler
+}
+
+func (h *LanguageHandler) Handle(c tele.Context) error {
+	return c.Send(i18n.From(c).T("language.choose"), keyboards.NewLanguageMenu(h.bundle.Locales()))
+}
+
+func (h *LanguageHandler) HandleCallbacks(c tele.Context) error {
+	const op = "language.HandleCallbacks"
+	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+
+	defer c.Respond()
+
+	// Язык из кнопки проверяем: данные callback приходят от клиента
+	loc, ok := h.bundle.Match(c.Callback().Data)
+	if !ok {
+		logger.WarnContext(ctx, "Неизвестный язык в callback", slog.String("data", c.Callback().Data))
+		return c.Send(i18n.From(c).T("language.error"))
+	}
+
+	if err := h.languages.Set(ctx, c.Sender().ID, loc.Locale); err != nil {
+		logger.ErrorContext(ctx, "Не удалось сохранить язык пользователя", slog.String("error", err.Error()))
+		return c.Send(i18n.From(c).T("language.error"))
+	}
+
+	// Отвечаем уже на выбранном языке
+	return c.Send(loc.T("language.changed"))
+}
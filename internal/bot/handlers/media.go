@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/i18n"
+	"github.com/GeorgeTyupin/labguard/internal/bot/middleware/loggers"
+	"github.com/GeorgeTyupin/labguard/internal/bot/models"
+	tele "gopkg.in/telebot.v4"
+)
+
+// maxAlbum сколько файлов Telegram принимает в одном альбоме
+const maxAlbum = 10
+
+type MediaAPIClient interface {
+	GetProductMedia(ctx context.Context, productID int64) ([]*models.ProductMedia, error)
+	OpenMedia(ctx context.Context, mediaID int64) (io.ReadCloser, error)
+}
+
+// MediaSender показывает файлы карточки продукта: обложку и скриншоты альбомом, пример
+// работы документом. Загруженный файл Telegram сохраняет у себя и возвращает file_id,
+// по которому его можно отправить снова, не скачивая с сервера.
+type MediaSender struct {
+	client  MediaAPIClient
+	fileIDs UserValues[string] // id файла на сервере -> file_id в Telegram
+	logger  *slog.Logger
+}
+
+func NewMediaSender(client MediaAPIClient, fileIDs UserValues[string], logger *slog.Logger) *MediaSender {
+	return &MediaSender{
+		client:  client,
+		fileIDs: fileIDs,
+		logger:  logger,
+	}
+}
+
+// Send отправляет файлы продукта. Карточка важнее картинок, поэтому ошибки только
+// пишутся в лог, и вызывающий показывает карточку в любом случае.
+func (s *MediaSender) Send(c tele.Context, productID int64) {
+	const op = "media.Send"
+	logger := s.logger.With(slog.String("op", op), slog.Int64("product_id", productID))
+	ctx := loggers.Context(c)
+
+	media, err := s.client.GetProductMedia(ctx, productID)
+	if err != nil {
+		logger.WarnContext(ctx, "Не удалось получить файлы продукта", slog.String("error", err.Error()))
+		return
+	}
+
+	var photos, samples []*models.ProductMedia
+	for _, m := range media {
+		if m.IsPhoto() {
+			photos = append(photos, m)
+		} else {
+			samples = append(samples, m)
+		}
+	}
+	if len(photos) > maxAlbum {
+		photos = photos[:maxAlbum]
+	}
+
+	if len(photos) > 0 {
+		if err := s.deliver(c, photos, sendPhotos(c)); err != nil {
+			logger.WarnContext(ctx, "Не удалось отправить обложку и скриншоты", slog.String("error", err.Error()))
+		}
+	}
+
+	caption := i18n.From(c).T("catalog.sample")
+	for _, sample := range samples {
+		if err := s.deliver(c, []*models.ProductMedia{sample}, sendDocument(c, sample, caption)); err != nil {
+			logger.WarnContext(ctx, "Не удалось отправить пример работы", slog.String("error", err.Error()))
+		}
+	}
+}
+
+// deliver отправляет файлы по сохраненным file_id, остальные загружает с сервера.
+// Если Telegram не принял сохраненный file_id, например у бота сменился токен,
+// file_id забываются и файлы отправляются еще раз загрузкой.
+func (s *MediaSender) deliver(c tele.Context, media []*models.ProductMedia, send func(files []*tele.File) error) error {
+	ctx := loggers.Context(c)
+
+	files, cached, err := s.open(ctx, media, true)
+	if err != nil {
+		return err
+	}
+	err = send(files)
+	closeFiles(files)
+
+	if err != nil && cached {
+		s.logger.WarnContext(ctx, "Telegram не принял сохраненные file_id, загружаем файлы заново",
+			slog.String("error", err.Error()),
+		)
+		s.forget(ctx, media)
+
+		files, _, err = s.open(ctx, media, false)
+		if err != nil {
+			return err
+		}
+		err = send(files)
+		closeFiles(files)
+	}
+	if err != nil {
+		return err
+	}
+
+	for i, file := range files {
+		if file.FileID == "" {
+			continue
+		}
+		if err := s.fileIDs.Set(ctx, media[i].ID, file.FileID); err != nil {
+			s.logger.WarnContext(ctx, "Не удалось сохранить file_id", slog.String("error", err.Error()))
+		}
+	}
+
+	return nil
+}
+
+// open файлы для отправки: по file_id, если он сохранен и cache true, иначе содержимое
+// с сервера. cached сообщает, что хотя бы один файл отправляется по file_id.
+func (s *MediaSender) open(ctx context.Context, media []*models.ProductMedia, cache bool) (files []*tele.File, cached bool, err error) {
+	files = make([]*tele.File, 0, len(media))
+
+	for _, m := range media {
+		if cache {
+			fileID, ok, err := s.fileIDs.Get(ctx, m.ID)
+			if err != nil {
+				s.logger.WarnContext(ctx, "Не удалось прочитать file_id", slog.String("error", err.Error()))
+			}
+			if ok {
+				files = append(files, &tele.File{FileID: fileID})
+				cached = true
+				continue
+			}
+		}
+
+		content, err := s.client.OpenMedia(ctx, m.ID)
+		if err != nil {
+			closeFiles(files)
+			return nil, false, err
+		}
+		file := tele.FromReader(content)
+		files = append(files, &file)
+	}
+
+	return files, cached, nil
+}
+
+func (s *MediaSender) forget(ctx context.Context, media []*models.ProductMedia) {
+	for _, m := range media {
+		if err := s.fileIDs.Delete(ctx, m.ID); err != nil {
+			s.logger.WarnContext(ctx, "Не удалось удалить file_id", slog.String("error", err.Error()))
+		}
+	}
+}
+
+// sendPhotos альбом из обложки и скриншотов. В альбоме должно быть от 2 файлов,
+// одну картинку Telegram принимает только отдельным сообщением.
+func sendPhotos(c tele.Context) func(files []*tele.File) error {
+	return func(files []*tele.File) error {
+		if len(files) == 1 {
+			photo := &tele.Photo{File: *files[0]}
+			if err := c.Send(photo); err != nil {
+				return err
+			}
+			files[0].FileID = photo.FileID
+			return nil
+		}
+
+		album := make(tele.Album, 0, len(files))
+		for _, file := range files {
+			album = append(album, &tele.Photo{File: *file})
+		}
+		if err := c.SendAlbum(album); err != nil {
+			return err
+		}
+		for i, item := range album {
+			files[i].FileID = item.MediaFile().FileID
+		}
+
+		return nil
+	}
+}
+
+// sendDocument пример работы с исходным именем файла
+func sendDocument(c tele.Context, sample *models.ProductMedia, caption string) func(files []*tele.File) error {
+	return func(files []*tele.File) error {
+		doc := &tele.Document{
+			File:     *files[0],
+			FileName: sample.FileName,
+			MIME:     sample.ContentType,
+			Caption:  caption,
+		}
+		if err := c.Send(doc); err != nil {
+			return err
+		}
+		files[0].FileID = doc.FileID
+
+		return nil
+	}
+}
+
+// closeFiles закрывает содержимое файлов, скачанных с сервера
+func closeFiles(files []*tele.File) {
+	for _, file := range files {
+		if closer, ok := file.FileReader.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+}
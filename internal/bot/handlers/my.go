@@ -1,20 +1,33 @@
 package handlers
 
 import (
-	"fmt"
+	"context"
+	"io"
 	"log/slog"
 	"strconv"
+	"time"
 
+	"github.com/GeorgeTyupin/labguard/internal/bot/i18n"
 	"github.com/GeorgeTyupin/labguard/internal/bot/keyboards"
+	"github.com/GeorgeTyupin/labguard/internal/bot/middleware/loggers"
 	"github.com/GeorgeTyupin/labguard/internal/bot/models"
 	tele "gopkg.in/telebot.v4"
 )
 
 type MyAPIClient interface {
-	CheckUserExists(telegramID int64) (bool, error)
-	GetProducts(telegramID int64) ([]*models.Product, error)
+	CheckUserExists(ctx context.Context, telegramID int64) (bool, error)
+	GetProducts(ctx context.Context, telegramID int64) ([]*models.Product, error)
+	RequestRefund(ctx context.Context, telegramID, productID int64, reason string) (*models.Refund, error)
+	RotateLicenseKey(ctx context.Context, telegramID int64) (string, error)
+	GetArtifact(ctx context.Context, telegramID, productID int64) (*models.Artifact, error)
+	DownloadArtifact(ctx context.Context, telegramID, productID int64) (io.ReadCloser, error)
+	ArtifactLink(ctx context.Context, telegramID, productID int64) (*models.ArtifactLink, error)
 }
 
+// maxArtifactDocument Telegram принимает от бота документы до 50 МБ, метка немного
+// увеличивает файл, поэтому материалы больше этого размера отдаются ссылкой
+const maxArtifactDocument = 49 << 20
+
 type MyHandler struct {
 	*BaseProductsHandler
 	client MyAPIClient
@@ -34,80 +47,250 @@ func NewMyHandler(apiClient MyAPIClient, logger *slog.Logger, cache ProductsCach
 func (h *MyHandler) Handle(c tele.Context) error {
 	const op = "my.Handle"
 	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
 
 	telegramID := c.Sender().ID
 
 	// Проверяем регистрацию пользователя
-	_, err := h.client.CheckUserExists(telegramID)
+	exists, err := h.client.CheckUserExists(ctx, telegramID)
 	if err != nil {
-		logger.Warn("нет метода проверки зарегистрированного пользователя", slog.String("error", err.Error()))
-		return c.Send("❌ Ошибка при проверке регистрации")
+		logger.WarnContext(ctx, "Ошибка проверки зарегистрированного пользователя", slog.String("error", err.Error()))
+		return c.Send(loc.T("error.registration_check"))
 	}
 
-	// TODO: расскоментировать после реализации
-	// if !exists {
-	// 	return c.Send(fmt.Sprintf("Вы еще не зарегистрированы! Используйте %s для регистрации", StartEndpoint))
-	// }
-
-	var products []*models.Product
+	if !exists {
+		return c.Send(loc.T("error.not_registered", i18n.Args{"Start": StartEndpoint}))
+	}
 
-	products, err = h.Cache.Get(telegramID) // Сначала пробуем получить из кеша
+	products, err := h.listProducts(ctx, telegramID, h.client.GetProducts)
 	if err != nil {
-		products, err = h.client.GetProducts(telegramID)
-		if err != nil {
-			logger.Warn("нет метода получения списка продуктов", slog.String("error", err.Error()))
-			return c.Send("❌ Ошибка при попытке получить список продуктов")
-		}
+		logger.WarnContext(ctx, "нет метода получения списка продуктов", slog.String("error", err.Error()))
+		return c.Send(loc.T("catalog.fetch_error"))
 	}
 
-	h.Cache.Set(telegramID, products)
-
-	productsMenu := keyboards.NewProductsMenu(products, h.purchased)
-
-	return c.Send("Список ваших продуктов:\n", productsMenu)
+	return c.Send(loc.T("my.title"), keyboards.NewMyMenu(loc, products))
 }
 
 func (h *MyHandler) HandleCallbacks(c tele.Context) error {
 	const op = "my.HandleCallbacks"
 	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
 
 	defer c.Respond()
 
 	// Проверяем, что это callback для продуктов
 	if c.Callback().Unique != keyboards.MyUniqueCallback {
-		logger.Warn("Unique не совпадает с my", slog.String("unique", c.Callback().Unique))
+		logger.WarnContext(ctx, "Unique не совпадает с my", slog.String("unique", c.Callback().Unique))
 		return nil
 	}
 
 	// Извлекаем индекс продукта
 	productIdx, err := strconv.Atoi(c.Callback().Data)
 	if err != nil {
-		logger.Error(
+		logger.ErrorContext(ctx,
 			"Не удалось конвертировать индекс продукта из строки в число",
 			slog.String("data", c.Callback().Data),
 		)
-		return c.Send(fmt.Sprintf("❌ Возникла внутренняя ошибка. Попробуйте ввести %s еще раз", MyEndpoint))
+		return c.Send(loc.T("error.internal_retry", i18n.Args{"Command": MyEndpoint}))
 	}
 
-	telegramID := c.Sender().ID
+	product, ok := h.cachedProduct(ctx, c.Sender().ID, productIdx)
+	if !ok {
+		return c.Send(loc.T("catalog.not_found", i18n.Args{"Command": MyEndpoint}))
+	}
+
+	logger.InfoContext(ctx, "Успешно получили продукт через callback", slog.Any("product", product))
+
+	message := loc.T("my.card", i18n.Args{
+		"Name":        product.Name,
+		"Description": product.Description,
+		"Link":        product.Link,
+	})
+
+	// Без материалов карточка показывается без кнопок скачивания
+	hasArtifact := true
+	if _, err := h.client.GetArtifact(ctx, c.Sender().ID, product.ID); err != nil {
+		hasArtifact = false
+		if !isNotFound(err) {
+			logger.WarnContext(ctx, "Не удалось проверить материалы продукта", slog.String("error", err.Error()))
+		}
+	}
+
+	return c.Send(message, h.sendOptions[msgTypeSuccess], keyboards.NewMyProductMenu(loc, product.ID, hasArtifact))
+}
+
+// HandleArtifactCallbacks отправляет материалы документом. Копия помечена сервером,
+// поэтому file_id не запоминается: каждому покупателю уходит своя копия.
+func (h *MyHandler) HandleArtifactCallbacks(c tele.Context) error {
+	const op = "my.HandleArtifactCallbacks"
+	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
+
+	defer c.Respond()
+
+	productID, err := strconv.ParseInt(c.Callback().Data, 10, 64)
+	if err != nil {
+		logger.ErrorContext(ctx, "Не удалось конвертировать id продукта из строки в число", slog.String("data", c.Callback().Data))
+		return c.Send(loc.T("error.internal_retry", i18n.Args{"Command": MyEndpoint}))
+	}
+
+	artifact, err := h.client.GetArtifact(ctx, c.Sender().ID, productID)
+	if err != nil {
+		return h.sendArtifactError(c, logger, err)
+	}
+
+	if artifact.Size > maxArtifactDocument {
+		return h.sendArtifactLink(c, logger, productID, loc.T("my.artifact_too_large"))
+	}
+
+	if err := c.Notify(tele.UploadingDocument); err != nil {
+		logger.WarnContext(ctx, "Не удалось показать статус отправки", slog.String("error", err.Error()))
+	}
 
-	products, err := h.Cache.Get(telegramID)
-	if err != nil || productIdx < 0 || productIdx >= len(products) {
-		logger.Info("Ошибка получения элемента из кеша", slog.String("error", err.Error()))
-		return c.Send(fmt.Sprintf("❌ Продукт не найден. Попробуйте вызвать %s еще раз", MyEndpoint))
+	content, err := h.client.DownloadArtifact(ctx, c.Sender().ID, productID)
+	if err != nil {
+		return h.sendArtifactError(c, logger, err)
+	}
+	defer content.Close()
+
+	doc := &tele.Document{
+		File:     tele.FromReader(content),
+		FileName: artifact.FileName,
+		MIME:     artifact.ContentType,
+		Caption:  loc.T("my.artifact_caption", i18n.Args{"Version": artifact.Version}),
+	}
+	if err := c.Send(doc); err != nil {
+		logger.ErrorContext(ctx, "Не удалось отправить материалы", slog.String("error", err.Error()))
+		return c.Send(loc.T("my.artifact_error"))
 	}
-	product := products[productIdx]
 
-	logger.Info("Успешно получили продукт через callback", slog.Any("product", product))
+	return nil
+}
 
-	message := fmt.Sprintf(
-		"*📦 %s*\n\n"+
-			"_%s_\n\n"+
-			"🔗 [GitHub](%s)\n",
-		product.Name,
-		product.Description,
-		product.Link,
-	)
+// HandleArtifactLinkCallbacks временная ссылка на скачивание с сервера
+func (h *MyHandler) HandleArtifactLinkCallbacks(c tele.Context) error {
+	const op = "my.HandleArtifactLinkCallbacks"
+	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
+
+	defer c.Respond()
+
+	productID, err := strconv.ParseInt(c.Callback().Data, 10, 64)
+	if err != nil {
+		logger.ErrorContext(ctx, "Не удалось конвертировать id продукта из строки в число", slog.String("data", c.Callback().Data))
+		return c.Send(loc.T("error.internal_retry", i18n.Args{"Command": MyEndpoint}))
+	}
+
+	return h.sendArtifactLink(c, logger, productID, "")
+}
+
+// sendArtifactLink запрашивает ссылку и отправляет ее, prefix — текст перед ссылкой
+func (h *MyHandler) sendArtifactLink(c tele.Context, logger *slog.Logger, productID int64, prefix string) error {
+	loc := i18n.From(c)
+
+	link, err := h.client.ArtifactLink(loggers.Context(c), c.Sender().ID, productID)
+	if err != nil {
+		return h.sendArtifactError(c, logger, err)
+	}
+
+	message := loc.T("my.artifact_link", i18n.Args{
+		"URL":      link.URL,
+		"FileName": link.FileName,
+		"Version":  link.Version,
+		"Minutes":  int64(time.Until(link.ExpiresAt).Round(time.Minute) / time.Minute),
+	})
+	if prefix != "" {
+		message = prefix + "\n\n" + message
+	}
 
 	return c.Send(message, h.sendOptions[msgTypeSuccess])
 }
+
+func (h *MyHandler) sendArtifactError(c tele.Context, logger *slog.Logger, err error) error {
+	loc := i18n.From(c)
+
+	if isNotFound(err) {
+		return c.Send(loc.T("my.artifact_none"))
+	}
+	if msg, ok := clientErrorMessage(err); ok {
+		return c.Send(loc.T("error.server", i18n.Args{"Message": msg}))
+	}
+
+	logger.ErrorContext(loggers.Context(c), "Ошибка получения материалов", slog.String("error", err.Error()))
+
+	return c.Send(loc.T("my.artifact_error"))
+}
+
+func (h *MyHandler) HandleRefundCallbacks(c tele.Context) error {
+	const op = "my.HandleRefundCallbacks"
+	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
+
+	defer c.Respond()
+
+	productID, err := strconv.ParseInt(c.Callback().Data, 10, 64)
+	if err != nil {
+		logger.ErrorContext(ctx, "Не удалось конвертировать id продукта из строки в число", slog.String("data", c.Callback().Data))
+		return c.Send(loc.T("error.internal_retry", i18n.Args{"Command": MyEndpoint}))
+	}
+
+	return c.Send(loc.T("my.refund_confirm"), keyboards.NewRefundConfirmMenu(loc, productID))
+}
+
+func (h *MyHandler) HandleRefundConfirmCallbacks(c tele.Context) error {
+	const op = "my.HandleRefundConfirmCallbacks"
+	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
+
+	defer c.Respond()
+
+	productID, err := strconv.ParseInt(c.Callback().Data, 10, 64)
+	if err != nil {
+		logger.ErrorContext(ctx, "Не удалось конвертировать id продукта из строки в число", slog.String("data", c.Callback().Data))
+		return c.Send(loc.T("error.internal_retry", i18n.Args{"Command": MyEndpoint}))
+	}
+
+	if _, err := h.client.RequestRefund(ctx, c.Sender().ID, productID, "Запрошено через бота"); err != nil {
+		if msg, ok := clientErrorMessage(err); ok {
+			return c.Send(loc.T("error.server", i18n.Args{"Message": msg}))
+		}
+		logger.ErrorContext(ctx, "Ошибка запроса возврата", slog.String("error", err.Error()))
+		return c.Send(loc.T("my.refund_error"))
+	}
+
+	return c.Send(loc.T("my.refund_sent"))
+}
+
+func (h *MyHandler) HandleRotateKeyCallbacks(c tele.Context) error {
+	defer c.Respond()
+
+	loc := i18n.From(c)
+
+	return c.Send(loc.T("my.rotate_confirm"), keyboards.NewRotateKeyConfirmMenu(loc))
+}
+
+func (h *MyHandler) HandleRotateKeyConfirmCallbacks(c tele.Context) error {
+	const op = "my.HandleRotateKeyConfirmCallbacks"
+	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
+
+	defer c.Respond()
+
+	key, err := h.client.RotateLicenseKey(ctx, c.Sender().ID)
+	if err != nil {
+		if msg, ok := clientErrorMessage(err); ok {
+			return c.Send(loc.T("error.server", i18n.Args{"Message": msg}))
+		}
+		logger.ErrorContext(ctx, "Ошибка перевыпуска ключа", slog.String("error", err.Error()))
+		return c.Send(loc.T("my.rotate_error"))
+	}
+
+	return c.Send(loc.T("my.rotated", i18n.Args{"Key": key}), h.sendOptions[msgTypeSuccess])
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/i18n"
+	"github.com/GeorgeTyupin/labguard/internal/bot/middleware/loggers"
+	"github.com/GeorgeTyupin/labguard/internal/bot/models"
+	tele "gopkg.in/telebot.v4"
+)
+
+type ReferralAPIClient interface {
+	GetReferralStats(ctx context.Context, telegramID int64) (*models.ReferralStats, error)
+}
+
+// ReferralHandler показывает персональную реферальную ссылку и статистику приглашений
+type ReferralHandler struct {
+	*BaseHandler
+	client      ReferralAPIClient
+	botUsername string
+}
+
+func NewReferralHandler(apiClient ReferralAPIClient, logger *slog.Logger, botUsername string) *ReferralHandler {
+	baseHandler := NewBaseHandler(logger)
+
+	handler := &ReferralHandler{
+		BaseHandler: baseHandler,
+		client:      apiClient,
+		botUsername: botUsername,
+	}
+
+	return handler
+}
+
+func (h *ReferralHandler) Handle(c tele.Context) error {
+	const op = "referral.Handle"
+	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
+
+	stats, err := h.client.GetReferralStats(ctx, c.Sender().ID)
+	if err != nil {
+		if msg, ok := clientErrorMessage(err); ok {
+			return c.Send(loc.T("error.server_register", i18n.Args{"Message": msg, "Start": StartEndpoint}))
+		}
+		logger.ErrorContext(ctx, "Ошибка получения статистики приглашений", slog.String("error", err.Error()))
+		return c.Send(loc.T("referral.error"))
+	}
+
+	link := fmt.Sprintf("https://t.me/%s?start=%s%s", h.botUsername, ReferralStartPrefix, stats.ReferralCode)
+
+	message := loc.T("referral.stats", i18n.Args{
+		"Link":     link,
+		"Invited":  stats.Invited,
+		"Rewarded": stats.Rewarded,
+		"Bonus":    stats.TotalBonus,
+	})
+
+	return c.Send(message)
+}
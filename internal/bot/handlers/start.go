@@ -1,38 +1,55 @@
 package handlers
 
 import (
-	"fmt"
+	"context"
 	"log/slog"
+	"strings"
 
+	"github.com/GeorgeTyupin/labguard/internal/bot/i18n"
 	"github.com/GeorgeTyupin/labguard/internal/bot/keyboards"
+	"github.com/GeorgeTyupin/labguard/internal/bot/middleware/loggers"
+	"github.com/GeorgeTyupin/labguard/internal/bot/models"
 	"github.com/GeorgeTyupin/labguard/internal/bot/validators"
 	tele "gopkg.in/telebot.v4"
 )
 
 type RegisterAPIClient interface {
-	CheckUserExists(telegramID int64) (bool, error)
-	RegisterUser(telegramID int64, name, group string) (string, error)
+	CheckUserExists(ctx context.Context, telegramID int64) (bool, error)
+	RegisterUser(ctx context.Context, telegramID int64, name, group, referralCode string) (string, error)
+	JoinSeatPool(ctx context.Context, telegramID int64, inviteCode string) (*models.SeatPool, error)
+}
+
+// validationMessages ключи текстов для ошибок проверки ФИО и группы
+var validationMessages = map[error]string{
+	validators.ErrNameEmpty:    "validation.name_empty",
+	validators.ErrNameTooLong:  "validation.name_too_long",
+	validators.ErrNameInvalid:  "validation.name_invalid",
+	validators.ErrGroupEmpty:   "validation.group_empty",
+	validators.ErrGroupInvalid: "validation.group_invalid",
 }
 
 type RegisterState struct {
-	Step  int
-	Name  string // ФИО пользователя
-	Group string // Группа пользователя
+	Step  int    `json:"step"`
+	Name  string `json:"name"`  // ФИО пользователя
+	Group string `json:"group"` // Группа пользователя
+
+	InviteCode   string `json:"invite_code,omitempty"`   // Код групповой лицензии из ссылки-приглашения, место занимаем после регистрации
+	ReferralCode string `json:"referral_code,omitempty"` // Код пригласившего из реферальной ссылки
 }
 
 type StartHandler struct {
 	*BaseHandler
 	client     RegisterAPIClient
-	userStates map[int64]*RegisterState // telegram_id -> stage
+	userStates UserValues[*RegisterState] // telegram_id -> stage
 }
 
-func NewStartHandler(apiClient RegisterAPIClient, logger *slog.Logger) *StartHandler {
+func NewStartHandler(apiClient RegisterAPIClient, logger *slog.Logger, userStates UserValues[*RegisterState]) *StartHandler {
 	baseHandler := NewBaseHandler(logger)
 
 	handler := &StartHandler{
 		BaseHandler: baseHandler,
 		client:      apiClient,
-		userStates:  make(map[int64]*RegisterState),
+		userStates:  userStates,
 	}
 
 	return handler
@@ -41,132 +58,176 @@ func NewStartHandler(apiClient RegisterAPIClient, logger *slog.Logger) *StartHan
 func (h *StartHandler) Handle(c tele.Context) error {
 	const op = "start.Handle"
 	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
 
 	telegramID := c.Sender().ID
 
 	// Начинаем процесс регистрации
-	exists, err := h.client.CheckUserExists(telegramID)
+	exists, err := h.client.CheckUserExists(ctx, telegramID)
 	if err != nil {
-		logger.Warn("нет метода проверки зарегистрированного пользователя", slog.String("error", err.Error()))
-		return c.Send("❌ Ошибка при проверке регистрации")
+		logger.WarnContext(ctx, "Ошибка проверки зарегистрированного пользователя", slog.String("error", err.Error()))
+		return c.Send(loc.T("error.registration_check"))
 	}
 
-	if exists {
-		return c.Send("Вы уже зарегистрированы! Используйте /my для просмотра токена")
+	// Ссылки с параметром: t.me/<bot>?start=seats_<code> или t.me/<bot>?start=ref_<code>
+	var inviteCode, referralCode string
+	payload := c.Message().Payload
+	if code, ok := strings.CutPrefix(payload, SeatsStartPrefix); ok {
+		inviteCode = code
+	} else if code, ok := strings.CutPrefix(payload, ReferralStartPrefix); ok {
+		referralCode = code
 	}
 
-	h.mu.Lock()
-	h.userStates[telegramID] = &RegisterState{Step: 1}
-	h.mu.Unlock()
-
-	text := `Привет! 👋
-
-Здесь вы можете купить готовые лабораторные работы и курсовые с полным исходным кодом.
-
-После покупки получите:
-✅ Рабочий код с комментариями
-✅ Доступ к GitHub репозиторию
-✅ Персональную лицензию на использование
+	if exists {
+		if inviteCode != "" {
+			return c.Send(h.joinSeatPool(ctx, loc, telegramID, inviteCode))
+		}
+		return c.Send(loc.T("start.already_registered", i18n.Args{"My": MyEndpoint}))
+	}
 
-Для начала давайте зарегистрируемся!
+	state := &RegisterState{Step: 1, InviteCode: inviteCode, ReferralCode: referralCode}
+	if err := h.userStates.Set(ctx, telegramID, state); err != nil {
+		logger.ErrorContext(ctx, "Не удалось сохранить шаг регистрации", slog.String("error", err.Error()))
+		return c.Send(loc.T("error.internal_later", i18n.Args{"Command": StartEndpoint}))
+	}
 
+	return c.Send(loc.T("start.greeting"))
+}
 
-📝 Напишите своё ФИО:`
+// Awaits сообщает, что пользователь проходит регистрацию
+func (h *StartHandler) Awaits(ctx context.Context, telegramID int64) bool {
+	_, ok, err := h.userStates.Get(ctx, telegramID)
+	if err != nil {
+		h.logger.WarnContext(ctx, "Не удалось прочитать шаг регистрации", slog.String("error", err.Error()))
+	}
 
-	return c.Send(text)
+	return ok
 }
 
 func (h *StartHandler) HandleMessage(c tele.Context) error {
 	const op = "start.HandleMessage"
 	logger := h.logger.With(slog.String("op", op))
+	ctx := loggers.Context(c)
+	loc := i18n.From(c)
 
 	telegramID := c.Sender().ID
 
-	h.mu.RLock()
-	state, ok := h.userStates[telegramID]
-	if !ok {
+	state, ok, err := h.userStates.Get(ctx, telegramID)
+	if err != nil || !ok {
 		return nil // Не в процессе регистрации
 	}
-	h.mu.RUnlock()
+
+	// Шаг хранится вне процесса, следующее сообщение может прийти на другую реплику
+	saveStep := func(step int) bool {
+		state.Step = step
+		if err := h.userStates.Set(ctx, telegramID, state); err != nil {
+			logger.ErrorContext(ctx, "Не удалось сохранить шаг регистрации", slog.String("error", err.Error()))
+			return false
+		}
+		return true
+	}
+	internalError := loc.T("error.internal_later", i18n.Args{"Command": StartEndpoint})
 
 	switch state.Step {
 	case 1:
 		// Сохраняем ФИО в состояние
 		state.Name = c.Text()
 		if err := validators.ValidateName(state.Name); err != nil {
-			return c.Send(fmt.Sprintf("Неверный формат ФИО : %s.\n\nВы ввели %s.\nВведите ФИО еще раз:", err.Error(), state.Name))
+			return c.Send(loc.T("start.name_invalid", i18n.Args{"Error": validationMessage(loc, err), "Input": state.Name}))
 		}
 
-		state.Step = 2
-		return c.Send("👥 Теперь введите группу:")
+		if !saveStep(2) {
+			return c.Send(internalError)
+		}
+		return c.Send(loc.T("start.ask_group"))
 
 	case 2:
 		// Сохраняем группу в состояние
 		state.Group = c.Text()
 		if err := validators.ValidateGroup(state.Group); err != nil {
-			return c.Send(fmt.Sprintf("Неверный формат группу: %s.\n\nВы ввели: %s.\nВведите группу еще раз", err.Error(), state.Group))
+			return c.Send(loc.T("start.group_invalid", i18n.Args{"Error": validationMessage(loc, err), "Input": state.Group}))
 		}
 
-		state.Step = 3
+		if !saveStep(3) {
+			return c.Send(internalError)
+		}
 
-		menu := keyboards.NewYesNoMenu()
+		menu := keyboards.NewYesNoMenu(loc)
 
-		return c.Send(fmt.Sprintf("ФИО: %s\nГруппа: %s\n\nВсё верно?", state.Name, state.Group), menu)
+		return c.Send(loc.T("start.confirm", i18n.Args{"Name": state.Name, "Group": state.Group}), menu)
 
 	case 3:
 		check := c.Text()
 		// Проверяем кнопку, которую нажал пользователь
 		switch check {
-		case keyboards.YesText:
+		case loc.T("button.yes"):
 			// Регистрируем пользователя с сохранёнными данными
-			token, err := h.client.RegisterUser(telegramID, state.Name, state.Group)
+			token, err := h.client.RegisterUser(ctx, telegramID, state.Name, state.Group, state.ReferralCode)
 			if err != nil {
-				logger.Warn("нет метода регистрации", slog.String("error", err.Error()))
-				return c.Send(fmt.Sprintf("❌ Произошла внутренняя ошибка. Попробуйте %s ещё раз позже.", StartEndpoint),
-					h.sendOptions[msgTypeError],
-				)
+				logger.WarnContext(ctx, "Ошибка регистрации", slog.String("error", err.Error()))
+				return c.Send(internalError, h.sendOptions[msgTypeError])
 			}
 
 			// Удаляем состояние после успешной регистрации
-			h.mu.Lock()
-			delete(h.userStates, telegramID)
-			h.mu.Unlock()
-
-			successMsg := fmt.Sprintf(
-				"✅ Регистрация завершена!\n\n"+
-					"👤 ФИО: %s\n"+
-					"👥 Группа: %s\n"+
-					"🔑 Токен: ```%s```\n\n"+
-					"📋 Доступные команды:\n"+
-					"/catalog — список доступных продуктов\n"+
-					"/my — мои покупки и токен\n"+
-					"/devices — сброс устройства",
-				state.Name, state.Group, token,
-			)
-			return c.Send(successMsg, h.sendOptions[msgTypeSuccess])
-
-		case keyboards.NoText:
+			h.resetState(ctx, telegramID)
+
+			successMsg := loc.T("start.success", i18n.Args{"Name": state.Name, "Group": state.Group, "Key": token})
+			if err := c.Send(successMsg, h.sendOptions[msgTypeSuccess]); err != nil {
+				return err
+			}
+
+			if state.InviteCode != "" {
+				return c.Send(h.joinSeatPool(ctx, loc, telegramID, state.InviteCode))
+			}
+
+			return nil
+
+		case loc.T("button.no"):
 			// Сбрасываем регистрацию
-			h.mu.Lock()
-			delete(h.userStates, telegramID)
-			h.mu.Unlock()
+			h.resetState(ctx, telegramID)
 
-			return c.Send(
-				fmt.Sprintf("Регистрация отменена. Введите %s для повторной попытки.", StartEndpoint),
-				h.sendOptions[msgTypeSuccess],
-			)
+			return c.Send(loc.T("start.cancelled", i18n.Args{"Start": StartEndpoint}), h.sendOptions[msgTypeSuccess])
 
 		default:
-			h.mu.Lock()
-			delete(h.userStates, telegramID)
-			h.mu.Unlock()
-
-			return c.Send(
-				fmt.Sprintf("Сделан неверный выбор. Введите %s для повторной попытки.", StartEndpoint),
-				h.sendOptions[msgTypeSuccess],
-			)
+			h.resetState(ctx, telegramID)
+
+			return c.Send(loc.T("start.wrong_choice", i18n.Args{"Start": StartEndpoint}), h.sendOptions[msgTypeSuccess])
 		}
 	}
 
 	return nil
 }
+
+// joinSeatPool занимает место в групповой лицензии и возвращает ответ пользователю
+func (h *StartHandler) joinSeatPool(ctx context.Context, loc *i18n.Localizer, telegramID int64, inviteCode string) string {
+	const op = "start.joinSeatPool"
+	logger := h.logger.With(slog.String("op", op))
+
+	pool, err := h.client.JoinSeatPool(ctx, telegramID, inviteCode)
+	if err != nil {
+		if msg, ok := clientErrorMessage(err); ok {
+			return loc.T("start.seats_join_failed", i18n.Args{"Message": msg})
+		}
+		logger.ErrorContext(ctx, "Ошибка вступления в групповую лицензию", slog.String("error", err.Error()))
+		return loc.T("start.seats_join_error")
+	}
+
+	return loc.T("start.seats_joined", i18n.Args{"Product": pool.ProductName, "My": MyEndpoint})
+}
+
+// validationMessage текст ошибки проверки на языке пользователя
+func validationMessage(loc *i18n.Localizer, err error) string {
+	if key, ok := validationMessages[err]; ok {
+		return loc.T(key)
+	}
+
+	return err.Error()
+}
+
+// resetState завершает регистрацию: успешно или с отменой
+func (h *StartHandler) resetState(ctx context.Context, telegramID int64) {
+	if err := h.userStates.Delete(ctx, telegramID); err != nil {
+		h.logger.WarnContext(ctx, "Не удалось удалить шаг регистрации", slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/middleware/loggers"
+	tele "gopkg.in/telebot.v4"
+)
+
+// TextInputHandler обработчик, который ждет от пользователя текстового ввода
+type TextInputHandler interface {
+	Awaits(ctx context.Context, telegramID int64) bool
+	HandleMessage(c tele.Context) error
+}
+
+// TextRouter передает текстовое сообщение тому обработчику, который ждет ввода от пользователя.
+// Telegram позволяет повесить на tele.OnText только один обработчик.
+type TextRouter struct {
+	handlers []TextInputHandler
+}
+
+func NewTextRouter(handlers ...TextInputHandler) *TextRouter {
+	return &TextRouter{handlers: handlers}
+}
+
+func (r *TextRouter) Handle(c tele.Context) error {
+	ctx := loggers.Context(c)
+	telegramID := c.Sender().ID
+
+	for _, h := range r.handlers {
+		if h.Awaits(ctx, telegramID) {
+			return h.HandleMessage(c)
+		}
+	}
+
+	return nil
+}
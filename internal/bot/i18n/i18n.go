@@ -0,0 +1,290 @@
+// Package i18n тексты бота на языках пользователей. Тексты лежат в locales/<язык>.yaml,
+// каждый текст — шаблон text/template, который получает Args. Текст с формами
+// множественного числа выбирает форму по Args["Count"].
+//
+// Тексты сообщений размечены HTML, данные в них экранируются автоматически (см. render).
+// Подписи кнопок и тексты, которые подставляются в другие тексты, лежат в plain и
+// не экранируются.
+package i18n
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/render"
+	tele "gopkg.in/telebot.v4"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed locales/*.yaml
+var localeFiles embed.FS
+
+// Args значения, которые подставляются в текст
+type Args map[string]any
+
+// CountArg аргумент, по которому выбирается форма множественного числа
+const CountArg = "Count"
+
+// ParseMode разметка текстов из messages. Бот отправляет сообщения с ней по умолчанию.
+const ParseMode = tele.ModeHTML
+
+type localeFile struct {
+	Name             string               `yaml:"name"` // Название языка на нем самом, для /language
+	DecimalSeparator string               `yaml:"decimal_separator"`
+	DateFormat       string               `yaml:"date_format"`
+	Messages         map[string]yaml.Node `yaml:"messages"` // Тексты сообщений, разметка ParseMode
+	Plain            map[string]yaml.Node `yaml:"plain"`    // Кнопки и подставляемые тексты без разметки
+}
+
+// message текст с формами множественного числа. У текста без форм есть только other.
+type message map[PluralForm]*template.Template
+
+// Localizer тексты одного языка
+type Localizer struct {
+	Locale string
+	Name   string
+
+	decimalSeparator string
+	dateFormat       string
+	plural           PluralRule
+	messages         map[string]message
+}
+
+// Bundle все языки бота
+type Bundle struct {
+	locales  map[string]*Localizer
+	fallback *Localizer
+}
+
+// Load читает встроенные файлы языков. fallback — язык для пользователей,
+// язык которых бот не знает. Тексты всех языков сверяются с ним, и Load
+// возвращает ошибку, если в каком-то языке не хватает текста.
+func Load(fallback string) (*Bundle, error) {
+	const op = "i18n.Load"
+
+	files, err := fs.Glob(localeFiles, "locales/*.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	bundle := &Bundle{locales: make(map[string]*Localizer, len(files))}
+	for _, file := range files {
+		locale := strings.TrimSuffix(path.Base(file), ".yaml")
+
+		l, err := loadLocale(locale, file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		bundle.locales[locale] = l
+	}
+
+	bundle.fallback = bundle.locales[fallback]
+	if bundle.fallback == nil {
+		return nil, fmt.Errorf("%s: нет файла языка %q", op, fallback)
+	}
+
+	for _, l := range bundle.locales {
+		var missing []string
+		for key := range bundle.fallback.messages {
+			if _, ok := l.messages[key]; !ok {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			return nil, fmt.Errorf("%s: в языке %s нет текстов: %s", op, l.Locale, strings.Join(missing, ", "))
+		}
+	}
+
+	return bundle, nil
+}
+
+func loadLocale(locale, file string) (*Localizer, error) {
+	data, err := localeFiles.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var lf localeFile
+	if err := yaml.Unmarshal(data, &lf); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	rule, ok := pluralRules[locale]
+	if !ok {
+		return nil, fmt.Errorf("%s: нет правил множественного числа для языка %s", file, locale)
+	}
+
+	l := &Localizer{
+		Locale:           locale,
+		Name:             lf.Name,
+		decimalSeparator: lf.DecimalSeparator,
+		dateFormat:       lf.DateFormat,
+		plural:           rule,
+		messages:         make(map[string]message, len(lf.Messages)+len(lf.Plain)),
+	}
+
+	sections := []struct {
+		nodes map[string]yaml.Node
+		mode  tele.ParseMode
+	}{
+		{lf.Messages, ParseMode},
+		{lf.Plain, tele.ModeDefault},
+	}
+	for _, section := range sections {
+		for key, node := range section.nodes {
+			if _, ok := l.messages[key]; ok {
+				return nil, fmt.Errorf("%s: %s: текст задан и в messages, и в plain", file, key)
+			}
+
+			msg, err := l.parseMessage(key, &node, section.mode)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", file, key, err)
+			}
+			l.messages[key] = msg
+		}
+	}
+
+	return l, nil
+}
+
+// parseMessage текст — строка или формы множественного числа {one: ..., other: ...}
+func (l *Localizer) parseMessage(key string, node *yaml.Node, mode tele.ParseMode) (message, error) {
+	forms := map[PluralForm]string{}
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		forms[Other] = node.Value
+	case yaml.MappingNode:
+		if err := node.Decode(&forms); err != nil {
+			return nil, err
+		}
+		for form := range forms {
+			if !slices.Contains(l.plural.Forms, form) {
+				return nil, fmt.Errorf("форма %q не нужна языку %s", form, l.Locale)
+			}
+		}
+		for _, form := range l.plural.Forms {
+			if _, ok := forms[form]; !ok {
+				return nil, fmt.Errorf("нет формы %q", form)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("ожидается строка или формы множественного числа")
+	}
+
+	msg := make(message, len(forms))
+	for form, text := range forms {
+		tmpl, err := render.Parse(key, text, mode, l.funcs())
+		if err != nil {
+			return nil, err
+		}
+		msg[form] = tmpl
+	}
+
+	return msg, nil
+}
+
+// funcs функции, доступные в текстах
+func (l *Localizer) funcs() template.FuncMap {
+	return template.FuncMap{
+		"money":  l.Money,
+		"date":   l.Date,
+		"plural": func(key string, count any) string { return l.T(key, Args{CountArg: count}) },
+	}
+}
+
+// Localizer язык по коду. Для неизвестного кода — язык по умолчанию.
+func (b *Bundle) Localizer(locale string) *Localizer {
+	if l, ok := b.locales[locale]; ok {
+		return l
+	}
+
+	return b.fallback
+}
+
+// Match язык по language_code из Telegram: "en", "en-US" и т.п.
+func (b *Bundle) Match(languageCode string) (*Localizer, bool) {
+	base, _, _ := strings.Cut(strings.ToLower(languageCode), "-")
+	l, ok := b.locales[base]
+
+	return l, ok
+}
+
+// Locales все языки, отсортированные по коду
+func (b *Bundle) Locales() []*Localizer {
+	locales := make([]*Localizer, 0, len(b.locales))
+	for _, l := range b.locales {
+		locales = append(locales, l)
+	}
+	sort.Slice(locales, func(i, j int) bool { return locales[i].Locale < locales[j].Locale })
+
+	return locales
+}
+
+// T текст по ключу. Если текста нет или он не собрался, возвращается сам ключ,
+// чтобы ошибка была видна, а бот продолжал отвечать.
+func (l *Localizer) T(key string, args ...Args) string {
+	if l == nil {
+		return key
+	}
+
+	msg, ok := l.messages[key]
+	if !ok {
+		return key
+	}
+
+	var data Args
+	if len(args) > 0 {
+		data = args[0]
+	}
+
+	// У текста без форм есть только other, у текста с формами other может не быть
+	tmpl, single := msg[Other]
+	if len(msg) > 1 || !single {
+		n, _ := toInt64(data[CountArg])
+		tmpl = msg[l.plural.Select(n)]
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return key
+	}
+
+	return buf.String()
+}
+
+// Money сумма в копейках с разделителем языка: 12,50₽ или 12.50₽
+func (l *Localizer) Money(kopecks int64) string {
+	if kopecks%100 == 0 {
+		return fmt.Sprintf("%d₽", kopecks/100)
+	}
+
+	return fmt.Sprintf("%d%s%02d₽", kopecks/100, l.decimalSeparator, kopecks%100)
+}
+
+// Date дата в привычном для языка виде
+func (l *Localizer) Date(t time.Time) string {
+	return t.Format(l.dateFormat)
+}
+
+func toInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case int32:
+		return int64(n), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,75 @@
+package i18n
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "перезаписать testdata/*.golden")
+
+const (
+	hostileName = `<b>Иван</b> "Админ" & <script>alert(1)</script>`
+	hostileText = `</i><a href="https://evil.example">жми</a> & <code>`
+)
+
+// hostileMessages тексты, в которые попадают данные пользователя или сервера
+var hostileMessages = []struct {
+	key  string
+	args Args
+}{
+	{key: "error.server", args: Args{"Message": hostileText}},
+	{key: "start.name_invalid", args: Args{"Error": hostileText, "Input": hostileName}},
+	{key: "start.confirm", args: Args{"Name": hostileName, "Group": `ИУ7-31 <i>&</i>`}},
+	{key: "start.success", args: Args{"Name": hostileName, "Group": `ИУ7-31 <i>&</i>`, "Key": `lg_live_<&">`}},
+	{key: "start.seats_joined", args: Args{"Product": hostileText, "My": "/my"}},
+	{key: "catalog.card", args: Args{"Name": hostileName, "Description": hostileText, "Price": 1500.0, "PeriodDays": 30}},
+	{key: "my.card", args: Args{"Name": hostileName, "Description": hostileText, "Link": `https://github.com/x?a=1&b="2"`}},
+	{key: "my.artifact_link", args: Args{"URL": `https://labguard.example/d/t?a=1&b="<x>"`, "FileName": `"report".pdf<`, "Version": 2, "Minutes": 15}},
+	{key: "promo.applied", args: Args{"Code": `<b>SALE</b>&`, "Price": int64(150000), "Discount": int64(15050), "Total": int64(134950)}},
+	{key: "group.created", args: Args{"Product": hostileText, "Seats": 22, "Link": `https://t.me/bot?start=<&>`, "Group": "/group"}},
+	{key: "group.pool.member", args: Args{"N": 1, "Name": hostileName, "Since": time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)}},
+	// Подписи кнопок без разметки, Telegram показывает их как есть
+	{key: "button.product", args: Args{"Name": hostileName, "Price": 1500.0}},
+	{key: "button.release", args: Args{"Name": hostileName}},
+}
+
+func TestMessagesGolden(t *testing.T) {
+	bundle, err := Load("ru")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	for _, l := range bundle.Locales() {
+		t.Run(l.Locale, func(t *testing.T) {
+			var buf bytes.Buffer
+			for _, m := range hostileMessages {
+				got := l.T(m.key, m.args)
+				if got == m.key {
+					t.Fatalf("текст %s не собрался", m.key)
+				}
+				fmt.Fprintf(&buf, "== %s ==\n%s\n", m.key, got)
+			}
+
+			path := filepath.Join("testdata", l.Locale+".golden")
+			if *update {
+				if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+					t.Fatalf("запись %s: %v", path, err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("чтение %s: %v (перезапишите эталоны флагом -update)", path, err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Fatalf("%s не совпадает с эталоном\nполучено:\n%s", path, buf.Bytes())
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package i18n
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/middleware/loggers"
+	tele "gopkg.in/telebot.v4"
+)
+
+const contextKey = "localizer"
+
+// Preferences язык, который пользователь выбрал в /language
+type Preferences interface {
+	Get(ctx context.Context, telegramID int64) (string, bool, error)
+	Set(ctx context.Context, telegramID int64, locale string) error
+}
+
+// Middleware выбирает язык ответа: выбранный в /language, иначе language_code
+// из Telegram, иначе язык по умолчанию. Должен стоять после RequestID.
+func Middleware(bundle *Bundle, prefs Preferences, logger *slog.Logger) tele.MiddlewareFunc {
+	logger = logger.With(slog.String("component", "i18n"))
+
+	return func(next tele.HandlerFunc) tele.HandlerFunc {
+		return func(c tele.Context) error {
+			c.Set(contextKey, resolve(c, bundle, prefs, logger))
+			return next(c)
+		}
+	}
+}
+
+func resolve(c tele.Context, bundle *Bundle, prefs Preferences, logger *slog.Logger) *Localizer {
+	sender := c.Sender()
+	if sender == nil {
+		return bundle.fallback
+	}
+
+	ctx := loggers.Context(c)
+	locale, ok, err := prefs.Get(ctx, sender.ID)
+	if err != nil {
+		logger.WarnContext(ctx, "Не удалось прочитать язык пользователя", slog.String("error", err.Error()))
+	}
+	if ok {
+		return bundle.Localizer(locale)
+	}
+
+	if l, ok := bundle.Match(sender.LanguageCode); ok {
+		return l
+	}
+
+	return bundle.fallback
+}
+
+// From язык ответа на текущее обновление
+func From(c tele.Context) *Localizer {
+	l, _ := c.Get(contextKey).(*Localizer)
+	return l
+}
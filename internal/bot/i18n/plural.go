@@ -0,0 +1,50 @@
+package i18n
+
+// PluralForm форма множественного числа по классификации CLDR
+type PluralForm string
+
+const (
+	One   PluralForm = "one"
+	Few   PluralForm = "few"
+	Many  PluralForm = "many"
+	Other PluralForm = "other"
+)
+
+// PluralRule формы множественного числа языка и выбор формы для целого числа
+type PluralRule struct {
+	Forms  []PluralForm
+	Select func(n int64) PluralForm
+}
+
+// pluralRules правила для языков, у которых есть файл в locales
+var pluralRules = map[string]PluralRule{
+	// 1 день, 2 дня, 5 дней, 11 дней, 21 день
+	"ru": {
+		Forms: []PluralForm{One, Few, Many},
+		Select: func(n int64) PluralForm {
+			if n < 0 {
+				n = -n
+			}
+			mod10, mod100 := n%10, n%100
+
+			switch {
+			case mod10 == 1 && mod100 != 11:
+				return One
+			case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+				return Few
+			default:
+				return Many
+			}
+		},
+	},
+	// 1 day, 2 days
+	"en": {
+		Forms: []PluralForm{One, Other},
+		Select: func(n int64) PluralForm {
+			if n == 1 {
+				return One
+			}
+			return Other
+		},
+	},
+}
@@ -5,18 +5,64 @@ import (
 	"time"
 
 	"github.com/GeorgeTyupin/labguard/internal/bot/config"
+	"github.com/GeorgeTyupin/labguard/pkg/auth"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func NewToken(cfg *config.Config) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
+// Issuer выпускает короткоживущие токены бота для запросов к серверу
+type Issuer struct {
+	key      *auth.SigningKey
+	subject  string
+	audience string
+	ttl      time.Duration
+}
+
+// NewIssuer загружает ключ подписи один раз при старте. В режиме hs256 токены
+// подписываются общим секретом JWT_SECRET.
+func NewIssuer(cfg *config.Config) (*Issuer, error) {
+	jwtConf := cfg.Client.JWT
+
+	var key *auth.SigningKey
+	switch jwtConf.Algorithm {
+	case auth.AlgorithmHS256:
+		if jwtConf.Secret == "" {
+			return nil, fmt.Errorf("в режиме %s нужна переменная JWT_SECRET", auth.AlgorithmHS256)
+		}
+		key = auth.NewHMACSigningKey(jwtConf.Secret)
+	case auth.AlgorithmAsymmetric:
+		var err error
+		key, err = auth.LoadSigningKey(jwtConf.KeyID, jwtConf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось загрузить ключ подписи jwt: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("неизвестный алгоритм подписи токенов %q", jwtConf.Algorithm)
+	}
 
-	claims := token.Claims.(jwt.MapClaims)
-	claims["bot_name"] = cfg.BotName
-	claims["exp"] = time.Now().Add(cfg.Client.JWT.TokenTTL).Unix()
+	return &Issuer{
+		key:      key,
+		subject:  cfg.BotName,
+		audience: jwtConf.Audience,
+		ttl:      jwtConf.TokenTTL,
+	}, nil
+}
+
+func (i *Issuer) NewToken() (string, error) {
+	now := time.Now()
+
+	claims := auth.Claims{
+		Role:   auth.RoleBot,
+		Scopes: auth.DefaultScopes(auth.RoleBot),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   i.subject,
+			Issuer:    auth.IssuerBot,
+			Audience:  jwt.ClaimStrings{i.audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(i.ttl)),
+		},
+	}
 
-	// Подписываем токен секретом
-	tokenString, err := token.SignedString([]byte(cfg.Client.JWT.Secret))
+	tokenString, err := i.key.Sign(claims)
 	if err != nil {
 		return "", fmt.Errorf("ошибка подписи jwt: %w", err)
 	}
@@ -3,15 +3,45 @@ package keyboards
 import (
 	"fmt"
 
+	"github.com/GeorgeTyupin/labguard/internal/bot/i18n"
 	tele "gopkg.in/telebot.v4"
 )
 
-func NewBuyMenu(id int64) *tele.ReplyMarkup {
+func NewBuyMenu(loc *i18n.Localizer, id int64) *tele.ReplyMarkup {
 	menu := &tele.ReplyMarkup{}
 
-	btnText := "Купить 🛒"
-	btn := menu.Data(btnText, BuyUniqueCallback, fmt.Sprint(id))
-	menu.Inline(menu.Row(btn))
+	btn := menu.Data(loc.T("button.buy"), BuyUniqueCallback, fmt.Sprint(id))
+	balanceBtn := menu.Data(loc.T("button.buy_balance"), BuyBalanceCallback, fmt.Sprint(id))
+	promoBtn := menu.Data(loc.T("button.promo"), PromoUniqueCallback, fmt.Sprint(id))
+	groupBtn := menu.Data(loc.T("button.buy_group"), GroupBuyUniqueCallback, fmt.Sprint(id))
+	menu.Inline(menu.Row(btn), menu.Row(balanceBtn), menu.Row(promoBtn), menu.Row(groupBtn))
+
+	return menu
+}
+
+// NewPromoBuyMenu кнопки покупки с уже примененным промокодом
+func NewPromoBuyMenu(loc *i18n.Localizer, id int64, code string) *tele.ReplyMarkup {
+	menu := &tele.ReplyMarkup{}
+
+	btn := menu.Data(loc.T("button.buy_discount"), BuyUniqueCallback, fmt.Sprint(id), code)
+	balanceBtn := menu.Data(loc.T("button.buy_balance"), BuyBalanceCallback, fmt.Sprint(id), code)
+	menu.Inline(menu.Row(btn), menu.Row(balanceBtn))
+
+	return menu
+}
+
+// NewPayMenu ссылка на оплату покупки и кнопка проверки оплаты с callback unique
+func NewPayMenu(loc *i18n.Localizer, unique string, purchaseID int64, paymentURL string) *tele.ReplyMarkup {
+	menu := &tele.ReplyMarkup{}
+
+	confirmBtn := menu.Data(loc.T("button.pay_confirm"), unique, fmt.Sprint(purchaseID))
+	if paymentURL == "" {
+		menu.Inline(menu.Row(confirmBtn))
+		return menu
+	}
+
+	payBtn := menu.URL(loc.T("button.pay"), paymentURL)
+	menu.Inline(menu.Row(payBtn), menu.Row(confirmBtn))
 
 	return menu
 }
@@ -4,4 +4,23 @@ const (
 	MyUniqueCallback      = "my"
 	CatalogUniqueCallback = "catalog"
 	BuyUniqueCallback     = "buy"
+	BuyBalanceCallback    = "buy_balance"
+	PromoUniqueCallback   = "promo"
+	PayConfirmCallback    = "pay_confirm"
+	RefundUniqueCallback  = "refund"
+	RefundConfirmCallback = "refund_confirm"
+
+	ArtifactUniqueCallback = "artifact"
+	ArtifactLinkCallback   = "artifact_link"
+
+	RotateKeyCallback        = "rotate_key"
+	RotateKeyConfirmCallback = "rotate_key_confirm"
+
+	GroupBuyUniqueCallback     = "group_buy"
+	GroupSeatsUniqueCallback   = "group_seats"
+	GroupPoolUniqueCallback    = "group_pool"
+	GroupReleaseUniqueCallback = "group_release"
+	GroupPayConfirmCallback    = "group_pay_confirm"
+
+	LanguageUniqueCallback = "language"
 )
@@ -0,0 +1,56 @@
+package keyboards
+
+import (
+	"fmt"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/i18n"
+	"github.com/GeorgeTyupin/labguard/internal/bot/models"
+	tele "gopkg.in/telebot.v4"
+)
+
+// SeatsOptions варианты числа мест при покупке групповой лицензии
+var SeatsOptions = []int{5, 10, 15, 20, 25, 30}
+
+func NewSeatsCountMenu(loc *i18n.Localizer, productID int64) *tele.ReplyMarkup {
+	menu := &tele.ReplyMarkup{}
+
+	btns := make([]tele.Btn, 0, len(SeatsOptions))
+	for _, seats := range SeatsOptions {
+		btnText := loc.T("unit.seats", i18n.Args{i18n.CountArg: seats})
+		btns = append(btns, menu.Data(btnText, GroupSeatsUniqueCallback, fmt.Sprint(productID), fmt.Sprint(seats)))
+	}
+
+	menu.Inline(menu.Split(3, btns)...)
+
+	return menu
+}
+
+func NewSeatPoolsMenu(loc *i18n.Localizer, pools []*models.SeatPool) *tele.ReplyMarkup {
+	menu := &tele.ReplyMarkup{}
+
+	rows := make([]tele.Row, 0, len(pools))
+	for _, pool := range pools {
+		btnText := loc.T("button.pool", i18n.Args{"Product": pool.ProductName, "Used": pool.Used, "Seats": pool.Seats})
+		rows = append(rows, menu.Row(menu.Data(btnText, GroupPoolUniqueCallback, fmt.Sprint(pool.ID))))
+	}
+
+	menu.Inline(rows...)
+
+	return menu
+}
+
+// NewSeatMembersMenu кнопки освобождения мест студентов пула
+func NewSeatMembersMenu(loc *i18n.Localizer, pool *models.SeatPool) *tele.ReplyMarkup {
+	menu := &tele.ReplyMarkup{}
+
+	rows := make([]tele.Row, 0, len(pool.Members))
+	for _, member := range pool.Members {
+		btnText := loc.T("button.release", i18n.Args{"Name": member.Name})
+		btn := menu.Data(btnText, GroupReleaseUniqueCallback, fmt.Sprint(pool.ID), fmt.Sprint(member.TelegramID))
+		rows = append(rows, menu.Row(btn))
+	}
+
+	menu.Inline(rows...)
+
+	return menu
+}
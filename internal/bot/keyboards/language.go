@@ -0,0 +1,19 @@
+package keyboards
+
+import (
+	"github.com/GeorgeTyupin/labguard/internal/bot/i18n"
+	tele "gopkg.in/telebot.v4"
+)
+
+// NewLanguageMenu кнопки выбора языка, каждая подписана на своем языке
+func NewLanguageMenu(locales []*i18n.Localizer) *tele.ReplyMarkup {
+	menu := &tele.ReplyMarkup{}
+
+	btns := make([]tele.Btn, 0, len(locales))
+	for _, l := range locales {
+		btns = append(btns, menu.Data(l.Name, LanguageUniqueCallback, l.Locale))
+	}
+	menu.Inline(menu.Row(btns...))
+
+	return menu
+}
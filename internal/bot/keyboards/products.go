@@ -3,13 +3,39 @@ package keyboards
 import (
 	"fmt"
 
+	"github.com/GeorgeTyupin/labguard/internal/bot/i18n"
 	"github.com/GeorgeTyupin/labguard/internal/bot/models"
 	tele "gopkg.in/telebot.v4"
 )
 
-func NewProductsMenu(products []*models.Product, purchased bool) *tele.ReplyMarkup {
+func NewProductsMenu(loc *i18n.Localizer, products []*models.Product, purchased bool) *tele.ReplyMarkup {
 	menu := &tele.ReplyMarkup{}
+	menu.Inline(productRows(loc, menu, products, purchased)...)
 
+	return menu
+}
+
+// NewMyMenu купленные продукты и управление лицензионным ключом
+func NewMyMenu(loc *i18n.Localizer, products []*models.Product) *tele.ReplyMarkup {
+	menu := &tele.ReplyMarkup{}
+
+	rows := productRows(loc, menu, products, true)
+	rows = append(rows, menu.Row(menu.Data(loc.T("button.rotate_key"), RotateKeyCallback)))
+	menu.Inline(rows...)
+
+	return menu
+}
+
+func NewRotateKeyConfirmMenu(loc *i18n.Localizer) *tele.ReplyMarkup {
+	menu := &tele.ReplyMarkup{}
+
+	confirmBtn := menu.Data(loc.T("button.rotate_confirm"), RotateKeyConfirmCallback)
+	menu.Inline(menu.Row(confirmBtn))
+
+	return menu
+}
+
+func productRows(loc *i18n.Localizer, menu *tele.ReplyMarkup, products []*models.Product, purchased bool) []tele.Row {
 	productsBtnList := make([]tele.Row, 0, len(products))
 
 	for i, product := range products {
@@ -19,17 +45,15 @@ func NewProductsMenu(products []*models.Product, purchased bool) *tele.ReplyMark
 
 		var btn tele.Btn
 		if purchased {
-			btnText := fmt.Sprintf("%s. Куплено ✅", product.Name)
+			btnText := loc.T("button.purchased", i18n.Args{"Name": product.Name})
 			btn = menu.Data(btnText, MyUniqueCallback, fmt.Sprint(i))
 		} else {
-			btnText := fmt.Sprintf("%s за %.0f₽", product.Name, product.Price)
+			btnText := loc.T("button.product", i18n.Args{"Name": product.Name, "Price": product.Price})
 			btn = menu.Data(btnText, CatalogUniqueCallback, fmt.Sprint(i))
 
 		}
 		productsBtnList = append(productsBtnList, menu.Row(btn))
 	}
 
-	menu.Inline(productsBtnList...)
-
-	return menu
+	return productsBtnList
 }
@@ -0,0 +1,36 @@
+package keyboards
+
+import (
+	"fmt"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/i18n"
+	tele "gopkg.in/telebot.v4"
+)
+
+// NewMyProductMenu кнопки под купленным продуктом в /my. Кнопки скачивания
+// показываются, если у продукта есть материалы.
+func NewMyProductMenu(loc *i18n.Localizer, productID int64, hasArtifact bool) *tele.ReplyMarkup {
+	menu := &tele.ReplyMarkup{}
+	data := fmt.Sprint(productID)
+
+	var rows []tele.Row
+	if hasArtifact {
+		rows = append(rows, menu.Row(
+			menu.Data(loc.T("button.artifact"), ArtifactUniqueCallback, data),
+			menu.Data(loc.T("button.artifact_link"), ArtifactLinkCallback, data),
+		))
+	}
+	rows = append(rows, menu.Row(menu.Data(loc.T("button.refund"), RefundUniqueCallback, data)))
+	menu.Inline(rows...)
+
+	return menu
+}
+
+func NewRefundConfirmMenu(loc *i18n.Localizer, productID int64) *tele.ReplyMarkup {
+	menu := &tele.ReplyMarkup{}
+
+	confirmBtn := menu.Data(loc.T("button.refund_confirm"), RefundConfirmCallback, fmt.Sprint(productID))
+	menu.Inline(menu.Row(confirmBtn))
+
+	return menu
+}
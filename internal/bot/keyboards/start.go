@@ -1,19 +1,15 @@
 package keyboards
 
 import (
+	"github.com/GeorgeTyupin/labguard/internal/bot/i18n"
 	tele "gopkg.in/telebot.v4"
 )
 
-const (
-	YesText = "✅ Да"
-	NoText  = "❌ Нет"
-)
-
-func NewYesNoMenu() *tele.ReplyMarkup {
+func NewYesNoMenu(loc *i18n.Localizer) *tele.ReplyMarkup {
 	menu := &tele.ReplyMarkup{ResizeKeyboard: true}
 
-	yesBtn := menu.Text(YesText)
-	noBtn := menu.Text(NoText)
+	yesBtn := menu.Text(loc.T("button.yes"))
+	noBtn := menu.Text(loc.T("button.no"))
 
 	menu.Reply(
 		menu.Row(yesBtn, noBtn),
@@ -0,0 +1,157 @@
+// Package metrics метрики бота в формате Prometheus. Бот не принимает HTTP запросов,
+// поэтому метрики отдаются отдельным служебным listener.
+package metrics
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	tele "gopkg.in/telebot.v4"
+)
+
+const namespace = "labguard_bot"
+
+// idSegment числовые сегменты пути заменяются на {id}, чтобы telegram_id не попадали в метки
+var idSegment = regexp.MustCompile(`/\d+(/|$)`)
+
+type Metrics struct {
+	registry        *prometheus.Registry
+	updates         *prometheus.CounterVec
+	handlerErrors   *prometheus.CounterVec
+	handlerDuration *prometheus.HistogramVec
+	cacheLookups    *prometheus.CounterVec
+	apiDuration     *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		updates: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "updates_total",
+			Help:      "Обработанные обновления Telegram по обработчику.",
+		}, []string{"handler"}),
+		handlerErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "handler_errors_total",
+			Help:      "Ошибки, которые вернули обработчики.",
+		}, []string{"handler"}),
+		handlerDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "handler_duration_seconds",
+			Help:      "Время обработки обновления.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"handler"}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_lookups_total",
+			Help:      "Чтения из кешей бота: hit или miss.",
+		}, []string{"cache", "result"}),
+		apiDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "api",
+			Name:      "request_duration_seconds",
+			Help:      "Время запросов бота к API сервера.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "path", "status"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.updates,
+		m.handlerErrors,
+		m.handlerDuration,
+		m.cacheLookups,
+		m.apiDuration,
+	)
+
+	return m
+}
+
+// Handler отдает метрики в формате Prometheus
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Instrument оборачивает обработчик обновлений и считает его вызовы, ошибки и время работы
+func (m *Metrics) Instrument(name string, handler tele.HandlerFunc) tele.HandlerFunc {
+	return func(c tele.Context) error {
+		start := time.Now()
+
+		err := handler(c)
+
+		m.updates.WithLabelValues(name).Inc()
+		m.handlerDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+		if err != nil {
+			m.handlerErrors.WithLabelValues(name).Inc()
+		}
+
+		return err
+	}
+}
+
+// CacheObserver функция для state.Values.Observe
+func (m *Metrics) CacheObserver(cache string) func(hit bool) {
+	hits := m.cacheLookups.WithLabelValues(cache, "hit")
+	misses := m.cacheLookups.WithLabelValues(cache, "miss")
+
+	return func(hit bool) {
+		if hit {
+			hits.Inc()
+		} else {
+			misses.Inc()
+		}
+	}
+}
+
+// Transport замеряет время запросов к API сервера
+func (m *Metrics) Transport(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+
+		resp, err := next.RoundTrip(req)
+
+		status := "error"
+		if err == nil {
+			status = strconv.Itoa(resp.StatusCode)
+		}
+		m.apiDuration.WithLabelValues(req.Method, normalizePath(req.URL.Path), status).
+			Observe(time.Since(start).Seconds())
+
+		return resp, err
+	})
+}
+
+// HandlerName имя обработчика для меток: команда, событие telebot или Unique кнопки
+func HandlerName(endpoint any) string {
+	switch e := endpoint.(type) {
+	case string:
+		return strings.TrimLeft(e, "\a\f")
+	case *tele.Btn:
+		return e.Unique
+	}
+
+	return "unknown"
+}
+
+func normalizePath(path string) string {
+	// Повторяем замену, потому что соседние сегменты делят между собой слеш
+	for idSegment.MatchString(path) {
+		path = idSegment.ReplaceAllString(path, "/{id}$1")
+	}
+
+	return path
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,107 @@
+package antiflood
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// cleanupInterval как часто удаляются состояния пользователей, которые давно ничего не присылали
+const cleanupInterval = 10 * time.Minute
+
+type userState struct {
+	tokens       float64
+	updatedAt    time.Time
+	blockedUntil time.Time
+	idleAt       time.Time // После этого момента корзина полная и состояние можно забыть
+	warned       bool
+}
+
+// MemoryStore счетчики в памяти процесса. Подходит для одной реплики бота.
+type MemoryStore struct {
+	mu    sync.Mutex
+	users map[int64]*userState
+	now   func() time.Time
+
+	done chan struct{}
+	once sync.Once
+}
+
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{
+		users: make(map[int64]*userState),
+		now:   time.Now,
+		done:  make(chan struct{}),
+	}
+
+	go s.cleanup()
+
+	return s
+}
+
+func (s *MemoryStore) Allow(_ context.Context, telegramID int64, limits Limits) (allowed, warn bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	burst := float64(limits.Burst)
+
+	state, ok := s.users[telegramID]
+	if !ok {
+		state = &userState{tokens: burst, updatedAt: now}
+		s.users[telegramID] = state
+	}
+
+	if now.Before(state.blockedUntil) {
+		return false, false, nil
+	}
+
+	state.tokens = min(burst, state.tokens+float64(now.Sub(state.updatedAt))/float64(limits.Refill))
+	state.updatedAt = now
+	state.idleAt = now.Add(time.Duration(burst) * limits.Refill)
+
+	if state.tokens >= 1 {
+		state.tokens--
+		state.warned = false
+		return true, false, nil
+	}
+
+	state.blockedUntil = now.Add(limits.Cooldown)
+	warn = !state.warned
+	state.warned = true
+
+	return false, warn, nil
+}
+
+func (s *MemoryStore) Stop() {
+	s.once.Do(func() {
+		close(s.done)
+	})
+}
+
+func (s *MemoryStore) cleanup() {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.removeIdle()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) removeIdle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for id, state := range s.users {
+		// Корзина уже наполнилась, состояние ничем не отличается от нового
+		if now.After(state.idleAt) && now.After(state.blockedUntil) {
+			delete(s.users, id)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package antiflood
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// allowScript тот же алгоритм, что в MemoryStore, одним скриптом, чтобы реплики
+// не затирали изменения друг друга. Время берется у сервера Redis, а не у реплик,
+// у которых часы могут расходиться.
+var allowScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local burst = tonumber(ARGV[1])
+local refill = tonumber(ARGV[2])
+local cooldown = tonumber(ARGV[3])
+
+local s = redis.call('HMGET', KEYS[1], 'tokens', 'updated', 'blocked', 'warned')
+local tokens = tonumber(s[1]) or burst
+local updated = tonumber(s[2]) or now
+local blocked = tonumber(s[3]) or 0
+local warned = s[4] == '1'
+
+if now < blocked then
+	return {0, 0}
+end
+
+tokens = math.min(burst, tokens + (now - updated) / refill)
+
+local allowed, warn = 0, 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	warned = false
+	allowed = 1
+else
+	blocked = now + cooldown
+	if not warned then
+		warn = 1
+	end
+	warned = true
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', now, 'blocked', blocked, 'warned', warned and '1' or '0')
+redis.call('PEXPIRE', KEYS[1], math.max(burst * refill, cooldown) + 1000)
+
+return {allowed, warn}
+`)
+
+// RedisStore счетчики на сервере Redis, общие для всех реплик бота. Ключ удаляется
+// сам, когда корзина пользователя заведомо наполнилась.
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisStore(client *redis.Client, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (s *RedisStore) Allow(ctx context.Context, telegramID int64, limits Limits) (allowed, warn bool, err error) {
+	const op = "antiflood.RedisStore.Allow"
+
+	key := s.prefix + "antiflood:" + strconv.FormatInt(telegramID, 10)
+	result, err := allowScript.Run(ctx, s.client, []string{key},
+		limits.Burst, limits.Refill.Milliseconds(), limits.Cooldown.Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return false, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result[0] == 1, result[1] == 1, nil
+}
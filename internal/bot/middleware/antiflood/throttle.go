@@ -0,0 +1,98 @@
+package antiflood
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/i18n"
+	"github.com/GeorgeTyupin/labguard/internal/bot/middleware/loggers"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Limits параметры ограничения для одного пользователя
+type Limits struct {
+	Burst    int
+	Refill   time.Duration
+	Cooldown time.Duration
+}
+
+// Store хранилище счетчиков пользователей
+type Store interface {
+	// Allow решает, обрабатывать ли обновление, и нужно ли предупредить пользователя
+	Allow(ctx context.Context, telegramID int64, limits Limits) (allowed, warn bool, err error)
+}
+
+// Throttler ограничивает частоту обновлений от одного пользователя. Пользователь может
+// прислать Burst сообщений подряд, дальше новые токены копятся по одному раз в Refill.
+// Когда токены кончились, пользователь получает одно предупреждение и Cooldown
+// его обновления не обрабатываются, чтобы не отвечать на флуд флудом.
+type Throttler struct {
+	store  Store
+	logger *slog.Logger
+
+	mu     sync.RWMutex
+	limits Limits
+}
+
+func NewThrottler(store Store, limits Limits, logger *slog.Logger) *Throttler {
+	return &Throttler{
+		store:  store,
+		limits: limits,
+		logger: logger.With(slog.String("component", "antiflood")),
+	}
+}
+
+// SetLimits меняет лимиты при перезагрузке конфига. Накопленные токены пользователей
+// сохраняются и при следующем обновлении обрезаются до нового burst.
+func (t *Throttler) SetLimits(limits Limits) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.limits = limits
+}
+
+func (t *Throttler) Middleware() tele.MiddlewareFunc {
+	return func(next tele.HandlerFunc) tele.HandlerFunc {
+		return func(c tele.Context) error {
+			sender := c.Sender()
+			if sender == nil {
+				return next(c)
+			}
+
+			ctx := loggers.Context(c)
+
+			t.mu.RLock()
+			limits := t.limits
+			t.mu.RUnlock()
+
+			allowed, warn, err := t.store.Allow(ctx, sender.ID, limits)
+			if err != nil {
+				// Недоступное хранилище не должно останавливать бота, пропускаем без ограничения
+				t.logger.WarnContext(ctx, "Не удалось проверить частоту обновлений", slog.String("error", err.Error()))
+				return next(c)
+			}
+			if allowed {
+				return next(c)
+			}
+
+			if !warn {
+				// Предупреждение уже отправлено, на кнопки отвечаем, чтобы не крутился индикатор
+				if c.Callback() != nil {
+					return c.Respond()
+				}
+				return nil
+			}
+
+			t.logger.InfoContext(ctx, "Пользователь ограничен за флуд", slog.Int64("telegram_id", sender.ID))
+
+			floodMessage := i18n.From(c).T("antiflood.too_often")
+			if c.Callback() != nil {
+				return c.Respond(&tele.CallbackResponse{Text: floodMessage, ShowAlert: true})
+			}
+
+			return c.Send(floodMessage)
+		}
+	}
+}
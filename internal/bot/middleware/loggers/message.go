@@ -7,22 +7,24 @@ import (
 	tele "gopkg.in/telebot.v4"
 )
 
+// MessageLogger пишет по записи на каждое обновление. Должен стоять после RequestID.
 func MessageLogger(logger *slog.Logger) tele.MiddlewareFunc {
 	return func(next tele.HandlerFunc) tele.HandlerFunc {
 		return func(c tele.Context) error {
-			logger.Debug("Обработка сообщения")
+			ctx := Context(c)
+			logger.DebugContext(ctx, "Обработка сообщения")
 			start := time.Now()
 
 			err := next(c)
 			duration := time.Since(start)
 			if err != nil {
-				logger.Error("Ошибка обработки",
+				logger.ErrorContext(ctx, "Ошибка обработки",
 					slog.Int64("telegram_id", c.Sender().ID),
 					slog.String("error", err.Error()),
 					slog.Duration("duration", duration),
 				)
 			} else {
-				logger.Info("Сообщение обработано",
+				logger.InfoContext(ctx, "Сообщение обработано",
 					slog.Int64("telegram_id", c.Sender().ID),
 					slog.String("user_message", c.Text()),
 					slog.Duration("duration", duration),
@@ -0,0 +1,35 @@
+package loggers
+
+import (
+	"context"
+
+	"github.com/GeorgeTyupin/labguard/pkg/logging"
+	tele "gopkg.in/telebot.v4"
+)
+
+const contextKey = "context"
+
+// RequestID выдает каждому обновлению Telegram свой id запроса. По нему связываются
+// записи бота и сервера: id уходит на сервер в заголовке X-Request-Id.
+func RequestID() tele.MiddlewareFunc {
+	return func(next tele.HandlerFunc) tele.HandlerFunc {
+		return func(c tele.Context) error {
+			SetContext(c, logging.WithRequestID(context.Background(), logging.NewRequestID()))
+			return next(c)
+		}
+	}
+}
+
+// Context контекст обработки обновления с его id запроса и спаном, для запросов к API и логов
+func Context(c tele.Context) context.Context {
+	if ctx, ok := c.Get(contextKey).(context.Context); ok {
+		return ctx
+	}
+
+	return context.Background()
+}
+
+// SetContext заменяет контекст обновления, например после открытия спана
+func SetContext(c tele.Context, ctx context.Context) {
+	c.Set(contextKey, ctx)
+}
@@ -0,0 +1,37 @@
+package tracing
+
+import (
+	"github.com/GeorgeTyupin/labguard/internal/bot/middleware/loggers"
+	pkgtracing "github.com/GeorgeTyupin/labguard/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	tele "gopkg.in/telebot.v4"
+)
+
+var tracer = pkgtracing.Tracer("labguard/bot")
+
+// Handler открывает спан на обработку обновления обработчиком name. Спан попадает
+// в контекст обновления, поэтому запросы к API сервера становятся его дочерними спанами.
+func Handler(name string, handler tele.HandlerFunc) tele.HandlerFunc {
+	return func(c tele.Context) error {
+		ctx, span := tracer.Start(loggers.Context(c), "telegram "+name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("telegram.handler", name),
+				attribute.Int("telegram.update_id", c.Update().ID),
+			),
+		)
+		defer span.End()
+
+		loggers.SetContext(c, ctx)
+
+		err := handler(c)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		return err
+	}
+}
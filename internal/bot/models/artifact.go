@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Artifact версия материалов продукта, которую может скачать покупатель
+type Artifact struct {
+	ID          int64  `json:"id"`
+	ProductID   int64  `json:"product_id"`
+	Version     int    `json:"version"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Notes       string `json:"notes"`
+}
+
+// ArtifactLink временная ссылка на скачивание материалов с сервера
+type ArtifactLink struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Version   int       `json:"version"`
+	FileName  string    `json:"file_name"`
+}
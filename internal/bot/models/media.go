@@ -0,0 +1,23 @@
+package models
+
+// Виды файлов в карточке продукта
+const (
+	MediaCover      = "cover"
+	MediaScreenshot = "screenshot"
+	MediaSample     = "sample"
+)
+
+// ProductMedia файл карточки продукта на сервере
+type ProductMedia struct {
+	ID          int64  `json:"id"`
+	ProductID   int64  `json:"product_id"`
+	Kind        string `json:"kind"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+// IsPhoto обложка и скриншоты показываются альбомом, пример работы — документом
+func (m *ProductMedia) IsPhoto() bool {
+	return m.Kind == MediaCover || m.Kind == MediaScreenshot
+}
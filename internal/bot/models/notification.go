@@ -0,0 +1,7 @@
+package models
+
+type Notification struct {
+	ID         int64  `json:"id"`
+	TelegramID int64  `json:"telegram_id"`
+	Text       string `json:"text"`
+}
@@ -1,5 +1,7 @@
 package models
 
+const BillingSubscription = "subscription"
+
 type Product struct {
 	ID          int64
 	Name        string
@@ -7,4 +9,10 @@ type Product struct {
 	Price       float64
 	Purchased   bool
 	Link        string
+	BillingType string
+	PeriodDays  int // Длительность периода подписки в днях
+}
+
+func (p *Product) IsSubscription() bool {
+	return p.BillingType == BillingSubscription
 }
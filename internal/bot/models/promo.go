@@ -0,0 +1,10 @@
+package models
+
+// PromoQuote цена продукта с примененным промокодом, суммы в копейках
+type PromoQuote struct {
+	ProductID     int64  `json:"product_id"`
+	Code          string `json:"code"`
+	OriginalPrice int64  `json:"original_price"`
+	Discount      int64  `json:"discount"`
+	Price         int64  `json:"price"`
+}
@@ -0,0 +1,8 @@
+package models
+
+type ReferralStats struct {
+	ReferralCode string `json:"referral_code"`
+	Invited      int    `json:"invited"`
+	Rewarded     int    `json:"rewarded"`
+	TotalBonus   int64  `json:"total_bonus"` // В копейках
+}
@@ -0,0 +1,9 @@
+package models
+
+// Refund заявка на возврат, сумма в копейках
+type Refund struct {
+	ID        int64  `json:"id"`
+	ProductID int64  `json:"product_id"`
+	Status    string `json:"status"`
+	Amount    int64  `json:"amount"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// SeatPool групповая лицензия на продукт
+type SeatPool struct {
+	ID          int64        `json:"id"`
+	ProductID   int64        `json:"product_id"`
+	ProductName string       `json:"product_name"`
+	Group       string       `json:"group"`
+	Seats       int          `json:"seats"`
+	Used        int          `json:"used"`
+	InviteCode  string       `json:"invite_code"`
+	Members     []SeatMember `json:"members"`
+}
+
+type SeatMember struct {
+	TelegramID int64     `json:"telegram_id"`
+	Name       string    `json:"name"`
+	AssignedAt time.Time `json:"assigned_at"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Wallet внутренний баланс пользователя, суммы в копейках
+type Wallet struct {
+	Balance      int64         `json:"balance"`
+	Transactions []Transaction `json:"transactions"`
+}
+
+type Transaction struct {
+	Kind        string    `json:"kind"`
+	Description string    `json:"description"`
+	Amount      int64     `json:"amount"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+const PurchasePaid = "paid"
+
+// Purchase результат покупки, суммы в копейках. Неоплаченную покупку нужно оплатить
+// на странице PaymentURL и подтвердить. У покупки мест SeatPoolID — групповая лицензия,
+// которая открывается после оплаты.
+type Purchase struct {
+	ID          int64  `json:"id"`
+	ProductID   int64  `json:"product_id"`
+	Discount    int64  `json:"discount"`
+	Amount      int64  `json:"amount"`
+	BalanceUsed int64  `json:"balance_used"`
+	Status      string `json:"status"`
+	PaymentURL  string `json:"payment_url"`
+	Seats       int    `json:"seats"`
+	SeatPoolID  *int64 `json:"seat_pool_id"`
+}
+
+func (p *Purchase) IsPaid() bool {
+	return p.Status == PurchasePaid
+}
@@ -0,0 +1,118 @@
+// Package render собирает тексты сообщений из шаблонов text/template с разметкой
+// Telegram. Данные, которые подставляет шаблон, экранируются автоматически под режим
+// разметки, поэтому имя пользователя или описание продукта с символами разметки
+// не ломают сообщение.
+package render
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	tele "gopkg.in/telebot.v4"
+)
+
+// escapeFunc имя функции, которую Parse дописывает в конец каждого вывода шаблона
+const escapeFunc = "_escape"
+
+// Parse разбирает шаблон text и дописывает экранирование для mode к каждому
+// {{...}}, который что-то выводит. Текст самого шаблона не экранируется: в нем
+// автор пишет разметку. Для tele.ModeDefault шаблон разбирается как есть.
+func Parse(name, text string, mode tele.ParseMode, funcs template.FuncMap) (*template.Template, error) {
+	escape, err := Escaper(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(funcs).
+		Funcs(template.FuncMap{escapeFunc: func(args ...any) string { return escape(fmt.Sprint(args...)) }}).
+		Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode != tele.ModeDefault {
+		for _, t := range tmpl.Templates() {
+			if t.Tree != nil {
+				escapeList(t.Tree.Root)
+			}
+		}
+	}
+
+	return tmpl, nil
+}
+
+// Escaper экранирование строки для режима разметки
+func Escaper(mode tele.ParseMode) (func(string) string, error) {
+	switch mode {
+	case tele.ModeDefault:
+		return func(s string) string { return s }, nil
+	case tele.ModeHTML:
+		return EscapeHTML, nil
+	case tele.ModeMarkdownV2:
+		return EscapeMarkdownV2, nil
+	default:
+		return nil, fmt.Errorf("режим разметки %q не поддерживается", mode)
+	}
+}
+
+// escapeList обходит дерево шаблона так же, как html/template: выводят текст
+// только действия, условия if, range и with выводом не являются
+func escapeList(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.ActionNode:
+			// {{$x := ...}} ничего не выводит
+			if len(n.Pipe.Decl) > 0 {
+				continue
+			}
+			n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+				NodeType: parse.NodeCommand,
+				Pos:      n.Pos,
+				Args:     []parse.Node{parse.NewIdentifier(escapeFunc).SetPos(n.Pos)},
+			})
+		case *parse.IfNode:
+			escapeList(n.List)
+			escapeList(n.ElseList)
+		case *parse.RangeNode:
+			escapeList(n.List)
+			escapeList(n.ElseList)
+		case *parse.WithNode:
+			escapeList(n.List)
+			escapeList(n.ElseList)
+		}
+	}
+}
+
+// htmlEscaper символы, которые Telegram требует заменять в режиме HTML
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// EscapeHTML экранирует данные для сообщений с разметкой HTML
+func EscapeHTML(s string) string {
+	return htmlEscaper.Replace(s)
+}
+
+// markdownV2Special символы, которые в MarkdownV2 нужно экранировать вне разметки
+const markdownV2Special = "\\_*[]()~`>#+-=|{}.!"
+
+// EscapeMarkdownV2 экранирует данные для сообщений с разметкой MarkdownV2
+func EscapeMarkdownV2(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+
+	for _, r := range s {
+		if strings.ContainsRune(markdownV2Special, r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,97 @@
+package render
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tele "gopkg.in/telebot.v4"
+)
+
+var update = flag.Bool("update", false, "перезаписать testdata/*.golden")
+
+// hostile данные пользователя и сервера, которые пытаются сломать разметку
+var hostile = map[string]any{
+	"Name":     `<script>alert("x")</script>`,
+	"Group":    `ИУ7 & <b>31</b>`,
+	"Title":    `</a><a href="https://evil.example">`,
+	"URL":      `https://labguard.example/d?token=a&b="c"`,
+	"Items":    []string{`<i>один</i>`, `"два" & три`},
+	"Empty":    "",
+	"Markdown": `_*[ссылка](https://evil.example)~` + "`code`" + `>#+-=|{}.!\`,
+}
+
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("запись %s: %v", path, err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("чтение %s: %v (перезапишите эталоны флагом -update)", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s не совпадает с эталоном\nполучено:\n%s\nожидалось:\n%s", path, got, want)
+	}
+}
+
+func TestParseGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		mode tele.ParseMode
+		text string
+	}{
+		{
+			name: "html_text",
+			mode: tele.ModeHTML,
+			text: "<b>{{.Name}}</b> из группы {{.Group}} &amp; друзья\n",
+		},
+		{
+			name: "html_link",
+			mode: tele.ModeHTML,
+			text: `<a href="{{.URL}}">{{.Title}}</a>` + "\n",
+		},
+		{
+			name: "html_control",
+			mode: tele.ModeHTML,
+			text: "{{if .Name}}<i>{{.Name}}</i>{{else}}пусто{{end}}\n" +
+				"{{range $i, $item := .Items}}{{$i}}. {{$item}}\n{{end}}" +
+				"{{with .Empty}}{{.}}{{else}}{{$g := .Group}}{{$g}}{{end}}\n" +
+				"{{printf \"%s!\" .Name}}\n",
+		},
+		{
+			name: "markdown_v2",
+			mode: tele.ModeMarkdownV2,
+			text: "*{{.Markdown}}* и {{.Name}}\n",
+		},
+		{
+			name: "default",
+			mode: tele.ModeDefault,
+			text: "{{.Name}} {{.Group}}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Parse(tt.name, tt.text, tt.mode, nil)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, hostile); err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+
+			assertGolden(t, tt.name, buf.Bytes())
+		})
+	}
+}
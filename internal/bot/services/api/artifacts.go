@@ -0,0 +1,50 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/models"
+)
+
+type artifactRequest struct {
+	TelegramID int64 `json:"telegram_id"`
+}
+
+// GetArtifact последняя версия материалов продукта, доступная покупателю
+func (client *HttpClient) GetArtifact(ctx context.Context, telegramID, productID int64) (*models.Artifact, error) {
+	var artifact models.Artifact
+
+	path := fmt.Sprintf("/api/v1/bot/products/%d/artifact?telegram_id=%d", productID, telegramID)
+	if err := client.doJSON(ctx, http.MethodGet, path, nil, &artifact); err != nil {
+		return nil, err
+	}
+
+	return &artifact, nil
+}
+
+// DownloadArtifact копия материалов с меткой покупателя, вызывающий закрывает ее
+func (client *HttpClient) DownloadArtifact(ctx context.Context, telegramID, productID int64) (io.ReadCloser, error) {
+	path := fmt.Sprintf("/api/v1/bot/products/%d/artifact/download", productID)
+
+	resp, err := client.do(ctx, client.downloads, http.MethodPost, path, artifactRequest{TelegramID: telegramID})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// ArtifactLink временная ссылка на скачивание материалов с сервера
+func (client *HttpClient) ArtifactLink(ctx context.Context, telegramID, productID int64) (*models.ArtifactLink, error) {
+	var link models.ArtifactLink
+
+	path := fmt.Sprintf("/api/v1/bot/products/%d/artifact/link", productID)
+	if err := client.doJSON(ctx, http.MethodPost, path, artifactRequest{TelegramID: telegramID}, &link); err != nil {
+		return nil, err
+	}
+
+	return &link, nil
+}
@@ -1,32 +1,93 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/GeorgeTyupin/labguard/internal/bot/config"
 	"github.com/GeorgeTyupin/labguard/internal/bot/models"
+	"github.com/GeorgeTyupin/labguard/pkg/logging"
 )
 
-// TODO Реализовать http клиент
+const (
+	requestTimeout  = 10 * time.Second
+	downloadTimeout = 2 * time.Minute // Файлы продуктов до 50 МБ
+)
+
+// TokenSource выпускает токены, которыми подписываются запросы к серверу
+type TokenSource interface {
+	NewToken() (string, error)
+}
 
 type HttpClient struct {
-	Client *http.Client
+	Client    *http.Client
+	downloads *http.Client // Для файлов, которым не хватит requestTimeout
+	baseURL   string
+	tokens    TokenSource
 }
 
-func NewHttpClient() *HttpClient {
-	return &HttpClient{}
+// NewHttpClient клиент API сервера. transport может быть nil, тогда используется http.DefaultTransport.
+func NewHttpClient(cfg *config.Config, tokens TokenSource, transport http.RoundTripper) *HttpClient {
+	return &HttpClient{
+		Client:    &http.Client{Timeout: requestTimeout, Transport: transport},
+		downloads: &http.Client{Timeout: downloadTimeout, Transport: transport},
+		baseURL:   strings.TrimRight(cfg.Client.ServerAddress, "/"),
+		tokens:    tokens,
+	}
 }
 
-func (client *HttpClient) CheckUserExists(uuid int64) (bool, error) {
-	// TODO Реализовать этот метод после написания сервера
-	return false, nil
+func (client *HttpClient) CheckUserExists(ctx context.Context, uuid int64) (bool, error) {
+	var resp struct {
+		Exists bool `json:"exists"`
+	}
+
+	path := fmt.Sprintf("/api/v1/bot/users/%d", uuid)
+	if err := client.doJSON(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return false, err
+	}
+
+	return resp.Exists, nil
 }
 
-func (client *HttpClient) RegisterUser(uuid int64, name, group string) (string, error) {
-	// TODO Реализовать этот метод после написания сервера
-	return "", nil
+func (client *HttpClient) RegisterUser(ctx context.Context, uuid int64, name, group, referralCode string) (string, error) {
+	body := struct {
+		TelegramID   int64  `json:"telegram_id"`
+		Name         string `json:"name"`
+		Group        string `json:"group"`
+		ReferralCode string `json:"referral_code,omitempty"`
+	}{TelegramID: uuid, Name: name, Group: group, ReferralCode: referralCode}
+
+	var resp struct {
+		LicenseKey string `json:"license_key"`
+	}
+	if err := client.doJSON(ctx, http.MethodPost, "/api/v1/bot/register", body, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.LicenseKey, nil
 }
 
-func (client *HttpClient) GetProducts(uuid int64) ([]*models.Product, error) {
+// RotateLicenseKey перевыпускает лицензионный ключ пользователя и возвращает новый
+func (client *HttpClient) RotateLicenseKey(ctx context.Context, telegramID int64) (string, error) {
+	var resp struct {
+		LicenseKey string `json:"license_key"`
+	}
+
+	path := fmt.Sprintf("/api/v1/bot/users/%d/license-key", telegramID)
+	if err := client.doJSON(ctx, http.MethodPost, path, nil, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.LicenseKey, nil
+}
+
+func (client *HttpClient) GetProducts(ctx context.Context, uuid int64) ([]*models.Product, error) {
 	// TODO Реализовать реальный запрос для получения списка продуктов
 
 	// Мок списка продуктов
@@ -52,8 +113,96 @@ func (client *HttpClient) GetProducts(uuid int64) ([]*models.Product, error) {
 	}, nil
 }
 
-func (client *HttpClient) BuyProduct(uuid int64, productID int64) error {
-	// TODO Реализовать этот метод после написания сервера
+func (client *HttpClient) BuyProduct(ctx context.Context, uuid int64, productID int64, promoCode string, useBalance bool) (*models.Purchase, error) {
+	body := struct {
+		TelegramID int64  `json:"telegram_id"`
+		ProductID  int64  `json:"product_id"`
+		PromoCode  string `json:"promo_code,omitempty"`
+		UseBalance bool   `json:"use_balance"`
+	}{TelegramID: uuid, ProductID: productID, PromoCode: promoCode, UseBalance: useBalance}
+
+	var purchase models.Purchase
+	if err := client.doJSON(ctx, http.MethodPost, "/api/v1/bot/purchases", body, &purchase); err != nil {
+		return nil, err
+	}
+
+	return &purchase, nil
+}
+
+// ConfirmPurchase просит сервер проверить оплату покупки у платежного провайдера
+func (client *HttpClient) ConfirmPurchase(ctx context.Context, telegramID, purchaseID int64) (*models.Purchase, error) {
+	body := struct {
+		TelegramID int64 `json:"telegram_id"`
+	}{TelegramID: telegramID}
+
+	var purchase models.Purchase
+	path := fmt.Sprintf("/api/v1/bot/purchases/%d/confirm", purchaseID)
+	if err := client.doJSON(ctx, http.MethodPost, path, body, &purchase); err != nil {
+		return nil, err
+	}
+
+	return &purchase, nil
+}
+
+// doJSON выполняет запрос к bot API сервера, подписывая его jwt бота.
+// body и out могут быть nil, если у запроса или ответа нет тела.
+func (client *HttpClient) doJSON(ctx context.Context, method, path string, body, out any) error {
+	resp, err := client.do(ctx, client.Client, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("не удалось разобрать ответ %s %s: %w", method, path, err)
+	}
 
 	return nil
 }
+
+// do отправляет подписанный запрос и возвращает ответ с успешным статусом,
+// вызывающий закрывает его тело. Ответ с ошибкой превращается в *StatusError.
+func (client *HttpClient) do(ctx context.Context, httpClient *http.Client, method, path string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось сериализовать запрос: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, client.baseURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+
+	token, err := client.tokens.NewToken()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса %s %s: %w", method, path, err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		return nil, newStatusError(resp)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// StatusError ответ сервера с кодом ошибки
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("сервер вернул %d: %s", e.StatusCode, e.Message)
+}
+
+func newStatusError(resp *http.Response) *StatusError {
+	var body struct {
+		Error string `json:"error"`
+	}
+
+	// Тело может быть не json, тогда показываем только статус
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Error == "" {
+		body.Error = http.StatusText(resp.StatusCode)
+	}
+
+	return &StatusError{StatusCode: resp.StatusCode, Message: body.Error}
+}
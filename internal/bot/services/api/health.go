@@ -0,0 +1,28 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Ping проверяет, что сервер отвечает. Готовность сервера не спрашиваем: если у него
+// недоступна база, бот все равно может отвечать пользователям сообщением об ошибке.
+func (client *HttpClient) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, client.baseURL+"/health/live", nil)
+	if err != nil {
+		return fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("сервер недоступен: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError(resp)
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/models"
+)
+
+// GetProductMedia файлы карточки продукта: обложка, скриншоты, пример работы
+func (client *HttpClient) GetProductMedia(ctx context.Context, productID int64) ([]*models.ProductMedia, error) {
+	var media []*models.ProductMedia
+
+	path := fmt.Sprintf("/api/v1/bot/products/%d/media", productID)
+	if err := client.doJSON(ctx, http.MethodGet, path, nil, &media); err != nil {
+		return nil, err
+	}
+
+	return media, nil
+}
+
+// OpenMedia открывает содержимое файла с сервера, вызывающий закрывает его
+func (client *HttpClient) OpenMedia(ctx context.Context, mediaID int64) (io.ReadCloser, error) {
+	path := fmt.Sprintf("/api/v1/bot/media/%d", mediaID)
+
+	resp, err := client.do(ctx, client.downloads, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
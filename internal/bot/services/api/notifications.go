@@ -0,0 +1,28 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/models"
+)
+
+func (client *HttpClient) PendingNotifications(ctx context.Context, limit int) ([]*models.Notification, error) {
+	var notifications []*models.Notification
+
+	path := fmt.Sprintf("/api/v1/bot/notifications?limit=%d", limit)
+	if err := client.doJSON(ctx, http.MethodGet, path, nil, &notifications); err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+func (client *HttpClient) AckNotifications(ctx context.Context, ids []int64) error {
+	body := struct {
+		IDs []int64 `json:"ids"`
+	}{IDs: ids}
+
+	return client.doJSON(ctx, http.MethodPost, "/api/v1/bot/notifications/ack", body, nil)
+}
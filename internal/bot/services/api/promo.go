@@ -0,0 +1,23 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/models"
+)
+
+func (client *HttpClient) QuotePromo(ctx context.Context, telegramID, productID int64, code string) (*models.PromoQuote, error) {
+	body := struct {
+		TelegramID int64  `json:"telegram_id"`
+		ProductID  int64  `json:"product_id"`
+		Code       string `json:"code"`
+	}{TelegramID: telegramID, ProductID: productID, Code: code}
+
+	var quote models.PromoQuote
+	if err := client.doJSON(ctx, http.MethodPost, "/api/v1/bot/promo/quote", body, &quote); err != nil {
+		return nil, err
+	}
+
+	return &quote, nil
+}
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/models"
+)
+
+func (client *HttpClient) GetReferralStats(ctx context.Context, telegramID int64) (*models.ReferralStats, error) {
+	var stats models.ReferralStats
+
+	path := fmt.Sprintf("/api/v1/bot/referrals?telegram_id=%d", telegramID)
+	if err := client.doJSON(ctx, http.MethodGet, path, nil, &stats); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/models"
+)
+
+func (client *HttpClient) RequestRefund(ctx context.Context, telegramID, productID int64, reason string) (*models.Refund, error) {
+	body := struct {
+		TelegramID int64  `json:"telegram_id"`
+		ProductID  int64  `json:"product_id"`
+		Reason     string `json:"reason"`
+	}{TelegramID: telegramID, ProductID: productID, Reason: reason}
+
+	var refund models.Refund
+	if err := client.doJSON(ctx, http.MethodPost, "/api/v1/bot/refunds", body, &refund); err != nil {
+		return nil, err
+	}
+
+	return &refund, nil
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/models"
+)
+
+// BuySeats оформляет покупку групповой лицензии. Лицензия открывается после оплаты покупки.
+func (client *HttpClient) BuySeats(ctx context.Context, telegramID, productID int64, seats int) (*models.Purchase, error) {
+	body := struct {
+		TelegramID    int64 `json:"telegram_id"`
+		ProductID     int64 `json:"product_id"`
+		Seats         int   `json:"seats"`
+		RestrictGroup bool  `json:"restrict_group"`
+	}{
+		TelegramID:    telegramID,
+		ProductID:     productID,
+		Seats:         seats,
+		RestrictGroup: true, // Через бота места покупает староста для своей группы
+	}
+
+	var purchase models.Purchase
+	if err := client.doJSON(ctx, http.MethodPost, "/api/v1/bot/seat-pools", body, &purchase); err != nil {
+		return nil, err
+	}
+
+	return &purchase, nil
+}
+
+func (client *HttpClient) JoinSeatPool(ctx context.Context, telegramID int64, inviteCode string) (*models.SeatPool, error) {
+	body := struct {
+		TelegramID int64  `json:"telegram_id"`
+		InviteCode string `json:"invite_code"`
+	}{TelegramID: telegramID, InviteCode: inviteCode}
+
+	var pool models.SeatPool
+	if err := client.doJSON(ctx, http.MethodPost, "/api/v1/bot/seat-pools/join", body, &pool); err != nil {
+		return nil, err
+	}
+
+	return &pool, nil
+}
+
+func (client *HttpClient) GetSeatPools(ctx context.Context, telegramID int64) ([]*models.SeatPool, error) {
+	var pools []*models.SeatPool
+
+	path := fmt.Sprintf("/api/v1/bot/seat-pools?telegram_id=%d", telegramID)
+	if err := client.doJSON(ctx, http.MethodGet, path, nil, &pools); err != nil {
+		return nil, err
+	}
+
+	return pools, nil
+}
+
+func (client *HttpClient) GetSeatPool(ctx context.Context, telegramID, poolID int64) (*models.SeatPool, error) {
+	var pool models.SeatPool
+
+	path := fmt.Sprintf("/api/v1/bot/seat-pools/%d?telegram_id=%d", poolID, telegramID)
+	if err := client.doJSON(ctx, http.MethodGet, path, nil, &pool); err != nil {
+		return nil, err
+	}
+
+	return &pool, nil
+}
+
+func (client *HttpClient) ReleaseSeat(ctx context.Context, telegramID, poolID, memberTelegramID int64) error {
+	body := struct {
+		TelegramID       int64 `json:"telegram_id"`
+		MemberTelegramID int64 `json:"member_telegram_id"`
+	}{TelegramID: telegramID, MemberTelegramID: memberTelegramID}
+
+	path := fmt.Sprintf("/api/v1/bot/seat-pools/%d/release", poolID)
+
+	return client.doJSON(ctx, http.MethodPost, path, body, nil)
+}
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/models"
+)
+
+func (client *HttpClient) GetWallet(ctx context.Context, telegramID int64) (*models.Wallet, error) {
+	var wallet models.Wallet
+
+	path := fmt.Sprintf("/api/v1/bot/balance?telegram_id=%d", telegramID)
+	if err := client.doJSON(ctx, http.MethodGet, path, nil, &wallet); err != nil {
+		return nil, err
+	}
+
+	return &wallet, nil
+}
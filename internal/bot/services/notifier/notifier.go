@@ -0,0 +1,148 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/models"
+	"github.com/GeorgeTyupin/labguard/pkg/logging"
+	tele "gopkg.in/telebot.v4"
+)
+
+const batchSize = 50
+
+type APIClient interface {
+	PendingNotifications(ctx context.Context, limit int) ([]*models.Notification, error)
+	AckNotifications(ctx context.Context, ids []int64) error
+}
+
+type Sender interface {
+	Send(to tele.Recipient, what interface{}, opts ...interface{}) (*tele.Message, error)
+}
+
+// Notifier забирает с сервера очередь уведомлений (напоминания о подписках и т.п.)
+// и отправляет их пользователям
+type Notifier struct {
+	client   APIClient
+	sender   Sender
+	logger   *slog.Logger
+	interval atomic.Int64 // time.Duration
+	reset    chan struct{}
+	done     chan struct{}
+	once     sync.Once
+	wg       sync.WaitGroup
+}
+
+func New(client APIClient, sender Sender, logger *slog.Logger, interval time.Duration) *Notifier {
+	n := &Notifier{
+		client: client,
+		sender: sender,
+		logger: logger.With(slog.String("component", "notifier")),
+		reset:  make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	n.interval.Store(int64(interval))
+
+	return n
+}
+
+// SetInterval меняет интервал опроса при перезагрузке конфига, отсчет начинается заново
+func (n *Notifier) SetInterval(interval time.Duration) {
+	n.interval.Store(int64(interval))
+
+	select {
+	case n.reset <- struct{}{}:
+	default:
+	}
+}
+
+func (n *Notifier) Start() {
+	n.wg.Add(1)
+
+	go func() {
+		defer n.wg.Done()
+
+		ticker := time.NewTicker(n.pollInterval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				n.deliver()
+			case <-n.reset:
+				ticker.Reset(n.pollInterval())
+			case <-n.done:
+				return
+			}
+		}
+	}()
+}
+
+func (n *Notifier) pollInterval() time.Duration {
+	return time.Duration(n.interval.Load())
+}
+
+func (n *Notifier) Stop() {
+	n.once.Do(func() {
+		close(n.done)
+	})
+	n.wg.Wait()
+}
+
+func (n *Notifier) deliver() {
+	const op = "notifier.deliver"
+	logger := n.logger.With(slog.String("op", op))
+
+	// Каждый опрос очереди получает свой id запроса, как обновление от пользователя
+	ctx, cancel := context.WithTimeout(logging.WithRequestID(context.Background(), logging.NewRequestID()), n.pollInterval())
+	defer cancel()
+
+	notifications, err := n.client.PendingNotifications(ctx, batchSize)
+	if err != nil {
+		logger.WarnContext(ctx, "Не удалось получить уведомления", slog.String("error", err.Error()))
+		return
+	}
+
+	delivered := make([]int64, 0, len(notifications))
+	for _, notification := range notifications {
+		// Текст уведомления приходит с сервера без разметки
+		_, err := n.sender.Send(tele.ChatID(notification.TelegramID), notification.Text, tele.ModeDefault)
+		if err != nil && !isPermanent(err) {
+			// Сервер выдаст уведомление снова, когда истечет срок, на который оно выдано этой реплике
+			logger.WarnContext(ctx, "Не удалось отправить уведомление",
+				slog.Int64("notification_id", notification.ID),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+
+		if err != nil {
+			logger.InfoContext(ctx, "Пользователь недоступен, уведомление пропущено",
+				slog.Int64("telegram_id", notification.TelegramID),
+				slog.String("error", err.Error()),
+			)
+		}
+
+		delivered = append(delivered, notification.ID)
+	}
+
+	if len(delivered) == 0 {
+		return
+	}
+
+	if err := n.client.AckNotifications(ctx, delivered); err != nil {
+		logger.ErrorContext(ctx, "Не удалось подтвердить доставку уведомлений", slog.String("error", err.Error()))
+	}
+}
+
+// isPermanent сообщает, что повторная отправка не поможет
+func isPermanent(err error) bool {
+	return errors.Is(err, tele.ErrBlockedByUser) ||
+		errors.Is(err, tele.ErrUserIsDeactivated) ||
+		errors.Is(err, tele.ErrNotStartedByUser) ||
+		errors.Is(err, tele.ErrChatNotFound)
+}
@@ -0,0 +1,177 @@
+// Package outbound ограничивает скорость исходящих сообщений бота, чтобы не упираться
+// в лимит Telegram (около 30 сообщений в секунду на бота).
+package outbound
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrQueueFull = errors.New("очередь исходящих сообщений переполнена")
+
+// limitedPrefixes методы Bot API, которые считаются отправкой сообщения
+var limitedPrefixes = []string{"send", "copy", "forward", "edit"}
+
+// Pacer выдает слоты на отправку в порядке очереди: каждый вызов Wait резервирует
+// следующий свободный слот и ждет его. Сверх burst сообщения идут не чаще perSecond.
+type Pacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    time.Duration // Насколько слоты могут опережать текущее время
+	next     time.Time
+	maxWait  time.Duration
+	now      func() time.Time
+}
+
+func NewPacer(perSecond float64, burst int, maxWait time.Duration) *Pacer {
+	p := &Pacer{now: time.Now}
+	p.SetRate(perSecond, burst, maxWait)
+
+	return p
+}
+
+// SetRate меняет скорость при перезагрузке конфига. Уже зарезервированные слоты не сдвигаются.
+func (p *Pacer) SetRate(perSecond float64, burst int, maxWait time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.interval = time.Duration(float64(time.Second) / perSecond)
+	p.burst = time.Duration(burst-1) * p.interval
+	p.maxWait = maxWait
+}
+
+// Wait ждет своей очереди. Если ждать пришлось бы дольше maxWait, возвращает ErrQueueFull,
+// чтобы обработчик не зависал, пока Telegram разгребает очередь.
+func (p *Pacer) Wait(ctx context.Context) error {
+	delay, err := p.reserve()
+	if err != nil {
+		return err
+	}
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PauseFor сдвигает очередь, когда Telegram сам ответил 429 с retry_after
+func (p *Pacer) PauseFor(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if until := p.now().Add(d); until.After(p.next) {
+		p.next = until
+	}
+}
+
+func (p *Pacer) reserve() (time.Duration, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	if earliest := now.Add(-p.burst); p.next.Before(earliest) {
+		p.next = earliest
+	}
+
+	delay := p.next.Sub(now)
+	if delay > p.maxWait {
+		return 0, ErrQueueFull
+	}
+
+	p.next = p.next.Add(p.interval)
+
+	return delay, nil
+}
+
+// Transport http.RoundTripper для клиента Bot API, который пропускает отправку
+// сообщений через Pacer. Остальные методы (getUpdates, getMe, ...) идут без ограничений.
+type Transport struct {
+	next   http.RoundTripper
+	pacer  *Pacer
+	logger *slog.Logger
+}
+
+func NewTransport(next http.RoundTripper, pacer *Pacer, logger *slog.Logger) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &Transport{
+		next:   next,
+		pacer:  pacer,
+		logger: logger.With(slog.String("component", "outbound")),
+	}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := path.Base(req.URL.Path)
+	if !isLimited(method) {
+		return t.next.RoundTrip(req)
+	}
+
+	if err := t.pacer.Wait(req.Context()); err != nil {
+		t.logger.Warn("Сообщение не отправлено", slog.String("method", method), slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		return resp, err
+	}
+
+	if retryAfter := readRetryAfter(resp); retryAfter > 0 {
+		t.logger.Warn("Telegram ограничил отправку", slog.String("method", method), slog.Duration("retry_after", retryAfter))
+		t.pacer.PauseFor(retryAfter)
+	}
+
+	return resp, nil
+}
+
+func isLimited(method string) bool {
+	for _, prefix := range limitedPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readRetryAfter достает parameters.retry_after из ответа 429 и возвращает тело на место
+func readRetryAfter(resp *http.Response) time.Duration {
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return 0
+	}
+
+	var body struct {
+		Parameters struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return 0
+	}
+
+	return time.Duration(body.Parameters.RetryAfter) * time.Second
+}
@@ -0,0 +1,113 @@
+package state
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// cleanupInterval как часто из памяти удаляются истекшие ключи
+const cleanupInterval = time.Minute
+
+type memoryItem struct {
+	value   []byte
+	expires time.Time
+}
+
+// MemoryStore значения в памяти процесса. Подходит для одной реплики бота.
+type MemoryStore struct {
+	mu    sync.Mutex
+	items map[string]memoryItem
+	now   func() time.Time
+
+	done chan struct{}
+	once sync.Once
+}
+
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{
+		items: make(map[string]memoryItem),
+		now:   time.Now,
+		done:  make(chan struct{}),
+	}
+
+	go s.cleanup()
+
+	return s
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[key]
+	if !ok || !s.now().Before(item.expires) {
+		return nil, ErrNotFound
+	}
+
+	return item.value, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[key] = memoryItem{value: value, expires: s.now().Add(ttl)}
+
+	return nil
+}
+
+func (s *MemoryStore) SetNX(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if item, ok := s.items[key]; ok && now.Before(item.expires) {
+		return false, nil
+	}
+
+	s.items[key] = memoryItem{value: value, expires: now.Add(ttl)}
+
+	return true, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.items, key)
+
+	return nil
+}
+
+func (s *MemoryStore) Stop() {
+	s.once.Do(func() {
+		close(s.done)
+	})
+}
+
+func (s *MemoryStore) cleanup() {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.removeExpired()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) removeExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for key, item := range s.items {
+		if !now.Before(item.expires) {
+			delete(s.items, key)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Package state общее состояние бота: кеши продуктов, шаги диалогов и обработанные
+// обновления. С одной репликой состояние хранится в памяти, с несколькими — на сервере
+// с протоколом Redis, чтобы любая реплика продолжала диалог, начатый на другой.
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ErrNotFound ключа нет или время его жизни истекло
+var ErrNotFound = errors.New("ключ не найден")
+
+// Store хранилище значений со временем жизни
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// SetNX записывает значение, только если ключа еще нет, и сообщает, записано ли оно
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Values значения типа V по telegram_id. Хранятся в Store в JSON под ключами prefix:id.
+type Values[V any] struct {
+	store   Store
+	prefix  string
+	ttl     time.Duration
+	observe func(hit bool)
+}
+
+func NewValues[V any](store Store, prefix string, ttl time.Duration) *Values[V] {
+	return &Values[V]{store: store, prefix: prefix, ttl: ttl}
+}
+
+// Observe задает функцию, которая получает результат каждого Get: попадание или промах.
+// Нужна для метрик, задается до начала работы со значениями.
+func (v *Values[V]) Observe(fn func(hit bool)) {
+	v.observe = fn
+}
+
+// Get значение пользователя. ok false, если значения нет.
+func (v *Values[V]) Get(ctx context.Context, telegramID int64) (value V, ok bool, err error) {
+	const op = "state.Values.Get"
+
+	data, err := v.store.Get(ctx, v.key(telegramID))
+	if errors.Is(err, ErrNotFound) {
+		v.report(false)
+		return value, false, nil
+	}
+	if err != nil {
+		return value, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := json.Unmarshal(data, &value); err != nil {
+		return value, false, fmt.Errorf("%s: %s: %w", op, v.key(telegramID), err)
+	}
+
+	v.report(true)
+	return value, true, nil
+}
+
+// Set сохраняет значение, время жизни отсчитывается заново
+func (v *Values[V]) Set(ctx context.Context, telegramID int64, value V) error {
+	const op = "state.Values.Set"
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := v.store.Set(ctx, v.key(telegramID), data, v.ttl); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (v *Values[V]) Delete(ctx context.Context, telegramID int64) error {
+	const op = "state.Values.Delete"
+
+	if err := v.store.Delete(ctx, v.key(telegramID)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (v *Values[V]) key(telegramID int64) string {
+	return v.prefix + ":" + strconv.FormatInt(telegramID, 10)
+}
+
+func (v *Values[V]) report(hit bool) {
+	if v.observe != nil {
+		v.observe(hit)
+	}
+}
@@ -0,0 +1,48 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Updates отметки об обновлениях Telegram, которые уже взяла в работу одна из реплик.
+// Telegram повторяет доставку webhook, если не получил ответ 200, и повтор может
+// прийти на другую реплику.
+type Updates struct {
+	store Store
+	ttl   time.Duration
+}
+
+// NewUpdates ttl сколько помнить обновление. Telegram хранит недоставленные обновления сутки.
+func NewUpdates(store Store, ttl time.Duration) *Updates {
+	return &Updates{store: store, ttl: ttl}
+}
+
+// Claim отмечает обновление как взятое в работу. false, если его уже взяли раньше.
+func (u *Updates) Claim(ctx context.Context, updateID int) (bool, error) {
+	const op = "state.Updates.Claim"
+
+	claimed, err := u.store.SetNX(ctx, updateKey(updateID), []byte("1"), u.ttl)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return claimed, nil
+}
+
+// Release снимает отметку, если обновление не удалось передать боту и Telegram должен его повторить
+func (u *Updates) Release(ctx context.Context, updateID int) error {
+	const op = "state.Updates.Release"
+
+	if err := u.store.Delete(ctx, updateKey(updateID)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func updateKey(updateID int) string {
+	return "update:" + strconv.Itoa(updateID)
+}
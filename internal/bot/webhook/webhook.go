@@ -0,0 +1,187 @@
+// Package webhook прием обновлений Telegram через webhook вместо long polling.
+package webhook
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/bot/config"
+	tele "gopkg.in/telebot.v4"
+)
+
+const (
+	// secretHeader заголовок, в котором Telegram повторяет secret_token из setWebhook
+	secretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+	// maxUpdateSize обновления Telegram намного меньше, лимит защищает от мусора
+	maxUpdateSize = 1 << 20
+
+	shutdownTimeout = 5 * time.Second
+
+	// releaseTimeout сколько ждать хранилище, снимая отметку с непереданного обновления
+	releaseTimeout = 2 * time.Second
+)
+
+// Updates отметки об обновлениях, которые уже взяла в работу одна из реплик бота
+type Updates interface {
+	Claim(ctx context.Context, updateID int) (bool, error)
+	Release(ctx context.Context, updateID int) error
+}
+
+// Poller реализует tele.Poller: слушает HTTP, проверяет секрет и передает обновления боту.
+// Ответ 200 уходит, только когда обновление принял цикл бота. Пока бот не запущен
+// или уже останавливается, Poller отвечает 503 и Telegram повторит доставку позже.
+// Повтор обновления, которое уже взяла в работу любая реплика, подтверждается без обработки.
+type Poller struct {
+	conf    config.WebhookConf
+	updates Updates
+	logger  *slog.Logger
+	server  *http.Server
+
+	dest     chan<- tele.Update
+	ready    chan struct{} // Закрывается, когда Poll получил канал обновлений
+	stopping chan struct{}
+}
+
+func New(conf config.WebhookConf, updates Updates, logger *slog.Logger) *Poller {
+	p := &Poller{
+		conf:     conf,
+		updates:  updates,
+		logger:   logger.With(slog.String("component", "webhook")),
+		ready:    make(chan struct{}),
+		stopping: make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+conf.Path, p.handle)
+	p.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	return p
+}
+
+// Start открывает listener и регистрирует webhook в Telegram. Вызывается до bot.Start,
+// чтобы ошибки адреса или регистрации останавливали запуск, а не терялись в фоне.
+func (p *Poller) Start(b *tele.Bot) error {
+	const op = "webhook.Start"
+
+	listener, err := net.Listen("tcp", p.conf.Listen)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	go func() {
+		if err := p.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			p.logger.Error("Ошибка listener webhook", slog.String("error", err.Error()))
+		}
+	}()
+
+	err = b.SetWebhook(&tele.Webhook{
+		MaxConnections: p.conf.MaxConnections,
+		DropUpdates:    p.conf.DropPending,
+		SecretToken:    p.conf.SecretToken,
+		Endpoint:       &tele.WebhookEndpoint{PublicURL: p.conf.PublicURL},
+	})
+	if err != nil {
+		p.server.Close()
+		return fmt.Errorf("%s: не удалось зарегистрировать webhook: %w", op, err)
+	}
+
+	p.logger.Info("Webhook зарегистрирован",
+		slog.String("listen", p.conf.Listen),
+		slog.String("public_url", p.conf.PublicURL),
+	)
+
+	return nil
+}
+
+// Poll передает обновления в dest, пока бот не остановлен, затем снимает webhook
+// и дожидается запросов, которые уже обрабатываются
+func (p *Poller) Poll(b *tele.Bot, dest chan tele.Update, stop chan struct{}) {
+	p.dest = dest
+	close(p.ready)
+
+	<-stop
+	close(p.stopping)
+
+	// Если реплик несколько, webhook нужен остальным, и снимать его нельзя
+	if !p.conf.KeepOnShutdown {
+		if err := b.RemoveWebhook(false); err != nil {
+			p.logger.Warn("Не удалось снять webhook", slog.String("error", err.Error()))
+		} else {
+			p.logger.Info("Webhook снят")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := p.server.Shutdown(ctx); err != nil {
+		p.logger.Warn("Listener webhook не успел завершиться", slog.String("error", err.Error()))
+	}
+}
+
+func (p *Poller) handle(w http.ResponseWriter, r *http.Request) {
+	secret := r.Header.Get(secretHeader)
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(p.conf.SecretToken)) != 1 {
+		p.logger.Warn("Запрос к webhook с неверным секретом", slog.String("remote_addr", r.RemoteAddr))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var update tele.Update
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxUpdateSize)).Decode(&update); err != nil {
+		p.logger.Warn("Не удалось разобрать обновление", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case <-p.ready:
+	default:
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	claimed, err := p.updates.Claim(r.Context(), update.ID)
+	if err != nil {
+		// Без хранилища лучше рискнуть повторной обработкой, чем потерять обновление
+		p.logger.Warn("Не удалось проверить повтор обновления", slog.Int("update_id", update.ID), slog.String("error", err.Error()))
+		claimed = true
+	}
+	if !claimed {
+		p.logger.Info("Повтор обновления пропущен", slog.Int("update_id", update.ID))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	select {
+	case p.dest <- update:
+		w.WriteHeader(http.StatusOK)
+	case <-p.stopping:
+		p.release(r.Context(), update.ID)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case <-r.Context().Done():
+		p.release(r.Context(), update.ID)
+	}
+}
+
+// release снимает отметку с обновления, которое не дошло до бота, чтобы повтор
+// от Telegram обработала эта или другая реплика
+func (p *Poller) release(ctx context.Context, updateID int) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+	defer cancel()
+
+	if err := p.updates.Release(ctx, updateID); err != nil {
+		p.logger.Warn("Не удалось снять отметку с обновления", slog.Int("update_id", updateID), slog.String("error", err.Error()))
+	}
+}
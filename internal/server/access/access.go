@@ -0,0 +1,31 @@
+package access
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LocalManager заглушка управления доступом к репозиториям с материалами продукта.
+// Пока интеграции с хостингом репозиториев нет, отзыв доступа только логируется.
+type LocalManager struct {
+	logger *slog.Logger
+}
+
+func NewLocalManager(logger *slog.Logger) *LocalManager {
+	return &LocalManager{logger: logger}
+}
+
+func (m *LocalManager) RevokeAccess(ctx context.Context, userID, productID int64) error {
+	m.logger.InfoContext(ctx, "Доступ к репозиторию продукта отозван",
+		slog.String("op", "access.LocalManager.RevokeAccess"),
+		slog.Int64("user_id", userID),
+		slog.Int64("product_id", productID),
+	)
+
+	return nil
+}
+
+// Ping проверяет доступность хостинга репозиториев. Заглушке ходить некуда.
+func (m *LocalManager) Ping(ctx context.Context) error {
+	return nil
+}
@@ -2,52 +2,107 @@ package app
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"os"
 	"time"
 
+	"github.com/GeorgeTyupin/labguard/internal/server/access"
+	"github.com/GeorgeTyupin/labguard/internal/server/blobstore"
 	"github.com/GeorgeTyupin/labguard/internal/server/config"
 	"github.com/GeorgeTyupin/labguard/internal/server/handlers"
+	"github.com/GeorgeTyupin/labguard/internal/server/metrics"
 	"github.com/GeorgeTyupin/labguard/internal/server/middleware"
+	"github.com/GeorgeTyupin/labguard/internal/server/payments"
+	"github.com/GeorgeTyupin/labguard/internal/server/ratelimit"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository/postgres"
+	"github.com/GeorgeTyupin/labguard/internal/server/scheduler"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/artifacts"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/audit"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/licenses"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/media"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/promo"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/purchases"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/referrals"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/refunds"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/seats"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/subscriptions"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/users"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/wallet"
+	"github.com/GeorgeTyupin/labguard/pkg/auth"
+	"github.com/GeorgeTyupin/labguard/pkg/health"
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// rateLimitIdle через сколько без запросов корзина лимитов удаляется
+const rateLimitIdle = time.Hour
+
 type ServerApp struct {
 	AppName         string
 	server          *http.Server
+	scheduler       *scheduler.Scheduler
+	limits          ratelimit.Store
 	logger          *slog.Logger
 	dbPool          *pgxpool.Pool
 	shutdownTimeout time.Duration
+
+	// Компоненты, которые принимают новые значения при перезагрузке конфига
+	rateLimits     *middleware.RateLimits
+	requestTimeout *middleware.RequestTimeout
+	referrals      *referrals.Service
+	subscriptions  *subscriptions.Service
 }
 
 func NewServerApp(logger *slog.Logger, cfg *config.Config, pool *pgxpool.Pool) *ServerApp {
 	appName := "HTTP Server"
 	logger = logger.With(slog.String("app", appName))
 
-	handler := registerHandlers(cfg.Server.JWTSecret)
-
-	server := &http.Server{
-		Addr:    cfg.Server.Address,
-		Handler: handler,
-	}
-
 	application := &ServerApp{
 		AppName:         appName,
-		server:          server,
+		scheduler:       scheduler.New(logger),
 		logger:          logger,
 		dbPool:          pool,
 		shutdownTimeout: cfg.Server.Timeouts.Shutdown,
+		rateLimits:      middleware.NewRateLimits(rateLimitRules(cfg)),
+		requestTimeout:  middleware.NewRequestTimeout(cfg.Server.Timeouts.Request),
+	}
+
+	storage := postgres.NewStorage(pool)
+
+	application.limits = ratelimit.NewMemoryStore()
+	if cfg.Server.RateLimit.Backend == "postgres" {
+		application.limits = ratelimit.NewPostgresStore(storage)
+	}
+
+	handler := application.registerHandlers(cfg, storage)
+	application.registerJobs(cfg, storage)
+
+	application.server = &http.Server{
+		Addr:        cfg.Server.Address,
+		Handler:     handler,
+		IdleTimeout: cfg.Server.Timeouts.Idle,
 	}
 
 	return application
 }
 
+// Reload применяет поля конфига, которые меняются без перезапуска (reload:"live")
+func (app *ServerApp) Reload(cfg *config.Config) {
+	app.rateLimits.Set(rateLimitRules(cfg))
+	app.requestTimeout.Set(cfg.Server.Timeouts.Request)
+	app.referrals.SetRewardPercent(cfg.Server.Referral.RewardPercent)
+	app.subscriptions.SetRemindBefore(cfg.Server.Scheduler.RemindBefore)
+}
+
 func (app *ServerApp) Run() error {
 	const op = "server.app.Run"
 	logger := app.logger.With(slog.String("op", op))
 
+	app.scheduler.Start()
+
 	logger.Info("Запуск сервера...", slog.String("address", app.server.Addr))
 
 	return app.server.ListenAndServe()
@@ -58,6 +113,8 @@ func (app *ServerApp) Shutdown() {
 	logger := app.logger.With(slog.String("op", op))
 	logger.Info("Завершение сервера...")
 
+	app.scheduler.Stop()
+
 	ctx, cancel := context.WithTimeout(context.Background(), app.shutdownTimeout)
 	defer cancel()
 
@@ -72,16 +129,306 @@ func (app *ServerApp) Shutdown() {
 	}
 }
 
-func registerHandlers(jwtSecret string) *chi.Mux {
+func (app *ServerApp) registerHandlers(cfg *config.Config, storage *postgres.Storage) *chi.Mux {
 	r := chi.NewRouter()
 
-	r.HandleFunc("/health", handlers.HealthCheckHandler)
+	serverMetrics := metrics.New(app.dbPool, storage, app.logger)
+
+	// id запроса и адрес клиента нужны логам и журналу аудита
+	r.Use(
+		serverMetrics.Middleware,
+		middleware.Tracing,
+		middleware.RequestID,
+		middleware.RequestMeta(cfg.Server.RateLimit.TrustedProxyHops()),
+		middleware.AccessLog(app.logger),
+	)
+
+	paymentProvider := payments.MustNew(app.logger, cfg.Server.Payments.Provider)
+	accessManager := access.NewLocalManager(app.logger)
+
+	// /health оставлен для старых проверок, он равен /health/live
+	r.Get("/health", health.LiveHandler)
+	r.Get("/health/live", health.LiveHandler)
+	blobs := app.mustBlobStore(cfg)
+	r.Get("/health/ready", readinessChecker(app.dbPool, paymentProvider, accessManager, blobs).ReadyHandler())
+	if cfg.Server.Metrics.Enabled {
+		r.Handle(cfg.Server.Metrics.Path, serverMetrics.Handler())
+	}
+
+	auditService := audit.NewService(storage, app.logger)
+	auditHandler := handlers.NewAuditHandler(auditService, app.logger)
+
+	// Проверка лицензии из десктопного клиента
+	licenseService := licenses.NewService(storage, auditService, app.logger)
+	verifyHandler := handlers.NewVerifyHandler(licenseService, serverMetrics, app.logger)
+	r.With(app.rateLimit("verify"), app.requestTimeout.Middleware).Post("/api/v1/verify", verifyHandler.Handle)
+
+	notificationsHandler := handlers.NewNotificationsHandler(storage, app.logger)
+
+	seatsService := seats.NewService(storage, auditService, app.logger)
+	seatsHandler := handlers.NewSeatsHandler(seatsService, app.logger)
+
+	promoService := promo.NewService(storage, auditService, app.logger)
+	promoHandler := handlers.NewPromoHandler(promoService, app.logger)
+
+	app.referrals = referrals.NewService(storage, app.logger, cfg.Server.Referral.RewardPercent)
+	usersHandler := handlers.NewUsersHandler(users.NewService(storage, auditService, app.logger), app.referrals, app.logger)
+
+	purchaseService := purchases.NewService(storage, paymentProvider, promoService, auditService, app.logger, app.referrals)
+	purchasesHandler := handlers.NewPurchasesHandler(purchaseService, app.logger)
+
+	walletHandler := handlers.NewWalletHandler(wallet.NewService(storage, app.logger), app.logger)
+
+	refundService := refunds.NewService(
+		storage,
+		paymentProvider,
+		accessManager,
+		auditService,
+		app.logger,
+	)
+	refundsHandler := handlers.NewRefundsHandler(refundService, app.logger)
+
+	mediaService := media.NewService(storage, blobs, auditService, app.logger)
+	mediaHandler := handlers.NewMediaHandler(mediaService, app.logger)
+
+	artifactsService := artifacts.NewService(
+		storage,
+		blobs,
+		auditService,
+		app.logger,
+		app.artifactLinkSecret(cfg),
+		cfg.Server.Artifacts.LinkTTL,
+		app.artifactWatermarkSecret(cfg),
+	)
+	artifactsHandler := handlers.NewArtifactsHandler(artifactsService, cfg.Server.Artifacts.PublicURL, app.logger)
+
+	// Ссылки на скачивание открывает покупатель в браузере, токен в пути заменяет авторизацию
+	r.With(app.rateLimit("download")).Get("/api/v1/downloads/{token}", artifactsHandler.HandleDownload)
+
+	keys := app.mustKeyRing(cfg)
+	r.Get("/.well-known/jwks.json", handlers.NewJWKSHandler(keys).Handle)
+
+	jwtMiddleware := middleware.JWTMiddleware(middleware.JWTOptions{
+		Keys:     keys,
+		Audience: cfg.Server.Auth.Audience,
+		Issuers:  cfg.Server.Auth.Issuers,
+	})
+	scope := middleware.RequireScopes
 
 	r.Route("/api/v1/bot", func(r chi.Router) {
-		r.Use(middleware.JWTMiddleware(jwtSecret))
+		r.Use(jwtMiddleware, middleware.RequireRole(auth.RoleBot))
+
+		// Группа нужна, чтобы лимит считался после разбора пути и видел {telegram_id}
+		r.Group(func(r chi.Router) {
+			r.Use(app.rateLimit("bot"), app.requestTimeout.Middleware)
 
-		r.Post("/register", handlers.RegisterUHandler)
+			r.With(scope(auth.ScopeUsersWrite)).Post("/register", usersHandler.HandleRegister)
+			r.With(scope(auth.ScopeUsersRead)).Get("/users/{telegram_id}", usersHandler.HandleExists)
+			r.With(scope(auth.ScopeUsersWrite)).Post("/users/{telegram_id}/license-key", usersHandler.HandleRotateLicenseKey)
+			r.With(scope(auth.ScopeUsersRead)).Get("/referrals", usersHandler.HandleReferralStats)
+
+			r.With(scope(auth.ScopePurchases)).Post("/purchases", purchasesHandler.HandleBuy)
+			r.With(scope(auth.ScopePurchases)).Post("/purchases/{id}/confirm", purchasesHandler.HandleConfirm)
+			r.With(scope(auth.ScopePurchases)).Post("/promo/quote", promoHandler.HandleQuote)
+			r.With(scope(auth.ScopeWalletRead)).Get("/balance", walletHandler.Handle)
+			r.With(scope(auth.ScopeRefunds)).Post("/refunds", refundsHandler.HandleRequest)
+			r.With(scope(auth.ScopeProducts)).Get("/products/{id}/media", mediaHandler.HandleList)
+			r.With(scope(auth.ScopeArtifacts)).Get("/products/{id}/artifact", artifactsHandler.HandleLatest)
+			r.With(scope(auth.ScopeArtifacts)).Post("/products/{id}/artifact/link", artifactsHandler.HandleLink)
+
+			// Очередь сообщений, которые бот доставляет пользователям
+			r.Route("/notifications", func(r chi.Router) {
+				r.Use(scope(auth.ScopeNotifications))
+
+				r.Get("/", notificationsHandler.HandlePending)
+				r.Post("/ack", notificationsHandler.HandleAck)
+			})
+
+			// Групповые лицензии
+			r.Route("/seat-pools", func(r chi.Router) {
+				r.Use(scope(auth.ScopeSeats))
+
+				r.Get("/", seatsHandler.HandleList)
+				r.With(scope(auth.ScopePurchases)).Post("/", purchasesHandler.HandleBuySeats)
+				r.Post("/join", seatsHandler.HandleJoin)
+				r.Get("/{id}", seatsHandler.HandleDetails)
+				r.Post("/{id}/release", seatsHandler.HandleRelease)
+				r.Post("/{id}/reassign", seatsHandler.HandleReassign)
+			})
+		})
+
+		// Файлы до 50 МБ не успевают уйти за дедлайн остальных запросов бота
+		r.Group(func(r chi.Router) {
+			r.Use(app.rateLimit("bot"))
+
+			r.With(scope(auth.ScopeProducts)).Get("/media/{id}", mediaHandler.HandleDownload)
+			r.With(scope(auth.ScopeArtifacts)).Post("/products/{id}/artifact/download", artifactsHandler.HandleDeliver)
+		})
+	})
+
+	r.Route("/api/v1/admin", func(r chi.Router) {
+		r.Use(app.rateLimit("admin"), jwtMiddleware, middleware.RequireRole(auth.RoleAdmin))
+
+		r.With(scope(auth.ScopePromoAdmin)).Post("/promo-codes", promoHandler.HandleCreate)
+		r.With(scope(auth.ScopeUsersAdmin)).Post("/users/{telegram_id}/leader", usersHandler.HandleSetLeader)
+
+		r.Route("/refunds", func(r chi.Router) {
+			r.Use(scope(auth.ScopeRefundsAdmin))
+
+			r.Get("/", refundsHandler.HandleList)
+			r.Post("/", refundsHandler.HandleCreate)
+			r.Post("/{id}/approve", refundsHandler.HandleApprove)
+			r.Post("/{id}/reject", refundsHandler.HandleReject)
+		})
+
+		// Обложки, скриншоты и примеры работ в карточках продуктов
+		r.Route("/products/{id}/media", func(r chi.Router) {
+			r.Use(scope(auth.ScopeProductsAdmin))
+
+			r.Get("/", mediaHandler.HandleList)
+			r.Post("/", mediaHandler.HandleUpload)
+			r.Delete("/{media_id}", mediaHandler.HandleDelete)
+		})
+
+		// Версии материалов для покупателей и поиск покупателя по метке из утекшей копии
+		r.Route("/products/{id}/artifacts", func(r chi.Router) {
+			r.Use(scope(auth.ScopeArtifactsAdmin))
+
+			r.Get("/", artifactsHandler.HandleList)
+			r.Post("/", artifactsHandler.HandleUpload)
+		})
+		r.With(scope(auth.ScopeArtifactsAdmin)).Get("/artifacts/trace", artifactsHandler.HandleTrace)
+
+		r.Route("/audit", func(r chi.Router) {
+			r.Use(scope(auth.ScopeAuditAdmin))
+
+			r.Get("/", auditHandler.HandleList)
+			r.Get("/export", auditHandler.HandleExport)
+		})
 	})
 
 	return r
 }
+
+// rateLimit лимиты группы маршрутов. Для группы без лимитов middleware ничего не делает.
+func (app *ServerApp) rateLimit(group string) func(http.Handler) http.Handler {
+	return middleware.RateLimit(app.limits, group, app.rateLimits, app.logger)
+}
+
+// rateLimitRules правила лимитов всех групп из конфига
+func rateLimitRules(cfg *config.Config) map[string][]middleware.RateLimitRule {
+	conf := cfg.Server.RateLimit
+
+	groups := make(map[string][]middleware.RateLimitRule, len(conf.Groups))
+	for group, groupConf := range conf.Groups {
+		var rules []middleware.RateLimitRule
+		addRule := func(name string, key middleware.KeyFunc, limit *config.LimitConf) {
+			if limit != nil {
+				rules = append(rules, middleware.RateLimitRule{
+					Name:  name,
+					Key:   key,
+					Limit: ratelimit.Limit{PerMinute: limit.PerMinute, Burst: limit.Burst},
+				})
+			}
+		}
+
+		addRule("ip", middleware.ByIP(conf.TrustedProxyHops()), groupConf.IP)
+		addRule("license_key", middleware.ByLicenseKey, groupConf.LicenseKey)
+		addRule("telegram_id", middleware.ByTelegramID, groupConf.TelegramID)
+
+		groups[group] = rules
+	}
+
+	return groups
+}
+
+// mustKeyRing собирает ключи проверки токенов из конфига
+func (app *ServerApp) mustKeyRing(cfg *config.Config) *auth.KeyRing {
+	authConf := cfg.Server.Auth
+	if authConf.Algorithm == auth.AlgorithmHS256 {
+		return auth.NewHMACKeyRing(cfg.Server.JWTSecret)
+	}
+
+	keys := auth.NewKeyRing()
+	for _, key := range authConf.Keys {
+		binding := auth.KeyBinding{Issuers: key.Issuers, Roles: key.Roles}
+		if err := keys.AddPublicKeyFile(key.ID, key.File, binding); err != nil {
+			app.logger.Error("Не удалось загрузить ключ проверки токенов",
+				slog.String("kid", key.ID),
+				slog.String("error", err.Error()),
+			)
+			os.Exit(1)
+		}
+	}
+
+	return keys
+}
+
+// mustBlobStore хранилище файлов из конфига
+func (app *ServerApp) mustBlobStore(cfg *config.Config) blobstore.Store {
+	conf := cfg.Server.Storage
+
+	var (
+		store blobstore.Store
+		err   error
+	)
+	switch conf.Backend {
+	case blobstore.BackendS3:
+		store, err = blobstore.NewS3Store(blobstore.S3Options{
+			Endpoint:  conf.S3.Endpoint,
+			Region:    conf.S3.Region,
+			Bucket:    conf.S3.Bucket,
+			AccessKey: conf.S3.AccessKey,
+			SecretKey: conf.S3.SecretKey,
+			UseSSL:    conf.S3.UseSSL,
+		})
+	default:
+		store, err = blobstore.NewFSStore(conf.FS.Dir)
+	}
+	if err != nil {
+		app.logger.Error("Не удалось подключить хранилище файлов",
+			slog.String("backend", conf.Backend),
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	return store
+}
+
+// artifactLinkSecret ключ подписи ссылок на скачивание. Если ключ не задан, он
+// генерируется при запуске, и выданные ссылки перестают работать после перезапуска.
+func (app *ServerApp) artifactLinkSecret(cfg *config.Config) []byte {
+	if secret := cfg.Server.Artifacts.LinkSecret; secret != "" {
+		return []byte(secret)
+	}
+
+	app.logger.Warn("ARTIFACT_LINK_SECRET не задан, ссылки на скачивание будут работать до перезапуска сервера")
+
+	return []byte(rand.Text())
+}
+
+// artifactWatermarkSecret ключ подписи кодов выдачи. Без ключа он генерируется при
+// запуске: метки по-прежнему находят покупателя по журналу, но подпись кодов,
+// выданных до перезапуска, уже не подтвердить.
+func (app *ServerApp) artifactWatermarkSecret(cfg *config.Config) []byte {
+	if secret := cfg.Server.Artifacts.WatermarkSecret; secret != "" {
+		return []byte(secret)
+	}
+
+	app.logger.Warn("ARTIFACT_WATERMARK_SECRET не задан, подпись меток будет проверяться до перезапуска сервера")
+
+	return []byte(rand.Text())
+}
+
+func (app *ServerApp) registerJobs(cfg *config.Config, storage *postgres.Storage) {
+	interval := cfg.Server.Scheduler.Interval
+
+	app.subscriptions = subscriptions.NewService(storage, app.logger, cfg.Server.Scheduler.RemindBefore)
+	app.scheduler.Every("subscription_reminders", interval, app.subscriptions.RemindExpiring)
+	app.scheduler.Every("subscription_expiration", interval, app.subscriptions.ExpireOverdue)
+
+	app.scheduler.Every("rate_limit_cleanup", interval, func(ctx context.Context) error {
+		return app.limits.Cleanup(ctx, rateLimitIdle)
+	})
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/repository/postgres"
+	"github.com/GeorgeTyupin/labguard/pkg/health"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// readyTimeout сколько ждать все проверки готовности вместе
+const readyTimeout = 3 * time.Second
+
+// pinger внешняя зависимость, доступность которой входит в готовность сервера
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// readinessChecker проверки готовности сервера: база, версия схемы, внешние провайдеры
+// и хранилище файлов
+func readinessChecker(pool *pgxpool.Pool, payments, access, storage pinger) *health.Checker {
+	checker := health.NewChecker(readyTimeout)
+
+	checker.Add("postgres", pool.Ping)
+	checker.Add("migrations", func(ctx context.Context) error {
+		return checkSchemaVersion(ctx, pool)
+	})
+	checker.Add("payments", payments.Ping)
+	checker.Add("access", access.Ping)
+	checker.Add("storage", storage.Ping)
+
+	return checker
+}
+
+// checkSchemaVersion сверяет версию схемы базы с миграциями бинарника. Пока новый
+// экземпляр не применил миграции или база ушла вперед старого, трафик ему не нужен.
+func checkSchemaVersion(ctx context.Context, pool *pgxpool.Pool) error {
+	want, err := postgres.LatestMigrationVersion()
+	if err != nil {
+		return err
+	}
+
+	got, err := postgres.SchemaVersion(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	if got != want {
+		return fmt.Errorf("версия схемы %d, бинарник ожидает %d", got, want)
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+// Package blobstore хранилище файлов сервера: медиа продуктов и выдаваемые покупателям
+// материалы. Метаданные файлов хранятся в базе, здесь только содержимое по ключу.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	BackendFS = "fs"
+	BackendS3 = "s3"
+)
+
+var (
+	ErrNotFound   = errors.New("файл не найден в хранилище")
+	ErrInvalidKey = errors.New("недопустимый ключ файла")
+)
+
+// Store хранилище содержимого файлов по ключу вида "products/1/media/abc.png"
+type Store interface {
+	// Put сохраняет size байт из r под ключом key, существующий файл заменяется
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open открывает файл на чтение, вызывающий закрывает его
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete удаляет файл, удаление отсутствующего файла не ошибка
+	Delete(ctx context.Context, key string) error
+	// Ping проверяет доступность хранилища для проверки готовности сервера
+	Ping(ctx context.Context) error
+}
+
+// checkKey ключи составляет сервер, но путь с .. или абсолютный путь вывел бы
+// файловое хранилище за пределы своей папки
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FSStore файлы в папке на диске. Подходит для одной реплики сервера или общего тома.
+type FSStore struct {
+	dir string
+}
+
+// NewFSStore создает папку dir, если ее нет
+func NewFSStore(dir string) (*FSStore, error) {
+	const op = "blobstore.NewFSStore"
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &FSStore{dir: dir}, nil
+}
+
+func (s *FSStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	const op = "blobstore.FSStore.Put"
+
+	if err := checkKey(key); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	target := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(target), 0o750); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Пишем во временный файл рядом и переименовываем, чтобы читатель
+	// никогда не увидел файл записанным наполовину
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if written != size {
+		return fmt.Errorf("%s: записано %d байт из %d", op, written, size)
+	}
+
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *FSStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	const op = "blobstore.FSStore.Open"
+
+	if err := checkKey(key); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	file, err := os.Open(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return file, nil
+}
+
+func (s *FSStore) Delete(ctx context.Context, key string) error {
+	const op = "blobstore.FSStore.Delete"
+
+	if err := checkKey(key); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Ping проверяет, что папка на месте и в нее можно писать
+func (s *FSStore) Ping(ctx context.Context) error {
+	const op = "blobstore.FSStore.Ping"
+
+	probe, err := os.CreateTemp(s.dir, ".ping-*")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	probe.Close()
+
+	return os.Remove(probe.Name())
+}
+
+func (s *FSStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
@@ -0,0 +1,109 @@
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Options подключение к хранилищу с протоколом S3: AWS, Yandex Object Storage, MinIO
+type S3Options struct {
+	Endpoint  string // host:port без схемы
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+}
+
+// S3Store файлы в бакете S3. Подходит для нескольких реплик сервера.
+type S3Store struct {
+	client *minio.Client
+	bucket string
+}
+
+func NewS3Store(opts S3Options) (*S3Store, error) {
+	const op = "blobstore.NewS3Store"
+
+	client, err := minio.New(opts.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, ""),
+		Secure: opts.UseSSL,
+		Region: opts.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &S3Store{client: client, bucket: opts.Bucket}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	const op = "blobstore.S3Store.Put"
+
+	if err := checkKey(key); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *S3Store) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	const op = "blobstore.S3Store.Open"
+
+	if err := checkKey(key); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// GetObject не ходит в S3, пока объект не начали читать. Stat сразу отличает
+	// отсутствующий файл от ошибки посреди отдачи.
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+			return nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return object, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	const op = "blobstore.S3Store.Delete"
+
+	if err := checkKey(key); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Ping проверяет, что бакет существует и ключи доступа подходят
+func (s *S3Store) Ping(ctx context.Context) error {
+	const op = "blobstore.S3Store.Ping"
+
+	exists, err := s.client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !exists {
+		return fmt.Errorf("%s: бакет %s не найден", op, s.bucket)
+	}
+
+	return nil
+}
@@ -1,70 +1,47 @@
 package config
 
 import (
-	"fmt"
 	"log/slog"
-	"os"
 
-	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/GeorgeTyupin/labguard/pkg/appconfig"
 )
 
+// Пути по умолчанию относительно рабочей папки, их можно заменить флагами -config и -env-file
 const (
 	yamlPath = "configs/server/server.yaml"
 	envPath  = "configs/server/postgres.env"
 )
 
 type Config struct {
-	Env string
-	ServerConfig
-	PostgresConfig
+	appconfig.Base `yaml:",inline"`
+	ServerConfig   `yaml:",inline"`
+	PostgresConfig `yaml:",inline"`
 }
 
-func MustLoad(logger *slog.Logger) *Config {
-	const op = "server.config.MustLoad"
-	logger = logger.With(slog.String("op", op))
+func MustLoad(logger *slog.Logger) (*Config, *appconfig.Source) {
+	var cfg Config
+	src := appconfig.MustLoad(logger, appconfig.Options{Name: "server", ConfigPath: yamlPath, EnvFile: envPath}, &cfg)
 
-	file, err := os.Open(yamlPath)
-	if err != nil {
-		logger.Error("Не удалось открыть файл с конфигом", slog.String("error", err.Error()))
-		os.Exit(1)
-	}
-	defer file.Close()
-
-	serverConf, err := LoadServerConf(file)
-	if err != nil {
-		logger.Error("Ошибка загрузки конфига сервера", slog.String("error", err.Error()))
-		os.Exit(1)
-	}
-
-	file.Seek(0, 0)
-	postgresConf, err := LoadPostgresConf(file)
-	if err != nil {
-		logger.Error("Ошибка загрузки конфига базы данных", slog.String("error", err.Error()))
-		os.Exit(1)
-	}
-
-	file.Seek(0, 0)
-	envConf, err := LoadEnvState(file)
-	if err != nil {
-		logger.Error("Ошибка загрузки конфига env", slog.String("error", err.Error()))
-		os.Exit(1)
-	}
-
-	return &Config{
-		Env:            envConf,
-		ServerConfig:   *serverConf,
-		PostgresConfig: *postgresConf,
-	}
+	return &cfg, src
 }
 
-func LoadEnvState(file *os.File) (string, error) {
-	var cfg struct {
-		Env string `yaml:"env" env-default:"local"`
-	}
+// NewReloader перечитывает конфиг по SIGHUP и передает в apply поля, которые меняются на ходу
+func NewReloader(src *appconfig.Source, cfg *Config, apply func(*Config), logger *slog.Logger) *appconfig.Reloader {
+	return appconfig.NewReloader(src, cfg,
+		func() appconfig.Config { return &Config{} },
+		func(c appconfig.Config) { apply(c.(*Config)) },
+		logger,
+	)
+}
 
-	if err := cleanenv.ParseYAML(file, &cfg); err != nil {
-		return "", fmt.Errorf("не удалось прочитать конфиг. Возникла ошибка %w", err)
-	}
+// ApplyProfile значения по умолчанию, которые отличаются между окружениями
+func (c *Config) ApplyProfile(p appconfig.Profile) {
+	c.ServerConfig.applyProfile(p)
+	c.PostgresConfig.applyProfile(p)
+}
 
-	return cfg.Env, nil
+// Validate проверяет связи между полями конфига
+func (c *Config) Validate(p *appconfig.Problems) {
+	c.ServerConfig.validate(p)
+	c.PostgresConfig.validate(p)
 }
@@ -1,12 +1,9 @@
 package config
 
 import (
-	"fmt"
-	"os"
 	"time"
 
-	"github.com/ilyakaznacheev/cleanenv"
-	"github.com/joho/godotenv"
+	"github.com/GeorgeTyupin/labguard/pkg/appconfig"
 )
 
 type PostgresConfig struct {
@@ -16,35 +13,48 @@ type PostgresConfig struct {
 type DBConfig struct {
 	Database   string         `yaml:"database" env:"POSTGRES_DB"`
 	User       string         `env:"POSTGRES_USER"`
-	Password   string         `env:"POSTGRES_PASSWORD"`
+	Password   string         `env:"POSTGRES_PASSWORD" secret:"true"`
 	Host       string         `env:"POSTGRES_HOST"`
 	Port       int            `env:"POSTGRES_PORT"`
-	PoolSize   int32          `yaml:"pool_size" env-default:"10"`
+	PoolSize   int32          `yaml:"pool_size"` // По умолчанию 10, в prod 25
 	Connection ConnectionConf `yaml:"connection"`
 }
 
 type ConnectionConf struct {
-	MaxLifeTime       time.Duration `yaml:"max_life_time" env-default:"30min"`
-	MaxIdleTime       time.Duration `yaml:"max_idle_time" env-default:"1min"`
+	MaxLifeTime       time.Duration `yaml:"max_life_time" env-default:"30m"`
+	MaxIdleTime       time.Duration `yaml:"max_idle_time" env-default:"1m"`
 	HealthCheckPeriod time.Duration `yaml:"health_check_period" env-default:"30s"`
 	Timeout           time.Duration `yaml:"timeout" env-default:"30s"`
 }
 
-func LoadPostgresConf(file *os.File) (*PostgresConfig, error) {
-	var pgConf PostgresConfig
-
-	if err := godotenv.Load(envPath); err != nil {
-		return nil, fmt.Errorf("не удалось прочитать env. Возникла ошибка %w", err)
+func (c *PostgresConfig) applyProfile(p appconfig.Profile) {
+	if c.Postgres.PoolSize == 0 {
+		c.Postgres.PoolSize = 10
+		if p == appconfig.ProfileProd {
+			c.Postgres.PoolSize = 25
+		}
 	}
+}
 
-	if err := cleanenv.ParseYAML(file, &pgConf); err != nil {
-		return nil, fmt.Errorf("не удалось прочитать конфиг. Возникла ошибка %w", err)
-	}
+func (c *PostgresConfig) validate(p *appconfig.Problems) {
+	db := c.Postgres
 
-	if err := cleanenv.ReadEnv(&pgConf); err != nil {
-		return nil, fmt.Errorf("не удалось прочитать env переменные. Возникла ошибка %w", err)
+	if db.Host == "" {
+		p.Addf("postgres.host", "не задан адрес базы, укажите его в конфиге или POSTGRES_HOST")
+	}
+	if db.Port < 1 || db.Port > 65535 {
+		p.Addf("postgres.port", "порт %d вне диапазона 1-65535", db.Port)
+	}
+	if db.Database == "" {
+		p.Addf("postgres.database", "не задано имя базы, укажите его в конфиге или POSTGRES_DB")
+	}
+	if db.User == "" {
+		p.Addf("POSTGRES_USER", "не задан пользователь базы")
+	}
+	if db.PoolSize < 1 {
+		p.Addf("postgres.pool_size", "размер пула должен быть положительным")
+	}
+	if db.Connection.Timeout <= 0 {
+		p.Addf("postgres.connection.timeout", "таймаут подключения должен быть положительным")
 	}
-
-	return &pgConf, nil
-
 }
@@ -2,43 +2,287 @@ package config
 
 import (
 	"fmt"
-	"os"
+	"strings"
 	"time"
 
-	"github.com/ilyakaznacheev/cleanenv"
-	"github.com/joho/godotenv"
+	"github.com/GeorgeTyupin/labguard/internal/server/blobstore"
+	"github.com/GeorgeTyupin/labguard/internal/server/payments"
+	"github.com/GeorgeTyupin/labguard/pkg/appconfig"
+	"github.com/GeorgeTyupin/labguard/pkg/auth"
+	"github.com/GeorgeTyupin/labguard/pkg/tracing"
 )
 
 type ServerConfig struct {
-	Server HTTPServerConf `yaml:"http_server"`
+	Server  HTTPServerConf `yaml:"http_server"`
+	Tracing tracing.Config `yaml:"tracing"`
 }
 
 type HTTPServerConf struct {
-	Address   string       `yaml:"address" env-default:"localhost:8080"`
-	JWTSecret string       `env:"JWT_SECRET" env-required:"true"`
-	Timeouts  TimeoutsConf `yaml:"timeouts"`
+	Address   string        `yaml:"address"`                 // По умолчанию localhost:8080 в local, иначе 0.0.0.0:8000
+	JWTSecret string        `env:"JWT_SECRET" secret:"true"` // Нужен только в режиме hs256
+	Auth      AuthConf      `yaml:"auth"`
+	Timeouts  TimeoutsConf  `yaml:"timeouts"`
+	Scheduler SchedulerConf `yaml:"scheduler"`
+	Referral  ReferralConf  `yaml:"referral" reload:"live"`
+	Payments  PaymentsConf  `yaml:"payments"`
+	RateLimit RateLimitConf `yaml:"rate_limit"`
+	Metrics   MetricsConf   `yaml:"metrics"`
+	Storage   StorageConf   `yaml:"storage"`
+	Artifacts ArtifactsConf `yaml:"artifacts"`
+}
+
+type AuthConf struct {
+	Audience string   `yaml:"audience" env-default:"labguard-api"`               // Токены, выпущенные для другого сервиса, отклоняются
+	Issuers  []string `yaml:"issuers" env-default:"labguard-bot,labguard-admin"` // Доверенные издатели токенов
+	// Algorithm hs256 — общий секрет JWT_SECRET, asymmetric — открытые ключи из Keys
+	Algorithm string    `yaml:"algorithm" env-default:"hs256"`
+	Keys      []KeyConf `yaml:"keys"`
+}
+
+// KeyConf открытый ключ проверки токенов. Во время ротации указываются и старый, и новый ключи.
+// Ключ проверяет только токены своих издателей и ролей.
+type KeyConf struct {
+	ID      string   `yaml:"id"`
+	File    string   `yaml:"file"`
+	Issuers []string `yaml:"issuers"`
+	Roles   []string `yaml:"roles"`
 }
 
 type TimeoutsConf struct {
 	Idle     time.Duration `yaml:"idle" env-default:"60s"`
-	Request  time.Duration `yaml:"request" env-default:"5s"`
-	Shutdown time.Duration `yaml:"shutdown" env-default:"10s"`
+	Request  time.Duration `yaml:"request" env-default:"5s" reload:"live"` // Дедлайн запросов /api/v1/verify и /api/v1/bot
+	Shutdown time.Duration `yaml:"shutdown"`                               // По умолчанию 10s, в prod 30s
+}
+
+type SchedulerConf struct {
+	Interval     time.Duration `yaml:"interval" env-default:"10m"`
+	RemindBefore time.Duration `yaml:"remind_before" env-default:"72h" reload:"live"` // За сколько до окончания подписки напоминать о продлении
+}
+
+type ReferralConf struct {
+	RewardPercent int64 `yaml:"reward_percent" env-default:"10"` // Бонус пригласившему в процентах от первой покупки
+}
+
+type PaymentsConf struct {
+	Provider string `yaml:"provider" env-default:"local"` // Платежный провайдер, пока поддерживается только local и только вне prod
+}
+
+// MetricsConf эндпоинт метрик Prometheus. Его стоит закрыть от внешнего мира на прокси.
+type MetricsConf struct {
+	Enabled bool   `yaml:"enabled" env-default:"true"`
+	Path    string `yaml:"path" env-default:"/metrics"`
+}
+
+// StorageConf хранилище файлов: обложки и скриншоты продуктов, материалы для покупателей
+type StorageConf struct {
+	Backend string        `yaml:"backend" env:"STORAGE_BACKEND" env-default:"fs"` // fs или s3 для нескольких реплик
+	FS      FSStorageConf `yaml:"fs"`
+	S3      S3StorageConf `yaml:"s3"`
+}
+
+type FSStorageConf struct {
+	Dir string `yaml:"dir" env-default:"data/storage"`
+}
+
+// S3StorageConf бакет S3: AWS, Yandex Object Storage или MinIO
+type S3StorageConf struct {
+	Endpoint  string `yaml:"endpoint" env:"S3_ENDPOINT"` // host:port без схемы
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket" env:"S3_BUCKET"`
+	UseSSL    bool   `yaml:"use_ssl" env-default:"true"`
+	AccessKey string `env:"S3_ACCESS_KEY"`
+	SecretKey string `env:"S3_SECRET_KEY" secret:"true"`
+}
+
+// ArtifactsConf выдача материалов покупателям по временным ссылкам
+type ArtifactsConf struct {
+	PublicURL       string        `yaml:"public_url" env:"PUBLIC_URL"`            // Адрес сервера для покупателей, вне prod по умолчанию http://<address>
+	LinkTTL         time.Duration `yaml:"link_ttl" env-default:"15m"`             // Сколько действует ссылка на скачивание
+	LinkSecret      string        `env:"ARTIFACT_LINK_SECRET" secret:"true"`      // Ключ подписи ссылок, без него ссылки живут до перезапуска
+	WatermarkSecret string        `env:"ARTIFACT_WATERMARK_SECRET" secret:"true"` // Ключ подписи меток по лицензии, без него подпись проверяется до перезапуска
+}
+
+// RateLimitConf лимиты запросов по группам маршрутов: verify, bot, admin, download
+type RateLimitConf struct {
+	Backend    string                    `yaml:"backend" env-default:"memory"` // memory или postgres для нескольких реплик
+	TrustProxy bool                      `yaml:"trust_proxy"`                  // Брать адрес клиента из X-Forwarded-For
+	ProxyHops  int                       `yaml:"proxy_hops" env-default:"1"`   // Сколько доверенных прокси дописывают адрес в X-Forwarded-For
+	Groups     map[string]RateLimitGroup `yaml:"groups" reload:"live"`
+}
+
+// RateLimitGroup лимиты группы по видам ключей. Не указанный лимит не применяется.
+type RateLimitGroup struct {
+	IP         *LimitConf `yaml:"ip"`
+	LicenseKey *LimitConf `yaml:"license_key"`
+	TelegramID *LimitConf `yaml:"telegram_id"`
+}
+
+type LimitConf struct {
+	PerMinute float64 `yaml:"per_minute"`
+	Burst     int     `yaml:"burst"`
+}
+
+func (c *ServerConfig) applyProfile(p appconfig.Profile) {
+	if c.Server.Address == "" {
+		c.Server.Address = "0.0.0.0:8000"
+		if p == appconfig.ProfileLocal {
+			c.Server.Address = "localhost:8080"
+		}
+	}
+
+	// В prod даем запросам больше времени завершиться при выкатке
+	if c.Server.Timeouts.Shutdown == 0 {
+		c.Server.Timeouts.Shutdown = 10 * time.Second
+		if p == appconfig.ProfileProd {
+			c.Server.Timeouts.Shutdown = 30 * time.Second
+		}
+	}
+
+	// В prod сервер стоит за прокси, и адрес для покупателей нужно указать явно
+	if c.Server.Artifacts.PublicURL == "" && p != appconfig.ProfileProd {
+		c.Server.Artifacts.PublicURL = "http://" + c.Server.Address
+	}
+
+	c.Tracing.ApplyProfile(p)
 }
 
-func LoadServerConf(file *os.File) (*ServerConfig, error) {
-	var config ServerConfig
+func (c *ServerConfig) validate(p *appconfig.Problems) {
+	srv := c.Server
+
+	srv.Auth.validate(p, srv.JWTSecret)
+	srv.RateLimit.validate(p)
+	srv.Storage.validate(p)
+	srv.Artifacts.validate(p)
+
+	if srv.Timeouts.Request <= 0 {
+		p.Addf("http_server.timeouts.request", "таймаут должен быть положительным")
+	}
+	if srv.Timeouts.Shutdown <= 0 {
+		p.Addf("http_server.timeouts.shutdown", "таймаут должен быть положительным")
+	}
+	if srv.Scheduler.Interval <= 0 {
+		p.Addf("http_server.scheduler.interval", "интервал должен быть положительным")
+	}
+	if srv.Referral.RewardPercent < 0 || srv.Referral.RewardPercent > 100 {
+		p.Addf("http_server.referral.reward_percent", "процент %d вне диапазона 0-100", srv.Referral.RewardPercent)
+	}
+	if srv.Payments.Provider != payments.ProviderLocal {
+		p.Addf("http_server.payments.provider", "неизвестный провайдер %q", srv.Payments.Provider)
+	} else if p.Profile() == appconfig.ProfileProd {
+		// Заглушка подтверждает любой платеж, в prod продукты раздавались бы бесплатно
+		p.Addf("http_server.payments.provider", "провайдер %s в prod запрещен", payments.ProviderLocal)
+	}
+	if srv.Metrics.Enabled && !strings.HasPrefix(srv.Metrics.Path, "/") {
+		p.Addf("http_server.metrics.path", "путь должен начинаться с /")
+	}
+
+	c.Tracing.Validate(p, "tracing")
+}
 
-	if err := godotenv.Load(envPath); err != nil {
-		return nil, fmt.Errorf("не удалось прочитать env. Возникла ошибка %w", err)
+func (c AuthConf) validate(p *appconfig.Problems, secret string) {
+	switch c.Algorithm {
+	case auth.AlgorithmHS256:
+		if secret == "" {
+			p.Addf("JWT_SECRET", "в режиме %s нужен общий секрет", auth.AlgorithmHS256)
+		} else if p.Profile() == appconfig.ProfileProd && len(secret) < appconfig.MinSecretLength {
+			p.Addf("JWT_SECRET", "в prod секрет должен быть не короче %d символов", appconfig.MinSecretLength)
+		}
+	case auth.AlgorithmAsymmetric:
+		if len(c.Keys) == 0 {
+			p.Addf("http_server.auth.keys", "в режиме %s нужен хотя бы один ключ", auth.AlgorithmAsymmetric)
+		}
+		for i, key := range c.Keys {
+			field := fmt.Sprintf("http_server.auth.keys[%d]", i)
+			if key.ID == "" || key.File == "" {
+				p.Addf(field, "нужны id и file")
+			}
+			if len(key.Issuers) == 0 || len(key.Roles) == 0 {
+				p.Addf(field, "нужны issuers и roles, которые может подписывать ключ")
+			}
+			for _, issuer := range key.Issuers {
+				for _, role := range key.Roles {
+					if !auth.IssuerAllowsRole(issuer, role) {
+						p.Addf(field, "издатель %s не может выдавать роль %s", issuer, role)
+					}
+				}
+			}
+		}
+	default:
+		p.Addf("http_server.auth.algorithm", "неизвестный алгоритм подписи токенов %q", c.Algorithm)
 	}
 
-	if err := cleanenv.ParseYAML(file, &config); err != nil {
-		return nil, fmt.Errorf("не удалось прочитать конфиг. Возникла ошибка %w", err)
+	if len(c.Issuers) == 0 {
+		p.Addf("http_server.auth.issuers", "нужен хотя бы один доверенный издатель")
 	}
+}
 
-	if err := cleanenv.ReadEnv(&config); err != nil {
-		return nil, fmt.Errorf("не удалось прочитать env переменные. Возникла ошибка %w", err)
+func (c StorageConf) validate(p *appconfig.Problems) {
+	switch c.Backend {
+	case blobstore.BackendFS:
+		if c.FS.Dir == "" {
+			p.Addf("http_server.storage.fs.dir", "нужна папка для файлов")
+		}
+	case blobstore.BackendS3:
+		if c.S3.Endpoint == "" {
+			p.Addf("http_server.storage.s3.endpoint", "нужен адрес хранилища")
+		} else if strings.Contains(c.S3.Endpoint, "://") {
+			p.Addf("http_server.storage.s3.endpoint", "адрес указывается без схемы, для https есть use_ssl")
+		}
+		if c.S3.Bucket == "" {
+			p.Addf("http_server.storage.s3.bucket", "нужен бакет")
+		}
+		if c.S3.AccessKey == "" || c.S3.SecretKey == "" {
+			p.Addf("S3_ACCESS_KEY", "для хранилища s3 нужны S3_ACCESS_KEY и S3_SECRET_KEY")
+		}
+	default:
+		p.Addf("http_server.storage.backend", "неизвестное хранилище файлов %q", c.Backend)
 	}
+}
 
-	return &config, nil
+func (c ArtifactsConf) validate(p *appconfig.Problems) {
+	if c.PublicURL == "" {
+		p.Addf("http_server.artifacts.public_url", "нужен адрес сервера для ссылок на скачивание")
+	} else if !strings.HasPrefix(c.PublicURL, "http://") && !strings.HasPrefix(c.PublicURL, "https://") {
+		p.Addf("http_server.artifacts.public_url", "адрес должен начинаться с http:// или https://")
+	}
+	if c.LinkTTL <= 0 {
+		p.Addf("http_server.artifacts.link_ttl", "срок действия ссылки должен быть положительным")
+	}
+
+	// Реплики должны подписывать ссылки одним ключом, а ссылки — переживать выкатку
+	if p.Profile() == appconfig.ProfileProd && len(c.LinkSecret) < appconfig.MinSecretLength {
+		p.Addf("ARTIFACT_LINK_SECRET", "в prod ключ подписи ссылок должен быть не короче %d символов", appconfig.MinSecretLength)
+	}
+	// Коды в утекших копиях проверяются спустя месяцы, ключ должен жить дольше сервера
+	if p.Profile() == appconfig.ProfileProd && len(c.WatermarkSecret) < appconfig.MinSecretLength {
+		p.Addf("ARTIFACT_WATERMARK_SECRET", "в prod ключ подписи меток должен быть не короче %d символов", appconfig.MinSecretLength)
+	}
+}
+
+// TrustedProxyHops число доверенных прокси перед сервером, 0 — X-Forwarded-For не читается
+func (c RateLimitConf) TrustedProxyHops() int {
+	if !c.TrustProxy {
+		return 0
+	}
+
+	return c.ProxyHops
+}
+
+func (c RateLimitConf) validate(p *appconfig.Problems) {
+	if c.Backend != "memory" && c.Backend != "postgres" {
+		p.Addf("http_server.rate_limit.backend", "неизвестное хранилище лимитов %q", c.Backend)
+	}
+
+	if c.TrustProxy && c.ProxyHops < 1 {
+		p.Addf("http_server.rate_limit.proxy_hops", "с trust_proxy нужен хотя бы один доверенный прокси")
+	}
+
+	for name, group := range c.Groups {
+		for _, limit := range []*LimitConf{group.IP, group.LicenseKey, group.TelegramID} {
+			if limit != nil && (limit.PerMinute <= 0 || limit.Burst < 1) {
+				p.Addf("http_server.rate_limit.groups."+name, "per_minute и burst должны быть положительными")
+				break
+			}
+		}
+	}
 }
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/artifacts"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// maxArtifactUpload предел тела запроса загрузки: файл до 200 МБ и поля формы
+	maxArtifactUpload = 201 << 20
+	// artifactFormMemory сколько формы держать в памяти, остальное уходит во временные файлы
+	artifactFormMemory = 1 << 20
+)
+
+type ArtifactsService interface {
+	Upload(ctx context.Context, upload artifacts.Upload) (*models.Artifact, error)
+	List(ctx context.Context, productID int64) ([]*models.Artifact, error)
+	Latest(ctx context.Context, telegramID, productID int64) (*models.Artifact, error)
+	Deliver(ctx context.Context, telegramID, productID int64) (*artifacts.Download, error)
+	Link(ctx context.Context, telegramID, productID int64) (*artifacts.Link, error)
+	OpenLink(ctx context.Context, token string) (*artifacts.Download, error)
+	Trace(ctx context.Context, mark string) (*models.DeliveryTrace, error)
+}
+
+// ArtifactsHandler материалы продуктов: загрузка администратором, выдача
+// покупателям через бота и по временным ссылкам, поиск покупателя по метке
+type ArtifactsHandler struct {
+	service   ArtifactsService
+	publicURL string
+	logger    *slog.Logger
+}
+
+// NewArtifactsHandler publicURL — адрес сервера, по которому покупатели открывают
+// ссылки на скачивание
+func NewArtifactsHandler(service ArtifactsService, publicURL string, logger *slog.Logger) *ArtifactsHandler {
+	return &ArtifactsHandler{
+		service:   service,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		logger:    logger,
+	}
+}
+
+type artifactResponse struct {
+	ID          int64     `json:"id"`
+	ProductID   int64     `json:"product_id"`
+	Version     int       `json:"version"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	Notes       string    `json:"notes,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type artifactRequest struct {
+	TelegramID int64 `json:"telegram_id"`
+}
+
+type artifactLinkResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Version   int       `json:"version"`
+	FileName  string    `json:"file_name"`
+}
+
+type deliveryTraceResponse struct {
+	Watermark   string           `json:"watermark"`
+	Channel     string           `json:"channel"`
+	DeliveredAt time.Time        `json:"delivered_at"`
+	LicenseID   int64            `json:"license_id"`
+	TelegramID  int64            `json:"telegram_id"`
+	UserName    string           `json:"user_name"`
+	UserGroup   string           `json:"user_group"`
+	Bound       bool             `json:"bound"`
+	Artifact    artifactResponse `json:"artifact"`
+}
+
+// HandleUpload загружает новую версию материалов. Форма multipart/form-data:
+// файл в поле file и необязательное описание изменений в notes.
+func (h *ArtifactsHandler) HandleUpload(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный id продукта")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxArtifactUpload)
+	if err := r.ParseMultipartForm(artifactFormMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "Файл слишком большой")
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Ожидается форма multipart/form-data")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Нет файла в поле file")
+		return
+	}
+	defer file.Close()
+
+	artifact, err := h.service.Upload(r.Context(), artifacts.Upload{
+		ProductID: productID,
+		FileName:  header.Filename,
+		Notes:     r.FormValue("notes"),
+		Size:      header.Size,
+		Content:   file,
+	})
+	if err != nil {
+		h.writeServiceError(w, r, "handlers.ArtifactUpload", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, toArtifactResponse(artifact))
+}
+
+// HandleList все версии материалов продукта, новые первыми
+func (h *ArtifactsHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный id продукта")
+		return
+	}
+
+	list, err := h.service.List(r.Context(), productID)
+	if err != nil {
+		h.writeServiceError(w, r, "handlers.ArtifactList", err)
+		return
+	}
+
+	resp := make([]artifactResponse, 0, len(list))
+	for _, a := range list {
+		resp = append(resp, toArtifactResponse(a))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleTrace покупатель, которому выдана копия с меткой из параметра watermark
+func (h *ArtifactsHandler) HandleTrace(w http.ResponseWriter, r *http.Request) {
+	mark := r.URL.Query().Get("watermark")
+	if mark == "" {
+		writeError(w, http.StatusBadRequest, "Не указана метка")
+		return
+	}
+
+	trace, err := h.service.Trace(r.Context(), mark)
+	if err != nil {
+		h.writeServiceError(w, r, "handlers.ArtifactTrace", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deliveryTraceResponse{
+		Watermark:   trace.Delivery.Watermark,
+		Channel:     trace.Delivery.Channel,
+		DeliveredAt: trace.Delivery.CreatedAt,
+		LicenseID:   trace.Delivery.LicenseID,
+		TelegramID:  trace.TelegramID,
+		UserName:    trace.UserName,
+		UserGroup:   trace.UserGroup,
+		Bound:       trace.Bound,
+		Artifact:    toArtifactResponse(&trace.Artifact),
+	})
+}
+
+// HandleLatest последняя версия материалов для покупателя, бот показывает ее в /my
+func (h *ArtifactsHandler) HandleLatest(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный id продукта")
+		return
+	}
+	telegramID, err := strconv.ParseInt(r.URL.Query().Get("telegram_id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный telegram_id")
+		return
+	}
+
+	artifact, err := h.service.Latest(r.Context(), telegramID, productID)
+	if err != nil {
+		h.writeServiceError(w, r, "handlers.ArtifactLatest", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toArtifactResponse(artifact))
+}
+
+// HandleDeliver отдает боту копию с меткой покупателя, бот отправляет ее документом
+func (h *ArtifactsHandler) HandleDeliver(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный id продукта")
+		return
+	}
+
+	var req artifactRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Неверное тело запроса")
+		return
+	}
+
+	download, err := h.service.Deliver(r.Context(), req.TelegramID, productID)
+	if err != nil {
+		h.writeServiceError(w, r, "handlers.ArtifactDeliver", err)
+		return
+	}
+	defer download.Close()
+
+	h.serveDownload(w, r, download)
+}
+
+// HandleLink временная ссылка на скачивание с сервера, для файлов, которые
+// не стоит или нельзя отправлять через Telegram
+func (h *ArtifactsHandler) HandleLink(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный id продукта")
+		return
+	}
+
+	var req artifactRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Неверное тело запроса")
+		return
+	}
+
+	link, err := h.service.Link(r.Context(), req.TelegramID, productID)
+	if err != nil {
+		h.writeServiceError(w, r, "handlers.ArtifactLink", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, artifactLinkResponse{
+		URL:       h.publicURL + "/api/v1/downloads/" + link.Token,
+		ExpiresAt: link.ExpiresAt,
+		Version:   link.Artifact.Version,
+		FileName:  link.Artifact.FileName,
+	})
+}
+
+// HandleDownload скачивание по временной ссылке. Токен в пути заменяет авторизацию.
+func (h *ArtifactsHandler) HandleDownload(w http.ResponseWriter, r *http.Request) {
+	download, err := h.service.OpenLink(r.Context(), chi.URLParam(r, "token"))
+	if err != nil {
+		h.writeServiceError(w, r, "handlers.ArtifactDownload", err)
+		return
+	}
+	defer download.Close()
+
+	h.serveDownload(w, r, download)
+}
+
+// serveDownload отдает копию с поддержкой Range, чтобы прерванное скачивание
+// большого архива можно было продолжить
+func (h *ArtifactsHandler) serveDownload(w http.ResponseWriter, r *http.Request, download *artifacts.Download) {
+	artifact := download.Artifact
+
+	w.Header().Set("Content-Type", artifact.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": artifact.FileName}))
+	w.Header().Set("X-Artifact-Version", strconv.Itoa(artifact.Version))
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	http.ServeContent(w, r, artifact.FileName, artifact.CreatedAt, download)
+}
+
+func (h *ArtifactsHandler) writeServiceError(w http.ResponseWriter, r *http.Request, op string, err error) {
+	switch {
+	case errors.Is(err, artifacts.ErrInvalidArtifact):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, artifacts.ErrNoAccess):
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	case errors.Is(err, artifacts.ErrProductNotFound), errors.Is(err, artifacts.ErrUserNotFound),
+		errors.Is(err, artifacts.ErrArtifactNotFound), errors.Is(err, artifacts.ErrWatermarkNotFound),
+		errors.Is(err, artifacts.ErrLinkInvalid):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, artifacts.ErrVersionConflict):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, artifacts.ErrLinkExpired):
+		writeError(w, http.StatusGone, err.Error())
+		return
+	}
+
+	h.logger.ErrorContext(r.Context(), "Ошибка материалов продукта", slog.String("op", op), slog.String("error", err.Error()))
+	writeError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+}
+
+func toArtifactResponse(a *models.Artifact) artifactResponse {
+	return artifactResponse{
+		ID:          a.ID,
+		ProductID:   a.ProductID,
+		Version:     a.Version,
+		FileName:    a.FileName,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		SHA256:      a.SHA256,
+		Notes:       a.Notes,
+		CreatedAt:   a.CreatedAt,
+	}
+}
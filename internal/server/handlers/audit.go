@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+)
+
+type AuditService interface {
+	Query(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEvent, error)
+	Export(ctx context.Context, filter models.AuditFilter, fn func(*models.AuditEvent) error) error
+}
+
+// AuditHandler просмотр и выгрузка журнала аудита для администраторов
+type AuditHandler struct {
+	service AuditService
+	logger  *slog.Logger
+}
+
+func NewAuditHandler(service AuditService, logger *slog.Logger) *AuditHandler {
+	return &AuditHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+type auditEventResponse struct {
+	ID         int64          `json:"id"`
+	Action     string         `json:"action"`
+	ActorType  string         `json:"actor_type"`
+	ActorID    string         `json:"actor_id,omitempty"`
+	TargetType string         `json:"target_type,omitempty"`
+	TargetID   string         `json:"target_id,omitempty"`
+	IP         string         `json:"ip,omitempty"`
+	RequestID  string         `json:"request_id,omitempty"`
+	Via        string         `json:"via,omitempty"`
+	Details    map[string]any `json:"details,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// HandleList страница журнала. Следующая страница запрашивается с before_id = id последней записи.
+func (h *AuditHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.AuditList"
+
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	events, err := h.service.Query(r.Context(), filter)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "Ошибка чтения журнала аудита", slog.String("op", op), slog.String("error", err.Error()))
+		writeError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+		return
+	}
+
+	resp := make([]auditEventResponse, 0, len(events))
+	for _, event := range events {
+		resp = append(resp, newAuditEventResponse(event))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleExport выгружает журнал в формате JSON lines: по одной записи в строке
+func (h *AuditHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.AuditExport"
+
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+
+	encoder := json.NewEncoder(w)
+	err = h.service.Export(r.Context(), filter, func(event *models.AuditEvent) error {
+		return encoder.Encode(newAuditEventResponse(event))
+	})
+	if err != nil {
+		// Заголовки уже отправлены, клиент увидит обрезанный файл
+		h.logger.ErrorContext(r.Context(), "Ошибка выгрузки журнала аудита", slog.String("op", op), slog.String("error", err.Error()))
+	}
+}
+
+// parseAuditFilter разбирает фильтры из query. Текст ошибки можно отдавать клиенту.
+func parseAuditFilter(r *http.Request) (models.AuditFilter, error) {
+	query := r.URL.Query()
+
+	filter := models.AuditFilter{
+		Action:     query.Get("action"),
+		ActorType:  query.Get("actor_type"),
+		ActorID:    query.Get("actor_id"),
+		TargetType: query.Get("target_type"),
+		TargetID:   query.Get("target_id"),
+	}
+
+	if raw := query.Get("from"); raw != "" {
+		from, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return filter, errors.New("Неверный from, ожидается время в формате RFC 3339")
+		}
+		filter.From = &from
+	}
+
+	if raw := query.Get("to"); raw != "" {
+		to, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return filter, errors.New("Неверный to, ожидается время в формате RFC 3339")
+		}
+		filter.To = &to
+	}
+
+	if raw := query.Get("before_id"); raw != "" {
+		beforeID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || beforeID <= 0 {
+			return filter, errors.New("Неверный before_id")
+		}
+		filter.BeforeID = beforeID
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return filter, errors.New("Неверный limit")
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+func newAuditEventResponse(event *models.AuditEvent) auditEventResponse {
+	return auditEventResponse{
+		ID:         event.ID,
+		Action:     event.Action,
+		ActorType:  event.ActorType,
+		ActorID:    event.ActorID,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		IP:         event.IP,
+		RequestID:  event.RequestID,
+		Via:        event.Via,
+		Details:    event.Details,
+		CreatedAt:  event.CreatedAt,
+	}
+}
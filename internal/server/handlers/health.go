@@ -1,14 +0,0 @@
-package handlers
-
-import (
-	"fmt"
-	"net/http"
-)
-
-func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-
-	w.WriteHeader(http.StatusOK)
-
-	fmt.Fprintln(w, "OK")
-}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/GeorgeTyupin/labguard/pkg/auth"
+)
+
+type JWKSHandler struct {
+	keys *auth.KeyRing
+}
+
+func NewJWKSHandler(keys *auth.KeyRing) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+// Handle публикует открытые ключи проверки токенов, чтобы другие сервисы могли
+// проверять токены без общего секрета
+func (h *JWKSHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, h.keys.JWKS())
+}
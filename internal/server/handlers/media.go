@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/media"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// maxMediaUpload предел тела запроса загрузки: самый большой файл и поля формы
+	maxMediaUpload = 51 << 20
+	// mediaFormMemory сколько формы держать в памяти, остальное уходит во временные файлы
+	mediaFormMemory = 1 << 20
+)
+
+type MediaService interface {
+	Upload(ctx context.Context, upload media.Upload) (*models.ProductMedia, error)
+	List(ctx context.Context, productID int64) ([]*models.ProductMedia, error)
+	Open(ctx context.Context, mediaID int64) (*models.ProductMedia, io.ReadCloser, error)
+	Delete(ctx context.Context, productID, mediaID int64) error
+}
+
+// MediaHandler обложки, скриншоты и примеры работ в карточках продуктов
+type MediaHandler struct {
+	service MediaService
+	logger  *slog.Logger
+}
+
+func NewMediaHandler(service MediaService, logger *slog.Logger) *MediaHandler {
+	return &MediaHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+type mediaResponse struct {
+	ID          int64     `json:"id"`
+	ProductID   int64     `json:"product_id"`
+	Kind        string    `json:"kind"`
+	Position    int       `json:"position"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// HandleUpload прикрепляет файл к продукту. Форма multipart/form-data: kind (cover,
+// screenshot, sample), необязательный position и файл в поле file.
+func (h *MediaHandler) HandleUpload(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный id продукта")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxMediaUpload)
+	if err := r.ParseMultipartForm(mediaFormMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "Файл слишком большой")
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Ожидается форма multipart/form-data")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	var position int
+	if raw := r.FormValue("position"); raw != "" {
+		position, err = strconv.Atoi(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Неверная позиция")
+			return
+		}
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Нет файла в поле file")
+		return
+	}
+	defer file.Close()
+
+	uploaded, err := h.service.Upload(r.Context(), media.Upload{
+		ProductID: productID,
+		Kind:      r.FormValue("kind"),
+		Position:  position,
+		FileName:  header.Filename,
+		Size:      header.Size,
+		Content:   file,
+	})
+	if err != nil {
+		h.writeServiceError(w, r, "handlers.MediaUpload", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, toMediaResponse(uploaded))
+}
+
+// HandleList файлы карточки продукта в порядке показа
+func (h *MediaHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный id продукта")
+		return
+	}
+
+	list, err := h.service.List(r.Context(), productID)
+	if err != nil {
+		h.writeServiceError(w, r, "handlers.MediaList", err)
+		return
+	}
+
+	resp := make([]mediaResponse, 0, len(list))
+	for _, m := range list {
+		resp = append(resp, toMediaResponse(m))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleDownload отдает содержимое файла. ETag — sha256 содержимого, поэтому
+// повторный запрос с If-None-Match получает 304 без чтения хранилища.
+func (h *MediaHandler) HandleDownload(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.MediaDownload"
+
+	mediaID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный id файла")
+		return
+	}
+
+	m, content, err := h.service.Open(r.Context(), mediaID)
+	if err != nil {
+		h.writeServiceError(w, r, op, err)
+		return
+	}
+	defer content.Close()
+
+	etag := strconv.Quote(m.SHA256)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	disposition := "inline"
+	if m.Kind == models.MediaSample {
+		disposition = "attachment"
+	}
+
+	w.Header().Set("Content-Type", m.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(m.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": m.FileName}))
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusOK)
+
+	// Заголовки уже отправлены, ошибку можно только записать в лог
+	if _, err := io.Copy(w, content); err != nil {
+		h.logger.WarnContext(r.Context(), "Не удалось отдать файл",
+			slog.String("op", op),
+			slog.Int64("media_id", mediaID),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+func (h *MediaHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный id продукта")
+		return
+	}
+	mediaID, err := strconv.ParseInt(chi.URLParam(r, "media_id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный id файла")
+		return
+	}
+
+	if err := h.service.Delete(r.Context(), productID, mediaID); err != nil {
+		h.writeServiceError(w, r, "handlers.MediaDelete", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *MediaHandler) writeServiceError(w http.ResponseWriter, r *http.Request, op string, err error) {
+	switch {
+	case errors.Is(err, media.ErrInvalidMedia):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, media.ErrProductNotFound), errors.Is(err, media.ErrMediaNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, media.ErrMediaExists), errors.Is(err, media.ErrTooManyMedia):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	h.logger.ErrorContext(r.Context(), "Ошибка файлов продукта", slog.String("op", op), slog.String("error", err.Error()))
+	writeError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+}
+
+func toMediaResponse(m *models.ProductMedia) mediaResponse {
+	return mediaResponse{
+		ID:          m.ID,
+		ProductID:   m.ProductID,
+		Kind:        m.Kind,
+		Position:    m.Position,
+		FileName:    m.FileName,
+		ContentType: m.ContentType,
+		Size:        m.Size,
+		SHA256:      m.SHA256,
+		CreatedAt:   m.CreatedAt,
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+)
+
+const (
+	defaultNotificationsLimit = 50
+	maxNotificationsLimit     = 500
+	// notificationsLease сколько выданные реплике бота уведомления не выдаются другим.
+	// Неподтвержденные за это время уведомления выдаются снова.
+	notificationsLease = 2 * time.Minute
+)
+
+type NotificationsRepository interface {
+	ClaimNotifications(ctx context.Context, limit int, lease time.Duration) ([]*models.Notification, error)
+	MarkNotificationsSent(ctx context.Context, ids []int64) error
+}
+
+// NotificationsHandler отдает боту очередь сообщений пользователям
+type NotificationsHandler struct {
+	repo   NotificationsRepository
+	logger *slog.Logger
+}
+
+func NewNotificationsHandler(repo NotificationsRepository, logger *slog.Logger) *NotificationsHandler {
+	return &NotificationsHandler{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+type notificationResponse struct {
+	ID         int64     `json:"id"`
+	TelegramID int64     `json:"telegram_id"`
+	Text       string    `json:"text"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ackNotificationsRequest struct {
+	IDs []int64 `json:"ids"`
+}
+
+// HandlePending выдает реплике бота уведомления из очереди. Каждое уведомление
+// достается одной реплике, поэтому несколько реплик не отправляют его дважды.
+func (h *NotificationsHandler) HandlePending(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.NotificationsPending"
+	logger := h.logger.With(slog.String("op", op))
+
+	limit := defaultNotificationsLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, "Неверный limit")
+			return
+		}
+		limit = min(parsed, maxNotificationsLimit)
+	}
+
+	notifications, err := h.repo.ClaimNotifications(r.Context(), limit, notificationsLease)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Не удалось получить очередь уведомлений", slog.String("error", err.Error()))
+		writeError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+		return
+	}
+
+	resp := make([]notificationResponse, 0, len(notifications))
+	for _, n := range notifications {
+		resp = append(resp, notificationResponse{
+			ID:         n.ID,
+			TelegramID: n.TelegramID,
+			Text:       n.Text,
+			CreatedAt:  n.CreatedAt,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *NotificationsHandler) HandleAck(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.NotificationsAck"
+	logger := h.logger.With(slog.String("op", op))
+
+	var req ackNotificationsRequest
+	if err := decodeJSON(r, &req); err != nil || len(req.IDs) == 0 {
+		writeError(w, http.StatusBadRequest, "Неверное тело запроса")
+		return
+	}
+
+	if err := h.repo.MarkNotificationsSent(r.Context(), req.IDs); err != nil {
+		logger.ErrorContext(r.Context(), "Не удалось отметить уведомления", slog.String("error", err.Error()))
+		writeError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/promo"
+)
+
+type PromoService interface {
+	Create(ctx context.Context, promo *models.PromoCode) error
+	Quote(ctx context.Context, telegramID, productID int64, code string) (*models.PriceQuote, error)
+}
+
+type PromoHandler struct {
+	service PromoService
+	logger  *slog.Logger
+}
+
+func NewPromoHandler(service PromoService, logger *slog.Logger) *PromoHandler {
+	return &PromoHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+type createPromoRequest struct {
+	Code           string     `json:"code"`
+	DiscountType   string     `json:"discount_type"`
+	DiscountValue  int64      `json:"discount_value"`
+	ProductID      *int64     `json:"product_id"`
+	Group          string     `json:"group"`
+	MaxUses        *int       `json:"max_uses"`
+	MaxUsesPerUser int        `json:"max_uses_per_user"`
+	ValidFrom      *time.Time `json:"valid_from"`
+	ValidUntil     *time.Time `json:"valid_until"`
+}
+
+type promoResponse struct {
+	ID             int64      `json:"id"`
+	Code           string     `json:"code"`
+	DiscountType   string     `json:"discount_type"`
+	DiscountValue  int64      `json:"discount_value"`
+	ProductID      *int64     `json:"product_id,omitempty"`
+	Group          string     `json:"group,omitempty"`
+	MaxUses        *int       `json:"max_uses,omitempty"`
+	MaxUsesPerUser int        `json:"max_uses_per_user"`
+	ValidFrom      time.Time  `json:"valid_from"`
+	ValidUntil     *time.Time `json:"valid_until,omitempty"`
+}
+
+type quoteRequest struct {
+	TelegramID int64  `json:"telegram_id"`
+	ProductID  int64  `json:"product_id"`
+	Code       string `json:"code"`
+}
+
+// quoteResponse суммы в копейках
+type quoteResponse struct {
+	ProductID     int64  `json:"product_id"`
+	Code          string `json:"code"`
+	OriginalPrice int64  `json:"original_price"`
+	Discount      int64  `json:"discount"`
+	Price         int64  `json:"price"`
+}
+
+func (h *PromoHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	var req createPromoRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Неверное тело запроса")
+		return
+	}
+
+	code := &models.PromoCode{
+		Code:           req.Code,
+		DiscountType:   req.DiscountType,
+		DiscountValue:  req.DiscountValue,
+		ProductID:      req.ProductID,
+		Group:          req.Group,
+		MaxUses:        req.MaxUses,
+		MaxUsesPerUser: req.MaxUsesPerUser,
+		ValidUntil:     req.ValidUntil,
+	}
+	if req.ValidFrom != nil {
+		code.ValidFrom = *req.ValidFrom
+	}
+	if code.MaxUsesPerUser == 0 {
+		code.MaxUsesPerUser = 1
+	}
+
+	if err := h.service.Create(r.Context(), code); err != nil {
+		h.writeServiceError(w, r, "handlers.PromoCreate", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, promoResponse{
+		ID:             code.ID,
+		Code:           code.Code,
+		DiscountType:   code.DiscountType,
+		DiscountValue:  code.DiscountValue,
+		ProductID:      code.ProductID,
+		Group:          code.Group,
+		MaxUses:        code.MaxUses,
+		MaxUsesPerUser: code.MaxUsesPerUser,
+		ValidFrom:      code.ValidFrom,
+		ValidUntil:     code.ValidUntil,
+	})
+}
+
+func (h *PromoHandler) HandleQuote(w http.ResponseWriter, r *http.Request) {
+	var req quoteRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Неверное тело запроса")
+		return
+	}
+
+	quote, err := h.service.Quote(r.Context(), req.TelegramID, req.ProductID, req.Code)
+	if err != nil {
+		h.writeServiceError(w, r, "handlers.PromoQuote", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, quoteResponse{
+		ProductID:     quote.ProductID,
+		Code:          quote.PromoCode.Code,
+		OriginalPrice: quote.OriginalPrice,
+		Discount:      quote.Discount,
+		Price:         quote.Price,
+	})
+}
+
+func (h *PromoHandler) writeServiceError(w http.ResponseWriter, r *http.Request, op string, err error) {
+	if status, ok := promoErrorStatus(err); ok {
+		writeError(w, status, err.Error())
+		return
+	}
+
+	h.logger.ErrorContext(r.Context(), "Ошибка промокода", slog.String("op", op), slog.String("error", err.Error()))
+	writeError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+}
+
+// promoErrorStatus возвращает HTTP статус для ошибок промокода, которые можно показать пользователю
+func promoErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, promo.ErrInvalidPromo):
+		return http.StatusBadRequest, true
+	case errors.Is(err, promo.ErrUserNotFound),
+		errors.Is(err, promo.ErrProductNotFound),
+		errors.Is(err, promo.ErrPromoNotFound):
+		return http.StatusNotFound, true
+	case errors.Is(err, promo.ErrCodeTaken):
+		return http.StatusConflict, true
+	case errors.Is(err, promo.ErrPromoNotStarted),
+		errors.Is(err, promo.ErrPromoExpired),
+		errors.Is(err, promo.ErrPromoWrongProduct),
+		errors.Is(err, promo.ErrPromoWrongGroup),
+		errors.Is(err, promo.ErrPromoExhausted):
+		return http.StatusUnprocessableEntity, true
+	}
+
+	return 0, false
+}
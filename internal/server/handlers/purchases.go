@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/purchases"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/seats"
+	"github.com/go-chi/chi/v5"
+)
+
+type PurchaseService interface {
+	Buy(ctx context.Context, telegramID, productID int64, promoCode string, useBalance bool) (*models.Purchase, error)
+	BuySeats(ctx context.Context, telegramID, productID int64, seats int, restrictGroup bool) (*models.Purchase, error)
+	Confirm(ctx context.Context, telegramID, purchaseID int64) (*models.Purchase, error)
+}
+
+type PurchasesHandler struct {
+	service PurchaseService
+	logger  *slog.Logger
+}
+
+func NewPurchasesHandler(service PurchaseService, logger *slog.Logger) *PurchasesHandler {
+	return &PurchasesHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+type buyRequest struct {
+	TelegramID int64  `json:"telegram_id"`
+	ProductID  int64  `json:"product_id"`
+	PromoCode  string `json:"promo_code"`
+	UseBalance bool   `json:"use_balance"` // Оплатить частично или полностью с внутреннего баланса
+}
+
+type buySeatsRequest struct {
+	TelegramID    int64 `json:"telegram_id"`
+	ProductID     int64 `json:"product_id"`
+	Seats         int   `json:"seats"`
+	RestrictGroup bool  `json:"restrict_group"`
+}
+
+type confirmPurchaseRequest struct {
+	TelegramID int64 `json:"telegram_id"`
+}
+
+// purchaseResponse суммы в копейках. Покупку в статусе pending нужно оплатить
+// на странице payment_url и подтвердить. У покупки мест seat_pool_id — групповая
+// лицензия, которая открывается после оплаты.
+type purchaseResponse struct {
+	ID            int64  `json:"id"`
+	ProductID     int64  `json:"product_id"`
+	OriginalPrice int64  `json:"original_price"`
+	Discount      int64  `json:"discount"`
+	Amount        int64  `json:"amount"`
+	BalanceUsed   int64  `json:"balance_used"`
+	Status        string `json:"status"`
+	PaymentURL    string `json:"payment_url,omitempty"`
+	Seats         int    `json:"seats,omitempty"`
+	SeatPoolID    *int64 `json:"seat_pool_id,omitempty"`
+}
+
+func (h *PurchasesHandler) HandleBuy(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.PurchasesBuy"
+	logger := h.logger.With(slog.String("op", op))
+
+	var req buyRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Неверное тело запроса")
+		return
+	}
+
+	purchase, err := h.service.Buy(r.Context(), req.TelegramID, req.ProductID, req.PromoCode, req.UseBalance)
+	if err != nil {
+		if status, ok := purchaseErrorStatus(err); ok {
+			writeError(w, status, err.Error())
+			return
+		}
+
+		logger.ErrorContext(r.Context(), "Ошибка оформления покупки", slog.String("error", err.Error()))
+		writeError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newPurchaseResponse(purchase))
+}
+
+// HandleBuySeats староста покупает групповую лицензию
+func (h *PurchasesHandler) HandleBuySeats(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.PurchasesBuySeats"
+	logger := h.logger.With(slog.String("op", op))
+
+	var req buySeatsRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Неверное тело запроса")
+		return
+	}
+
+	purchase, err := h.service.BuySeats(r.Context(), req.TelegramID, req.ProductID, req.Seats, req.RestrictGroup)
+	if err != nil {
+		if status, ok := purchaseErrorStatus(err); ok {
+			writeError(w, status, err.Error())
+			return
+		}
+
+		logger.ErrorContext(r.Context(), "Ошибка оформления групповой лицензии", slog.String("error", err.Error()))
+		writeError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newPurchaseResponse(purchase))
+}
+
+// HandleConfirm проверяет оплату покупки у провайдера и завершает ее
+func (h *PurchasesHandler) HandleConfirm(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.PurchasesConfirm"
+	logger := h.logger.With(slog.String("op", op))
+
+	purchaseID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный id покупки")
+		return
+	}
+
+	var req confirmPurchaseRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Неверное тело запроса")
+		return
+	}
+
+	purchase, err := h.service.Confirm(r.Context(), req.TelegramID, purchaseID)
+	if err != nil {
+		if status, ok := purchaseErrorStatus(err); ok {
+			writeError(w, status, err.Error())
+			return
+		}
+
+		logger.ErrorContext(r.Context(), "Ошибка подтверждения оплаты", slog.String("error", err.Error()))
+		writeError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newPurchaseResponse(purchase))
+}
+
+func purchaseErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, seats.ErrInvalidSeats):
+		return http.StatusBadRequest, true
+	case errors.Is(err, purchases.ErrNotLeader):
+		return http.StatusForbidden, true
+	case errors.Is(err, purchases.ErrUserNotFound),
+		errors.Is(err, purchases.ErrProductNotFound),
+		errors.Is(err, purchases.ErrPurchaseNotFound):
+		return http.StatusNotFound, true
+	case errors.Is(err, purchases.ErrPaymentPending),
+		errors.Is(err, purchases.ErrPaymentCanceled),
+		errors.Is(err, purchases.ErrAlreadyOwned):
+		return http.StatusConflict, true
+	}
+
+	return promoErrorStatus(err)
+}
+
+func newPurchaseResponse(purchase *models.Purchase) purchaseResponse {
+	return purchaseResponse{
+		ID:            purchase.ID,
+		ProductID:     purchase.ProductID,
+		OriginalPrice: purchase.OriginalPrice,
+		Discount:      purchase.Discount,
+		Amount:        purchase.Amount,
+		BalanceUsed:   purchase.BalanceUsed,
+		Status:        purchase.Status,
+		PaymentURL:    purchase.PaymentURL,
+		Seats:         purchase.Seats,
+		SeatPoolID:    purchase.SeatPoolID,
+	}
+}
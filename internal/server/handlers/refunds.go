@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/refunds"
+	"github.com/go-chi/chi/v5"
+)
+
+type RefundsService interface {
+	Request(ctx context.Context, telegramID, productID int64, reason string) (*models.Refund, error)
+	RefundPurchase(ctx context.Context, purchaseID int64, reason string) (*models.RefundDetails, error)
+	Approve(ctx context.Context, refundID int64) (*models.RefundDetails, error)
+	Reject(ctx context.Context, refundID int64, note string) (*models.RefundDetails, error)
+	List(ctx context.Context, status string, limit int) ([]*models.RefundDetails, error)
+}
+
+// RefundsHandler принимает заявки на возврат от бота и решения администратора
+type RefundsHandler struct {
+	service RefundsService
+	logger  *slog.Logger
+}
+
+func NewRefundsHandler(service RefundsService, logger *slog.Logger) *RefundsHandler {
+	return &RefundsHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+type requestRefundRequest struct {
+	TelegramID int64  `json:"telegram_id"`
+	ProductID  int64  `json:"product_id"`
+	Reason     string `json:"reason"`
+}
+
+type adminRefundRequest struct {
+	PurchaseID int64  `json:"purchase_id"`
+	Reason     string `json:"reason"`
+}
+
+type rejectRefundRequest struct {
+	Note string `json:"note"`
+}
+
+// refundResponse суммы в копейках
+type refundResponse struct {
+	ID               int64      `json:"id"`
+	PurchaseID       int64      `json:"purchase_id"`
+	ProductID        int64      `json:"product_id,omitempty"`
+	ProductName      string     `json:"product_name,omitempty"`
+	TelegramID       int64      `json:"telegram_id,omitempty"`
+	Initiator        string     `json:"initiator"`
+	Reason           string     `json:"reason,omitempty"`
+	Status           string     `json:"status"`
+	Amount           int64      `json:"amount"`
+	BalanceUsed      int64      `json:"balance_used"`
+	ProviderRefundID string     `json:"provider_refund_id,omitempty"`
+	Note             string     `json:"note,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ResolvedAt       *time.Time `json:"resolved_at,omitempty"`
+}
+
+// HandleRequest заявка пользователя из бота
+func (h *RefundsHandler) HandleRequest(w http.ResponseWriter, r *http.Request) {
+	var req requestRefundRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Неверное тело запроса")
+		return
+	}
+
+	refund, err := h.service.Request(r.Context(), req.TelegramID, req.ProductID, req.Reason)
+	if err != nil {
+		h.writeServiceError(w, r, "handlers.RefundRequest", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, refundResponse{
+		ID:         refund.ID,
+		PurchaseID: refund.PurchaseID,
+		ProductID:  req.ProductID,
+		TelegramID: req.TelegramID,
+		Initiator:  refund.Initiator,
+		Reason:     refund.Reason,
+		Status:     refund.Status,
+		Amount:     refund.Amount,
+		CreatedAt:  refund.CreatedAt,
+	})
+}
+
+func (h *RefundsHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	limit := refunds.DefaultListLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, "Неверный limit")
+			return
+		}
+		limit = parsed
+	}
+
+	list, err := h.service.List(r.Context(), r.URL.Query().Get("status"), limit)
+	if err != nil {
+		h.writeServiceError(w, r, "handlers.RefundList", err)
+		return
+	}
+
+	resp := make([]refundResponse, 0, len(list))
+	for _, refund := range list {
+		resp = append(resp, newRefundResponse(refund))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleCreate возврат по инициативе администратора, проводится сразу
+func (h *RefundsHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	var req adminRefundRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Неверное тело запроса")
+		return
+	}
+
+	refund, err := h.service.RefundPurchase(r.Context(), req.PurchaseID, req.Reason)
+	if err != nil {
+		h.writeServiceError(w, r, "handlers.RefundCreate", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newRefundResponse(refund))
+}
+
+func (h *RefundsHandler) HandleApprove(w http.ResponseWriter, r *http.Request) {
+	refundID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный id заявки")
+		return
+	}
+
+	refund, err := h.service.Approve(r.Context(), refundID)
+	if err != nil {
+		h.writeServiceError(w, r, "handlers.RefundApprove", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newRefundResponse(refund))
+}
+
+func (h *RefundsHandler) HandleReject(w http.ResponseWriter, r *http.Request) {
+	refundID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный id заявки")
+		return
+	}
+
+	var req rejectRefundRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Неверное тело запроса")
+		return
+	}
+
+	refund, err := h.service.Reject(r.Context(), refundID, req.Note)
+	if err != nil {
+		h.writeServiceError(w, r, "handlers.RefundReject", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newRefundResponse(refund))
+}
+
+func (h *RefundsHandler) writeServiceError(w http.ResponseWriter, r *http.Request, op string, err error) {
+	switch {
+	case errors.Is(err, refunds.ErrUserNotFound),
+		errors.Is(err, refunds.ErrPurchaseNotFound),
+		errors.Is(err, refunds.ErrRefundNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, refunds.ErrAlreadyRequested),
+		errors.Is(err, refunds.ErrNotPending),
+		errors.Is(err, refunds.ErrNoPayment):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, refunds.ErrProviderFailed):
+		writeError(w, http.StatusBadGateway, err.Error())
+	default:
+		h.logger.ErrorContext(r.Context(), "Ошибка возврата", slog.String("op", op), slog.String("error", err.Error()))
+		writeError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+	}
+}
+
+func newRefundResponse(refund *models.RefundDetails) refundResponse {
+	return refundResponse{
+		ID:               refund.ID,
+		PurchaseID:       refund.PurchaseID,
+		ProductID:        refund.Purchase.ProductID,
+		ProductName:      refund.ProductName,
+		TelegramID:       refund.TelegramID,
+		Initiator:        refund.Initiator,
+		Reason:           refund.Reason,
+		Status:           refund.Status,
+		Amount:           refund.Amount,
+		BalanceUsed:      refund.Purchase.BalanceUsed,
+		ProviderRefundID: refund.ProviderRefundID,
+		Note:             refund.Note,
+		CreatedAt:        refund.CreatedAt,
+		ResolvedAt:       refund.ResolvedAt,
+	}
+}
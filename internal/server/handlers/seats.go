@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/seats"
+	"github.com/go-chi/chi/v5"
+)
+
+type SeatsService interface {
+	Join(ctx context.Context, telegramID int64, inviteCode string) (*models.SeatPool, error)
+	OwnedPools(ctx context.Context, ownerTelegramID int64) ([]*models.SeatPool, error)
+	Members(ctx context.Context, ownerTelegramID, poolID int64) (*models.SeatPool, []*models.SeatAssignment, error)
+	Release(ctx context.Context, ownerTelegramID, poolID, memberTelegramID int64) error
+	Reassign(ctx context.Context, ownerTelegramID, poolID, fromTelegramID, toTelegramID int64) error
+}
+
+// SeatsHandler управляет групповыми лицензиями по запросам бота
+type SeatsHandler struct {
+	service SeatsService
+	logger  *slog.Logger
+}
+
+func NewSeatsHandler(service SeatsService, logger *slog.Logger) *SeatsHandler {
+	return &SeatsHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+type seatPoolResponse struct {
+	ID          int64  `json:"id"`
+	ProductID   int64  `json:"product_id"`
+	ProductName string `json:"product_name"`
+	Group       string `json:"group,omitempty"`
+	Seats       int    `json:"seats"`
+	Used        int    `json:"used"`
+	InviteCode  string `json:"invite_code"`
+}
+
+type seatMemberResponse struct {
+	TelegramID int64     `json:"telegram_id"`
+	Name       string    `json:"name"`
+	AssignedAt time.Time `json:"assigned_at"`
+}
+
+type seatPoolDetailsResponse struct {
+	seatPoolResponse
+	Members []seatMemberResponse `json:"members"`
+}
+
+type joinSeatPoolRequest struct {
+	TelegramID int64  `json:"telegram_id"`
+	InviteCode string `json:"invite_code"`
+}
+
+type releaseSeatRequest struct {
+	TelegramID       int64 `json:"telegram_id"`
+	MemberTelegramID int64 `json:"member_telegram_id"`
+}
+
+type reassignSeatRequest struct {
+	TelegramID     int64 `json:"telegram_id"`
+	FromTelegramID int64 `json:"from_telegram_id"`
+	ToTelegramID   int64 `json:"to_telegram_id"`
+}
+
+func (h *SeatsHandler) HandleJoin(w http.ResponseWriter, r *http.Request) {
+	var req joinSeatPoolRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Неверное тело запроса")
+		return
+	}
+
+	pool, err := h.service.Join(r.Context(), req.TelegramID, req.InviteCode)
+	if err != nil {
+		h.writeServiceError(w, r, "handlers.SeatsJoin", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newSeatPoolResponse(pool))
+}
+
+func (h *SeatsHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	telegramID, err := strconv.ParseInt(r.URL.Query().Get("telegram_id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный telegram_id")
+		return
+	}
+
+	pools, err := h.service.OwnedPools(r.Context(), telegramID)
+	if err != nil {
+		h.writeServiceError(w, r, "handlers.SeatsList", err)
+		return
+	}
+
+	resp := make([]seatPoolResponse, 0, len(pools))
+	for _, pool := range pools {
+		resp = append(resp, newSeatPoolResponse(pool))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *SeatsHandler) HandleDetails(w http.ResponseWriter, r *http.Request) {
+	poolID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный id групповой лицензии")
+		return
+	}
+
+	telegramID, err := strconv.ParseInt(r.URL.Query().Get("telegram_id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный telegram_id")
+		return
+	}
+
+	pool, members, err := h.service.Members(r.Context(), telegramID, poolID)
+	if err != nil {
+		h.writeServiceError(w, r, "handlers.SeatsDetails", err)
+		return
+	}
+
+	resp := seatPoolDetailsResponse{
+		seatPoolResponse: newSeatPoolResponse(pool),
+		Members:          make([]seatMemberResponse, 0, len(members)),
+	}
+	for _, member := range members {
+		resp.Members = append(resp.Members, seatMemberResponse{
+			TelegramID: member.TelegramID,
+			Name:       member.Name,
+			AssignedAt: member.AssignedAt,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *SeatsHandler) HandleRelease(w http.ResponseWriter, r *http.Request) {
+	poolID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный id групповой лицензии")
+		return
+	}
+
+	var req releaseSeatRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Неверное тело запроса")
+		return
+	}
+
+	if err := h.service.Release(r.Context(), req.TelegramID, poolID, req.MemberTelegramID); err != nil {
+		h.writeServiceError(w, r, "handlers.SeatsRelease", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *SeatsHandler) HandleReassign(w http.ResponseWriter, r *http.Request) {
+	poolID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный id групповой лицензии")
+		return
+	}
+
+	var req reassignSeatRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Неверное тело запроса")
+		return
+	}
+
+	err = h.service.Reassign(r.Context(), req.TelegramID, poolID, req.FromTelegramID, req.ToTelegramID)
+	if err != nil {
+		h.writeServiceError(w, r, "handlers.SeatsReassign", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *SeatsHandler) writeServiceError(w http.ResponseWriter, r *http.Request, op string, err error) {
+	switch {
+	case errors.Is(err, seats.ErrInvalidSeats):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, seats.ErrUserNotFound),
+		errors.Is(err, seats.ErrPoolNotFound),
+		errors.Is(err, seats.ErrSeatNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, seats.ErrNotOwner),
+		errors.Is(err, seats.ErrGroupMismatch):
+		writeError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, seats.ErrNoFreeSeats),
+		errors.Is(err, seats.ErrAlreadyLicensed):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.ErrorContext(r.Context(), "Ошибка групповой лицензии", slog.String("op", op), slog.String("error", err.Error()))
+		writeError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+	}
+}
+
+func newSeatPoolResponse(pool *models.SeatPool) seatPoolResponse {
+	return seatPoolResponse{
+		ID:          pool.ID,
+		ProductID:   pool.ProductID,
+		ProductName: pool.ProductName,
+		Group:       pool.Group,
+		Seats:       pool.Seats,
+		Used:        pool.Used,
+		InviteCode:  pool.InviteCode,
+	}
+}
@@ -1,7 +1,210 @@
 package handlers
 
-import "net/http"
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
 
-func RegisterUHandler(w http.ResponseWriter, r *http.Request) {
-	// TODO Реализовать этот метод
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/referrals"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/users"
+	"github.com/go-chi/chi/v5"
+)
+
+type UsersService interface {
+	Register(ctx context.Context, telegramID int64, name, group, referralCode string) (*models.User, error)
+	Exists(ctx context.Context, telegramID int64) (bool, error)
+	RotateLicenseKey(ctx context.Context, telegramID int64) (string, error)
+	SetLeader(ctx context.Context, telegramID int64, leader bool) (*models.User, error)
+}
+
+type ReferralsService interface {
+	Stats(ctx context.Context, telegramID int64) (*models.ReferralStats, error)
+}
+
+type UsersHandler struct {
+	users     UsersService
+	referrals ReferralsService
+	logger    *slog.Logger
+}
+
+func NewUsersHandler(users UsersService, referrals ReferralsService, logger *slog.Logger) *UsersHandler {
+	return &UsersHandler{
+		users:     users,
+		referrals: referrals,
+		logger:    logger,
+	}
+}
+
+type registerRequest struct {
+	TelegramID   int64  `json:"telegram_id"`
+	Name         string `json:"name"`
+	Group        string `json:"group"`
+	ReferralCode string `json:"referral_code"`
+}
+
+type registerResponse struct {
+	LicenseKey   string `json:"license_key"`
+	ReferralCode string `json:"referral_code"`
+}
+
+type licenseKeyResponse struct {
+	LicenseKey string `json:"license_key"`
+}
+
+type setLeaderRequest struct {
+	Leader bool `json:"leader"`
+}
+
+type leaderResponse struct {
+	TelegramID int64 `json:"telegram_id"`
+	Leader     bool  `json:"leader"`
+}
+
+type userExistsResponse struct {
+	Exists bool `json:"exists"`
+}
+
+type referralStatsResponse struct {
+	ReferralCode string `json:"referral_code"`
+	Invited      int    `json:"invited"`
+	Rewarded     int    `json:"rewarded"`
+	TotalBonus   int64  `json:"total_bonus"`
+}
+
+func (h *UsersHandler) HandleRegister(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.UsersRegister"
+	logger := h.logger.With(slog.String("op", op))
+
+	var req registerRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Неверное тело запроса")
+		return
+	}
+
+	user, err := h.users.Register(r.Context(), req.TelegramID, req.Name, req.Group, req.ReferralCode)
+	switch {
+	case errors.Is(err, users.ErrInvalidUser):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, users.ErrAlreadyExists):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		logger.ErrorContext(r.Context(), "Ошибка регистрации пользователя", slog.String("error", err.Error()))
+		writeError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, registerResponse{
+		LicenseKey:   user.LicenseKey,
+		ReferralCode: user.ReferralCode,
+	})
+}
+
+func (h *UsersHandler) HandleExists(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.UsersExists"
+	logger := h.logger.With(slog.String("op", op))
+
+	telegramID, err := strconv.ParseInt(chi.URLParam(r, "telegram_id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный telegram_id")
+		return
+	}
+
+	exists, err := h.users.Exists(r.Context(), telegramID)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Ошибка проверки пользователя", slog.String("error", err.Error()))
+		writeError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, userExistsResponse{Exists: exists})
+}
+
+// HandleRotateLicenseKey перевыпускает лицензионный ключ, например если пользователь его случайно опубликовал
+func (h *UsersHandler) HandleRotateLicenseKey(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.UsersRotateLicenseKey"
+	logger := h.logger.With(slog.String("op", op))
+
+	telegramID, err := strconv.ParseInt(chi.URLParam(r, "telegram_id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный telegram_id")
+		return
+	}
+
+	key, err := h.users.RotateLicenseKey(r.Context(), telegramID)
+	if errors.Is(err, users.ErrUserNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Ошибка перевыпуска ключа", slog.String("error", err.Error()))
+		writeError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, licenseKeyResponse{LicenseKey: key})
+}
+
+// HandleSetLeader администратор назначает старосту или снимает назначение
+func (h *UsersHandler) HandleSetLeader(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.UsersSetLeader"
+	logger := h.logger.With(slog.String("op", op))
+
+	telegramID, err := strconv.ParseInt(chi.URLParam(r, "telegram_id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный telegram_id")
+		return
+	}
+
+	var req setLeaderRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Неверное тело запроса")
+		return
+	}
+
+	user, err := h.users.SetLeader(r.Context(), telegramID, req.Leader)
+	if errors.Is(err, users.ErrUserNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Ошибка назначения старосты", slog.String("error", err.Error()))
+		writeError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, leaderResponse{TelegramID: user.TelegramID, Leader: user.IsLeader})
+}
+
+func (h *UsersHandler) HandleReferralStats(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.UsersReferralStats"
+	logger := h.logger.With(slog.String("op", op))
+
+	telegramID, err := strconv.ParseInt(r.URL.Query().Get("telegram_id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный telegram_id")
+		return
+	}
+
+	stats, err := h.referrals.Stats(r.Context(), telegramID)
+	if errors.Is(err, referrals.ErrUserNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Ошибка получения статистики приглашений", slog.String("error", err.Error()))
+		writeError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, referralStatsResponse{
+		ReferralCode: stats.ReferralCode,
+		Invited:      stats.Invited,
+		Rewarded:     stats.Rewarded,
+		TotalBonus:   stats.TotalBonus,
+	})
 }
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/services/licenses"
+)
+
+type LicenseVerifier interface {
+	Verify(ctx context.Context, licenseKey string, productID int64, fingerprint string) (*licenses.VerifyResult, error)
+}
+
+// VerifyMetrics учитывает результаты проверки лицензий
+type VerifyMetrics interface {
+	ObserveVerify(reason string)
+}
+
+type VerifyHandler struct {
+	service LicenseVerifier
+	metrics VerifyMetrics
+	logger  *slog.Logger
+}
+
+func NewVerifyHandler(service LicenseVerifier, metrics VerifyMetrics, logger *slog.Logger) *VerifyHandler {
+	return &VerifyHandler{
+		service: service,
+		metrics: metrics,
+		logger:  logger,
+	}
+}
+
+// Причины для метрик, когда проверка не дошла до результата
+const (
+	verifyBadRequest = "bad_request"
+	verifyError      = "error"
+)
+
+type verifyRequest struct {
+	LicenseKey  string `json:"license_key"`
+	ProductID   int64  `json:"product_id"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+type verifyResponse struct {
+	Allowed   bool       `json:"allowed"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func (h *VerifyHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.Verify"
+	logger := h.logger.With(slog.String("op", op))
+
+	var req verifyRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.metrics.ObserveVerify(verifyBadRequest)
+		writeError(w, http.StatusBadRequest, "Неверное тело запроса")
+		return
+	}
+
+	result, err := h.service.Verify(r.Context(), req.LicenseKey, req.ProductID, req.Fingerprint)
+	if errors.Is(err, licenses.ErrInvalidRequest) {
+		h.metrics.ObserveVerify(verifyBadRequest)
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		h.metrics.ObserveVerify(verifyError)
+		logger.ErrorContext(r.Context(), "Ошибка проверки лицензии", slog.String("error", err.Error()))
+		writeError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+		return
+	}
+
+	h.metrics.ObserveVerify(result.Reason)
+
+	if !result.Allowed {
+		logger.InfoContext(r.Context(), "Доступ запрещен",
+			slog.Int64("product_id", req.ProductID),
+			slog.String("reason", result.Reason),
+		)
+	}
+
+	writeJSON(w, http.StatusOK, verifyResponse{
+		Allowed:   result.Allowed,
+		Reason:    result.Reason,
+		ExpiresAt: result.ExpiresAt,
+	})
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/wallet"
+)
+
+const maxWalletHistory = 100
+
+type WalletService interface {
+	Wallet(ctx context.Context, telegramID int64, historyLimit int) (*models.Wallet, error)
+}
+
+type WalletHandler struct {
+	service WalletService
+	logger  *slog.Logger
+}
+
+func NewWalletHandler(service WalletService, logger *slog.Logger) *WalletHandler {
+	return &WalletHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// walletResponse суммы в копейках
+type walletResponse struct {
+	Balance      int64                 `json:"balance"`
+	Transactions []walletEntryResponse `json:"transactions"`
+}
+
+type walletEntryResponse struct {
+	Kind        string    `json:"kind"`
+	Description string    `json:"description"`
+	Amount      int64     `json:"amount"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (h *WalletHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.Wallet"
+	logger := h.logger.With(slog.String("op", op))
+
+	telegramID, err := strconv.ParseInt(r.URL.Query().Get("telegram_id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Неверный telegram_id")
+		return
+	}
+
+	limit := wallet.DefaultHistoryLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, "Неверный limit")
+			return
+		}
+		limit = min(parsed, maxWalletHistory)
+	}
+
+	userWallet, err := h.service.Wallet(r.Context(), telegramID, limit)
+	if errors.Is(err, wallet.ErrUserNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Ошибка получения баланса", slog.String("error", err.Error()))
+		writeError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+		return
+	}
+
+	resp := walletResponse{
+		Balance:      userWallet.Balance,
+		Transactions: make([]walletEntryResponse, 0, len(userWallet.History)),
+	}
+	for _, entry := range userWallet.History {
+		resp.Transactions = append(resp.Transactions, walletEntryResponse{
+			Kind:        entry.Kind,
+			Description: entry.Description,
+			Amount:      entry.Amount,
+			CreatedAt:   entry.CreatedAt,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
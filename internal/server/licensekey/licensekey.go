@@ -0,0 +1,47 @@
+// Package licensekey выпускает лицензионные ключи пользователей. Ключ показывается
+// пользователю один раз, в базе хранится только его хеш.
+package licensekey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	// Prefix отличает лицензионный ключ от jwt сервисов и упрощает поиск утекших ключей
+	Prefix = "lg_live_"
+
+	keyBytes = 20
+	hintLen  = len(Prefix) + 4
+)
+
+// Key только что выпущенный ключ. Plain нельзя сохранять, он отдается пользователю.
+type Key struct {
+	Plain string
+	Hash  string
+	Hint  string // Начало ключа, по которому пользователь узнает его в интерфейсе
+}
+
+func Generate() (*Key, error) {
+	buf := make([]byte, keyBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("не удалось сгенерировать лицензионный ключ: %w", err)
+	}
+
+	plain := Prefix + hex.EncodeToString(buf)
+
+	return &Key{
+		Plain: plain,
+		Hash:  Hash(plain),
+		Hint:  plain[:hintLen],
+	}, nil
+}
+
+// Hash хеш ключа для хранения и поиска. Ключ случайный и длинный, поэтому медленный
+// хеш паролей здесь не нужен.
+func Hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
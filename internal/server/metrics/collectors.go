@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// purchasesQueryTimeout сбор метрик не должен зависать вместе с базой
+const purchasesQueryTimeout = 3 * time.Second
+
+// poolCollector статистика пула соединений pgxpool на момент сбора метрик
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	acquired        *prometheus.Desc
+	idle            *prometheus.Desc
+	total           *prometheus.Desc
+	max             *prometheus.Desc
+	acquires        *prometheus.Desc
+	acquireDuration *prometheus.Desc
+	emptyAcquires   *prometheus.Desc
+	canceled        *prometheus.Desc
+}
+
+func newPoolCollector(pool *pgxpool.Pool) *poolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+
+	return &poolCollector{
+		pool:            pool,
+		acquired:        desc("acquired_conns", "Соединения, занятые запросами."),
+		idle:            desc("idle_conns", "Свободные соединения."),
+		total:           desc("total_conns", "Все открытые соединения."),
+		max:             desc("max_conns", "Максимальный размер пула."),
+		acquires:        desc("acquires_total", "Сколько раз соединение было взято из пула."),
+		acquireDuration: desc("acquire_duration_seconds_total", "Суммарное время ожидания соединения."),
+		emptyAcquires:   desc("empty_acquires_total", "Сколько раз пришлось ждать, потому что свободных соединений не было."),
+		canceled:        desc("canceled_acquires_total", "Сколько ожиданий соединения было отменено."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.canceled, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+}
+
+// purchasesCollector число покупок в каждом статусе. Считается запросом к базе при сборе,
+// поэтому показывает все покупки, а не только прошедшие через эту реплику.
+type purchasesCollector struct {
+	repo   PurchaseCounter
+	logger *slog.Logger
+	desc   *prometheus.Desc
+}
+
+func newPurchasesCollector(repo PurchaseCounter, logger *slog.Logger) *purchasesCollector {
+	return &purchasesCollector{
+		repo:   repo,
+		logger: logger,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "purchases"),
+			"Число покупок по статусам.",
+			[]string{"state"}, nil,
+		),
+	}
+}
+
+func (c *purchasesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *purchasesCollector) Collect(ch chan<- prometheus.Metric) {
+	const op = "metrics.purchasesCollector.Collect"
+
+	ctx, cancel := context.WithTimeout(context.Background(), purchasesQueryTimeout)
+	defer cancel()
+
+	counts, err := c.repo.PurchaseCountsByStatus(ctx)
+	if err != nil {
+		c.logger.Warn("Не удалось посчитать покупки для метрик", slog.String("op", op), slog.String("error", err.Error()))
+		return
+	}
+
+	for state, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count), state)
+	}
+}
@@ -1,14 +1,29 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 
+	"github.com/GeorgeTyupin/labguard/pkg/auth"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func JWTMiddleware(jwtSecret string) func(http.Handler) http.Handler {
+// JWTOptions параметры проверки токенов
+type JWTOptions struct {
+	Keys     *auth.KeyRing
+	Audience string
+	Issuers  []string // Доверенные издатели токенов
+}
+
+// JWTMiddleware проверяет подпись и claims токена и кладет его владельца в контекст запроса
+func JWTMiddleware(opts JWTOptions) func(http.Handler) http.Handler {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(opts.Keys.Methods()),
+		jwt.WithAudience(opts.Audience),
+		jwt.WithExpirationRequired(),
+	)
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
@@ -25,12 +40,8 @@ func JWTMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 
 			tokenString := parts[1]
 
-			token, err := jwt.Parse(tokenString, func(t *jwt.Token) (any, error) {
-				if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("неопределенный метод подписи: %v", t.Header["alg"])
-				}
-				return []byte(jwtSecret), nil
-			}, nil)
+			var claims auth.Claims
+			token, err := parser.ParseWithClaims(tokenString, &claims, opts.Keys.Keyfunc)
 
 			if err != nil {
 				http.Error(w, "Не удалось спарсить токен", http.StatusUnauthorized)
@@ -42,7 +53,30 @@ func JWTMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			if !slices.Contains(opts.Issuers, claims.Issuer) {
+				http.Error(w, "Недоверенный издатель токена", http.StatusUnauthorized)
+				return
+			}
+
+			if claims.Subject == "" || !auth.KnownRole(claims.Role) {
+				http.Error(w, "В токене нет владельца или роли", http.StatusUnauthorized)
+				return
+			}
+
+			// Издатель bot не выдает роль администратора. Подпись ключом издателя проверил
+			// Keyfunc, с общим секретом HS256 это только проверка заявленных claims
+			if !auth.IssuerAllowsRole(claims.Issuer, claims.Role) {
+				http.Error(w, "Издатель токена не может выдавать эту роль", http.StatusUnauthorized)
+				return
+			}
+
+			principal := &Principal{
+				Subject: claims.Subject,
+				Role:    claims.Role,
+				Scopes:  auth.AllowedScopes(claims.Role, claims.Scopes),
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
 		})
 	}
 }
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/licensekey"
+	"github.com/GeorgeTyupin/labguard/internal/server/ratelimit"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxPeekBody сколько байт тела запроса читается, чтобы достать ключ лимита
+const maxPeekBody = 64 << 10
+
+// KeyFunc достает из запроса значение, по которому считается лимит.
+// Пустая строка означает, что правило к запросу не применяется.
+type KeyFunc func(r *http.Request) string
+
+// RateLimitRule лимит для одного вида ключа
+type RateLimitRule struct {
+	Name  string
+	Key   KeyFunc
+	Limit ratelimit.Limit
+}
+
+// RateLimits правила лимитов по группам маршрутов. Set заменяет все правила разом,
+// запросы, которые уже проверяются, дорабатывают со старыми.
+type RateLimits struct {
+	groups atomic.Pointer[map[string][]RateLimitRule]
+}
+
+func NewRateLimits(groups map[string][]RateLimitRule) *RateLimits {
+	limits := &RateLimits{}
+	limits.Set(groups)
+
+	return limits
+}
+
+func (l *RateLimits) Set(groups map[string][]RateLimitRule) {
+	l.groups.Store(&groups)
+}
+
+func (l *RateLimits) rules(group string) []RateLimitRule {
+	return (*l.groups.Load())[group]
+}
+
+// RateLimit отклоняет запрос с 429, если исчерпан лимит хотя бы по одному правилу группы.
+// Отклоненный запрос не тратит лимит: токены, которые он успел взять по другим правилам,
+// возвращаются в корзины. Если хранилище лимитов недоступно, запрос пропускается:
+// лимиты защищают сервис, но не должны сами его ронять.
+func RateLimit(store ratelimit.Store, group string, limits *RateLimits, logger *slog.Logger) func(http.Handler) http.Handler {
+	logger = logger.With(slog.String("component", "ratelimit"), slog.String("group", group))
+
+	type takenToken struct {
+		key   string
+		limit ratelimit.Limit
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var taken []takenToken
+			for _, rule := range limits.rules(group) {
+				value := rule.Key(r)
+				if value == "" {
+					continue
+				}
+
+				key := group + ":" + rule.Name + ":" + value
+				result, err := store.Take(r.Context(), key, rule.Limit)
+				if err != nil {
+					logger.ErrorContext(r.Context(), "Ошибка хранилища лимитов", slog.String("rule", rule.Name), slog.String("error", err.Error()))
+					continue
+				}
+
+				if !result.Allowed {
+					for _, token := range taken {
+						if err := store.Refund(r.Context(), token.key, token.limit); err != nil {
+							logger.ErrorContext(r.Context(), "Не удалось вернуть токен в корзину", slog.String("error", err.Error()))
+						}
+					}
+
+					retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+					w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+
+					w.Header().Set("Content-Type", "application/json; charset=utf-8")
+					w.WriteHeader(http.StatusTooManyRequests)
+					json.NewEncoder(w).Encode(map[string]string{"error": "Слишком много запросов, попробуйте позже"})
+					return
+				}
+
+				taken = append(taken, takenToken{key: key, limit: rule.Limit})
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ByIP ключ по адресу клиента, см. ClientIP
+func ByIP(proxyHops int) KeyFunc {
+	return func(r *http.Request) string {
+		return ClientIP(r, proxyHops)
+	}
+}
+
+// ByLicenseKey ключ по лицензионному ключу из тела запроса. В хранилище лимитов
+// попадает только хеш ключа.
+func ByLicenseKey(r *http.Request) string {
+	key := peekJSONField(r, "license_key")
+	if key == "" {
+		return ""
+	}
+
+	return licensekey.Hash(strings.TrimSpace(key))
+}
+
+// ByTelegramID ключ по пользователю бота: из параметра пути, query или тела запроса
+func ByTelegramID(r *http.Request) string {
+	if id := chi.URLParam(r, "telegram_id"); id != "" {
+		return id
+	}
+
+	if id := r.URL.Query().Get("telegram_id"); id != "" {
+		return id
+	}
+
+	return peekJSONField(r, "telegram_id")
+}
+
+// peekJSONField читает поле из JSON тела и возвращает тело на место для обработчика
+func peekJSONField(r *http.Request, field string) string {
+	if r.Body == nil || r.Body == http.NoBody {
+		return ""
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxPeekBody))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), r.Body))
+	if err != nil {
+		return ""
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(data, &body); err != nil {
+		return ""
+	}
+
+	raw, ok := body[field]
+	if !ok {
+		return ""
+	}
+
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		return str
+	}
+
+	return string(raw)
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/ratelimit"
+)
+
+func TestRateLimitRefundsOnDeny(t *testing.T) {
+	rules := map[string][]RateLimitRule{
+		"bot": {
+			{Name: "ip", Key: ByIP(0), Limit: ratelimit.Limit{PerMinute: 1, Burst: 3}},
+			{Name: "telegram_id", Key: ByTelegramID, Limit: ratelimit.Limit{PerMinute: 1, Burst: 1}},
+		},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := RateLimit(ratelimit.NewMemoryStore(), "bot", NewRateLimits(rules), logger)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }),
+	)
+
+	// Все запросы с одного адреса. Лимит пользователя 1 исчерпан первым запросом,
+	// но отклоненные запросы 2 и 3 не должны тратить лимит адреса.
+	tests := []struct {
+		telegramID string
+		status     int
+	}{
+		{telegramID: "1", status: http.StatusNoContent},
+		{telegramID: "1", status: http.StatusTooManyRequests},
+		{telegramID: "1", status: http.StatusTooManyRequests},
+		{telegramID: "2", status: http.StatusNoContent},
+		{telegramID: "3", status: http.StatusNoContent},
+		{telegramID: "4", status: http.StatusTooManyRequests},
+	}
+
+	for i, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/?telegram_id="+tt.telegramID, nil)
+		r.RemoteAddr = "203.0.113.7:40000"
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Fatalf("запрос %d от %s: статус %d, ожидался %d", i, tt.telegramID, w.Code, tt.status)
+		}
+		if tt.status == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Fatalf("запрос %d: нет Retry-After", i)
+		}
+	}
+}
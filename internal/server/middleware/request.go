@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/GeorgeTyupin/labguard/pkg/logging"
+)
+
+// maxRequestIDLength id длиннее считаются мусором и заменяются своими
+const maxRequestIDLength = 64
+
+type requestInfoKey struct{}
+
+// RequestInfo сведения о запросе, которые нужны журналу аудита и логам
+type RequestInfo struct {
+	ID string
+	IP string
+}
+
+// RequestID берет id запроса из заголовка X-Request-Id, который присылает бот, или выпускает новый.
+// id возвращается в ответе и попадает в контекст, а через него в каждую запись лога.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(logging.RequestIDHeader)
+		if !validRequestID(id) {
+			id = logging.NewRequestID()
+		}
+
+		w.Header().Set(logging.RequestIDHeader, id)
+
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// RequestMeta кладет в контекст id запроса и адрес клиента. Должен стоять после RequestID.
+func RequestMeta(proxyHops int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info := &RequestInfo{
+				ID: logging.RequestID(r.Context()),
+				IP: ClientIP(r, proxyHops),
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info)))
+		})
+	}
+}
+
+func RequestInfoFromContext(ctx context.Context) (*RequestInfo, bool) {
+	info, ok := ctx.Value(requestInfoKey{}).(*RequestInfo)
+	return info, ok
+}
+
+// ClientIP адрес клиента. За proxyHops доверенными прокси адрес берется из X-Forwarded-For:
+// каждый прокси дописывает в конец адрес, с которого к нему пришли, поэтому клиент —
+// proxyHops-я запись справа. Записи левее мог прислать сам клиент, им верить нельзя.
+// При proxyHops = 0 заголовки прокси не читаются. X-Real-IP не читается никогда: прокси
+// не обязан его перезаписывать, и тогда в нем то, что прислал клиент.
+func ClientIP(r *http.Request, proxyHops int) string {
+	if proxyHops > 0 {
+		if ip := forwardedIP(r.Header.Values("X-Forwarded-For"), proxyHops); ip != "" {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// forwardedIP proxyHops-я справа запись X-Forwarded-For. Повторенные заголовки
+// склеиваются по порядку. Если записей меньше, берется самая левая.
+func forwardedIP(headers []string, proxyHops int) string {
+	var entries []string
+	for _, header := range headers {
+		for entry := range strings.SplitSeq(header, ",") {
+			entries = append(entries, strings.TrimSpace(entry))
+		}
+	}
+
+	if len(entries) == 0 {
+		return ""
+	}
+
+	ip := entries[max(len(entries)-proxyHops, 0)]
+	if net.ParseIP(ip) == nil {
+		return ""
+	}
+
+	return ip
+}
+
+// validRequestID чужой id попадает в логи и журнал аудита, поэтому пропускаем только безопасные символы
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, ch := range id {
+		isAlnum := ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9'
+		if !isAlnum && ch != '-' && ch != '_' && ch != '.' {
+			return false
+		}
+	}
+
+	return true
+}
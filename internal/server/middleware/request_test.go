@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		proxyHops int
+		forwarded []string
+		realIP    string
+		want      string
+	}{
+		{name: "без прокси заголовки не читаются", forwarded: []string{"198.51.100.1"}, realIP: "198.51.100.2", want: "192.0.2.10"},
+		{name: "один прокси", proxyHops: 1, forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "поддельная запись клиента слева", proxyHops: 1, forwarded: []string{"10.0.0.1, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "два прокси", proxyHops: 2, forwarded: []string{"10.0.0.1, 198.51.100.1, 172.16.0.5"}, want: "198.51.100.1"},
+		{name: "повторенный заголовок", proxyHops: 1, forwarded: []string{"10.0.0.1", "198.51.100.1"}, want: "198.51.100.1"},
+		{name: "записей меньше, чем прокси", proxyHops: 3, forwarded: []string{"198.51.100.1, 172.16.0.5"}, want: "198.51.100.1"},
+		{name: "мусор вместо адреса", proxyHops: 1, forwarded: []string{"<script>"}, want: "192.0.2.10"},
+		{name: "X-Real-IP от клиента не читается", proxyHops: 1, realIP: "198.51.100.2", want: "192.0.2.10"},
+		{name: "X-Real-IP не перебивает X-Forwarded-For", proxyHops: 1, forwarded: []string{"198.51.100.1"}, realIP: "198.51.100.2", want: "198.51.100.1"},
+		{name: "IPv6", proxyHops: 1, forwarded: []string{"2001:db8::1"}, want: "2001:db8::1"},
+		{name: "прокси не прислал заголовков", proxyHops: 1, want: "192.0.2.10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "192.0.2.10:51000"
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			if got := ClientIP(r, tt.proxyHops); got != tt.want {
+				t.Fatalf("ClientIP = %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
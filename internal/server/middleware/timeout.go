@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// RequestTimeout ограничивает время обработки запроса дедлайном контекста. Запросы
+// к базе и другим сервисам прерываются по нему. Значение можно менять на ходу.
+type RequestTimeout struct {
+	timeout atomic.Int64 // time.Duration
+}
+
+func NewRequestTimeout(timeout time.Duration) *RequestTimeout {
+	t := &RequestTimeout{}
+	t.Set(timeout)
+
+	return t
+}
+
+func (t *RequestTimeout) Set(timeout time.Duration) {
+	t.timeout.Store(int64(timeout))
+}
+
+func (t *RequestTimeout) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Duration(t.timeout.Load()))
+		defer cancel()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
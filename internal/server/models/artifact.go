@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Способы выдачи материалов покупателю
+const (
+	DeliveryTelegram = "telegram" // Документом в чат с ботом
+	DeliveryLink     = "link"     // По временной ссылке на сервер
+)
+
+// Artifact версия материалов продукта: архив с исходным кодом или отчет. Версии не
+// меняются, исправленные материалы загружаются следующей версией.
+type Artifact struct {
+	ID          int64
+	ProductID   int64
+	Version     int
+	StorageKey  string
+	FileName    string
+	ContentType string
+	Size        int64
+	SHA256      string
+	Notes       string
+	CreatedAt   time.Time
+}
+
+// ArtifactDelivery выдача материалов покупателю. Watermark зашит в выданную копию.
+type ArtifactDelivery struct {
+	ID         int64
+	ArtifactID int64
+	UserID     int64
+	LicenseID  int64
+	Watermark  string
+	Channel    string
+	CreatedAt  time.Time
+}
+
+// DeliveryTrace кому и что было выдано с меткой, найденной в утекшей копии. Bound —
+// метка подписана ключом сервера по лицензии из журнала.
+type DeliveryTrace struct {
+	Delivery   ArtifactDelivery
+	Artifact   Artifact
+	TelegramID int64
+	UserName   string
+	UserGroup  string
+	Bound      bool
+}
@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// Действия, которые попадают в журнал аудита
+const (
+	AuditUserRegistered    = "user.registered"
+	AuditUserLeaderChanged = "user.leader_changed"
+	AuditLicenseKeyRotated = "license_key.rotated"
+	AuditPurchaseCompleted = "purchase.completed"
+	AuditSeatPoolCreated   = "seat_pool.created"
+	AuditLicenseIssued     = "license.issued"
+	AuditLicenseRevoked    = "license.revoked"
+	AuditDeviceBound       = "device.bound"
+	AuditDeviceReset       = "device.reset"
+	AuditVerifyDenied      = "verify.denied"
+	AuditRefundRequested   = "refund.requested"
+	AuditRefundApproved    = "refund.approved"
+	AuditRefundRejected    = "refund.rejected"
+	AuditPromoCreated      = "promo.created"
+
+	AuditProductMediaAdded   = "product_media.added"
+	AuditProductMediaRemoved = "product_media.removed"
+
+	AuditArtifactUploaded   = "artifact.uploaded"
+	AuditArtifactDelivered  = "artifact.delivered"
+	AuditArtifactDownloaded = "artifact.downloaded"
+)
+
+// Кто совершил действие
+const (
+	ActorUser   = "user"   // Пользователь бота, ActorID — telegram_id
+	ActorAdmin  = "admin"  // Администратор, ActorID — subject его токена
+	ActorClient = "client" // Десктопный клиент, ActorID — id пользователя-владельца ключа
+	ActorSystem = "system"
+)
+
+// Над чем совершено действие
+const (
+	TargetUser     = "user"
+	TargetPurchase = "purchase"
+	TargetLicense  = "license"
+	TargetSeatPool = "seat_pool"
+	TargetRefund   = "refund"
+	TargetPromo    = "promo_code"
+	TargetProduct  = "product"
+	TargetArtifact = "artifact"
+)
+
+// AuditEvent запись журнала аудита. Записи только добавляются и никогда не меняются.
+type AuditEvent struct {
+	ID         int64
+	Action     string
+	ActorType  string
+	ActorID    string
+	TargetType string
+	TargetID   string
+	IP         string
+	RequestID  string
+	Via        string // Токен, с которым пришел запрос: роль и subject
+	Details    map[string]any
+	CreatedAt  time.Time
+}
+
+// AuditFilter условия выборки журнала, пустые поля не ограничивают выборку
+type AuditFilter struct {
+	Action     string
+	ActorType  string
+	ActorID    string
+	TargetType string
+	TargetID   string
+	From       *time.Time
+	To         *time.Time
+	BeforeID   int64 // Для постраничного просмотра от новых записей к старым
+	Limit      int   // 0 — без ограничения, используется при экспорте
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Виды транзакций кошелька
+const (
+	LedgerReferralBonus = "referral_bonus"
+	LedgerPurchase      = "purchase"
+	LedgerRefund        = "refund"
+	LedgerHold          = "hold" // Резерв баланса под неоплаченную покупку и его снятие
+)
+
+// Системные счета двойной записи
+const (
+	AccountBonus    = "bonus"
+	AccountRevenue  = "revenue"
+	AccountExternal = "external"
+	AccountHold     = "hold"
+)
+
+// LedgerEntry проводка по кошельку пользователя: положительная сумма — зачисление,
+// отрицательная — списание
+type LedgerEntry struct {
+	TransactionID int64
+	Kind          string
+	Description   string
+	Amount        int64
+	CreatedAt     time.Time
+}
+
+type Wallet struct {
+	Balance int64
+	History []*LedgerEntry
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+const (
+	LicenseActive  = "active"
+	LicenseExpired = "expired"
+	LicenseRevoked = "revoked"
+)
+
+type License struct {
+	ID                int64
+	UserID            int64
+	ProductID         int64
+	Status            string
+	DeviceFingerprint string
+	ExpiresAt         *time.Time // nil для бессрочных лицензий
+	CreatedAt         time.Time
+}
+
+// IsExpired проверяет истек ли оплаченный период лицензии на момент now
+func (l *License) IsExpired(now time.Time) bool {
+	return l.ExpiresAt != nil && !l.ExpiresAt.After(now)
+}
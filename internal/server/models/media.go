@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Виды медиа продукта
+const (
+	MediaCover      = "cover"      // Обложка, первая в альбоме карточки
+	MediaScreenshot = "screenshot" // Скриншоты после обложки в порядке Position
+	MediaSample     = "sample"     // Пример работы в PDF, отправляется документом
+)
+
+// ProductMedia файл карточки продукта. Содержимое лежит в хранилище файлов под StorageKey,
+// файл не меняется: новая версия загружается новой записью.
+type ProductMedia struct {
+	ID          int64
+	ProductID   int64
+	Kind        string
+	Position    int
+	StorageKey  string
+	FileName    string
+	ContentType string
+	Size        int64
+	SHA256      string
+	CreatedAt   time.Time
+}
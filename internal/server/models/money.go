@@ -0,0 +1,12 @@
+package models
+
+import "fmt"
+
+// FormatRubles форматирует сумму в копейках для сообщений пользователю
+func FormatRubles(kopecks int64) string {
+	if kopecks%100 == 0 {
+		return fmt.Sprintf("%d₽", kopecks/100)
+	}
+
+	return fmt.Sprintf("%d,%02d₽", kopecks/100, kopecks%100)
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Notification сообщение пользователю, которое сервер передает боту для отправки
+type Notification struct {
+	ID         int64
+	TelegramID int64
+	Text       string
+	CreatedAt  time.Time
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+const (
+	BillingOneTime      = "one_time"
+	BillingSubscription = "subscription"
+)
+
+type Product struct {
+	ID          int64
+	Name        string
+	Description string
+	Price       int64 // Цена в копейках
+	Link        string
+	BillingType string
+	PeriodDays  int // Длительность периода подписки, для разовых покупок 0
+	CreatedAt   time.Time
+}
+
+func (p *Product) IsSubscription() bool {
+	return p.BillingType == BillingSubscription
+}
+
+// Period возвращает длительность одного оплаченного периода подписки
+func (p *Product) Period() time.Duration {
+	return time.Duration(p.PeriodDays) * 24 * time.Hour
+}
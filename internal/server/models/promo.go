@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+const (
+	DiscountPercent = "percent"
+	DiscountFixed   = "fixed"
+)
+
+type PromoCode struct {
+	ID             int64
+	Code           string
+	DiscountType   string
+	DiscountValue  int64  // Процент для percent, копейки для fixed
+	ProductID      *int64 // nil — промокод действует на весь каталог
+	Group          string // Если задана, промокод доступен только студентам этой группы
+	MaxUses        *int   // nil — без общего ограничения
+	MaxUsesPerUser int
+	ValidFrom      time.Time
+	ValidUntil     *time.Time
+	CreatedAt      time.Time
+}
+
+// Discount считает скидку в копейках для цены price. Скидка не превышает цену.
+func (p *PromoCode) Discount(price int64) int64 {
+	var discount int64
+	switch p.DiscountType {
+	case DiscountPercent:
+		discount = price * p.DiscountValue / 100
+	case DiscountFixed:
+		discount = p.DiscountValue
+	}
+
+	return min(discount, price)
+}
+
+// PromoUsage сколько раз промокод уже применили всего и конкретным пользователем
+type PromoUsage struct {
+	Total  int
+	ByUser int
+}
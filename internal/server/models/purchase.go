@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+const (
+	PurchasePending  = "pending"
+	PurchasePaid     = "paid"
+	PurchaseRefunded = "refunded"
+	PurchaseCanceled = "canceled" // Платеж отменен, зарезервированный баланс вернулся в кошелек
+)
+
+type Purchase struct {
+	ID                int64
+	UserID            int64
+	ProductID         int64
+	LicenseID         *int64
+	OriginalPrice     int64 // Цена продукта в копейках до скидок
+	Discount          int64
+	Amount            int64 // Итоговая сумма к оплате
+	BalanceUsed       int64 // Часть суммы, списанная с внутреннего баланса
+	PromoCodeID       *int64
+	Status            string
+	ProviderPaymentID string // Платеж у провайдера на часть суммы сверх баланса, пустой при оплате только с баланса
+	PaymentURL        string // Страница оплаты для покупателя
+	Seats             int    // Число мест групповой лицензии, 0 — личная покупка
+	SeatPoolID        *int64 // Групповая лицензия, которую открывает оплата мест
+	CreatedAt         time.Time
+	PaidAt            *time.Time
+}
+
+// PriceQuote расчет цены продукта для пользователя с учетом скидок
+type PriceQuote struct {
+	ProductID     int64
+	OriginalPrice int64
+	Discount      int64
+	Price         int64
+	PromoCode     *PromoCode
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+type ReferralReward struct {
+	ID         int64
+	ReferrerID int64
+	RefereeID  int64
+	PurchaseID int64
+	Amount     int64 // Бонус в копейках
+	CreatedAt  time.Time
+}
+
+// ReferralStats статистика приглашений пользователя
+type ReferralStats struct {
+	ReferralCode string
+	Invited      int   // Зарегистрировались по ссылке
+	Rewarded     int   // Совершили первую оплаченную покупку
+	TotalBonus   int64 // Сумма начисленных бонусов в копейках
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+const (
+	RefundRequested  = "requested"
+	RefundProcessing = "processing"
+	RefundCompleted  = "completed"
+	RefundRejected   = "rejected"
+	RefundFailed     = "failed"
+
+	RefundByUser  = "user"
+	RefundByAdmin = "admin"
+)
+
+type Refund struct {
+	ID               int64
+	PurchaseID       int64
+	Initiator        string
+	Reason           string
+	Status           string
+	Amount           int64
+	ProviderRefundID string
+	Note             string
+	CreatedAt        time.Time
+	ResolvedAt       *time.Time
+}
+
+// RefundDetails возврат вместе с покупкой и данными пользователя для уведомлений
+type RefundDetails struct {
+	Refund
+	Purchase    Purchase
+	TelegramID  int64
+	ProductName string
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// SeatPool групповая лицензия: владелец (староста или админ) покупает seats мест на продукт,
+// а студенты занимают их по коду приглашения
+type SeatPool struct {
+	ID          int64
+	ProductID   int64
+	ProductName string
+	OwnerUserID int64
+	Group       string // Если задана, занять место могут только студенты этой группы
+	Seats       int
+	Used        int
+	InviteCode  string
+	CreatedAt   time.Time
+}
+
+func (p *SeatPool) Free() int {
+	return max(p.Seats-p.Used, 0)
+}
+
+type SeatAssignment struct {
+	ID         int64
+	PoolID     int64
+	UserID     int64
+	TelegramID int64
+	Name       string
+	LicenseID  int64
+	AssignedAt time.Time
+}
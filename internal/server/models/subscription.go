@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+const (
+	SubscriptionActive   = "active"
+	SubscriptionExpired  = "expired"
+	SubscriptionCanceled = "canceled"
+)
+
+type Subscription struct {
+	ID               int64
+	LicenseID        int64
+	UserID           int64
+	ProductID        int64
+	Status           string
+	CurrentPeriodEnd time.Time
+	RemindedAt       *time.Time // Когда отправили напоминание о продлении в текущем периоде
+	CreatedAt        time.Time
+}
+
+// ExpiringSubscription подписка с данными, нужными для напоминания пользователю
+type ExpiringSubscription struct {
+	Subscription
+	TelegramID  int64
+	ProductName string
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+type User struct {
+	ID           int64
+	TelegramID   int64
+	Name         string
+	Group        string
+	LicenseKey   string // Открытый ключ, заполнен только сразу после выпуска
+	KeyHash      string
+	KeyHint      string
+	ReferralCode string
+	ReferredBy   *int64 // Кто пригласил пользователя по реферальной ссылке
+	IsLeader     bool   // Староста, может покупать групповые лицензии
+	CreatedAt    time.Time
+}
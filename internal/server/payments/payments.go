@@ -0,0 +1,142 @@
+package payments
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const ProviderLocal = "local"
+
+// Статусы платежа у провайдера
+const (
+	PaymentPending   = "pending"
+	PaymentSucceeded = "succeeded"
+	PaymentCanceled  = "canceled"
+)
+
+// localPaymentPrefix отличает платежи локального провайдера от платежей настоящих провайдеров
+const localPaymentPrefix = "local_pay_"
+
+var (
+	ErrUnknownProvider = errors.New("неизвестный платежный провайдер")
+	ErrUnknownPayment  = errors.New("платеж не найден у провайдера")
+)
+
+// Payment платеж у провайдера, покупатель оплачивает его на странице URL
+type Payment struct {
+	ID  string
+	URL string
+}
+
+// Provider платежный провайдер, через который покупатели платят и получают деньги обратно
+type Provider interface {
+	// CreatePayment создает платеж на amount копеек по покупке
+	CreatePayment(ctx context.Context, purchaseID, amount int64, description string) (*Payment, error)
+	// PaymentStatus возвращает статус платежа: PaymentPending, PaymentSucceeded или PaymentCanceled
+	PaymentStatus(ctx context.Context, paymentID string) (string, error)
+	// Refund возвращает amount копеек по платежу paymentID и отдает идентификатор возврата у провайдера
+	Refund(ctx context.Context, paymentID string, amount int64) (string, error)
+	// Ping проверяет, что провайдер доступен, для проверки готовности сервера
+	Ping(ctx context.Context) error
+}
+
+// New создает провайдера по имени из конфига
+func New(name string, logger *slog.Logger) (Provider, error) {
+	switch name {
+	case ProviderLocal, "":
+		return NewLocalProvider(logger), nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+}
+
+// MustNew как New, но завершает процесс, если провайдер не настроен
+func MustNew(logger *slog.Logger, name string) Provider {
+	provider, err := New(name, logger)
+	if err != nil {
+		logger.Error("Ошибка настройки платежного провайдера", slog.String("op", "payments.MustNew"), slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	return provider
+}
+
+// LocalProvider заглушка провайдера для локальной разработки: деньги никуда не уходят,
+// каждый созданный платеж сразу считается оплаченным, возврат только логируется.
+// В prod конфиг ее не пропускает.
+type LocalProvider struct {
+	logger *slog.Logger
+}
+
+func NewLocalProvider(logger *slog.Logger) *LocalProvider {
+	return &LocalProvider{logger: logger}
+}
+
+func (p *LocalProvider) CreatePayment(ctx context.Context, purchaseID, amount int64, description string) (*Payment, error) {
+	const op = "payments.LocalProvider.CreatePayment"
+
+	paymentID, err := localID(localPaymentPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	p.logger.InfoContext(ctx, "Платеж создан локальным провайдером",
+		slog.String("op", op),
+		slog.Int64("purchase_id", purchaseID),
+		slog.Int64("amount", amount),
+		slog.String("payment_id", paymentID),
+	)
+
+	// Страницы оплаты у заглушки нет, покупатель сразу подтверждает оплату в боте
+	return &Payment{ID: paymentID}, nil
+}
+
+func (p *LocalProvider) PaymentStatus(ctx context.Context, paymentID string) (string, error) {
+	if !strings.HasPrefix(paymentID, localPaymentPrefix) {
+		return "", fmt.Errorf("%w: %s", ErrUnknownPayment, paymentID)
+	}
+
+	return PaymentSucceeded, nil
+}
+
+func (p *LocalProvider) Refund(ctx context.Context, paymentID string, amount int64) (string, error) {
+	const op = "payments.LocalProvider.Refund"
+
+	if !strings.HasPrefix(paymentID, localPaymentPrefix) {
+		return "", fmt.Errorf("%s: %w: %s", op, ErrUnknownPayment, paymentID)
+	}
+
+	refundID, err := localID("local_")
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	p.logger.InfoContext(ctx, "Возврат проведен локальным провайдером",
+		slog.String("op", op),
+		slog.String("payment_id", paymentID),
+		slog.Int64("amount", amount),
+		slog.String("refund_id", refundID),
+	)
+
+	return refundID, nil
+}
+
+// Ping локальному провайдеру ходить некуда, он доступен всегда
+func (p *LocalProvider) Ping(ctx context.Context) error {
+	return nil
+}
+
+func localID(prefix string) (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return prefix + hex.EncodeToString(buf), nil
+}
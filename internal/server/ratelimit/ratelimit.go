@@ -0,0 +1,151 @@
+// Package ratelimit реализует ограничение частоты запросов алгоритмом token bucket.
+// Корзина с емкостью Burst пополняется со скоростью PerMinute токенов в минуту,
+// каждый запрос забирает один токен.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit параметры корзины
+type Limit struct {
+	PerMinute float64
+	Burst     int
+}
+
+func (l Limit) perSecond() float64 {
+	return l.PerMinute / 60
+}
+
+// Result решение по запросу. RetryAfter заполнен, только если запрос отклонен.
+type Result struct {
+	Allowed    bool
+	RetryAfter time.Duration
+}
+
+// Store хранилище корзин
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// Refund возвращает в корзину токен, взятый запросом, который отклонило другое правило
+	Refund(ctx context.Context, key string, limit Limit) error
+	// Cleanup удаляет корзины, к которым не обращались дольше idle. Такие корзины
+	// все равно уже полные, поэтому их удаление не меняет поведения лимитов.
+	Cleanup(ctx context.Context, idle time.Duration) error
+}
+
+// retryAfter время, за которое в корзине с tokens токенами накопится один
+func retryAfter(tokens float64, limit Limit) time.Duration {
+	if limit.PerMinute <= 0 {
+		return time.Minute
+	}
+
+	seconds := (1 - tokens) / limit.perSecond()
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// MemoryStore корзины в памяти процесса. Подходит для одной реплики сервера.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	burst := float64(limit.Burst)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, updatedAt: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.updatedAt).Seconds()*limit.perSecond())
+	b.updatedAt = now
+
+	if b.tokens < 1 {
+		return Result{RetryAfter: retryAfter(b.tokens, limit)}, nil
+	}
+
+	b.tokens--
+	return Result{Allowed: true}, nil
+}
+
+func (s *MemoryStore) Refund(_ context.Context, key string, limit Limit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b, ok := s.buckets[key]; ok {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+1)
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Cleanup(_ context.Context, idle time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	threshold := s.now().Add(-idle)
+	for key, b := range s.buckets {
+		if b.updatedAt.Before(threshold) {
+			delete(s.buckets, key)
+		}
+	}
+
+	return nil
+}
+
+// PostgresRepository корзины в общей таблице, чтобы лимиты работали на все реплики сразу
+type PostgresRepository interface {
+	TakeRateLimitToken(ctx context.Context, key string, perSecond float64, burst int) (allowed bool, tokens float64, err error)
+	RefundRateLimitToken(ctx context.Context, key string, burst int) error
+	DeleteIdleRateLimits(ctx context.Context, idle time.Duration) error
+}
+
+type PostgresStore struct {
+	repo PostgresRepository
+}
+
+func NewPostgresStore(repo PostgresRepository) *PostgresStore {
+	return &PostgresStore{repo: repo}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	allowed, tokens, err := s.repo.TakeRateLimitToken(ctx, key, limit.perSecond(), limit.Burst)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if !allowed {
+		return Result{RetryAfter: retryAfter(tokens, limit)}, nil
+	}
+
+	return Result{Allowed: true}, nil
+}
+
+func (s *PostgresStore) Refund(ctx context.Context, key string, limit Limit) error {
+	return s.repo.RefundRateLimitToken(ctx, key, limit.Burst)
+}
+
+func (s *PostgresStore) Cleanup(ctx context.Context, idle time.Duration) error {
+	return s.repo.DeleteIdleRateLimits(ctx, idle)
+}
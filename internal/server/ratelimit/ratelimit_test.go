@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock время, которое тест переводит вручную
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newTestStore() (*MemoryStore, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	store.now = clock.Now
+
+	return store, clock
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	limit := Limit{PerMinute: 60, Burst: 3} // Токен в секунду
+
+	type step struct {
+		wait       time.Duration
+		refund     bool // Вернуть токен вместо того, чтобы брать
+		allowed    bool
+		retryAfter time.Duration
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "корзина заполнена на burst",
+			steps: []step{
+				{allowed: true},
+				{allowed: true},
+				{allowed: true},
+				{retryAfter: time.Second},
+			},
+		},
+		{
+			name: "пополнение со скоростью per_minute",
+			steps: []step{
+				{allowed: true},
+				{allowed: true},
+				{allowed: true},
+				{wait: 500 * time.Millisecond, retryAfter: 500 * time.Millisecond},
+				{wait: 500 * time.Millisecond, allowed: true},
+				{retryAfter: time.Second},
+			},
+		},
+		{
+			name: "пополнение не больше burst",
+			steps: []step{
+				{allowed: true},
+				{wait: time.Hour, allowed: true},
+				{allowed: true},
+				{allowed: true},
+				{retryAfter: time.Second},
+			},
+		},
+		{
+			name: "возврат токена",
+			steps: []step{
+				{allowed: true},
+				{allowed: true},
+				{allowed: true},
+				{refund: true},
+				{allowed: true},
+				{retryAfter: time.Second},
+			},
+		},
+		{
+			name: "возврат не больше burst",
+			steps: []step{
+				{allowed: true},
+				{refund: true},
+				{refund: true},
+				{allowed: true},
+				{allowed: true},
+				{allowed: true},
+				{retryAfter: time.Second},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, clock := newTestStore()
+			ctx := context.Background()
+
+			for i, s := range tt.steps {
+				clock.now = clock.now.Add(s.wait)
+
+				if s.refund {
+					if err := store.Refund(ctx, "key", limit); err != nil {
+						t.Fatalf("шаг %d: Refund: %v", i, err)
+					}
+					continue
+				}
+
+				result, err := store.Take(ctx, "key", limit)
+				if err != nil {
+					t.Fatalf("шаг %d: Take: %v", i, err)
+				}
+				if result.Allowed != s.allowed || result.RetryAfter != s.retryAfter {
+					t.Fatalf("шаг %d: получено %+v, ожидалось allowed=%v retry_after=%s", i, result, s.allowed, s.retryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		tokens float64
+		limit  Limit
+		want   time.Duration
+	}{
+		{name: "пустая корзина", tokens: 0, limit: Limit{PerMinute: 60}, want: time.Second},
+		{name: "почти полный токен", tokens: 0.75, limit: Limit{PerMinute: 60}, want: 250 * time.Millisecond},
+		{name: "медленное пополнение", tokens: 0, limit: Limit{PerMinute: 2}, want: 30 * time.Second},
+		{name: "без пополнения", tokens: 0, limit: Limit{PerMinute: 0}, want: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryAfter(tt.tokens, tt.limit); got != tt.want {
+				t.Fatalf("retryAfter = %s, ожидалось %s", got, tt.want)
+			}
+		})
+	}
+}
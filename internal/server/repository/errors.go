@@ -0,0 +1,12 @@
+package repository
+
+import "errors"
+
+var (
+	ErrNotFound = errors.New("запись не найдена")
+	ErrConflict = errors.New("запись уже существует")
+
+	ErrNoFreeSeats   = errors.New("в групповой лицензии не осталось свободных мест")
+	ErrDeviceBound   = errors.New("лицензия уже привязана к другому устройству")
+	ErrLimitExceeded = errors.New("превышен лимит использований")
+)
@@ -0,0 +1,185 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+const artifactColumns = `id, product_id, version, storage_key, file_name, content_type, size, sha256, notes, created_at`
+
+// CreateArtifact добавляет следующую версию материалов продукта и заполняет artifact.Version.
+// Одновременная загрузка двух версий — ErrConflict, несуществующий продукт — ErrNotFound.
+func (s *Storage) CreateArtifact(ctx context.Context, artifact *models.Artifact) error {
+	const op = "postgres.CreateArtifact"
+
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO product_artifacts (product_id, version, storage_key, file_name, content_type, size, sha256, notes)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4, $5, $6, $7
+		FROM product_artifacts WHERE product_id = $1
+		RETURNING id, version, created_at`,
+		artifact.ProductID, artifact.StorageKey, artifact.FileName, artifact.ContentType,
+		artifact.Size, artifact.SHA256, artifact.Notes,
+	).Scan(&artifact.ID, &artifact.Version, &artifact.CreatedAt)
+	switch {
+	case isUniqueViolation(err):
+		return fmt.Errorf("%s: %w", op, repository.ErrConflict)
+	case isForeignKeyViolation(err):
+		return fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	case err != nil:
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Artifacts все версии материалов продукта, новые первыми
+func (s *Storage) Artifacts(ctx context.Context, productID int64) ([]*models.Artifact, error) {
+	const op = "postgres.Artifacts"
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+artifactColumns+` FROM product_artifacts
+		WHERE product_id = $1
+		ORDER BY version DESC`,
+		productID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var artifacts []*models.Artifact
+	for rows.Next() {
+		a, err := scanArtifact(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		artifacts = append(artifacts, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return artifacts, nil
+}
+
+// LatestArtifact последняя версия материалов продукта
+func (s *Storage) LatestArtifact(ctx context.Context, productID int64) (*models.Artifact, error) {
+	const op = "postgres.LatestArtifact"
+
+	artifact, err := scanArtifact(s.pool.QueryRow(ctx, `
+		SELECT `+artifactColumns+` FROM product_artifacts
+		WHERE product_id = $1
+		ORDER BY version DESC
+		LIMIT 1`,
+		productID,
+	))
+	if isNoRows(err) {
+		return nil, fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return artifact, nil
+}
+
+func (s *Storage) ArtifactByID(ctx context.Context, artifactID int64) (*models.Artifact, error) {
+	const op = "postgres.ArtifactByID"
+
+	artifact, err := scanArtifact(s.pool.QueryRow(ctx, `SELECT `+artifactColumns+` FROM product_artifacts WHERE id = $1`, artifactID))
+	if isNoRows(err) {
+		return nil, fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return artifact, nil
+}
+
+// CreateArtifactDelivery записывает выдачу материалов. Совпадение метки — ErrConflict.
+func (s *Storage) CreateArtifactDelivery(ctx context.Context, delivery *models.ArtifactDelivery) error {
+	const op = "postgres.CreateArtifactDelivery"
+
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO artifact_deliveries (artifact_id, user_id, license_id, watermark, channel)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		delivery.ArtifactID, delivery.UserID, delivery.LicenseID, delivery.Watermark, delivery.Channel,
+	).Scan(&delivery.ID, &delivery.CreatedAt)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%s: %w", op, repository.ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ArtifactDeliveryByID(ctx context.Context, deliveryID int64) (*models.ArtifactDelivery, error) {
+	const op = "postgres.ArtifactDeliveryByID"
+
+	var d models.ArtifactDelivery
+	err := s.pool.QueryRow(ctx, `
+		SELECT id, artifact_id, user_id, license_id, watermark, channel, created_at
+		FROM artifact_deliveries WHERE id = $1`,
+		deliveryID,
+	).Scan(&d.ID, &d.ArtifactID, &d.UserID, &d.LicenseID, &d.Watermark, &d.Channel, &d.CreatedAt)
+	if isNoRows(err) {
+		return nil, fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &d, nil
+}
+
+// TraceDelivery выдача по метке вместе с покупателем и версией материалов
+func (s *Storage) TraceDelivery(ctx context.Context, watermark string) (*models.DeliveryTrace, error) {
+	const op = "postgres.TraceDelivery"
+
+	var t models.DeliveryTrace
+	d, a := &t.Delivery, &t.Artifact
+	err := s.pool.QueryRow(ctx, `
+		SELECT d.id, d.artifact_id, d.user_id, d.license_id, d.watermark, d.channel, d.created_at,
+			a.id, a.product_id, a.version, a.storage_key, a.file_name, a.content_type, a.size, a.sha256, a.notes, a.created_at,
+			u.telegram_id, u.name, u.group_name
+		FROM artifact_deliveries d
+		JOIN product_artifacts a ON a.id = d.artifact_id
+		JOIN users u ON u.id = d.user_id
+		WHERE d.watermark = $1`,
+		watermark,
+	).Scan(
+		&d.ID, &d.ArtifactID, &d.UserID, &d.LicenseID, &d.Watermark, &d.Channel, &d.CreatedAt,
+		&a.ID, &a.ProductID, &a.Version, &a.StorageKey, &a.FileName, &a.ContentType, &a.Size, &a.SHA256, &a.Notes, &a.CreatedAt,
+		&t.TelegramID, &t.UserName, &t.UserGroup,
+	)
+	if isNoRows(err) {
+		return nil, fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &t, nil
+}
+
+func scanArtifact(row pgx.Row) (*models.Artifact, error) {
+	var a models.Artifact
+	err := row.Scan(
+		&a.ID, &a.ProductID, &a.Version, &a.StorageKey, &a.FileName,
+		&a.ContentType, &a.Size, &a.SHA256, &a.Notes, &a.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Storage) CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	const op = "postgres.CreateAuditEvent"
+
+	details := event.Details
+	if details == nil {
+		details = map[string]any{}
+	}
+
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO audit_log (action, actor_type, actor_id, target_type, target_id, ip, request_id, via, details)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at`,
+		event.Action, event.ActorType, event.ActorID, event.TargetType, event.TargetID,
+		event.IP, event.RequestID, event.Via, details,
+	).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AuditEvents вызывает fn для каждой записи журнала, подходящей под filter, от новых к старым.
+// Строки читаются потоком, поэтому экспорт большого журнала не держит его в памяти.
+func (s *Storage) AuditEvents(ctx context.Context, filter models.AuditFilter, fn func(*models.AuditEvent) error) error {
+	const op = "postgres.AuditEvents"
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, action, actor_type, actor_id, target_type, target_id, ip, request_id, via, details, created_at
+		FROM audit_log
+		WHERE ($1 = '' OR action = $1)
+			AND ($2 = '' OR actor_type = $2)
+			AND ($3 = '' OR actor_id = $3)
+			AND ($4 = '' OR target_type = $4)
+			AND ($5 = '' OR target_id = $5)
+			AND ($6::timestamptz IS NULL OR created_at >= $6)
+			AND ($7::timestamptz IS NULL OR created_at < $7)
+			AND ($8 = 0 OR id < $8)
+		ORDER BY id DESC
+		LIMIT NULLIF($9, 0)`,
+		filter.Action, filter.ActorType, filter.ActorID, filter.TargetType, filter.TargetID,
+		filter.From, filter.To, filter.BeforeID, filter.Limit,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err := fn(event); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func scanAuditEvent(row pgx.Row) (*models.AuditEvent, error) {
+	var e models.AuditEvent
+	err := row.Scan(
+		&e.ID, &e.Action, &e.ActorType, &e.ActorID, &e.TargetType, &e.TargetID,
+		&e.IP, &e.RequestID, &e.Via, &e.Details, &e.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
@@ -42,6 +42,7 @@ func newPool(pgConf config.PostgresConfig) (*pgxpool.Pool, error) {
 	poolConf.MaxConnIdleTime = pgConf.Postgres.Connection.MaxIdleTime
 	poolConf.HealthCheckPeriod = pgConf.Postgres.Connection.HealthCheckPeriod
 	poolConf.ConnConfig.ConnectTimeout = pgConf.Postgres.Connection.Timeout
+	poolConf.ConnConfig.Tracer = newQueryTracer()
 
 	pool, err := pgxpool.NewWithConfig(context.Background(), poolConf)
 	if err != nil {
@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// ledgerPosting одна проводка транзакции
+type ledgerPosting struct {
+	accountID int64
+	amount    int64
+}
+
+// WalletBalance возвращает баланс кошелька пользователя в копейках
+func (s *Storage) WalletBalance(ctx context.Context, userID int64) (int64, error) {
+	const op = "postgres.WalletBalance"
+
+	var balance int64
+	err := s.pool.QueryRow(ctx, `
+		SELECT COALESCE(SUM(e.amount), 0)
+		FROM ledger_entries e
+		JOIN ledger_accounts a ON a.id = e.account_id
+		WHERE a.user_id = $1`,
+		userID,
+	).Scan(&balance)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return balance, nil
+}
+
+// WalletHistory возвращает последние limit проводок по кошельку пользователя
+func (s *Storage) WalletHistory(ctx context.Context, userID int64, limit int) ([]*models.LedgerEntry, error) {
+	const op = "postgres.WalletHistory"
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT t.id, t.kind, t.description, e.amount, t.created_at
+		FROM ledger_entries e
+		JOIN ledger_accounts a ON a.id = e.account_id
+		JOIN ledger_transactions t ON t.id = e.transaction_id
+		WHERE a.user_id = $1
+		ORDER BY t.id DESC
+		LIMIT $2`,
+		userID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var history []*models.LedgerEntry
+	for rows.Next() {
+		var entry models.LedgerEntry
+		if err := rows.Scan(&entry.TransactionID, &entry.Kind, &entry.Description, &entry.Amount, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		history = append(history, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return history, nil
+}
+
+// lockWallet создает кошелек пользователя при необходимости, блокирует его до конца
+// транзакции и возвращает id счета и текущий баланс
+func lockWallet(ctx context.Context, tx pgx.Tx, userID int64) (int64, int64, error) {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO ledger_accounts (kind, user_id) VALUES ('wallet', $1)
+		ON CONFLICT (user_id) DO NOTHING`,
+		userID,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var accountID int64
+	err = tx.QueryRow(ctx, `SELECT id FROM ledger_accounts WHERE user_id = $1 FOR UPDATE`, userID).Scan(&accountID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var balance int64
+	err = tx.QueryRow(ctx, `SELECT COALESCE(SUM(amount), 0) FROM ledger_entries WHERE account_id = $1`, accountID).
+		Scan(&balance)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return accountID, balance, nil
+}
+
+func systemAccount(ctx context.Context, tx pgx.Tx, code string) (int64, error) {
+	var accountID int64
+	err := tx.QueryRow(ctx, `SELECT id FROM ledger_accounts WHERE code = $1`, code).Scan(&accountID)
+	if err != nil {
+		return 0, fmt.Errorf("системный счет %s: %w", code, err)
+	}
+
+	return accountID, nil
+}
+
+// postTransaction записывает транзакцию из сбалансированных проводок и возвращает ее id.
+// Нулевые проводки пропускаются.
+func postTransaction(ctx context.Context, tx pgx.Tx, kind, description string, purchaseID *int64, postings ...ledgerPosting) (int64, error) {
+	var sum int64
+	for _, p := range postings {
+		sum += p.amount
+	}
+	if sum != 0 {
+		return 0, errors.New("сумма проводок транзакции не равна нулю")
+	}
+
+	var txnID int64
+	err := tx.QueryRow(ctx, `
+		INSERT INTO ledger_transactions (kind, description, purchase_id)
+		VALUES ($1, $2, $3)
+		RETURNING id`,
+		kind, description, purchaseID,
+	).Scan(&txnID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, p := range postings {
+		if p.amount == 0 {
+			continue
+		}
+
+		_, err := tx.Exec(ctx, `
+			INSERT INTO ledger_entries (transaction_id, account_id, amount)
+			VALUES ($1, $2, $3)`,
+			txnID, p.accountID, p.amount,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return txnID, nil
+}
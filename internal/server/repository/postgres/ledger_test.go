@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+)
+
+func TestLedgerBalanceTrigger(t *testing.T) {
+	s := testStorage(t)
+
+	tests := []struct {
+		name     string
+		amounts  []int64 // Проводки транзакции: бонусы, выручка, ...
+		balanced bool
+	}{
+		{name: "сбалансирована", amounts: []int64{-500, 500}, balanced: true},
+		{name: "три счета", amounts: []int64{-500, 200, 300}, balanced: true},
+		{name: "одна проводка", amounts: []int64{500}},
+		{name: "сумма не ноль", amounts: []int64{-500, 400}},
+	}
+
+	codes := []string{models.AccountBonus, models.AccountRevenue, models.AccountExternal}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			tx, err := s.pool.Begin(ctx)
+			if err != nil {
+				t.Fatalf("начало транзакции: %v", err)
+			}
+			defer tx.Rollback(ctx)
+
+			var txnID int64
+			err = tx.QueryRow(ctx, `INSERT INTO ledger_transactions (kind) VALUES ('test') RETURNING id`).Scan(&txnID)
+			if err != nil {
+				t.Fatalf("транзакция: %v", err)
+			}
+
+			// Пишем мимо postTransaction, чтобы проверить сам триггер
+			for i, amount := range tt.amounts {
+				accountID, err := systemAccount(ctx, tx, codes[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = tx.Exec(ctx, `
+					INSERT INTO ledger_entries (transaction_id, account_id, amount)
+					VALUES ($1, $2, $3)`,
+					txnID, accountID, amount,
+				)
+				if err != nil {
+					t.Fatalf("проводка: %v", err)
+				}
+			}
+
+			err = tx.Commit(ctx)
+			if tt.balanced && err != nil {
+				t.Fatalf("сбалансированная транзакция отклонена: %v", err)
+			}
+			if !tt.balanced && err == nil {
+				t.Fatal("несбалансированная транзакция закоммичена")
+			}
+		})
+	}
+}
+
+func TestPostTransactionRejectsUnbalanced(t *testing.T) {
+	// До базы дело не доходит, поэтому tx не нужен
+	_, err := postTransaction(context.Background(), nil, models.LedgerPurchase, "", nil,
+		ledgerPosting{accountID: 1, amount: 100},
+		ledgerPosting{accountID: 2, amount: -99},
+	)
+	if err == nil {
+		t.Fatal("ожидалась ошибка несбалансированных проводок")
+	}
+}
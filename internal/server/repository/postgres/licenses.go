@@ -0,0 +1,181 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+// LicenseByKey ищет лицензию пользователя с хешем ключа keyHash на продукт productID
+func (s *Storage) LicenseByKey(ctx context.Context, keyHash string, productID int64) (*models.License, error) {
+	const op = "postgres.LicenseByKey"
+
+	var license models.License
+	err := s.pool.QueryRow(ctx, `
+		SELECT l.id, l.user_id, l.product_id, l.status, l.device_fingerprint, l.expires_at, l.created_at
+		FROM licenses l
+		JOIN users u ON u.id = l.user_id
+		WHERE u.license_key_hash = $1 AND l.product_id = $2`,
+		keyHash, productID,
+	).Scan(
+		&license.ID, &license.UserID, &license.ProductID, &license.Status,
+		&license.DeviceFingerprint, &license.ExpiresAt, &license.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &license, nil
+}
+
+// LicenseByUser лицензия пользователя на продукт в любом статусе
+func (s *Storage) LicenseByUser(ctx context.Context, userID, productID int64) (*models.License, error) {
+	const op = "postgres.LicenseByUser"
+
+	var license models.License
+	err := s.pool.QueryRow(ctx, `
+		SELECT id, user_id, product_id, status, device_fingerprint, expires_at, created_at
+		FROM licenses
+		WHERE user_id = $1 AND product_id = $2`,
+		userID, productID,
+	).Scan(
+		&license.ID, &license.UserID, &license.ProductID, &license.Status,
+		&license.DeviceFingerprint, &license.ExpiresAt, &license.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &license, nil
+}
+
+// BindDevice привязывает лицензию к устройству, если она еще ни к чему не привязана.
+// Если два устройства привязываются одновременно, выигрывает первое, второе получает
+// repository.ErrDeviceBound.
+func (s *Storage) BindDevice(ctx context.Context, licenseID int64, fingerprint string) error {
+	const op = "postgres.BindDevice"
+
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE licenses SET device_fingerprint = $2
+		WHERE id = $1 AND device_fingerprint = ''`,
+		licenseID, fingerprint,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	// Лицензию уже кто-то привязал: повторная проверка с того же устройства не ошибка
+	var bound string
+	err = s.pool.QueryRow(ctx, `SELECT device_fingerprint FROM licenses WHERE id = $1`, licenseID).Scan(&bound)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if bound != fingerprint {
+		return fmt.Errorf("%s: %w", op, repository.ErrDeviceBound)
+	}
+
+	return nil
+}
+
+// IssueLicense выдает пользователю лицензию на продукт. Для продуктов с подпиской
+// вместе с лицензией создается подписка на первый оплаченный период.
+func (s *Storage) IssueLicense(ctx context.Context, userID, productID int64) (*models.License, error) {
+	const op = "postgres.IssueLicense"
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	license, err := issueLicense(ctx, tx, userID, productID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return license, nil
+}
+
+func issueLicense(ctx context.Context, tx pgx.Tx, userID, productID int64) (*models.License, error) {
+	var product models.Product
+	err := tx.QueryRow(ctx, `SELECT billing_type, period_days FROM products WHERE id = $1`, productID).
+		Scan(&product.BillingType, &product.PeriodDays)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var expiresAt *time.Time
+	if product.IsSubscription() {
+		// Продление активной подписки начинается с конца текущего периода
+		periodStart := time.Now()
+
+		var current *time.Time
+		err := tx.QueryRow(ctx, `
+			SELECT expires_at FROM licenses
+			WHERE user_id = $1 AND product_id = $2 AND status = 'active'
+			FOR UPDATE`,
+			userID, productID,
+		).Scan(&current)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+		if current != nil && current.After(periodStart) {
+			periodStart = *current
+		}
+
+		periodEnd := periodStart.Add(product.Period())
+		expiresAt = &periodEnd
+	}
+
+	license := models.License{UserID: userID, ProductID: productID, ExpiresAt: expiresAt}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO licenses (user_id, product_id, status, expires_at)
+		VALUES ($1, $2, 'active', $3)
+		ON CONFLICT (user_id, product_id) DO UPDATE
+			SET status = 'active', expires_at = EXCLUDED.expires_at
+		RETURNING id, status, device_fingerprint, created_at`,
+		userID, productID, expiresAt,
+	).Scan(&license.ID, &license.Status, &license.DeviceFingerprint, &license.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if product.IsSubscription() {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO subscriptions (license_id, status, current_period_end)
+			VALUES ($1, 'active', $2)
+			ON CONFLICT (license_id) DO UPDATE
+				SET status = 'active', current_period_end = EXCLUDED.current_period_end, reminded_at = NULL`,
+			license.ID, *expiresAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &license, nil
+}
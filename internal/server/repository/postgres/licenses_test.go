@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+)
+
+func TestBindDeviceRace(t *testing.T) {
+	s := testStorage(t)
+	ctx := context.Background()
+
+	user := createTestUser(t, s, 1001)
+	productID := createTestProduct(t, s, 10000)
+	license, err := s.IssueLicense(ctx, user.ID, productID)
+	if err != nil {
+		t.Fatalf("IssueLicense: %v", err)
+	}
+
+	const devices = 8
+	errs := make([]error, devices)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range devices {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			errs[i] = s.BindDevice(ctx, license.ID, fmt.Sprintf("device-%d", i))
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	winner := -1
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			if winner >= 0 {
+				t.Fatalf("привязались два устройства: %d и %d", winner, i)
+			}
+			winner = i
+		case !errors.Is(err, repository.ErrDeviceBound):
+			t.Fatalf("устройство %d: ожидалась ErrDeviceBound, получено %v", i, err)
+		}
+	}
+	if winner < 0 {
+		t.Fatal("ни одно устройство не привязалось")
+	}
+
+	// Повторная привязка того же устройства не ошибка
+	if err := s.BindDevice(ctx, license.ID, fmt.Sprintf("device-%d", winner)); err != nil {
+		t.Fatalf("повторная привязка: %v", err)
+	}
+
+	stored, err := s.LicenseByUser(ctx, user.ID, productID)
+	if err != nil {
+		t.Fatalf("LicenseByUser: %v", err)
+	}
+	if want := fmt.Sprintf("device-%d", winner); stored.DeviceFingerprint != want {
+		t.Fatalf("привязано %q, ожидалось %q", stored.DeviceFingerprint, want)
+	}
+}
+
+func TestBindDeviceNotFound(t *testing.T) {
+	s := testStorage(t)
+
+	err := s.BindDevice(context.Background(), 424242, "device")
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("ожидалась ErrNotFound, получено %v", err)
+	}
+}
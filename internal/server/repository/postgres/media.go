@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+const mediaColumns = `id, product_id, kind, position, storage_key, file_name, content_type, size, sha256, created_at`
+
+// CreateProductMedia добавляет файл к карточке продукта. Вторая обложка или второй
+// пример — ErrConflict, несуществующий продукт — ErrNotFound.
+func (s *Storage) CreateProductMedia(ctx context.Context, media *models.ProductMedia) error {
+	const op = "postgres.CreateProductMedia"
+
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO product_media (product_id, kind, position, storage_key, file_name, content_type, size, sha256)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at`,
+		media.ProductID, media.Kind, media.Position, media.StorageKey,
+		media.FileName, media.ContentType, media.Size, media.SHA256,
+	).Scan(&media.ID, &media.CreatedAt)
+	switch {
+	case isUniqueViolation(err):
+		return fmt.Errorf("%s: %w", op, repository.ErrConflict)
+	case isForeignKeyViolation(err):
+		return fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	case err != nil:
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ProductMedia файлы карточки продукта в порядке показа: обложка, скриншоты, пример
+func (s *Storage) ProductMedia(ctx context.Context, productID int64) ([]*models.ProductMedia, error) {
+	const op = "postgres.ProductMedia"
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+mediaColumns+` FROM product_media
+		WHERE product_id = $1
+		ORDER BY CASE kind WHEN 'cover' THEN 0 WHEN 'screenshot' THEN 1 ELSE 2 END, position, id`,
+		productID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var media []*models.ProductMedia
+	for rows.Next() {
+		m, err := scanProductMedia(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		media = append(media, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return media, nil
+}
+
+func (s *Storage) ProductMediaByID(ctx context.Context, mediaID int64) (*models.ProductMedia, error) {
+	const op = "postgres.ProductMediaByID"
+
+	media, err := scanProductMedia(s.pool.QueryRow(ctx, `SELECT `+mediaColumns+` FROM product_media WHERE id = $1`, mediaID))
+	if isNoRows(err) {
+		return nil, fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return media, nil
+}
+
+// DeleteProductMedia удаляет запись о файле продукта и возвращает ее, чтобы
+// вызывающий удалил содержимое из хранилища
+func (s *Storage) DeleteProductMedia(ctx context.Context, productID, mediaID int64) (*models.ProductMedia, error) {
+	const op = "postgres.DeleteProductMedia"
+
+	media, err := scanProductMedia(s.pool.QueryRow(ctx, `
+		DELETE FROM product_media WHERE id = $1 AND product_id = $2
+		RETURNING `+mediaColumns,
+		mediaID, productID,
+	))
+	if isNoRows(err) {
+		return nil, fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return media, nil
+}
+
+func scanProductMedia(row pgx.Row) (*models.ProductMedia, error) {
+	var m models.ProductMedia
+	err := row.Scan(
+		&m.ID, &m.ProductID, &m.Kind, &m.Position, &m.StorageKey,
+		&m.FileName, &m.ContentType, &m.Size, &m.SHA256, &m.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
@@ -0,0 +1,162 @@
+package postgres
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+func MustMigrate(logger *slog.Logger, pool *pgxpool.Pool) {
+	const op = "server.repository.postgres.MustMigrate"
+	logger = logger.With(slog.String("op", op))
+
+	version, err := Migrate(context.Background(), pool)
+	if err != nil {
+		logger.Error("Не удалось применить миграции", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	logger.Info("Схема базы данных актуальна", slog.Int("version", version))
+}
+
+// Migrate применяет все еще не примененные миграции, каждую в своей транзакции.
+// Возвращает версию схемы после применения.
+func Migrate(ctx context.Context, pool *pgxpool.Pool) (int, error) {
+	return migrateTo(ctx, pool, math.MaxInt)
+}
+
+// migrateTo применяет миграции до версии target включительно
+func migrateTo(ctx context.Context, pool *pgxpool.Pool, target int) (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INT PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось создать таблицу миграций: %w", err)
+	}
+
+	current, err := SchemaVersion(ctx, pool)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if m.version > target {
+			break
+		}
+
+		if err := applyMigration(ctx, pool, m); err != nil {
+			return current, err
+		}
+		current = m.version
+	}
+
+	return current, nil
+}
+
+// SchemaVersion возвращает версию последней примененной миграции
+func SchemaVersion(ctx context.Context, pool *pgxpool.Pool) (int, error) {
+	var version int
+	err := pool.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось получить версию схемы: %w", err)
+	}
+
+	return version, nil
+}
+
+// LatestMigrationVersion версия последней миграции, встроенной в бинарник.
+// Схема базы с другой версией не подходит этому бинарнику.
+func LatestMigrationVersion() (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+
+	return migrations[len(migrations)-1].version, nil
+}
+
+func applyMigration(ctx context.Context, pool *pgxpool.Pool, m migration) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("не удалось начать транзакцию миграции %s: %w", m.name, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, m.sql); err != nil {
+		return fmt.Errorf("ошибка применения миграции %s: %w", m.name, err)
+	}
+
+	if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, m.version); err != nil {
+		return fmt.Errorf("не удалось записать версию миграции %s: %w", m.name, err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+func loadMigrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrationsFS, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать миграции: %w", err)
+	}
+
+	migrations := make([]migration, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+
+		// Имя файла миграции имеет вид 0001_description.sql
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("неверное имя файла миграции %s", name)
+		}
+
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("неверная версия в имени миграции %s: %w", name, err)
+		}
+
+		data, err := migrationsFS.ReadFile("migrations/" + name)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать миграцию %s: %w", name, err)
+		}
+
+		migrations = append(migrations, migration{version: version, name: name, sql: string(data)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/licensekey"
+)
+
+// Подсказки ключей, выданных до 0008, той же длины, что у новых ключей, но открывают
+// не больше 4 символов ключа
+func TestLegacyKeyHints(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	if _, err := migrateTo(ctx, pool, 7); err != nil {
+		t.Fatalf("миграции до 0007: %v", err)
+	}
+	_, err := pool.Exec(ctx, `
+		INSERT INTO users (telegram_id, name, group_name, license_key, referral_code) VALUES
+			(1, 'Старый', 'ИУ5-31', 'abcd1234efgh5678', 'ref1'),
+			(2, 'Открытый', 'ИУ5-31', 'wxyz9876stuv5432', 'ref2')`)
+	if err != nil {
+		t.Fatalf("пользователи со старыми ключами: %v", err)
+	}
+
+	if _, err := migrateTo(ctx, pool, 8); err != nil {
+		t.Fatalf("миграция 0008: %v", err)
+	}
+
+	// База, где 0008 успела записать 12 символов старого ключа
+	if _, err := pool.Exec(ctx, `UPDATE users SET license_key_hint = 'wxyz9876stuv' WHERE telegram_id = 2`); err != nil {
+		t.Fatalf("подсказка из прежней 0008: %v", err)
+	}
+
+	key, err := licensekey.Generate()
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	_, err = pool.Exec(ctx, `
+		INSERT INTO users (telegram_id, name, group_name, license_key_hash, license_key_hint, referral_code)
+		VALUES (3, 'Новый', 'ИУ5-31', $1, $2, 'ref3')`,
+		key.Hash, key.Hint,
+	)
+	if err != nil {
+		t.Fatalf("пользователь с новым ключом: %v", err)
+	}
+
+	if _, err := Migrate(ctx, pool); err != nil {
+		t.Fatalf("миграции: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		telegramID int64
+		want       string
+	}{
+		{name: "старый ключ", telegramID: 1, want: "abcd********"},
+		{name: "старый ключ после прежней 0008", telegramID: 2, want: "wxyz********"},
+		{name: "новый ключ не меняется", telegramID: 3, want: key.Hint},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hint string
+			if err := pool.QueryRow(ctx, `SELECT license_key_hint FROM users WHERE telegram_id = $1`, tt.telegramID).Scan(&hint); err != nil {
+				t.Fatalf("подсказка: %v", err)
+			}
+			if hint != tt.want {
+				t.Fatalf("подсказка %q, ожидалась %q", hint, tt.want)
+			}
+			if len(hint) != len(key.Hint) {
+				t.Fatalf("длина подсказки %d, у новых ключей %d", len(hint), len(key.Hint))
+			}
+		})
+	}
+}
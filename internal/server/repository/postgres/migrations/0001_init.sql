@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS users (
+    id          BIGSERIAL PRIMARY KEY,
+    telegram_id BIGINT      NOT NULL UNIQUE,
+    name        TEXT        NOT NULL,
+    group_name  TEXT        NOT NULL,
+    license_key TEXT        NOT NULL UNIQUE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS products (
+    id          BIGSERIAL PRIMARY KEY,
+    name        TEXT        NOT NULL,
+    description TEXT        NOT NULL DEFAULT '',
+    price       BIGINT      NOT NULL CHECK (price >= 0),
+    link        TEXT        NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS licenses (
+    id                 BIGSERIAL PRIMARY KEY,
+    user_id            BIGINT      NOT NULL REFERENCES users (id),
+    product_id         BIGINT      NOT NULL REFERENCES products (id),
+    status             TEXT        NOT NULL DEFAULT 'active',
+    device_fingerprint TEXT        NOT NULL DEFAULT '',
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, product_id)
+);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id          BIGSERIAL PRIMARY KEY,
+    telegram_id BIGINT      NOT NULL,
+    text        TEXT        NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at     TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS notifications_pending_idx ON notifications (id) WHERE sent_at IS NULL;
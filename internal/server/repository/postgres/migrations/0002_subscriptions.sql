@@ -0,0 +1,23 @@
+ALTER TABLE products
+    ADD COLUMN billing_type TEXT NOT NULL DEFAULT 'one_time'
+        CHECK (billing_type IN ('one_time', 'subscription')),
+    ADD COLUMN period_days  INT  NOT NULL DEFAULT 0
+        CHECK (period_days >= 0);
+
+ALTER TABLE products
+    ADD CONSTRAINT products_subscription_period_check
+        CHECK (billing_type <> 'subscription' OR period_days > 0);
+
+ALTER TABLE licenses
+    ADD COLUMN expires_at TIMESTAMPTZ;
+
+CREATE TABLE subscriptions (
+    id                 BIGSERIAL PRIMARY KEY,
+    license_id         BIGINT      NOT NULL UNIQUE REFERENCES licenses (id),
+    status             TEXT        NOT NULL DEFAULT 'active',
+    current_period_end TIMESTAMPTZ NOT NULL,
+    reminded_at        TIMESTAMPTZ,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX subscriptions_period_end_idx ON subscriptions (current_period_end) WHERE status = 'active';
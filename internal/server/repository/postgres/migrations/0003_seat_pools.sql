@@ -0,0 +1,26 @@
+CREATE TABLE seat_pools (
+    id            BIGSERIAL PRIMARY KEY,
+    product_id    BIGINT      NOT NULL REFERENCES products (id),
+    owner_user_id BIGINT      NOT NULL REFERENCES users (id),
+    group_name    TEXT        NOT NULL DEFAULT '',
+    seats         INT         NOT NULL CHECK (seats > 0),
+    invite_code   TEXT        NOT NULL UNIQUE,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX seat_pools_owner_idx ON seat_pools (owner_user_id);
+
+CREATE TABLE seat_assignments (
+    id          BIGSERIAL PRIMARY KEY,
+    pool_id     BIGINT      NOT NULL REFERENCES seat_pools (id),
+    user_id     BIGINT      NOT NULL REFERENCES users (id),
+    license_id  BIGINT      NOT NULL REFERENCES licenses (id),
+    assigned_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    released_at TIMESTAMPTZ
+);
+
+-- Одно занятое место на пользователя в пуле
+CREATE UNIQUE INDEX seat_assignments_active_idx ON seat_assignments (pool_id, user_id) WHERE released_at IS NULL;
+
+ALTER TABLE licenses
+    ADD COLUMN seat_pool_id BIGINT REFERENCES seat_pools (id);
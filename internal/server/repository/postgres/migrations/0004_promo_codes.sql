@@ -0,0 +1,40 @@
+CREATE TABLE promo_codes (
+    id                BIGSERIAL PRIMARY KEY,
+    code              TEXT        NOT NULL UNIQUE,
+    discount_type     TEXT        NOT NULL CHECK (discount_type IN ('percent', 'fixed')),
+    discount_value    BIGINT      NOT NULL CHECK (discount_value > 0),
+    product_id        BIGINT      REFERENCES products (id),
+    group_name        TEXT        NOT NULL DEFAULT '',
+    max_uses          INT         CHECK (max_uses > 0),
+    max_uses_per_user INT         NOT NULL DEFAULT 1 CHECK (max_uses_per_user > 0),
+    valid_from        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    valid_until       TIMESTAMPTZ,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (discount_type <> 'percent' OR discount_value <= 100)
+);
+
+CREATE TABLE purchases (
+    id             BIGSERIAL PRIMARY KEY,
+    user_id        BIGINT      NOT NULL REFERENCES users (id),
+    product_id     BIGINT      NOT NULL REFERENCES products (id),
+    license_id     BIGINT      REFERENCES licenses (id),
+    original_price BIGINT      NOT NULL,
+    discount       BIGINT      NOT NULL DEFAULT 0,
+    amount         BIGINT      NOT NULL CHECK (amount >= 0),
+    promo_code_id  BIGINT      REFERENCES promo_codes (id),
+    status         TEXT        NOT NULL DEFAULT 'pending',
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    paid_at        TIMESTAMPTZ
+);
+
+CREATE INDEX purchases_user_idx ON purchases (user_id);
+
+CREATE TABLE promo_redemptions (
+    id            BIGSERIAL PRIMARY KEY,
+    promo_code_id BIGINT      NOT NULL REFERENCES promo_codes (id),
+    user_id       BIGINT      NOT NULL REFERENCES users (id),
+    purchase_id   BIGINT      NOT NULL UNIQUE REFERENCES purchases (id),
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX promo_redemptions_code_user_idx ON promo_redemptions (promo_code_id, user_id);
@@ -0,0 +1,24 @@
+ALTER TABLE users
+    ADD COLUMN referral_code TEXT UNIQUE,
+    ADD COLUMN referred_by   BIGINT REFERENCES users (id),
+    ADD CONSTRAINT users_no_self_referral CHECK (referred_by <> id);
+
+UPDATE users SET referral_code = substr(md5(random()::text || id::text), 1, 10)
+WHERE referral_code IS NULL;
+
+ALTER TABLE users
+    ALTER COLUMN referral_code SET NOT NULL;
+
+CREATE INDEX users_referred_by_idx ON users (referred_by);
+
+-- Бонус начисляется один раз за каждого приглашенного
+CREATE TABLE referral_rewards (
+    id          BIGSERIAL PRIMARY KEY,
+    referrer_id BIGINT      NOT NULL REFERENCES users (id),
+    referee_id  BIGINT      NOT NULL UNIQUE REFERENCES users (id),
+    purchase_id BIGINT      NOT NULL UNIQUE REFERENCES purchases (id),
+    amount      BIGINT      NOT NULL CHECK (amount >= 0),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX referral_rewards_referrer_idx ON referral_rewards (referrer_id);
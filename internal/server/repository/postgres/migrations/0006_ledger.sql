@@ -0,0 +1,81 @@
+-- Счета двойной записи: кошельки пользователей и системные счета
+CREATE TABLE ledger_accounts (
+    id         BIGSERIAL PRIMARY KEY,
+    kind       TEXT        NOT NULL CHECK (kind IN ('wallet', 'system')),
+    code       TEXT        UNIQUE,
+    user_id    BIGINT      UNIQUE REFERENCES users (id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK ((kind = 'wallet') = (user_id IS NOT NULL)),
+    CHECK ((kind = 'system') = (code IS NOT NULL))
+);
+
+INSERT INTO ledger_accounts (kind, code) VALUES
+    ('system', 'bonus'),    -- Расходы на бонусы и промо-начисления
+    ('system', 'revenue'),  -- Выручка от продаж
+    ('system', 'external'); -- Деньги, пришедшие через платежную систему
+
+CREATE TABLE ledger_transactions (
+    id          BIGSERIAL PRIMARY KEY,
+    kind        TEXT        NOT NULL,
+    description TEXT        NOT NULL DEFAULT '',
+    purchase_id BIGINT      REFERENCES purchases (id),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE ledger_entries (
+    id             BIGSERIAL PRIMARY KEY,
+    transaction_id BIGINT NOT NULL REFERENCES ledger_transactions (id),
+    account_id     BIGINT NOT NULL REFERENCES ledger_accounts (id),
+    amount         BIGINT NOT NULL CHECK (amount <> 0)
+);
+
+CREATE INDEX ledger_entries_account_idx ON ledger_entries (account_id, transaction_id);
+
+-- Сумма проводок каждой транзакции должна быть нулевой, проверяем при коммите
+CREATE FUNCTION ledger_check_balanced() RETURNS trigger AS $$
+BEGIN
+    IF (SELECT SUM(amount) FROM ledger_entries WHERE transaction_id = NEW.transaction_id) <> 0 THEN
+        RAISE EXCEPTION 'ledger transaction % is not balanced', NEW.transaction_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER ledger_entries_balanced
+    AFTER INSERT ON ledger_entries
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION ledger_check_balanced();
+
+ALTER TABLE purchases
+    ADD COLUMN balance_used BIGINT NOT NULL DEFAULT 0 CHECK (balance_used >= 0 AND balance_used <= amount);
+
+ALTER TABLE referral_rewards
+    ADD COLUMN transaction_id BIGINT REFERENCES ledger_transactions (id);
+
+-- Переносим уже начисленные реферальные бонусы в кошельки
+DO $$
+DECLARE
+    reward  RECORD;
+    wallet  BIGINT;
+    bonus   BIGINT;
+    txn     BIGINT;
+BEGIN
+    SELECT id INTO bonus FROM ledger_accounts WHERE code = 'bonus';
+
+    FOR reward IN SELECT * FROM referral_rewards WHERE amount > 0 ORDER BY id LOOP
+        INSERT INTO ledger_accounts (kind, user_id) VALUES ('wallet', reward.referrer_id)
+        ON CONFLICT (user_id) DO NOTHING;
+        SELECT id INTO wallet FROM ledger_accounts WHERE user_id = reward.referrer_id;
+
+        INSERT INTO ledger_transactions (kind, description, created_at)
+        VALUES ('referral_bonus', 'Бонус за приглашенного', reward.created_at)
+        RETURNING id INTO txn;
+
+        INSERT INTO ledger_entries (transaction_id, account_id, amount) VALUES
+            (txn, bonus, -reward.amount),
+            (txn, wallet, reward.amount);
+
+        UPDATE referral_rewards SET transaction_id = txn WHERE id = reward.id;
+    END LOOP;
+END;
+$$;
@@ -0,0 +1,15 @@
+CREATE TABLE refunds (
+    id                 BIGSERIAL PRIMARY KEY,
+    purchase_id        BIGINT      NOT NULL UNIQUE REFERENCES purchases (id),
+    initiator          TEXT        NOT NULL CHECK (initiator IN ('user', 'admin')),
+    reason             TEXT        NOT NULL DEFAULT '',
+    status             TEXT        NOT NULL DEFAULT 'requested'
+        CHECK (status IN ('requested', 'processing', 'completed', 'rejected', 'failed')),
+    amount             BIGINT      NOT NULL CHECK (amount >= 0),
+    provider_refund_id TEXT        NOT NULL DEFAULT '',
+    note               TEXT        NOT NULL DEFAULT '', -- Комментарий администратора или текст ошибки
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    resolved_at        TIMESTAMPTZ
+);
+
+CREATE INDEX refunds_status_idx ON refunds (status, id);
@@ -0,0 +1,15 @@
+-- Лицензионные ключи хранятся только в виде sha256 хеша. Ключи, выданные до
+-- миграции, хешируются и продолжают работать до первого перевыпуска.
+ALTER TABLE users
+    ADD COLUMN license_key_hash       TEXT,
+    ADD COLUMN license_key_hint       TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN license_key_rotated_at TIMESTAMPTZ;
+
+UPDATE users
+SET license_key_hash = encode(sha256(convert_to(license_key, 'UTF8')), 'hex'),
+    license_key_hint = left(license_key, 4);
+
+ALTER TABLE users
+    ALTER COLUMN license_key_hash SET NOT NULL,
+    ADD CONSTRAINT users_license_key_hash_key UNIQUE (license_key_hash),
+    DROP COLUMN license_key;
@@ -0,0 +1,17 @@
+-- Корзины token bucket, общие для всех реплик сервера
+CREATE UNLOGGED TABLE rate_limit_buckets (
+    key        TEXT             PRIMARY KEY,
+    tokens     DOUBLE PRECISION NOT NULL,
+    allowed    BOOLEAN          NOT NULL,
+    updated_at TIMESTAMPTZ      NOT NULL DEFAULT now()
+);
+
+CREATE INDEX rate_limit_buckets_updated_at_idx ON rate_limit_buckets (updated_at);
+
+-- Сколько токенов в корзине с учетом пополнения с момента последнего запроса
+CREATE FUNCTION rate_limit_refill(tokens DOUBLE PRECISION, updated_at TIMESTAMPTZ,
+                                  per_second DOUBLE PRECISION, burst DOUBLE PRECISION)
+    RETURNS DOUBLE PRECISION
+    LANGUAGE sql STABLE AS $$
+    SELECT LEAST(burst, tokens + EXTRACT(EPOCH FROM GREATEST(now() - updated_at, interval '0')) * per_second)
+$$;
@@ -0,0 +1,29 @@
+CREATE TABLE audit_log (
+    id          BIGSERIAL PRIMARY KEY,
+    action      TEXT        NOT NULL,
+    actor_type  TEXT        NOT NULL,
+    actor_id    TEXT        NOT NULL DEFAULT '',
+    target_type TEXT        NOT NULL DEFAULT '',
+    target_id   TEXT        NOT NULL DEFAULT '',
+    ip          TEXT        NOT NULL DEFAULT '',
+    request_id  TEXT        NOT NULL DEFAULT '',
+    via         TEXT        NOT NULL DEFAULT '',
+    details     JSONB       NOT NULL DEFAULT '{}',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX audit_log_action_idx ON audit_log (action, id);
+CREATE INDEX audit_log_actor_idx ON audit_log (actor_type, actor_id, id);
+CREATE INDEX audit_log_target_idx ON audit_log (target_type, target_id, id);
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
+
+-- Журнал только дополняется: изменить или удалить запись нельзя даже из приложения
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log только для добавления записей';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
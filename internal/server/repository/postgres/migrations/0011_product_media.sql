@@ -0,0 +1,17 @@
+CREATE TABLE product_media (
+    id           BIGSERIAL PRIMARY KEY,
+    product_id   BIGINT      NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    kind         TEXT        NOT NULL CHECK (kind IN ('cover', 'screenshot', 'sample')),
+    position     INT         NOT NULL DEFAULT 0,
+    storage_key  TEXT        NOT NULL UNIQUE,
+    file_name    TEXT        NOT NULL,
+    content_type TEXT        NOT NULL,
+    size         BIGINT      NOT NULL CHECK (size > 0),
+    sha256       TEXT        NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX product_media_product_idx ON product_media (product_id, position, id);
+
+-- Обложка и пример у продукта в единственном экземпляре
+CREATE UNIQUE INDEX product_media_single_idx ON product_media (product_id, kind) WHERE kind IN ('cover', 'sample');
@@ -0,0 +1,27 @@
+CREATE TABLE product_artifacts (
+    id           BIGSERIAL PRIMARY KEY,
+    product_id   BIGINT      NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    version      INT         NOT NULL CHECK (version > 0),
+    storage_key  TEXT        NOT NULL UNIQUE,
+    file_name    TEXT        NOT NULL,
+    content_type TEXT        NOT NULL,
+    size         BIGINT      NOT NULL CHECK (size > 0),
+    sha256       TEXT        NOT NULL,
+    notes        TEXT        NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (product_id, version)
+);
+
+-- Каждая выдача материалов покупателю. Метка выдачи зашита в выданный файл,
+-- по ней находится покупатель, от которого утекла копия.
+CREATE TABLE artifact_deliveries (
+    id          BIGSERIAL PRIMARY KEY,
+    artifact_id BIGINT      NOT NULL REFERENCES product_artifacts (id),
+    user_id     BIGINT      NOT NULL REFERENCES users (id),
+    license_id  BIGINT      NOT NULL REFERENCES licenses (id),
+    watermark   TEXT        NOT NULL UNIQUE,
+    channel     TEXT        NOT NULL CHECK (channel IN ('telegram', 'link')),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX artifact_deliveries_user_idx ON artifact_deliveries (user_id, created_at);
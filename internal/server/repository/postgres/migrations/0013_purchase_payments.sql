@@ -0,0 +1,13 @@
+-- Платеж у провайдера на часть покупки сверх баланса. Покупка остается pending,
+-- пока провайдер не подтвердит оплату.
+ALTER TABLE purchases
+    ADD COLUMN provider_payment_id TEXT NOT NULL DEFAULT '',
+    ADD COLUMN payment_url         TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX purchases_provider_payment_idx ON purchases (provider_payment_id) WHERE provider_payment_id <> '';
+
+-- Одна неоплаченная покупка продукта на пользователя, повторное нажатие на "Купить" вернет ее
+CREATE UNIQUE INDEX purchases_pending_idx ON purchases (user_id, product_id) WHERE status = 'pending';
+
+INSERT INTO ledger_accounts (kind, code) VALUES
+    ('system', 'hold'); -- Баланс, зарезервированный под неоплаченные покупки
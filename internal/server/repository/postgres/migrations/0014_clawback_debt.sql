@@ -0,0 +1,4 @@
+-- Часть реферального бонуса, которую не удалось списать при возврате покупки:
+-- пригласивший успел ее потратить, а кошелек в минус не уходит
+ALTER TABLE referral_rewards
+    ADD COLUMN clawback_debt BIGINT NOT NULL DEFAULT 0 CHECK (clawback_debt >= 0);
@@ -0,0 +1,15 @@
+-- Старосты, которым администратор разрешил покупать групповые лицензии
+ALTER TABLE users
+    ADD COLUMN is_leader BOOLEAN NOT NULL DEFAULT false;
+
+-- Групповая лицензия оплачивается покупкой на seats мест и работает только после оплаты.
+-- Пулы, созданные до оплаты мест, остаются без покупки.
+ALTER TABLE purchases
+    ADD COLUMN seats INT NOT NULL DEFAULT 0 CHECK (seats >= 0);
+
+ALTER TABLE seat_pools
+    ADD COLUMN purchase_id BIGINT UNIQUE REFERENCES purchases (id);
+
+-- Личная покупка и покупка мест на тот же продукт не мешают друг другу
+DROP INDEX purchases_pending_idx;
+CREATE UNIQUE INDEX purchases_pending_idx ON purchases (user_id, product_id, seats) WHERE status = 'pending';
@@ -0,0 +1,7 @@
+-- Ключи, выданные до 0008, не начинаются с lg_live_. Их подсказка дополняется маской
+-- до длины подсказки новых ключей (len(lg_live_) + 4), но показывает не больше 4
+-- символов самого ключа. Подсказки длиннее 4 символов обрезаются: их могла оставить
+-- версия 0008, которая брала у старых ключей 12 символов.
+UPDATE users
+SET license_key_hint = rpad(left(license_key_hint, 4), length('lg_live_') + 4, '*')
+WHERE license_key_hint NOT LIKE 'lg\_live\_%';
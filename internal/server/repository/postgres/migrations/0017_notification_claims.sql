@@ -0,0 +1,5 @@
+-- Реплики бота забирают уведомления из очереди с отметкой времени, чтобы одно
+-- уведомление не отправили две реплики. Неподтвержденное уведомление снова
+-- выдается, когда захват устарел.
+ALTER TABLE notifications
+    ADD COLUMN claimed_at TIMESTAMPTZ;
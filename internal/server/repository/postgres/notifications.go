@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateNotification ставит сообщение пользователю в очередь на отправку ботом
+func (s *Storage) CreateNotification(ctx context.Context, telegramID int64, text string) error {
+	const op = "postgres.CreateNotification"
+
+	if _, err := s.pool.Exec(ctx, insertNotificationQuery, telegramID, text); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ClaimNotifications забирает еще не отправленные ботом сообщения в порядке создания.
+// Забранные сообщения не выдаются другим репликам бота, пока не пройдет lease: если
+// реплика не подтвердила отправку за это время, сообщение выдается снова.
+func (s *Storage) ClaimNotifications(ctx context.Context, limit int, lease time.Duration) ([]*models.Notification, error) {
+	const op = "postgres.ClaimNotifications"
+
+	// SKIP LOCKED не дает параллельным запросам ждать друг друга и забрать одни строки
+	rows, err := s.pool.Query(ctx, `
+		UPDATE notifications SET claimed_at = now()
+		WHERE id IN (
+			SELECT id FROM notifications
+			WHERE sent_at IS NULL AND (claimed_at IS NULL OR claimed_at < now() - make_interval(secs => $2))
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, telegram_id, text, created_at`,
+		limit, lease.Seconds(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var notifications []*models.Notification
+	for rows.Next() {
+		var n models.Notification
+		if err := rows.Scan(&n.ID, &n.TelegramID, &n.Text, &n.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		notifications = append(notifications, &n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// RETURNING не сохраняет порядок подзапроса
+	slices.SortFunc(notifications, func(a, b *models.Notification) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return notifications, nil
+}
+
+// MarkNotificationsSent отмечает сообщения как доставленные ботом
+func (s *Storage) MarkNotificationsSent(ctx context.Context, ids []int64) error {
+	const op = "postgres.MarkNotificationsSent"
+
+	_, err := s.pool.Exec(ctx, `
+		UPDATE notifications SET sent_at = now()
+		WHERE id = ANY($1) AND sent_at IS NULL`,
+		ids,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+const insertNotificationQuery = `INSERT INTO notifications (telegram_id, text) VALUES ($1, $2)`
+
+func createNotification(ctx context.Context, tx pgx.Tx, telegramID int64, text string) error {
+	_, err := tx.Exec(ctx, insertNotificationQuery, telegramID, text)
+	return err
+}
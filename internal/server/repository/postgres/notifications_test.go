@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClaimNotifications(t *testing.T) {
+	s := testStorage(t)
+	ctx := context.Background()
+
+	const total = 40
+	for i := range total {
+		if err := s.CreateNotification(ctx, int64(1000+i), "напоминание"); err != nil {
+			t.Fatalf("CreateNotification: %v", err)
+		}
+	}
+
+	// Реплики забирают очередь одновременно, каждое уведомление достается одной
+	const replicas = 4
+	var (
+		mu      sync.Mutex
+		claimed = make(map[int64]int)
+		wg      sync.WaitGroup
+	)
+	for range replicas {
+		wg.Go(func() {
+			notifications, err := s.ClaimNotifications(ctx, total, time.Minute)
+			if err != nil {
+				t.Errorf("ClaimNotifications: %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, n := range notifications {
+				claimed[n.ID]++
+			}
+		})
+	}
+	wg.Wait()
+
+	if len(claimed) != total {
+		t.Fatalf("забрано %d уведомлений, ожидалось %d", len(claimed), total)
+	}
+	for id, count := range claimed {
+		if count != 1 {
+			t.Fatalf("уведомление %d забрано %d раз", id, count)
+		}
+	}
+
+	if again, err := s.ClaimNotifications(ctx, total, time.Minute); err != nil || len(again) != 0 {
+		t.Fatalf("повторно забрано %d уведомлений, ошибка %v", len(again), err)
+	}
+
+	// Реплика подтвердила часть, остальные после истечения захвата выдаются снова
+	var sent []int64
+	for id := range claimed {
+		if len(sent) < total/2 {
+			sent = append(sent, id)
+		}
+	}
+	if err := s.MarkNotificationsSent(ctx, sent); err != nil {
+		t.Fatalf("MarkNotificationsSent: %v", err)
+	}
+	if _, err := s.pool.Exec(ctx, `UPDATE notifications SET claimed_at = now() - interval '2 minutes'`); err != nil {
+		t.Fatalf("старение захвата: %v", err)
+	}
+
+	retried, err := s.ClaimNotifications(ctx, total, time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimNotifications: %v", err)
+	}
+	if len(retried) != total-len(sent) {
+		t.Fatalf("снова выдано %d уведомлений, ожидалось %d", len(retried), total-len(sent))
+	}
+	for i := 1; i < len(retried); i++ {
+		if retried[i-1].ID >= retried[i].ID {
+			t.Fatalf("уведомления не по порядку создания: %d после %d", retried[i].ID, retried[i-1].ID)
+		}
+	}
+}
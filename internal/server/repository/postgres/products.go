@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+const productColumns = `id, name, description, price, link, billing_type, period_days, created_at`
+
+func (s *Storage) ProductByID(ctx context.Context, productID int64) (*models.Product, error) {
+	const op = "postgres.ProductByID"
+
+	product, err := scanProduct(s.pool.QueryRow(ctx, `SELECT `+productColumns+` FROM products WHERE id = $1`, productID))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return product, nil
+}
+
+func scanProduct(row pgx.Row) (*models.Product, error) {
+	var p models.Product
+	err := row.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Link, &p.BillingType, &p.PeriodDays, &p.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+const promoColumns = `
+	id, code, discount_type, discount_value, product_id, group_name,
+	max_uses, max_uses_per_user, valid_from, valid_until, created_at`
+
+func (s *Storage) CreatePromoCode(ctx context.Context, promo *models.PromoCode) error {
+	const op = "postgres.CreatePromoCode"
+
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO promo_codes (
+			code, discount_type, discount_value, product_id, group_name,
+			max_uses, max_uses_per_user, valid_from, valid_until
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at`,
+		promo.Code, promo.DiscountType, promo.DiscountValue, promo.ProductID, promo.Group,
+		promo.MaxUses, promo.MaxUsesPerUser, promo.ValidFrom, promo.ValidUntil,
+	).Scan(&promo.ID, &promo.CreatedAt)
+	switch {
+	case isUniqueViolation(err):
+		return fmt.Errorf("%s: %w", op, repository.ErrConflict)
+	case isForeignKeyViolation(err):
+		return fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	case err != nil:
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) PromoCodeByCode(ctx context.Context, code string) (*models.PromoCode, error) {
+	const op = "postgres.PromoCodeByCode"
+
+	promo, err := scanPromoCode(s.pool.QueryRow(ctx, `SELECT `+promoColumns+` FROM promo_codes WHERE code = $1`, code))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return promo, nil
+}
+
+// PromoUsage считает погашения промокода всего и пользователем userID
+func (s *Storage) PromoUsage(ctx context.Context, promoID, userID int64) (*models.PromoUsage, error) {
+	const op = "postgres.PromoUsage"
+
+	usage, err := promoUsage(ctx, s.pool, promoID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return usage, nil
+}
+
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func promoUsage(ctx context.Context, q querier, promoID, userID int64) (*models.PromoUsage, error) {
+	var usage models.PromoUsage
+	err := q.QueryRow(ctx, `
+		SELECT count(*), count(*) FILTER (WHERE user_id = $2)
+		FROM promo_redemptions
+		WHERE promo_code_id = $1`,
+		promoID, userID,
+	).Scan(&usage.Total, &usage.ByUser)
+	if err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
+
+func scanPromoCode(row pgx.Row) (*models.PromoCode, error) {
+	var p models.PromoCode
+	err := row.Scan(
+		&p.ID, &p.Code, &p.DiscountType, &p.DiscountValue, &p.ProductID, &p.Group,
+		&p.MaxUses, &p.MaxUsesPerUser, &p.ValidFrom, &p.ValidUntil, &p.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
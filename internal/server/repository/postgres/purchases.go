@@ -0,0 +1,425 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+const purchaseColumns = `
+	id, user_id, product_id, license_id, original_price, discount, amount, balance_used,
+	promo_code_id, status, provider_payment_id, payment_url, seats,
+	(SELECT sp.id FROM seat_pools sp WHERE sp.purchase_id = purchases.id),
+	created_at, paid_at`
+
+// CreatePurchase записывает покупку и гасит промокод. Если useBalance, часть суммы (вплоть до всей)
+// берется с кошелька пользователя. Покупка, которую баланс покрывает целиком, сразу оплачена
+// и выдает лицензию. Иначе она остается pending до подтверждения оплаты провайдером, а часть
+// с кошелька резервируется, чтобы ее не потратили дважды. Лимиты промокода и баланс проверяются
+// под блокировкой, чтобы параллельные покупки их не превысили. Если у пользователя уже есть
+// неоплаченная покупка этого продукта, возвращает repository.ErrConflict.
+func (s *Storage) CreatePurchase(ctx context.Context, purchase *models.Purchase, useBalance bool) error {
+	const op = "postgres.CreatePurchase"
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = createPurchase(ctx, tx, purchase, useBalance, nil)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%s: %w", op, repository.ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CreateSeatPurchase записывает покупку seats мест вместе с групповой лицензией pool.
+// Лицензия открывается для студентов только после оплаты покупки, в остальном
+// все как в CreatePurchase без списания с баланса.
+func (s *Storage) CreateSeatPurchase(ctx context.Context, purchase *models.Purchase, pool *models.SeatPool) error {
+	const op = "postgres.CreateSeatPurchase"
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = createPurchase(ctx, tx, purchase, false, pool)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%s: %w", op, repository.ErrConflict)
+	}
+	if isForeignKeyViolation(err) {
+		return fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// createPurchase pool задан для покупки мест: вместо личной лицензии создается групповая
+func createPurchase(ctx context.Context, tx pgx.Tx, purchase *models.Purchase, useBalance bool, pool *models.SeatPool) error {
+	if purchase.PromoCodeID != nil {
+		if err := checkPromoLimits(ctx, tx, *purchase.PromoCodeID, purchase.UserID); err != nil {
+			return err
+		}
+	}
+
+	walletID, balance, err := lockWallet(ctx, tx, purchase.UserID)
+	if err != nil {
+		return err
+	}
+
+	purchase.BalanceUsed = 0
+	if useBalance && balance > 0 {
+		purchase.BalanceUsed = min(balance, purchase.Amount)
+	}
+
+	status := models.PurchasePending
+	if purchase.BalanceUsed == purchase.Amount {
+		status = models.PurchasePaid
+	}
+
+	if status == models.PurchasePaid && pool == nil {
+		license, err := issueLicense(ctx, tx, purchase.UserID, purchase.ProductID)
+		if err != nil {
+			return err
+		}
+		purchase.LicenseID = &license.ID
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO purchases (
+			user_id, product_id, license_id, original_price, discount, amount,
+			balance_used, promo_code_id, seats, status, paid_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10::text, CASE WHEN $10::text = 'paid' THEN now() END)
+		RETURNING id, status, created_at, paid_at`,
+		purchase.UserID, purchase.ProductID, purchase.LicenseID, purchase.OriginalPrice,
+		purchase.Discount, purchase.Amount, purchase.BalanceUsed, purchase.PromoCodeID, purchase.Seats, status,
+	).Scan(&purchase.ID, &purchase.Status, &purchase.CreatedAt, &purchase.PaidAt)
+	if err != nil {
+		return err
+	}
+
+	if pool != nil {
+		if err := insertSeatPool(ctx, tx, pool, purchase.ID); err != nil {
+			return err
+		}
+		purchase.SeatPoolID = &pool.ID
+	}
+
+	if status == models.PurchasePaid {
+		if err := postPurchasePayment(ctx, tx, purchase, walletID); err != nil {
+			return err
+		}
+	} else if err := holdBalance(ctx, tx, purchase, walletID); err != nil {
+		return err
+	}
+
+	if purchase.PromoCodeID != nil {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO promo_redemptions (promo_code_id, user_id, purchase_id)
+			VALUES ($1, $2, $3)`,
+			*purchase.PromoCodeID, purchase.UserID, purchase.ID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PurchaseByID возвращает покупку по id
+func (s *Storage) PurchaseByID(ctx context.Context, purchaseID int64) (*models.Purchase, error) {
+	const op = "postgres.PurchaseByID"
+
+	purchase, err := scanPurchase(s.pool.QueryRow(ctx, `SELECT `+purchaseColumns+` FROM purchases WHERE id = $1`, purchaseID))
+	if isNoRows(err) {
+		return nil, fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return purchase, nil
+}
+
+// PendingPurchase возвращает неоплаченную покупку продукта пользователем: личную
+// при seats = 0 или покупку seats мест
+func (s *Storage) PendingPurchase(ctx context.Context, userID, productID int64, seats int) (*models.Purchase, error) {
+	const op = "postgres.PendingPurchase"
+
+	purchase, err := scanPurchase(s.pool.QueryRow(ctx, `
+		SELECT `+purchaseColumns+` FROM purchases
+		WHERE user_id = $1 AND product_id = $2 AND seats = $3 AND status = 'pending'`,
+		userID, productID, seats,
+	))
+	if isNoRows(err) {
+		return nil, fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return purchase, nil
+}
+
+// SetPurchasePayment сохраняет платеж, созданный у провайдера для неоплаченной покупки
+func (s *Storage) SetPurchasePayment(ctx context.Context, purchaseID int64, paymentID, paymentURL string) error {
+	const op = "postgres.SetPurchasePayment"
+
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE purchases SET provider_payment_id = $2, payment_url = $3
+		WHERE id = $1 AND status = 'pending'`,
+		purchaseID, paymentID, paymentURL,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repository.ErrConflict)
+	}
+
+	return nil
+}
+
+// ConfirmPurchase помечает неоплаченную покупку оплаченной после подтверждения провайдером,
+// выдает лицензию (у покупки мест открывается групповая лицензия) и проводит оплату по счетам. Если покупка уже не pending,
+// возвращает repository.ErrConflict.
+func (s *Storage) ConfirmPurchase(ctx context.Context, purchase *models.Purchase) error {
+	const op = "postgres.ConfirmPurchase"
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		UPDATE purchases SET status = 'paid', paid_at = now()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING status, paid_at`,
+		purchase.ID,
+	).Scan(&purchase.Status, &purchase.PaidAt)
+	if isNoRows(err) {
+		return fmt.Errorf("%s: %w", op, repository.ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if purchase.Seats == 0 {
+		license, err := issueLicense(ctx, tx, purchase.UserID, purchase.ProductID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		purchase.LicenseID = &license.ID
+
+		if _, err := tx.Exec(ctx, `UPDATE purchases SET license_id = $2 WHERE id = $1`, purchase.ID, license.ID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	holdID, err := systemAccount(ctx, tx, models.AccountHold)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := postPurchasePayment(ctx, tx, purchase, holdID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CancelPurchase отменяет неоплаченную покупку, когда провайдер отменил платеж:
+// резерв возвращается в кошелек, а промокод снова можно применить.
+// Если покупка уже не pending, возвращает repository.ErrConflict.
+func (s *Storage) CancelPurchase(ctx context.Context, purchase *models.Purchase) error {
+	const op = "postgres.CancelPurchase"
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		UPDATE purchases SET status = 'canceled'
+		WHERE id = $1 AND status = 'pending'
+		RETURNING status`,
+		purchase.ID,
+	).Scan(&purchase.Status)
+	if isNoRows(err) {
+		return fmt.Errorf("%s: %w", op, repository.ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if purchase.BalanceUsed > 0 {
+		walletID, _, err := lockWallet(ctx, tx, purchase.UserID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		holdID, err := systemAccount(ctx, tx, models.AccountHold)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		_, err = postTransaction(ctx, tx, models.LedgerHold, "Резерв снят: оплата отменена", &purchase.ID,
+			ledgerPosting{accountID: holdID, amount: -purchase.BalanceUsed},
+			ledgerPosting{accountID: walletID, amount: purchase.BalanceUsed},
+		)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM promo_redemptions WHERE purchase_id = $1`, purchase.ID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// holdBalance резервирует часть суммы с кошелька до подтверждения оплаты остатка
+func holdBalance(ctx context.Context, tx pgx.Tx, purchase *models.Purchase, walletID int64) error {
+	if purchase.BalanceUsed == 0 {
+		return nil
+	}
+
+	holdID, err := systemAccount(ctx, tx, models.AccountHold)
+	if err != nil {
+		return err
+	}
+
+	_, err = postTransaction(ctx, tx, models.LedgerHold, "Резерв под покупку", &purchase.ID,
+		ledgerPosting{accountID: walletID, amount: -purchase.BalanceUsed},
+		ledgerPosting{accountID: holdID, amount: purchase.BalanceUsed},
+	)
+
+	return err
+}
+
+func checkPromoLimits(ctx context.Context, tx pgx.Tx, promoID, userID int64) error {
+	var maxUses *int
+	var maxUsesPerUser int
+	err := tx.QueryRow(ctx, `
+		SELECT max_uses, max_uses_per_user FROM promo_codes
+		WHERE id = $1 FOR UPDATE`,
+		promoID,
+	).Scan(&maxUses, &maxUsesPerUser)
+	if err != nil {
+		return err
+	}
+
+	usage, err := promoUsage(ctx, tx, promoID, userID)
+	if err != nil {
+		return err
+	}
+
+	if (maxUses != nil && usage.Total >= *maxUses) || usage.ByUser >= maxUsesPerUser {
+		return repository.ErrLimitExceeded
+	}
+
+	return nil
+}
+
+// postPurchasePayment проводит оплату: выручка получает полную сумму, платежная система —
+// оплаченную у провайдера часть, а часть с баланса списывается со счета balanceID:
+// с кошелька при оплате только с баланса или с резерва после подтверждения платежа
+func postPurchasePayment(ctx context.Context, tx pgx.Tx, purchase *models.Purchase, balanceID int64) error {
+	if purchase.Amount == 0 {
+		return nil
+	}
+
+	revenueID, err := systemAccount(ctx, tx, models.AccountRevenue)
+	if err != nil {
+		return err
+	}
+
+	externalID, err := systemAccount(ctx, tx, models.AccountExternal)
+	if err != nil {
+		return err
+	}
+
+	_, err = postTransaction(ctx, tx, models.LedgerPurchase, "Оплата покупки", &purchase.ID,
+		ledgerPosting{accountID: revenueID, amount: purchase.Amount},
+		ledgerPosting{accountID: balanceID, amount: -purchase.BalanceUsed},
+		ledgerPosting{accountID: externalID, amount: -(purchase.Amount - purchase.BalanceUsed)},
+	)
+
+	return err
+}
+
+// PurchaseCountsByStatus число покупок в каждом статусе
+func (s *Storage) PurchaseCountsByStatus(ctx context.Context) (map[string]int64, error) {
+	const op = "postgres.PurchaseCountsByStatus"
+
+	rows, err := s.pool.Query(ctx, `SELECT status, count(*) FROM purchases GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var status string
+		var count int64
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		counts[status] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return counts, nil
+}
+
+func scanPurchase(row pgx.Row) (*models.Purchase, error) {
+	var p models.Purchase
+	err := row.Scan(
+		&p.ID, &p.UserID, &p.ProductID, &p.LicenseID, &p.OriginalPrice, &p.Discount, &p.Amount,
+		&p.BalanceUsed, &p.PromoCodeID, &p.Status, &p.ProviderPaymentID, &p.PaymentURL,
+		&p.Seats, &p.SeatPoolID, &p.CreatedAt, &p.PaidAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
@@ -0,0 +1,223 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+)
+
+// creditWallet зачисляет amount на кошелек пользователя со счета бонусов
+func creditWallet(t *testing.T, s *Storage, userID, amount int64) {
+	t.Helper()
+	ctx := context.Background()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("начало транзакции: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	walletID, _, err := lockWallet(ctx, tx, userID)
+	if err != nil {
+		t.Fatalf("кошелек: %v", err)
+	}
+	bonusID, err := systemAccount(ctx, tx, models.AccountBonus)
+	if err != nil {
+		t.Fatalf("счет бонусов: %v", err)
+	}
+
+	_, err = postTransaction(ctx, tx, models.LedgerReferralBonus, "Тестовое пополнение", nil,
+		ledgerPosting{accountID: bonusID, amount: -amount},
+		ledgerPosting{accountID: walletID, amount: amount},
+	)
+	if err != nil {
+		t.Fatalf("пополнение: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("коммит: %v", err)
+	}
+}
+
+func accountBalance(t *testing.T, s *Storage, code string) int64 {
+	t.Helper()
+
+	var balance int64
+	err := s.pool.QueryRow(context.Background(), `
+		SELECT COALESCE(SUM(e.amount), 0) FROM ledger_entries e
+		JOIN ledger_accounts a ON a.id = e.account_id
+		WHERE a.code = $1`,
+		code,
+	).Scan(&balance)
+	if err != nil {
+		t.Fatalf("баланс счета %s: %v", code, err)
+	}
+
+	return balance
+}
+
+func TestPurchasePaymentFlow(t *testing.T) {
+	const price, balance = 10000, 3000
+
+	tests := []struct {
+		name    string
+		settle  func(s *Storage, purchase *models.Purchase) error
+		status  string
+		wallet  int64
+		revenue int64
+	}{
+		{
+			name:    "оплата подтверждена",
+			settle:  func(s *Storage, p *models.Purchase) error { return s.ConfirmPurchase(context.Background(), p) },
+			status:  models.PurchasePaid,
+			wallet:  0,
+			revenue: price,
+		},
+		{
+			name:    "платеж отменен",
+			settle:  func(s *Storage, p *models.Purchase) error { return s.CancelPurchase(context.Background(), p) },
+			status:  models.PurchaseCanceled,
+			wallet:  balance,
+			revenue: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testStorage(t)
+			ctx := context.Background()
+
+			user := createTestUser(t, s, 2001)
+			productID := createTestProduct(t, s, price)
+			creditWallet(t, s, user.ID, balance)
+
+			purchase := &models.Purchase{UserID: user.ID, ProductID: productID, OriginalPrice: price, Amount: price}
+			if err := s.CreatePurchase(ctx, purchase, true); err != nil {
+				t.Fatalf("CreatePurchase: %v", err)
+			}
+			if purchase.Status != models.PurchasePending || purchase.BalanceUsed != balance || purchase.LicenseID != nil {
+				t.Fatalf("покупка %+v, ожидалась pending без лицензии с резервом %d", purchase, balance)
+			}
+
+			// Пока платеж не подтвержден, резерв нельзя потратить, а выручки нет
+			if got, _ := s.WalletBalance(ctx, user.ID); got != 0 {
+				t.Fatalf("баланс с резервом %d, ожидался 0", got)
+			}
+			if got := accountBalance(t, s, models.AccountHold); got != balance {
+				t.Fatalf("резерв %d, ожидался %d", got, balance)
+			}
+
+			// Вторая неоплаченная покупка того же продукта не создается
+			again := &models.Purchase{UserID: user.ID, ProductID: productID, OriginalPrice: price, Amount: price}
+			if err := s.CreatePurchase(ctx, again, false); !errors.Is(err, repository.ErrConflict) {
+				t.Fatalf("повторная покупка: ожидалась ErrConflict, получено %v", err)
+			}
+
+			if err := tt.settle(s, purchase); err != nil {
+				t.Fatalf("завершение покупки: %v", err)
+			}
+			if err := tt.settle(s, purchase); !errors.Is(err, repository.ErrConflict) {
+				t.Fatalf("повторное завершение: ожидалась ErrConflict, получено %v", err)
+			}
+
+			stored, err := s.PurchaseByID(ctx, purchase.ID)
+			if err != nil {
+				t.Fatalf("PurchaseByID: %v", err)
+			}
+			if stored.Status != tt.status {
+				t.Fatalf("статус %s, ожидался %s", stored.Status, tt.status)
+			}
+			if (tt.status == models.PurchasePaid) != (stored.LicenseID != nil) {
+				t.Fatalf("лицензия %v при статусе %s", stored.LicenseID, stored.Status)
+			}
+
+			if got, _ := s.WalletBalance(ctx, user.ID); got != tt.wallet {
+				t.Fatalf("баланс %d, ожидался %d", got, tt.wallet)
+			}
+			if got := accountBalance(t, s, models.AccountHold); got != 0 {
+				t.Fatalf("в резерве осталось %d", got)
+			}
+			if got := accountBalance(t, s, models.AccountRevenue); got != tt.revenue {
+				t.Fatalf("выручка %d, ожидалась %d", got, tt.revenue)
+			}
+		})
+	}
+}
+
+func TestPurchaseFromBalance(t *testing.T) {
+	s := testStorage(t)
+	ctx := context.Background()
+
+	user := createTestUser(t, s, 2002)
+	productID := createTestProduct(t, s, 5000)
+	creditWallet(t, s, user.ID, 8000)
+
+	purchase := &models.Purchase{UserID: user.ID, ProductID: productID, OriginalPrice: 5000, Amount: 5000}
+	if err := s.CreatePurchase(ctx, purchase, true); err != nil {
+		t.Fatalf("CreatePurchase: %v", err)
+	}
+
+	if purchase.Status != models.PurchasePaid || purchase.LicenseID == nil || purchase.PaidAt == nil {
+		t.Fatalf("покупка с баланса %+v, ожидалась сразу оплаченной с лицензией", purchase)
+	}
+	if got, _ := s.WalletBalance(ctx, user.ID); got != 3000 {
+		t.Fatalf("баланс %d, ожидался 3000", got)
+	}
+	if got := accountBalance(t, s, models.AccountExternal); got != 0 {
+		t.Fatalf("через провайдера проведено %d, ожидался 0", got)
+	}
+}
+
+func TestSeatPurchase(t *testing.T) {
+	s := testStorage(t)
+	ctx := context.Background()
+
+	leader := createTestUser(t, s, 2003)
+	student := createTestUser(t, s, 2004)
+	productID := createTestProduct(t, s, 1000)
+
+	purchase := &models.Purchase{UserID: leader.ID, ProductID: productID, OriginalPrice: 3000, Amount: 3000, Seats: 3}
+	pool := &models.SeatPool{ProductID: productID, OwnerUserID: leader.ID, Seats: 3, InviteCode: "seat-test"}
+	if err := s.CreateSeatPurchase(ctx, purchase, pool); err != nil {
+		t.Fatalf("CreateSeatPurchase: %v", err)
+	}
+	if purchase.Status != models.PurchasePending || purchase.SeatPoolID == nil || *purchase.SeatPoolID != pool.ID {
+		t.Fatalf("покупка мест %+v, ожидалась pending с пулом %d", purchase, pool.ID)
+	}
+
+	// Пока места не оплачены, пула нет ни для студентов, ни для старосты
+	if _, err := s.SeatPoolByCode(ctx, pool.InviteCode); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("неоплаченный пул по коду: ожидалась ErrNotFound, получено %v", err)
+	}
+	if _, err := s.AssignSeat(ctx, pool.ID, student.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("место в неоплаченном пуле: ожидалась ErrNotFound, получено %v", err)
+	}
+	if owned, err := s.SeatPoolsByOwner(ctx, leader.ID); err != nil || len(owned) != 0 {
+		t.Fatalf("пулы старосты до оплаты %v, ошибка %v", owned, err)
+	}
+
+	if err := s.ConfirmPurchase(ctx, purchase); err != nil {
+		t.Fatalf("ConfirmPurchase: %v", err)
+	}
+	if purchase.LicenseID != nil {
+		t.Fatalf("покупка мест выдала старосте личную лицензию %d", *purchase.LicenseID)
+	}
+
+	stored, err := s.PurchaseByID(ctx, purchase.ID)
+	if err != nil {
+		t.Fatalf("PurchaseByID: %v", err)
+	}
+	if stored.Seats != 3 || stored.SeatPoolID == nil || *stored.SeatPoolID != pool.ID {
+		t.Fatalf("сохраненная покупка %+v, ожидалось 3 места в пуле %d", stored, pool.ID)
+	}
+
+	if _, err := s.AssignSeat(ctx, pool.ID, student.ID); err != nil {
+		t.Fatalf("место в оплаченном пуле: %v", err)
+	}
+	if got := accountBalance(t, s, models.AccountRevenue); got != 3000 {
+		t.Fatalf("выручка %d, ожидалась 3000", got)
+	}
+}
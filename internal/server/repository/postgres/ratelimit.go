@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TakeRateLimitToken атомарно пополняет корзину key и забирает из нее токен, если он есть.
+// Возвращает решение и число токенов, оставшихся в корзине.
+func (s *Storage) TakeRateLimitToken(ctx context.Context, key string, perSecond float64, burst int) (bool, float64, error) {
+	const op = "postgres.TakeRateLimitToken"
+
+	var (
+		allowed bool
+		tokens  float64
+	)
+	// Выражения в SET читают заблокированную версию строки, поэтому параллельные
+	// запросы к одной корзине не могут забрать один и тот же токен
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+		VALUES ($1, $3::float8 - 1, $3::float8 >= 1, now())
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = CASE
+				WHEN rate_limit_refill(b.tokens, b.updated_at, $2, $3) >= 1
+				THEN rate_limit_refill(b.tokens, b.updated_at, $2, $3) - 1
+				ELSE rate_limit_refill(b.tokens, b.updated_at, $2, $3)
+			END,
+			allowed = rate_limit_refill(b.tokens, b.updated_at, $2, $3) >= 1,
+			updated_at = GREATEST(now(), b.updated_at)
+		RETURNING allowed, tokens`,
+		key, perSecond, burst,
+	).Scan(&allowed, &tokens)
+	if err != nil {
+		return false, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return allowed, tokens, nil
+}
+
+// RefundRateLimitToken возвращает в корзину key один токен, не больше burst
+func (s *Storage) RefundRateLimitToken(ctx context.Context, key string, burst int) error {
+	const op = "postgres.RefundRateLimitToken"
+
+	_, err := s.pool.Exec(ctx, `UPDATE rate_limit_buckets SET tokens = LEAST(tokens + 1, $2::float8) WHERE key = $1`, key, burst)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteIdleRateLimits(ctx context.Context, idle time.Duration) error {
+	const op = "postgres.DeleteIdleRateLimits"
+
+	_, err := s.pool.Exec(ctx, `DELETE FROM rate_limit_buckets WHERE updated_at < now() - $1::interval`, idle)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+)
+
+// IsFirstPaidPurchase проверяет, что кроме purchaseID у пользователя нет покупок, оплаченных
+// через платежного провайдера. Покупки подтверждаются не в порядке создания, поэтому
+// сравнивать по id нельзя.
+func (s *Storage) IsFirstPaidPurchase(ctx context.Context, userID, purchaseID int64) (bool, error) {
+	const op = "postgres.IsFirstPaidPurchase"
+
+	var earlier bool
+	err := s.pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM purchases
+			WHERE user_id = $1 AND id <> $2 AND paid_at IS NOT NULL
+				AND provider_payment_id <> '' AND amount > balance_used
+		)`,
+		userID, purchaseID,
+	).Scan(&earlier)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return !earlier, nil
+}
+
+// CreateReferralReward записывает бонус пригласившему и зачисляет его на кошелек.
+// За одного приглашенного бонус начисляется только один раз, повторная попытка
+// вернет repository.ErrConflict.
+func (s *Storage) CreateReferralReward(ctx context.Context, reward *models.ReferralReward) error {
+	const op = "postgres.CreateReferralReward"
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO referral_rewards (referrer_id, referee_id, purchase_id, amount)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING
+		RETURNING id, created_at`,
+		reward.ReferrerID, reward.RefereeID, reward.PurchaseID, reward.Amount,
+	).Scan(&reward.ID, &reward.CreatedAt)
+	if isNoRows(err) {
+		return fmt.Errorf("%s: %w", op, repository.ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if reward.Amount > 0 {
+		walletID, _, err := lockWallet(ctx, tx, reward.ReferrerID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		bonusID, err := systemAccount(ctx, tx, models.AccountBonus)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		txnID, err := postTransaction(ctx, tx, models.LedgerReferralBonus, "Бонус за приглашенного", nil,
+			ledgerPosting{accountID: bonusID, amount: -reward.Amount},
+			ledgerPosting{accountID: walletID, amount: reward.Amount},
+		)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if _, err := tx.Exec(ctx, `UPDATE referral_rewards SET transaction_id = $2 WHERE id = $1`, reward.ID, txnID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ReferralStats(ctx context.Context, userID int64) (*models.ReferralStats, error) {
+	const op = "postgres.ReferralStats"
+
+	var stats models.ReferralStats
+	err := s.pool.QueryRow(ctx, `
+		SELECT
+			u.referral_code,
+			(SELECT count(*) FROM users r WHERE r.referred_by = u.id),
+			(SELECT count(*) FROM referral_rewards rr WHERE rr.referrer_id = u.id),
+			(SELECT COALESCE(SUM(rr.amount), 0) FROM referral_rewards rr WHERE rr.referrer_id = u.id)
+		FROM users u
+		WHERE u.id = $1`,
+		userID,
+	).Scan(&stats.ReferralCode, &stats.Invited, &stats.Rewarded, &stats.TotalBonus)
+	if isNoRows(err) {
+		return nil, fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &stats, nil
+}
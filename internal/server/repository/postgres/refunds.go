@@ -0,0 +1,367 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+const refundDetailsColumns = `
+	r.id, r.purchase_id, r.initiator, r.reason, r.status, r.amount, r.provider_refund_id,
+	r.note, r.created_at, r.resolved_at,
+	p.id, p.user_id, p.product_id, p.license_id, p.original_price, p.discount, p.amount,
+	p.balance_used, p.promo_code_id, p.status, p.provider_payment_id, p.payment_url, p.seats,
+	(SELECT sp.id FROM seat_pools sp WHERE sp.purchase_id = p.id), p.created_at, p.paid_at,
+	u.telegram_id, pr.name`
+
+const refundDetailsFrom = `
+	FROM refunds r
+	JOIN purchases p ON p.id = r.purchase_id
+	JOIN users u ON u.id = p.user_id
+	JOIN products pr ON pr.id = p.product_id`
+
+// LatestPaidPurchase возвращает последнюю оплаченную и не возвращенную покупку продукта пользователем.
+// Покупки мест для группы не учитываются: их возвращает только администратор.
+func (s *Storage) LatestPaidPurchase(ctx context.Context, userID, productID int64) (*models.Purchase, error) {
+	const op = "postgres.LatestPaidPurchase"
+
+	purchase, err := scanPurchase(s.pool.QueryRow(ctx, `
+		SELECT `+purchaseColumns+` FROM purchases
+		WHERE user_id = $1 AND product_id = $2 AND status = 'paid' AND seats = 0
+		ORDER BY id DESC
+		LIMIT 1`,
+		userID, productID,
+	))
+	if isNoRows(err) {
+		return nil, fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return purchase, nil
+}
+
+// CreateRefund создает заявку на возврат оплаченной покупки. На одну покупку — одна заявка.
+func (s *Storage) CreateRefund(ctx context.Context, refund *models.Refund) error {
+	const op = "postgres.CreateRefund"
+
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO refunds (purchase_id, initiator, reason, amount)
+		SELECT id, $2, $3, amount FROM purchases
+		WHERE id = $1 AND status = 'paid'
+		RETURNING id, status, amount, created_at`,
+		refund.PurchaseID, refund.Initiator, refund.Reason,
+	).Scan(&refund.ID, &refund.Status, &refund.Amount, &refund.CreatedAt)
+	switch {
+	case isUniqueViolation(err):
+		return fmt.Errorf("%s: %w", op, repository.ErrConflict)
+	case isNoRows(err):
+		return fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	case err != nil:
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RefundByID(ctx context.Context, refundID int64) (*models.RefundDetails, error) {
+	const op = "postgres.RefundByID"
+
+	refund, err := scanRefundDetails(s.pool.QueryRow(ctx, `SELECT `+refundDetailsColumns+refundDetailsFrom+` WHERE r.id = $1`, refundID))
+	if isNoRows(err) {
+		return nil, fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return refund, nil
+}
+
+// Refunds возвращает заявки на возврат в статусе status, пустой статус — все заявки
+func (s *Storage) Refunds(ctx context.Context, status string, limit int) ([]*models.RefundDetails, error) {
+	const op = "postgres.Refunds"
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+refundDetailsColumns+refundDetailsFrom+`
+		WHERE $1 = '' OR r.status = $1
+		ORDER BY r.id DESC
+		LIMIT $2`,
+		status, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var refunds []*models.RefundDetails
+	for rows.Next() {
+		refund, err := scanRefundDetails(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		refunds = append(refunds, refund)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return refunds, nil
+}
+
+// StartRefundProcessing переводит заявку в processing, если она ожидает решения или
+// прошлая попытка завершилась ошибкой. Иначе возвращает repository.ErrConflict.
+func (s *Storage) StartRefundProcessing(ctx context.Context, refundID int64) error {
+	const op = "postgres.StartRefundProcessing"
+
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE refunds SET status = 'processing', note = ''
+		WHERE id = $1 AND status IN ('requested', 'failed')`,
+		refundID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repository.ErrConflict)
+	}
+
+	return nil
+}
+
+func (s *Storage) RejectRefund(ctx context.Context, refundID int64, note string) error {
+	const op = "postgres.RejectRefund"
+
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE refunds SET status = 'rejected', note = $2, resolved_at = now()
+		WHERE id = $1 AND status IN ('requested', 'failed')`,
+		refundID, note,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repository.ErrConflict)
+	}
+
+	return nil
+}
+
+func (s *Storage) FailRefund(ctx context.Context, refundID int64, note string) error {
+	const op = "postgres.FailRefund"
+
+	_, err := s.pool.Exec(ctx, `
+		UPDATE refunds SET status = 'failed', note = $2
+		WHERE id = $1 AND status = 'processing'`,
+		refundID, note,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CompleteRefund завершает возврат: покупка помечается возвращенной, лицензия отзывается
+// и отвязывается от устройства, подписка отменяется, а по счетам проводится сторно оплаты.
+// При возврате мест для группы отзываются все лицензии, выданные по пулу, и места освобождаются.
+// Реферальный бонус, начисленный за эту покупку, списывается обратно в пределах баланса пригласившего.
+func (s *Storage) CompleteRefund(ctx context.Context, refund *models.RefundDetails, providerRefundID string) error {
+	const op = "postgres.CompleteRefund"
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE refunds SET status = 'completed', provider_refund_id = $2, resolved_at = now()
+		WHERE id = $1 AND status = 'processing'`,
+		refund.ID, providerRefundID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repository.ErrConflict)
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE purchases SET status = 'refunded' WHERE id = $1`, refund.PurchaseID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if refund.Purchase.LicenseID != nil {
+		if err := revokeLicense(ctx, tx, *refund.Purchase.LicenseID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if refund.Purchase.SeatPoolID != nil {
+		if err := revokeSeatPool(ctx, tx, *refund.Purchase.SeatPoolID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := postRefund(ctx, tx, &refund.Purchase); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// revokeLicense отзывает лицензию, отвязывает устройство и отменяет подписку
+func revokeLicense(ctx context.Context, tx pgx.Tx, licenseID int64) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE licenses SET status = 'revoked', device_fingerprint = ''
+		WHERE id = $1`,
+		licenseID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE subscriptions SET status = 'canceled'
+		WHERE license_id = $1 AND status = 'active'`,
+		licenseID,
+	)
+
+	return err
+}
+
+// revokeSeatPool отзывает лицензии, выданные по пулу, и освобождает все занятые места
+func revokeSeatPool(ctx context.Context, tx pgx.Tx, poolID int64) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE licenses SET status = 'revoked', device_fingerprint = ''
+		WHERE seat_pool_id = $1`,
+		poolID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE subscriptions SET status = 'canceled'
+		WHERE license_id IN (SELECT id FROM licenses WHERE seat_pool_id = $1) AND status = 'active'`,
+		poolID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE seat_assignments SET released_at = now()
+		WHERE pool_id = $1 AND released_at IS NULL`,
+		poolID,
+	)
+
+	return err
+}
+
+// postRefund сторнирует проводки оплаты и реферального бонуса
+func postRefund(ctx context.Context, tx pgx.Tx, purchase *models.Purchase) error {
+	if purchase.Amount > 0 {
+		walletID, _, err := lockWallet(ctx, tx, purchase.UserID)
+		if err != nil {
+			return err
+		}
+
+		revenueID, err := systemAccount(ctx, tx, models.AccountRevenue)
+		if err != nil {
+			return err
+		}
+
+		externalID, err := systemAccount(ctx, tx, models.AccountExternal)
+		if err != nil {
+			return err
+		}
+
+		_, err = postTransaction(ctx, tx, models.LedgerRefund, "Возврат покупки", &purchase.ID,
+			ledgerPosting{accountID: revenueID, amount: -purchase.Amount},
+			ledgerPosting{accountID: walletID, amount: purchase.BalanceUsed},
+			ledgerPosting{accountID: externalID, amount: purchase.Amount - purchase.BalanceUsed},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	var rewardID, referrerID, bonus int64
+	err := tx.QueryRow(ctx, `SELECT id, referrer_id, amount FROM referral_rewards WHERE purchase_id = $1`, purchase.ID).
+		Scan(&rewardID, &referrerID, &bonus)
+	if isNoRows(err) || (err == nil && bonus == 0) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	referrerWalletID, balance, err := lockWallet(ctx, tx, referrerID)
+	if err != nil {
+		return err
+	}
+
+	// Бонус мог быть уже потрачен. Кошелек не уводим в минус, недостачу записываем долгом
+	// пригласившего, чтобы ее было видно при разборе
+	taken, debt := clawback(bonus, balance)
+
+	if debt > 0 {
+		_, err := tx.Exec(ctx, `UPDATE referral_rewards SET clawback_debt = $2 WHERE id = $1`, rewardID, debt)
+		if err != nil {
+			return err
+		}
+	}
+
+	if taken == 0 {
+		return nil
+	}
+
+	bonusID, err := systemAccount(ctx, tx, models.AccountBonus)
+	if err != nil {
+		return err
+	}
+
+	_, err = postTransaction(ctx, tx, models.LedgerReferralBonus, "Отмена бонуса: приглашенный вернул покупку", &purchase.ID,
+		ledgerPosting{accountID: referrerWalletID, amount: -taken},
+		ledgerPosting{accountID: bonusID, amount: taken},
+	)
+
+	return err
+}
+
+// clawback делит списание бонуса на часть, которая есть на кошельке, и недостачу
+func clawback(bonus, balance int64) (taken, debt int64) {
+	taken = min(bonus, max(balance, 0))
+
+	return taken, bonus - taken
+}
+
+func scanRefundDetails(row pgx.Row) (*models.RefundDetails, error) {
+	var r models.RefundDetails
+	err := row.Scan(
+		&r.ID, &r.PurchaseID, &r.Initiator, &r.Reason, &r.Status, &r.Amount, &r.ProviderRefundID,
+		&r.Note, &r.CreatedAt, &r.ResolvedAt,
+		&r.Purchase.ID, &r.Purchase.UserID, &r.Purchase.ProductID, &r.Purchase.LicenseID,
+		&r.Purchase.OriginalPrice, &r.Purchase.Discount, &r.Purchase.Amount, &r.Purchase.BalanceUsed,
+		&r.Purchase.PromoCodeID, &r.Purchase.Status, &r.Purchase.ProviderPaymentID, &r.Purchase.PaymentURL,
+		&r.Purchase.Seats, &r.Purchase.SeatPoolID, &r.Purchase.CreatedAt, &r.Purchase.PaidAt,
+		&r.TelegramID, &r.ProductName,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
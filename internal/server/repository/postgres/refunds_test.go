@@ -0,0 +1,190 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+)
+
+func TestClawback(t *testing.T) {
+	tests := []struct {
+		name    string
+		bonus   int64
+		balance int64
+		taken   int64
+		debt    int64
+	}{
+		{name: "бонус на месте", bonus: 1000, balance: 5000, taken: 1000},
+		{name: "ровно бонус", bonus: 1000, balance: 1000, taken: 1000},
+		{name: "часть потрачена", bonus: 1000, balance: 300, taken: 300, debt: 700},
+		{name: "все потрачено", bonus: 1000, balance: 0, debt: 1000},
+		{name: "кошелек уже в минусе", bonus: 1000, balance: -50, debt: 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taken, debt := clawback(tt.bonus, tt.balance)
+			if taken != tt.taken || debt != tt.debt {
+				t.Fatalf("clawback(%d, %d) = %d, %d, ожидалось %d, %d",
+					tt.bonus, tt.balance, taken, debt, tt.taken, tt.debt)
+			}
+		})
+	}
+}
+
+func TestCompleteRefundSpentBonus(t *testing.T) {
+	s := testStorage(t)
+	ctx := context.Background()
+
+	referrer := createTestUser(t, s, 3001)
+	referee := createTestUser(t, s, 3002)
+	productID := createTestProduct(t, s, 10000)
+	cheapID := createTestProduct(t, s, 800)
+
+	// Приглашенный платит картой, пригласившему начисляется бонус
+	purchase := &models.Purchase{UserID: referee.ID, ProductID: productID, OriginalPrice: 10000, Amount: 10000}
+	if err := s.CreatePurchase(ctx, purchase, false); err != nil {
+		t.Fatalf("CreatePurchase: %v", err)
+	}
+	if err := s.SetPurchasePayment(ctx, purchase.ID, "pay-1", ""); err != nil {
+		t.Fatalf("SetPurchasePayment: %v", err)
+	}
+	if err := s.ConfirmPurchase(ctx, purchase); err != nil {
+		t.Fatalf("ConfirmPurchase: %v", err)
+	}
+
+	reward := &models.ReferralReward{ReferrerID: referrer.ID, RefereeID: referee.ID, PurchaseID: purchase.ID, Amount: 1000}
+	if err := s.CreateReferralReward(ctx, reward); err != nil {
+		t.Fatalf("CreateReferralReward: %v", err)
+	}
+
+	// Пригласивший тратит большую часть бонуса
+	spent := &models.Purchase{UserID: referrer.ID, ProductID: cheapID, OriginalPrice: 800, Amount: 800}
+	if err := s.CreatePurchase(ctx, spent, true); err != nil {
+		t.Fatalf("покупка с баланса: %v", err)
+	}
+
+	refund := &models.Refund{PurchaseID: purchase.ID, Initiator: models.RefundByAdmin}
+	if err := s.CreateRefund(ctx, refund); err != nil {
+		t.Fatalf("CreateRefund: %v", err)
+	}
+	if err := s.StartRefundProcessing(ctx, refund.ID); err != nil {
+		t.Fatalf("StartRefundProcessing: %v", err)
+	}
+	details, err := s.RefundByID(ctx, refund.ID)
+	if err != nil {
+		t.Fatalf("RefundByID: %v", err)
+	}
+	if details.Purchase.ProviderPaymentID != "pay-1" {
+		t.Fatalf("платеж покупки %q, ожидался pay-1", details.Purchase.ProviderPaymentID)
+	}
+	if err := s.CompleteRefund(ctx, details, "refund-1"); err != nil {
+		t.Fatalf("CompleteRefund: %v", err)
+	}
+
+	if got, _ := s.WalletBalance(ctx, referrer.ID); got != 0 {
+		t.Fatalf("баланс пригласившего %d, ожидался 0", got)
+	}
+
+	var debt int64
+	if err := s.pool.QueryRow(ctx, `SELECT clawback_debt FROM referral_rewards WHERE id = $1`, reward.ID).Scan(&debt); err != nil {
+		t.Fatalf("долг: %v", err)
+	}
+	if debt != 800 {
+		t.Fatalf("долг %d, ожидался 800", debt)
+	}
+}
+
+func TestCompleteRefundSeatPurchase(t *testing.T) {
+	s := testStorage(t)
+	ctx := context.Background()
+
+	leader := createTestUser(t, s, 3003)
+	first := createTestUser(t, s, 3004)
+	second := createTestUser(t, s, 3005)
+	productID := createTestProduct(t, s, 1000)
+
+	// Личная лицензия старосты куплена отдельно и должна пережить возврат мест
+	personal := &models.Purchase{UserID: leader.ID, ProductID: productID, OriginalPrice: 1000, Amount: 1000}
+	if err := s.CreatePurchase(ctx, personal, false); err != nil {
+		t.Fatalf("CreatePurchase: %v", err)
+	}
+	if err := s.ConfirmPurchase(ctx, personal); err != nil {
+		t.Fatalf("ConfirmPurchase: %v", err)
+	}
+
+	purchase := &models.Purchase{UserID: leader.ID, ProductID: productID, OriginalPrice: 2000, Amount: 2000, Seats: 2}
+	pool := &models.SeatPool{ProductID: productID, OwnerUserID: leader.ID, Seats: 2, InviteCode: "refund-seats"}
+	if err := s.CreateSeatPurchase(ctx, purchase, pool); err != nil {
+		t.Fatalf("CreateSeatPurchase: %v", err)
+	}
+	if err := s.SetPurchasePayment(ctx, purchase.ID, "pay-seats", ""); err != nil {
+		t.Fatalf("SetPurchasePayment: %v", err)
+	}
+	if err := s.ConfirmPurchase(ctx, purchase); err != nil {
+		t.Fatalf("ConfirmPurchase: %v", err)
+	}
+
+	var licenses []int64
+	for _, user := range []*models.User{first, second} {
+		license, err := s.AssignSeat(ctx, pool.ID, user.ID)
+		if err != nil {
+			t.Fatalf("AssignSeat: %v", err)
+		}
+		licenses = append(licenses, license.ID)
+	}
+
+	// Обычная заявка пользователя относится к личной покупке, а не к местам
+	latest, err := s.LatestPaidPurchase(ctx, leader.ID, productID)
+	if err != nil {
+		t.Fatalf("LatestPaidPurchase: %v", err)
+	}
+	if latest.ID != personal.ID {
+		t.Fatalf("последняя покупка %d, ожидалась личная %d", latest.ID, personal.ID)
+	}
+
+	refund := &models.Refund{PurchaseID: purchase.ID, Initiator: models.RefundByAdmin}
+	if err := s.CreateRefund(ctx, refund); err != nil {
+		t.Fatalf("CreateRefund: %v", err)
+	}
+	if err := s.StartRefundProcessing(ctx, refund.ID); err != nil {
+		t.Fatalf("StartRefundProcessing: %v", err)
+	}
+	details, err := s.RefundByID(ctx, refund.ID)
+	if err != nil {
+		t.Fatalf("RefundByID: %v", err)
+	}
+	if details.Purchase.Seats != 2 || details.Purchase.SeatPoolID == nil || *details.Purchase.SeatPoolID != pool.ID {
+		t.Fatalf("покупка в заявке %+v, ожидалось 2 места в пуле %d", details.Purchase, pool.ID)
+	}
+	if err := s.CompleteRefund(ctx, details, "refund-seats"); err != nil {
+		t.Fatalf("CompleteRefund: %v", err)
+	}
+
+	for _, id := range licenses {
+		var status string
+		if err := s.pool.QueryRow(ctx, `SELECT status FROM licenses WHERE id = $1`, id).Scan(&status); err != nil {
+			t.Fatalf("лицензия %d: %v", id, err)
+		}
+		if status != "revoked" {
+			t.Fatalf("лицензия участника %d в статусе %s, ожидался revoked", id, status)
+		}
+	}
+
+	var occupied int
+	if err := s.pool.QueryRow(ctx, `SELECT count(*) FROM seat_assignments WHERE pool_id = $1 AND released_at IS NULL`, pool.ID).Scan(&occupied); err != nil {
+		t.Fatalf("места: %v", err)
+	}
+	if occupied != 0 {
+		t.Fatalf("занято мест %d, ожидалось 0", occupied)
+	}
+
+	var status string
+	if err := s.pool.QueryRow(ctx, `SELECT status FROM licenses WHERE id = $1`, *personal.LicenseID).Scan(&status); err != nil {
+		t.Fatalf("личная лицензия: %v", err)
+	}
+	if status != "active" {
+		t.Fatalf("личная лицензия старосты в статусе %s, ожидался active", status)
+	}
+}
@@ -0,0 +1,305 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+const seatPoolColumns = `
+	sp.id, sp.product_id, p.name, sp.owner_user_id, sp.group_name, sp.seats,
+	(SELECT count(*) FROM seat_assignments sa WHERE sa.pool_id = sp.id AND sa.released_at IS NULL),
+	sp.invite_code, sp.created_at`
+
+const seatPoolFrom = `
+	FROM seat_pools sp
+	JOIN products p ON p.id = sp.product_id`
+
+// seatPoolPaid пул работает только после оплаты покупки мест. Пулы без покупки
+// созданы до оплаты мест и остаются действующими.
+const seatPoolPaid = `
+	(sp.purchase_id IS NULL OR EXISTS (
+		SELECT 1 FROM purchases pu WHERE pu.id = sp.purchase_id AND pu.status = 'paid'
+	))`
+
+// insertSeatPool создает групповую лицензию, оплачиваемую покупкой purchaseID
+func insertSeatPool(ctx context.Context, tx pgx.Tx, pool *models.SeatPool, purchaseID int64) error {
+	return tx.QueryRow(ctx, `
+		INSERT INTO seat_pools (product_id, owner_user_id, group_name, seats, invite_code, purchase_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		pool.ProductID, pool.OwnerUserID, pool.Group, pool.Seats, pool.InviteCode, purchaseID,
+	).Scan(&pool.ID, &pool.CreatedAt)
+}
+
+func (s *Storage) SeatPoolByCode(ctx context.Context, inviteCode string) (*models.SeatPool, error) {
+	const op = "postgres.SeatPoolByCode"
+
+	pool, err := scanSeatPool(s.pool.QueryRow(ctx, `SELECT `+seatPoolColumns+seatPoolFrom+` WHERE sp.invite_code = $1 AND `+seatPoolPaid, inviteCode))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pool, nil
+}
+
+func (s *Storage) SeatPoolByID(ctx context.Context, poolID int64) (*models.SeatPool, error) {
+	const op = "postgres.SeatPoolByID"
+
+	pool, err := scanSeatPool(s.pool.QueryRow(ctx, `SELECT `+seatPoolColumns+seatPoolFrom+` WHERE sp.id = $1 AND `+seatPoolPaid, poolID))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pool, nil
+}
+
+func (s *Storage) SeatPoolsByOwner(ctx context.Context, ownerUserID int64) ([]*models.SeatPool, error) {
+	const op = "postgres.SeatPoolsByOwner"
+
+	rows, err := s.pool.Query(ctx, `SELECT `+seatPoolColumns+seatPoolFrom+` WHERE sp.owner_user_id = $1 AND `+seatPoolPaid+` ORDER BY sp.id`, ownerUserID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var pools []*models.SeatPool
+	for rows.Next() {
+		pool, err := scanSeatPool(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		pools = append(pools, pool)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pools, nil
+}
+
+// SeatAssignments возвращает занятые места пула
+func (s *Storage) SeatAssignments(ctx context.Context, poolID int64) ([]*models.SeatAssignment, error) {
+	const op = "postgres.SeatAssignments"
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT sa.id, sa.pool_id, sa.user_id, u.telegram_id, u.name, sa.license_id, sa.assigned_at
+		FROM seat_assignments sa
+		JOIN users u ON u.id = sa.user_id
+		WHERE sa.pool_id = $1 AND sa.released_at IS NULL
+		ORDER BY sa.assigned_at`,
+		poolID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var assignments []*models.SeatAssignment
+	for rows.Next() {
+		var a models.SeatAssignment
+		if err := rows.Scan(&a.ID, &a.PoolID, &a.UserID, &a.TelegramID, &a.Name, &a.LicenseID, &a.AssignedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		assignments = append(assignments, &a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return assignments, nil
+}
+
+// AssignSeat занимает место в пуле и выдает пользователю лицензию на продукт пула
+func (s *Storage) AssignSeat(ctx context.Context, poolID, userID int64) (*models.License, error) {
+	const op = "postgres.AssignSeat"
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	license, err := assignSeat(ctx, tx, poolID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return license, nil
+}
+
+// ReleaseSeat освобождает место и возвращает id отозванной лицензии
+func (s *Storage) ReleaseSeat(ctx context.Context, poolID, userID int64) (int64, error) {
+	const op = "postgres.ReleaseSeat"
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	licenseID, err := releaseSeat(ctx, tx, poolID, userID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return licenseID, nil
+}
+
+// ReassignSeat передает место одного пользователя другому в одной транзакции.
+// Возвращает id отозванной лицензии и новую лицензию.
+func (s *Storage) ReassignSeat(ctx context.Context, poolID, fromUserID, toUserID int64) (int64, *models.License, error) {
+	const op = "postgres.ReassignSeat"
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	revokedID, err := releaseSeat(ctx, tx, poolID, fromUserID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	license, err := assignSeat(ctx, tx, poolID, toUserID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revokedID, license, nil
+}
+
+func assignSeat(ctx context.Context, tx pgx.Tx, poolID, userID int64) (*models.License, error) {
+	// Блокируем пул, чтобы параллельные вступления не превысили число мест
+	var productID int64
+	var seats int
+	err := tx.QueryRow(ctx, `
+		SELECT sp.product_id, sp.seats FROM seat_pools sp
+		WHERE sp.id = $1 AND `+seatPoolPaid+`
+		FOR UPDATE OF sp`,
+		poolID,
+	).Scan(&productID, &seats)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var used int
+	err = tx.QueryRow(ctx, `
+		SELECT count(*) FROM seat_assignments
+		WHERE pool_id = $1 AND released_at IS NULL`,
+		poolID,
+	).Scan(&used)
+	if err != nil {
+		return nil, err
+	}
+
+	if used >= seats {
+		return nil, repository.ErrNoFreeSeats
+	}
+
+	var licensed bool
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM licenses
+			WHERE user_id = $1 AND product_id = $2 AND status = 'active'
+		)`,
+		userID, productID,
+	).Scan(&licensed)
+	if err != nil {
+		return nil, err
+	}
+
+	// У пользователя уже есть действующая лицензия, место тратить не нужно
+	if licensed {
+		return nil, repository.ErrConflict
+	}
+
+	license, err := issueLicense(ctx, tx, userID, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE licenses SET seat_pool_id = $2 WHERE id = $1`, license.ID, poolID); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO seat_assignments (pool_id, user_id, license_id)
+		VALUES ($1, $2, $3)`,
+		poolID, userID, license.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return license, nil
+}
+
+func releaseSeat(ctx context.Context, tx pgx.Tx, poolID, userID int64) (int64, error) {
+	var licenseID int64
+	err := tx.QueryRow(ctx, `
+		UPDATE seat_assignments SET released_at = now()
+		WHERE pool_id = $1 AND user_id = $2 AND released_at IS NULL
+		RETURNING license_id`,
+		poolID, userID,
+	).Scan(&licenseID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, repository.ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE licenses SET status = 'revoked' WHERE id = $1`, licenseID); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE subscriptions SET status = 'canceled'
+		WHERE license_id = $1 AND status = 'active'`,
+		licenseID,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return licenseID, nil
+}
+
+func scanSeatPool(row pgx.Row) (*models.SeatPool, error) {
+	var pool models.SeatPool
+	err := row.Scan(
+		&pool.ID, &pool.ProductID, &pool.ProductName, &pool.OwnerUserID, &pool.Group,
+		&pool.Seats, &pool.Used, &pool.InviteCode, &pool.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &pool, nil
+}
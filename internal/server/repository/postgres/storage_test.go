@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testDSNEnv база для тестов репозитория. Каждый тест работает в своей схеме,
+// которая удаляется после теста, поэтому подходит любая база разработки.
+const testDSNEnv = "LABGUARD_TEST_DATABASE_URL"
+
+// testStorage хранилище поверх чистой схемы с примененными миграциями.
+// Без LABGUARD_TEST_DATABASE_URL тест пропускается.
+func testStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	pool := testPool(t)
+	if _, err := Migrate(context.Background(), pool); err != nil {
+		t.Fatalf("миграции: %v", err)
+	}
+
+	return NewStorage(pool)
+}
+
+// testPool подключение к чистой схеме без миграций
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s не задана, тест с Postgres пропущен", testDSNEnv)
+	}
+
+	ctx := context.Background()
+	admin, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("подключение к тестовой базе: %v", err)
+	}
+	t.Cleanup(admin.Close)
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("создание схемы: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+	})
+
+	conf, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("разбор DSN: %v", err)
+	}
+	conf.ConnConfig.RuntimeParams["search_path"] = schema
+
+	pool, err := pgxpool.NewWithConfig(ctx, conf)
+	if err != nil {
+		t.Fatalf("подключение к тестовой схеме: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+func createTestUser(t *testing.T, s *Storage, telegramID int64) *models.User {
+	t.Helper()
+
+	user := &models.User{
+		TelegramID:   telegramID,
+		Name:         "Тест",
+		Group:        "ИУ5-31",
+		KeyHash:      fmt.Sprintf("hash-%d", telegramID),
+		KeyHint:      "lg_live_test",
+		ReferralCode: fmt.Sprintf("ref%d", telegramID),
+	}
+	if err := s.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("создание пользователя: %v", err)
+	}
+
+	return user
+}
+
+func createTestProduct(t *testing.T, s *Storage, price int64) int64 {
+	t.Helper()
+
+	var id int64
+	err := s.pool.QueryRow(context.Background(),
+		`INSERT INTO products (name, price) VALUES ('Лабораторная', $1) RETURNING id`, price,
+	).Scan(&id)
+	if err != nil {
+		t.Fatalf("создание продукта: %v", err)
+	}
+
+	return id
+}
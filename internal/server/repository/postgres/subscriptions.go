@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const expiringSubscriptionColumns = `
+	s.id, s.license_id, l.user_id, l.product_id, s.status, s.current_period_end,
+	s.reminded_at, s.created_at, u.telegram_id, p.name`
+
+// ExpiringSubscriptions возвращает активные подписки, период которых закончится до before
+// и по которым еще не отправлялось напоминание
+func (s *Storage) ExpiringSubscriptions(ctx context.Context, before time.Time) ([]*models.ExpiringSubscription, error) {
+	const op = "postgres.ExpiringSubscriptions"
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+expiringSubscriptionColumns+`
+		FROM subscriptions s
+		JOIN licenses l ON l.id = s.license_id
+		JOIN users u ON u.id = l.user_id
+		JOIN products p ON p.id = l.product_id
+		WHERE s.status = 'active'
+			AND s.reminded_at IS NULL
+			AND s.current_period_end > now()
+			AND s.current_period_end <= $1
+		ORDER BY s.current_period_end`,
+		before,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	subs, err := scanExpiringSubscriptions(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subs, nil
+}
+
+// RemindSubscription помечает подписку как уведомленную и ставит сообщение в очередь бота
+func (s *Storage) RemindSubscription(ctx context.Context, subscriptionID, telegramID int64, text string) error {
+	const op = "postgres.RemindSubscription"
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE subscriptions SET reminded_at = now()
+		WHERE id = $1 AND reminded_at IS NULL`,
+		subscriptionID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Напоминание уже отправлено параллельным запуском планировщика
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+
+	if err := createNotification(ctx, tx, telegramID, text); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ExpireSubscriptions переводит в статус expired подписки с закончившимся периодом
+// вместе с их лицензиями и возвращает их
+func (s *Storage) ExpireSubscriptions(ctx context.Context, now time.Time) ([]*models.ExpiringSubscription, error) {
+	const op = "postgres.ExpireSubscriptions"
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		WITH expired AS (
+			UPDATE subscriptions SET status = 'expired'
+			WHERE status = 'active' AND current_period_end <= $1
+			RETURNING *
+		)
+		SELECT `+expiringSubscriptionColumns+`
+		FROM expired s
+		JOIN licenses l ON l.id = s.license_id
+		JOIN users u ON u.id = l.user_id
+		JOIN products p ON p.id = l.product_id`,
+		now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	subs, err := scanExpiringSubscriptions(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	licenseIDs := make([]int64, 0, len(subs))
+	for _, sub := range subs {
+		licenseIDs = append(licenseIDs, sub.LicenseID)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE licenses SET status = 'expired'
+		WHERE id = ANY($1) AND status = 'active'`,
+		licenseIDs,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subs, nil
+}
+
+func scanExpiringSubscriptions(rows pgx.Rows) ([]*models.ExpiringSubscription, error) {
+	defer rows.Close()
+
+	var subs []*models.ExpiringSubscription
+	for rows.Next() {
+		var sub models.ExpiringSubscription
+		err := rows.Scan(
+			&sub.ID, &sub.LicenseID, &sub.UserID, &sub.ProductID, &sub.Status, &sub.CurrentPeriodEnd,
+			&sub.RemindedAt, &sub.CreatedAt, &sub.TelegramID, &sub.ProductName,
+		)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, &sub)
+	}
+
+	return subs, rows.Err()
+}
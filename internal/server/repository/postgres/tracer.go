@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+
+	"github.com/GeorgeTyupin/labguard/pkg/tracing"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxStatementLength длинные запросы обрезаются, чтобы не раздувать спаны
+const maxStatementLength = 2000
+
+// queryTracer пишет спан на каждый запрос к базе. Параметры запроса в спан не попадают:
+// среди них бывают хеши ключей и персональные данные.
+type queryTracer struct {
+	tracer trace.Tracer
+}
+
+func newQueryTracer() *queryTracer {
+	return &queryTracer{tracer: tracing.Tracer("labguard/postgres")}
+}
+
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	statement := strings.Join(strings.Fields(data.SQL), " ")
+	if len(statement) > maxStatementLength {
+		statement = statement[:maxStatementLength]
+	}
+
+	ctx, _ = t.tracer.Start(ctx, spanName(statement),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBQueryText(statement),
+		),
+	)
+
+	return ctx
+}
+
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	if data.Err != nil {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+		return
+	}
+
+	span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+}
+
+// spanName первое слово запроса: SELECT, INSERT, BEGIN и т.п.
+func spanName(statement string) string {
+	operation, _, _ := strings.Cut(statement, " ")
+	if operation == "" {
+		return "postgres"
+	}
+
+	return "postgres " + strings.ToUpper(operation)
+}
@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+const userColumns = `id, telegram_id, name, group_name, license_key_hash, license_key_hint, referral_code, referred_by, is_leader, created_at`
+
+func (s *Storage) CreateUser(ctx context.Context, user *models.User) error {
+	const op = "postgres.CreateUser"
+
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO users (telegram_id, name, group_name, license_key_hash, license_key_hint, referral_code, referred_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`,
+		user.TelegramID, user.Name, user.Group, user.KeyHash, user.KeyHint, user.ReferralCode, user.ReferredBy,
+	).Scan(&user.ID, &user.CreatedAt)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%s: %w", op, repository.ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) UserByTelegramID(ctx context.Context, telegramID int64) (*models.User, error) {
+	const op = "postgres.UserByTelegramID"
+
+	user, err := scanUser(s.pool.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE telegram_id = $1`, telegramID))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) UserByReferralCode(ctx context.Context, code string) (*models.User, error) {
+	const op = "postgres.UserByReferralCode"
+
+	user, err := scanUser(s.pool.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE referral_code = $1`, code))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) UserByID(ctx context.Context, userID int64) (*models.User, error) {
+	const op = "postgres.UserByID"
+
+	user, err := scanUser(s.pool.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, userID))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// RotateLicenseKey заменяет лицензионный ключ пользователя, старый ключ сразу перестает работать
+func (s *Storage) RotateLicenseKey(ctx context.Context, userID int64, keyHash, keyHint string) error {
+	const op = "postgres.RotateLicenseKey"
+
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE users
+		SET license_key_hash = $2, license_key_hint = $3, license_key_rotated_at = now()
+		WHERE id = $1`,
+		userID, keyHash, keyHint,
+	)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%s: %w", op, repository.ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	}
+
+	return nil
+}
+
+// SetLeader назначает пользователя старостой или снимает назначение
+func (s *Storage) SetLeader(ctx context.Context, userID int64, leader bool) error {
+	const op = "postgres.SetLeader"
+
+	tag, err := s.pool.Exec(ctx, `UPDATE users SET is_leader = $2 WHERE id = $1`, userID, leader)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repository.ErrNotFound)
+	}
+
+	return nil
+}
+
+func scanUser(row pgx.Row) (*models.User, error) {
+	var user models.User
+	err := row.Scan(
+		&user.ID, &user.TelegramID, &user.Name, &user.Group, &user.KeyHash, &user.KeyHint,
+		&user.ReferralCode, &user.ReferredBy, &user.IsLeader, &user.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,90 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/pkg/logging"
+)
+
+type JobFunc func(ctx context.Context) error
+
+type job struct {
+	name     string
+	interval time.Duration
+	run      JobFunc
+}
+
+// Scheduler периодически запускает фоновые задачи сервера
+type Scheduler struct {
+	logger *slog.Logger
+	jobs   []job
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New(logger *slog.Logger) *Scheduler {
+	return &Scheduler{
+		logger: logger.With(slog.String("component", "scheduler")),
+	}
+}
+
+// Every регистрирует задачу, которая запускается сразу после старта и затем раз в interval
+func (s *Scheduler) Every(name string, interval time.Duration, run JobFunc) {
+	s.jobs = append(s.jobs, job{name: name, interval: interval, run: run})
+}
+
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.loop(ctx, j)
+		}()
+	}
+}
+
+// Stop отменяет контекст задач и дожидается их завершения
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, j job) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		s.runJob(ctx, j)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Scheduler) runJob(ctx context.Context, j job) {
+	logger := s.logger.With(slog.String("job", j.name))
+
+	// Свой id у каждого запуска связывает записи задачи в логах
+	ctx = logging.WithRequestID(ctx, logging.NewRequestID())
+
+	start := time.Now()
+	if err := j.run(ctx); err != nil {
+		logger.ErrorContext(ctx, "Ошибка выполнения задачи", slog.String("error", err.Error()))
+		return
+	}
+
+	logger.DebugContext(ctx, "Задача выполнена", slog.Duration("duration", time.Since(start)))
+}
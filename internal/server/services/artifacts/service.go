@@ -0,0 +1,600 @@
+package artifacts
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/blobstore"
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+	"github.com/GeorgeTyupin/labguard/internal/server/watermark"
+)
+
+const (
+	maxArtifactSize   = 200 << 20
+	maxFileNameLength = 128
+	maxNotesLength    = 1000
+)
+
+var (
+	ErrProductNotFound   = errors.New("продукт не найден")
+	ErrUserNotFound      = errors.New("пользователь не найден")
+	ErrNoAccess          = errors.New("нет действующей лицензии на продукт")
+	ErrArtifactNotFound  = errors.New("у продукта нет материалов для скачивания")
+	ErrInvalidArtifact   = errors.New("неверный файл")
+	ErrVersionConflict   = errors.New("одновременно загружается другая версия, повторите загрузку")
+	ErrLinkInvalid       = errors.New("неверная ссылка на скачивание")
+	ErrLinkExpired       = errors.New("ссылка на скачивание устарела, получите новую в боте")
+	ErrWatermarkNotFound = errors.New("выдача с такой меткой не найдена")
+)
+
+var extensions = map[string]string{
+	watermark.FormatZIP: ".zip",
+	watermark.FormatPDF: ".pdf",
+}
+
+type Repository interface {
+	ProductByID(ctx context.Context, productID int64) (*models.Product, error)
+	UserByTelegramID(ctx context.Context, telegramID int64) (*models.User, error)
+	LicenseByUser(ctx context.Context, userID, productID int64) (*models.License, error)
+	CreateArtifact(ctx context.Context, artifact *models.Artifact) error
+	Artifacts(ctx context.Context, productID int64) ([]*models.Artifact, error)
+	LatestArtifact(ctx context.Context, productID int64) (*models.Artifact, error)
+	ArtifactByID(ctx context.Context, artifactID int64) (*models.Artifact, error)
+	CreateArtifactDelivery(ctx context.Context, delivery *models.ArtifactDelivery) error
+	ArtifactDeliveryByID(ctx context.Context, deliveryID int64) (*models.ArtifactDelivery, error)
+	TraceDelivery(ctx context.Context, watermark string) (*models.DeliveryTrace, error)
+}
+
+// Blobs хранилище содержимого файлов
+type Blobs interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Auditor журнал аудита
+type Auditor interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+// Upload новая версия материалов продукта. Content читается несколько раз:
+// перед сохранением проверяется, что в файл получится поставить метку.
+type Upload struct {
+	ProductID int64
+	FileName  string
+	Notes     string
+	Size      int64
+	Content   io.ReaderAt
+}
+
+// Link временная ссылка на скачивание материалов
+type Link struct {
+	Token     string
+	ExpiresAt time.Time
+	Artifact  *models.Artifact
+}
+
+// Download копия материалов с меткой покупателя во временном файле.
+// Вызывающий закрывает ее, файл при этом удаляется.
+type Download struct {
+	Artifact *models.Artifact
+	Delivery *models.ArtifactDelivery
+	Size     int64
+
+	file *os.File
+}
+
+func (d *Download) Read(p []byte) (int, error) {
+	return d.file.Read(p)
+}
+
+func (d *Download) Seek(offset int64, whence int) (int64, error) {
+	return d.file.Seek(offset, whence)
+}
+
+func (d *Download) Close() error {
+	return removeTemp(d.file)
+}
+
+// Service материалы продуктов для покупателей: архивы с исходным кодом и отчеты.
+// Каждая выданная копия помечена кодом, подписанным по лицензии покупателя.
+type Service struct {
+	repo         Repository
+	blobs        Blobs
+	audit        Auditor
+	logger       *slog.Logger
+	linkSecret   []byte
+	linkTTL      time.Duration
+	watermarkKey []byte
+	now          func() time.Time
+}
+
+func NewService(repo Repository, blobs Blobs, audit Auditor, logger *slog.Logger, linkSecret []byte, linkTTL time.Duration, watermarkKey []byte) *Service {
+	return &Service{
+		repo:         repo,
+		blobs:        blobs,
+		audit:        audit,
+		logger:       logger,
+		linkSecret:   linkSecret,
+		linkTTL:      linkTTL,
+		watermarkKey: watermarkKey,
+		now:          time.Now,
+	}
+}
+
+// Upload проверяет файл и сохраняет его следующей версией материалов продукта
+func (s *Service) Upload(ctx context.Context, upload Upload) (*models.Artifact, error) {
+	const op = "artifacts.Upload"
+
+	if err := validate(upload); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.ProductByID(ctx, upload.ProductID); errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrProductNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Тип файла определяем по содержимому, а не по заголовку запроса
+	head := make([]byte, 512)
+	n, err := upload.Content.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	contentType, _, _ := strings.Cut(http.DetectContentType(head[:n]), ";")
+	format, ok := watermark.FormatOf(contentType)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s, нужен ZIP-архив или PDF", ErrInvalidArtifact, contentType)
+	}
+
+	if err := watermark.Check(upload.Content, upload.Size, format); err != nil {
+		return nil, fmt.Errorf("%w: в файл не получается поставить метку: %w", ErrInvalidArtifact, err)
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	artifact := &models.Artifact{
+		ProductID:   upload.ProductID,
+		StorageKey:  path.Join("products", strconv.FormatInt(upload.ProductID, 10), "artifacts", name+extensions[format]),
+		FileName:    upload.FileName,
+		ContentType: contentType,
+		Size:        upload.Size,
+		Notes:       upload.Notes,
+	}
+
+	hash := sha256.New()
+	content := io.TeeReader(io.NewSectionReader(upload.Content, 0, upload.Size), hash)
+	if err := s.blobs.Put(ctx, artifact.StorageKey, content, artifact.Size, contentType); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	artifact.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	err = s.repo.CreateArtifact(ctx, artifact)
+	if err != nil {
+		s.deleteBlob(ctx, artifact.StorageKey)
+	}
+	switch {
+	case errors.Is(err, repository.ErrConflict):
+		return nil, ErrVersionConflict
+	case errors.Is(err, repository.ErrNotFound):
+		return nil, ErrProductNotFound
+	case err != nil:
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.logger.InfoContext(ctx, "Загружена новая версия материалов",
+		slog.String("op", op),
+		slog.Int64("product_id", artifact.ProductID),
+		slog.Int64("artifact_id", artifact.ID),
+		slog.Int("version", artifact.Version),
+	)
+	s.audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditArtifactUploaded,
+		TargetType: models.TargetArtifact,
+		TargetID:   strconv.FormatInt(artifact.ID, 10),
+		Details: map[string]any{
+			"product_id": artifact.ProductID,
+			"version":    artifact.Version,
+			"file_name":  artifact.FileName,
+			"size":       artifact.Size,
+			"sha256":     artifact.SHA256,
+		},
+	})
+
+	return artifact, nil
+}
+
+// List все версии материалов продукта, новые первыми
+func (s *Service) List(ctx context.Context, productID int64) ([]*models.Artifact, error) {
+	const op = "artifacts.List"
+
+	if _, err := s.repo.ProductByID(ctx, productID); errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrProductNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	artifacts, err := s.repo.Artifacts(ctx, productID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return artifacts, nil
+}
+
+// Latest последняя версия материалов, которую может скачать покупатель
+func (s *Service) Latest(ctx context.Context, telegramID, productID int64) (*models.Artifact, error) {
+	const op = "artifacts.Latest"
+
+	user, err := s.user(ctx, telegramID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.license(ctx, user.ID, productID); err != nil {
+		return nil, err
+	}
+
+	artifact, err := s.repo.LatestArtifact(ctx, productID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrArtifactNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return artifact, nil
+}
+
+// Deliver выдает покупателю копию последней версии материалов с его меткой
+func (s *Service) Deliver(ctx context.Context, telegramID, productID int64) (*Download, error) {
+	artifact, delivery, err := s.deliver(ctx, telegramID, productID, models.DeliveryTelegram)
+	if err != nil {
+		return nil, err
+	}
+
+	download, err := s.prepare(ctx, artifact, delivery)
+	if err != nil {
+		return nil, err
+	}
+
+	return download, nil
+}
+
+// Link выдает покупателю временную ссылку на копию с его меткой. Метка ставится
+// сейчас, по ссылке скачивается копия этой выдачи.
+func (s *Service) Link(ctx context.Context, telegramID, productID int64) (*Link, error) {
+	artifact, delivery, err := s.deliver(ctx, telegramID, productID, models.DeliveryLink)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := s.now().Add(s.linkTTL)
+
+	return &Link{
+		Token:     s.sign(delivery.ID, expiresAt),
+		ExpiresAt: expiresAt,
+		Artifact:  artifact,
+	}, nil
+}
+
+// OpenLink копия по временной ссылке. Лицензия проверяется снова: после возврата
+// денег ссылка перестает работать, даже если еще не истекла.
+func (s *Service) OpenLink(ctx context.Context, token string) (*Download, error) {
+	const op = "artifacts.OpenLink"
+
+	deliveryID, expiresAt, err := s.parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if !s.now().Before(expiresAt) {
+		return nil, ErrLinkExpired
+	}
+
+	delivery, err := s.repo.ArtifactDeliveryByID(ctx, deliveryID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrLinkInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	artifact, err := s.repo.ArtifactByID(ctx, delivery.ArtifactID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := s.license(ctx, delivery.UserID, artifact.ProductID); err != nil {
+		return nil, err
+	}
+
+	download, err := s.prepare(ctx, artifact, delivery)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditArtifactDownloaded,
+		TargetType: models.TargetArtifact,
+		TargetID:   strconv.FormatInt(artifact.ID, 10),
+		Details:    map[string]any{"delivery_id": delivery.ID, "user_id": delivery.UserID},
+	})
+
+	return download, nil
+}
+
+// Trace выдача по метке из утекшей копии. Метку можно передать с префиксом labguard:.
+// Если код не подписан по лицензии из журнала, выдача все равно возвращается с
+// Bound = false: запись в журнале могли изменить или код выдан до подписи.
+func (s *Service) Trace(ctx context.Context, mark string) (*models.DeliveryTrace, error) {
+	const op = "artifacts.Trace"
+
+	code := strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(mark), watermark.Prefix))
+	if code == "" {
+		return nil, ErrWatermarkNotFound
+	}
+
+	trace, err := s.repo.TraceDelivery(ctx, code)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrWatermarkNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	trace.Bound = watermark.Bound(s.watermarkKey, trace.Delivery.Watermark, trace.Delivery.LicenseID)
+
+	return trace, nil
+}
+
+// deliver проверяет лицензию и записывает выдачу последней версии с новой меткой,
+// подписанной по лицензии
+func (s *Service) deliver(ctx context.Context, telegramID, productID int64, channel string) (*models.Artifact, *models.ArtifactDelivery, error) {
+	const op = "artifacts.deliver"
+
+	user, err := s.user(ctx, telegramID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	license, err := s.license(ctx, user.ID, productID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	artifact, err := s.repo.LatestArtifact(ctx, productID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, ErrArtifactNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	code, err := watermark.NewCode(s.watermarkKey, license.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	delivery := &models.ArtifactDelivery{
+		ArtifactID: artifact.ID,
+		UserID:     user.ID,
+		LicenseID:  license.ID,
+		Watermark:  code,
+		Channel:    channel,
+	}
+	if err := s.repo.CreateArtifactDelivery(ctx, delivery); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.logger.InfoContext(ctx, "Материалы выданы покупателю",
+		slog.String("op", op),
+		slog.Int64("product_id", productID),
+		slog.Int64("artifact_id", artifact.ID),
+		slog.Int64("delivery_id", delivery.ID),
+		slog.String("channel", channel),
+	)
+	s.audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditArtifactDelivered,
+		ActorType:  models.ActorUser,
+		ActorID:    strconv.FormatInt(telegramID, 10),
+		TargetType: models.TargetArtifact,
+		TargetID:   strconv.FormatInt(artifact.ID, 10),
+		Details: map[string]any{
+			"delivery_id": delivery.ID,
+			"product_id":  productID,
+			"version":     artifact.Version,
+			"channel":     channel,
+		},
+	})
+
+	return artifact, delivery, nil
+}
+
+// prepare ставит метку выдачи в копию материалов. Метка ставится с произвольным
+// доступом к файлу, поэтому содержимое сначала копируется из хранилища на диск.
+func (s *Service) prepare(ctx context.Context, artifact *models.Artifact, delivery *models.ArtifactDelivery) (*Download, error) {
+	const op = "artifacts.prepare"
+
+	content, err := s.blobs.Open(ctx, artifact.StorageKey)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		s.logger.ErrorContext(ctx, "Материалов нет в хранилище",
+			slog.String("op", op),
+			slog.Int64("artifact_id", artifact.ID),
+			slog.String("key", artifact.StorageKey),
+		)
+		return nil, ErrArtifactNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer content.Close()
+
+	original, err := os.CreateTemp("", "labguard-artifact-*")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer removeTemp(original)
+
+	size, err := io.Copy(original, content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	format, ok := watermark.FormatOf(artifact.ContentType)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w: %s", op, watermark.ErrUnsupported, artifact.ContentType)
+	}
+
+	marked, err := os.CreateTemp("", "labguard-download-*")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := watermark.Apply(marked, original, size, format, delivery.Watermark); err != nil {
+		removeTemp(marked)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	markedSize, err := marked.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = marked.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		removeTemp(marked)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Download{
+		Artifact: artifact,
+		Delivery: delivery,
+		Size:     markedSize,
+		file:     marked,
+	}, nil
+}
+
+func (s *Service) user(ctx context.Context, telegramID int64) (*models.User, error) {
+	const op = "artifacts.user"
+
+	user, err := s.repo.UserByTelegramID(ctx, telegramID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// license действующая лицензия пользователя на продукт
+func (s *Service) license(ctx context.Context, userID, productID int64) (*models.License, error) {
+	const op = "artifacts.license"
+
+	license, err := s.repo.LicenseByUser(ctx, userID, productID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrNoAccess
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Планировщик мог еще не успеть перевести лицензию в expired, поэтому срок проверяем и здесь
+	if license.Status != models.LicenseActive || license.IsExpired(s.now()) {
+		return nil, ErrNoAccess
+	}
+
+	return license, nil
+}
+
+// sign токен ссылки: id выдачи и срок действия, подписанные HMAC. Базе токен не нужен.
+func (s *Service) sign(deliveryID int64, expiresAt time.Time) string {
+	payload := make([]byte, 16)
+	binary.BigEndian.PutUint64(payload, uint64(deliveryID))
+	binary.BigEndian.PutUint64(payload[8:], uint64(expiresAt.Unix()))
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	return encoded + "." + s.signature(encoded)
+}
+
+func (s *Service) parse(token string) (int64, time.Time, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.signature(encoded))) {
+		return 0, time.Time{}, ErrLinkInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(payload) != 16 {
+		return 0, time.Time{}, ErrLinkInvalid
+	}
+
+	deliveryID := int64(binary.BigEndian.Uint64(payload))
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(payload[8:])), 0)
+
+	return deliveryID, expiresAt, nil
+}
+
+func (s *Service) signature(encoded string) string {
+	mac := hmac.New(sha256.New, s.linkSecret)
+	mac.Write([]byte(encoded))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// deleteBlob удаляет файл, для которого не появилась запись. Ошибка только логируется.
+func (s *Service) deleteBlob(ctx context.Context, key string) {
+	if err := s.blobs.Delete(ctx, key); err != nil {
+		s.logger.WarnContext(ctx, "Не удалось удалить файл из хранилища",
+			slog.String("key", key),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+func validate(upload Upload) error {
+	switch {
+	case upload.Size <= 0:
+		return fmt.Errorf("%w: файл пустой", ErrInvalidArtifact)
+	case upload.Size > maxArtifactSize:
+		return fmt.Errorf("%w: файл больше %d МБ", ErrInvalidArtifact, maxArtifactSize>>20)
+	case upload.FileName == "" || len(upload.FileName) > maxFileNameLength:
+		return fmt.Errorf("%w: длина имени файла от 1 до %d символов", ErrInvalidArtifact, maxFileNameLength)
+	case len(upload.Notes) > maxNotesLength:
+		return fmt.Errorf("%w: описание версии длиннее %d символов", ErrInvalidArtifact, maxNotesLength)
+	}
+
+	return nil
+}
+
+// randomName имя файла в хранилище: по нему нельзя угадать соседние файлы
+func randomName() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+func removeTemp(f *os.File) error {
+	f.Close()
+	return os.Remove(f.Name())
+}
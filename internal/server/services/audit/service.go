@@ -0,0 +1,100 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/middleware"
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/pkg/auth"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+type Repository interface {
+	CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error
+	AuditEvents(ctx context.Context, filter models.AuditFilter, fn func(*models.AuditEvent) error) error
+}
+
+// Service пишет журнал аудита и отдает его администраторам
+type Service struct {
+	repo   Repository
+	logger *slog.Logger
+}
+
+func NewService(repo Repository, logger *slog.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Record дописывает событие в журнал. Адрес клиента, id запроса и токен берутся из
+// контекста запроса. Если действующее лицо не указано, им считается владелец токена.
+// Ошибка записи только логируется: сбой журнала не должен отменять само действие.
+func (s *Service) Record(ctx context.Context, event models.AuditEvent) {
+	const op = "audit.Record"
+
+	if info, ok := middleware.RequestInfoFromContext(ctx); ok {
+		event.IP = info.IP
+		event.RequestID = info.ID
+	}
+
+	if principal, ok := middleware.PrincipalFromContext(ctx); ok {
+		event.Via = principal.Role + ":" + principal.Subject
+
+		if event.ActorType == "" && principal.Role == auth.RoleAdmin {
+			event.ActorType = models.ActorAdmin
+			event.ActorID = principal.Subject
+		}
+	}
+
+	if event.ActorType == "" {
+		event.ActorType = models.ActorSystem
+	}
+
+	if err := s.repo.CreateAuditEvent(ctx, &event); err != nil {
+		s.logger.ErrorContext(ctx, "Не удалось записать событие аудита",
+			slog.String("op", op),
+			slog.String("action", event.Action),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// Query возвращает страницу журнала от новых записей к старым
+func (s *Service) Query(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEvent, error) {
+	const op = "audit.Query"
+
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultLimit
+	}
+	filter.Limit = min(filter.Limit, MaxLimit)
+
+	var events []*models.AuditEvent
+	err := s.repo.AuditEvents(ctx, filter, func(event *models.AuditEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+// Export передает в fn все записи под фильтром без ограничения количества
+func (s *Service) Export(ctx context.Context, filter models.AuditFilter, fn func(*models.AuditEvent) error) error {
+	const op = "audit.Export"
+
+	filter.Limit = 0
+	if err := s.repo.AuditEvents(ctx, filter, fn); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
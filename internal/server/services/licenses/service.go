@@ -0,0 +1,142 @@
+package licenses
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/licensekey"
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+)
+
+// Причины результата проверки лицензии, которые получает десктопный клиент
+const (
+	ReasonOK             = "ok"
+	ReasonNotFound       = "not_found"
+	ReasonExpired        = "expired"
+	ReasonRevoked        = "revoked"
+	ReasonDeviceMismatch = "device_mismatch"
+)
+
+var ErrInvalidRequest = errors.New("не указан ключ, продукт или fingerprint устройства")
+
+type Repository interface {
+	LicenseByKey(ctx context.Context, keyHash string, productID int64) (*models.License, error)
+	BindDevice(ctx context.Context, licenseID int64, fingerprint string) error
+}
+
+type VerifyResult struct {
+	Allowed   bool
+	Reason    string
+	ExpiresAt *time.Time
+}
+
+// Auditor журнал аудита
+type Auditor interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+type Service struct {
+	repo   Repository
+	audit  Auditor
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+func NewService(repo Repository, audit Auditor, logger *slog.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		audit:  audit,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// Verify проверяет, может ли устройство с fingerprint запустить продукт по ключу licenseKey.
+// При первой успешной проверке лицензия привязывается к устройству.
+func (s *Service) Verify(ctx context.Context, licenseKey string, productID int64, fingerprint string) (*VerifyResult, error) {
+	const op = "licenses.Verify"
+	logger := s.logger.With(slog.String("op", op), slog.Int64("product_id", productID))
+
+	if licenseKey == "" || productID <= 0 || fingerprint == "" {
+		return nil, ErrInvalidRequest
+	}
+
+	keyHash := licensekey.Hash(strings.TrimSpace(licenseKey))
+
+	license, err := s.repo.LicenseByKey(ctx, keyHash, productID)
+	if errors.Is(err, repository.ErrNotFound) {
+		// Сам ключ в журнал не пишем, начала хеша достаточно, чтобы связать попытки между собой
+		s.audit.Record(ctx, models.AuditEvent{
+			Action:    models.AuditVerifyDenied,
+			ActorType: models.ActorClient,
+			Details: map[string]any{
+				"reason":     ReasonNotFound,
+				"product_id": productID,
+				"key_hash":   keyHash[:16],
+			},
+		})
+		return deny(ReasonNotFound, nil), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	logger = logger.With(slog.Int64("license_id", license.ID))
+
+	switch {
+	case license.Status == models.LicenseRevoked:
+		return s.deny(ctx, license, ReasonRevoked), nil
+	case license.Status == models.LicenseExpired || license.IsExpired(s.now()):
+		// Планировщик мог еще не успеть перевести лицензию в expired, поэтому срок проверяем и здесь
+		return s.deny(ctx, license, ReasonExpired), nil
+	}
+
+	if license.DeviceFingerprint == "" {
+		err := s.repo.BindDevice(ctx, license.ID, fingerprint)
+		if errors.Is(err, repository.ErrDeviceBound) {
+			// Другое устройство успело привязать лицензию между чтением и привязкой
+			return s.deny(ctx, license, ReasonDeviceMismatch), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		logger.InfoContext(ctx, "Лицензия привязана к устройству")
+
+		s.audit.Record(ctx, models.AuditEvent{
+			Action:     models.AuditDeviceBound,
+			ActorType:  models.ActorClient,
+			ActorID:    strconv.FormatInt(license.UserID, 10),
+			TargetType: models.TargetLicense,
+			TargetID:   strconv.FormatInt(license.ID, 10),
+			Details:    map[string]any{"fingerprint": fingerprint},
+		})
+	} else if license.DeviceFingerprint != fingerprint {
+		return s.deny(ctx, license, ReasonDeviceMismatch), nil
+	}
+
+	return &VerifyResult{Allowed: true, Reason: ReasonOK, ExpiresAt: license.ExpiresAt}, nil
+}
+
+// deny отказ по найденной лицензии, попадает в журнал аудита
+func (s *Service) deny(ctx context.Context, license *models.License, reason string) *VerifyResult {
+	s.audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditVerifyDenied,
+		ActorType:  models.ActorClient,
+		ActorID:    strconv.FormatInt(license.UserID, 10),
+		TargetType: models.TargetLicense,
+		TargetID:   strconv.FormatInt(license.ID, 10),
+		Details:    map[string]any{"reason": reason, "product_id": license.ProductID},
+	})
+
+	return deny(reason, license.ExpiresAt)
+}
+
+func deny(reason string, expiresAt *time.Time) *VerifyResult {
+	return &VerifyResult{Allowed: false, Reason: reason, ExpiresAt: expiresAt}
+}
@@ -0,0 +1,182 @@
+package licenses
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/licensekey"
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+)
+
+const testKey = "lg_live_testkey"
+
+// fakeRepo одна лицензия. BindDevice привязывает только непривязанную лицензию, как
+// условный UPDATE в Postgres, а LicenseByKey ждет, пока лицензию прочитают все readers, чтобы
+// проверки гарантированно шли наперегонки.
+type fakeRepo struct {
+	mu      sync.Mutex
+	license models.License
+	readers sync.WaitGroup
+}
+
+func (r *fakeRepo) LicenseByKey(_ context.Context, keyHash string, productID int64) (*models.License, error) {
+	r.mu.Lock()
+	license := r.license
+	r.mu.Unlock()
+
+	r.readers.Done()
+	r.readers.Wait()
+
+	if keyHash != licensekey.Hash(testKey) || productID != license.ProductID {
+		return nil, repository.ErrNotFound
+	}
+
+	return &license, nil
+}
+
+func (r *fakeRepo) BindDevice(_ context.Context, _ int64, fingerprint string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch r.license.DeviceFingerprint {
+	case "":
+		r.license.DeviceFingerprint = fingerprint
+		return nil
+	case fingerprint:
+		return nil
+	}
+
+	return repository.ErrDeviceBound
+}
+
+type nopAuditor struct{}
+
+func (nopAuditor) Record(context.Context, models.AuditEvent) {}
+
+func newTestService(license models.License, readers int) (*Service, *fakeRepo) {
+	repo := &fakeRepo{license: license}
+	repo.readers.Add(readers)
+
+	return NewService(repo, nopAuditor{}, slog.New(slog.NewTextHandler(io.Discard, nil))), repo
+}
+
+func TestVerifyConcurrentFirstBind(t *testing.T) {
+	const devices = 8
+	s, repo := newTestService(models.License{ID: 1, UserID: 1, ProductID: 7, Status: models.LicenseActive}, devices)
+
+	results := make([]*VerifyResult, devices)
+	var wg sync.WaitGroup
+	for i := range devices {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := s.Verify(context.Background(), testKey, 7, fmt.Sprintf("device-%d", i))
+			if err != nil {
+				t.Errorf("устройство %d: %v", i, err)
+				return
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	allowed := 0
+	for i, result := range results {
+		if result == nil {
+			continue
+		}
+		if result.Allowed {
+			allowed++
+			if want := fmt.Sprintf("device-%d", i); repo.license.DeviceFingerprint != want {
+				t.Errorf("доступ получило %s, а привязано %s", want, repo.license.DeviceFingerprint)
+			}
+			continue
+		}
+		if result.Reason != ReasonDeviceMismatch {
+			t.Errorf("устройство %d: причина %q, ожидалась %q", i, result.Reason, ReasonDeviceMismatch)
+		}
+	}
+	if allowed != 1 {
+		t.Fatalf("доступ получили %d устройств, ожидалось одно", allowed)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name        string
+		license     models.License
+		key         string
+		fingerprint string
+		allowed     bool
+		reason      string
+	}{
+		{
+			name:        "первая привязка",
+			license:     models.License{Status: models.LicenseActive},
+			key:         testKey,
+			fingerprint: "a",
+			allowed:     true,
+			reason:      ReasonOK,
+		},
+		{
+			name:        "то же устройство",
+			license:     models.License{Status: models.LicenseActive, DeviceFingerprint: "a"},
+			key:         testKey,
+			fingerprint: "a",
+			allowed:     true,
+			reason:      ReasonOK,
+		},
+		{
+			name:        "другое устройство",
+			license:     models.License{Status: models.LicenseActive, DeviceFingerprint: "a"},
+			key:         testKey,
+			fingerprint: "b",
+			reason:      ReasonDeviceMismatch,
+		},
+		{
+			name:        "неизвестный ключ",
+			license:     models.License{Status: models.LicenseActive},
+			key:         "lg_live_other",
+			fingerprint: "a",
+			reason:      ReasonNotFound,
+		},
+		{
+			name:        "отозвана",
+			license:     models.License{Status: models.LicenseRevoked},
+			key:         testKey,
+			fingerprint: "a",
+			reason:      ReasonRevoked,
+		},
+		{
+			name:        "срок истек",
+			license:     models.License{Status: models.LicenseActive, ExpiresAt: &past},
+			key:         testKey,
+			fingerprint: "a",
+			reason:      ReasonExpired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.license.ID, tt.license.UserID, tt.license.ProductID = 1, 1, 7
+			s, _ := newTestService(tt.license, 1)
+
+			result, err := s.Verify(context.Background(), tt.key, 7, tt.fingerprint)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if result.Allowed != tt.allowed || result.Reason != tt.reason {
+				t.Fatalf("получено allowed=%v reason=%q, ожидалось allowed=%v reason=%q",
+					result.Allowed, result.Reason, tt.allowed, tt.reason)
+			}
+		})
+	}
+}
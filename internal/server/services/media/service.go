@@ -0,0 +1,330 @@
+package media
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/blobstore"
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+)
+
+const (
+	// Telegram принимает фото до 10 МБ, документ от бота до 50 МБ
+	maxPhotoSize    = 10 << 20
+	maxDocumentSize = 50 << 20
+
+	// В альбоме Telegram не больше 10 файлов: обложка и 9 скриншотов
+	maxScreenshots = 9
+
+	maxFileNameLength = 128
+)
+
+var (
+	ErrProductNotFound = errors.New("продукт не найден")
+	ErrMediaNotFound   = errors.New("файл не найден")
+	ErrInvalidMedia    = errors.New("неверный файл")
+	ErrMediaExists     = errors.New("у продукта уже есть такой файл, сначала удалите старый")
+	ErrTooManyMedia    = errors.New("у продукта уже максимум скриншотов")
+)
+
+// allowedTypes форматы файлов по видам медиа. Тип определяется по содержимому,
+// а не по заголовку запроса.
+var allowedTypes = map[string][]string{
+	models.MediaCover:      {"image/jpeg", "image/png"},
+	models.MediaScreenshot: {"image/jpeg", "image/png"},
+	models.MediaSample:     {"application/pdf"},
+}
+
+var extensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"application/pdf": ".pdf",
+}
+
+type Repository interface {
+	ProductByID(ctx context.Context, productID int64) (*models.Product, error)
+	CreateProductMedia(ctx context.Context, media *models.ProductMedia) error
+	ProductMedia(ctx context.Context, productID int64) ([]*models.ProductMedia, error)
+	ProductMediaByID(ctx context.Context, mediaID int64) (*models.ProductMedia, error)
+	DeleteProductMedia(ctx context.Context, productID, mediaID int64) (*models.ProductMedia, error)
+}
+
+// Blobs хранилище содержимого файлов
+type Blobs interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Auditor журнал аудита
+type Auditor interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+// Upload файл, который администратор прикрепляет к карточке продукта
+type Upload struct {
+	ProductID int64
+	Kind      string
+	Position  int
+	FileName  string
+	Size      int64
+	Content   io.Reader
+}
+
+// Service обложки, скриншоты и примеры работ в карточках продуктов
+type Service struct {
+	repo   Repository
+	blobs  Blobs
+	audit  Auditor
+	logger *slog.Logger
+}
+
+func NewService(repo Repository, blobs Blobs, audit Auditor, logger *slog.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		blobs:  blobs,
+		audit:  audit,
+		logger: logger,
+	}
+}
+
+// Upload проверяет файл, сохраняет его в хранилище и прикрепляет к продукту
+func (s *Service) Upload(ctx context.Context, upload Upload) (*models.ProductMedia, error) {
+	const op = "media.Upload"
+
+	if err := validate(upload); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.ProductByID(ctx, upload.ProductID); errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrProductNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if upload.Kind == models.MediaScreenshot {
+		existing, err := s.repo.ProductMedia(ctx, upload.ProductID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if countKind(existing, models.MediaScreenshot) >= maxScreenshots {
+			return nil, ErrTooManyMedia
+		}
+	}
+
+	// Тип файла определяем по первым байтам, их же потом отдаем в хранилище
+	content := bufio.NewReaderSize(upload.Content, 512)
+	head, err := content.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	contentType, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	ext, ok := extensions[contentType]
+	if !ok || !allowed(upload.Kind, contentType) {
+		return nil, fmt.Errorf("%w: %s не подходит для %s", ErrInvalidMedia, contentType, upload.Kind)
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	media := &models.ProductMedia{
+		ProductID:   upload.ProductID,
+		Kind:        upload.Kind,
+		Position:    upload.Position,
+		StorageKey:  path.Join("products", strconv.FormatInt(upload.ProductID, 10), "media", name+ext),
+		FileName:    upload.FileName,
+		ContentType: contentType,
+		Size:        upload.Size,
+	}
+
+	hash := sha256.New()
+	if err := s.blobs.Put(ctx, media.StorageKey, io.TeeReader(content, hash), media.Size, contentType); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	media.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	err = s.repo.CreateProductMedia(ctx, media)
+	if err != nil {
+		// Запись не появилась, файл в хранилище никому не нужен
+		s.deleteBlob(ctx, media.StorageKey)
+	}
+	switch {
+	case errors.Is(err, repository.ErrConflict):
+		return nil, ErrMediaExists
+	case errors.Is(err, repository.ErrNotFound):
+		return nil, ErrProductNotFound
+	case err != nil:
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.logger.InfoContext(ctx, "Файл добавлен в карточку продукта",
+		slog.String("op", op),
+		slog.Int64("product_id", media.ProductID),
+		slog.Int64("media_id", media.ID),
+		slog.String("kind", media.Kind),
+	)
+	s.audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditProductMediaAdded,
+		TargetType: models.TargetProduct,
+		TargetID:   strconv.FormatInt(media.ProductID, 10),
+		Details: map[string]any{
+			"media_id":  media.ID,
+			"kind":      media.Kind,
+			"file_name": media.FileName,
+			"size":      media.Size,
+			"sha256":    media.SHA256,
+		},
+	})
+
+	return media, nil
+}
+
+// List файлы карточки продукта в порядке показа
+func (s *Service) List(ctx context.Context, productID int64) ([]*models.ProductMedia, error) {
+	const op = "media.List"
+
+	if _, err := s.repo.ProductByID(ctx, productID); errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrProductNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	media, err := s.repo.ProductMedia(ctx, productID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return media, nil
+}
+
+// Open открывает содержимое файла, вызывающий закрывает его
+func (s *Service) Open(ctx context.Context, mediaID int64) (*models.ProductMedia, io.ReadCloser, error) {
+	const op = "media.Open"
+
+	media, err := s.repo.ProductMediaByID(ctx, mediaID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, ErrMediaNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	content, err := s.blobs.Open(ctx, media.StorageKey)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		s.logger.ErrorContext(ctx, "Файла нет в хранилище",
+			slog.String("op", op),
+			slog.Int64("media_id", mediaID),
+			slog.String("key", media.StorageKey),
+		)
+		return nil, nil, ErrMediaNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return media, content, nil
+}
+
+// Delete открепляет файл от продукта и удаляет его из хранилища
+func (s *Service) Delete(ctx context.Context, productID, mediaID int64) error {
+	const op = "media.Delete"
+
+	media, err := s.repo.DeleteProductMedia(ctx, productID, mediaID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrMediaNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.deleteBlob(ctx, media.StorageKey)
+
+	s.audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditProductMediaRemoved,
+		TargetType: models.TargetProduct,
+		TargetID:   strconv.FormatInt(productID, 10),
+		Details:    map[string]any{"media_id": mediaID, "kind": media.Kind},
+	})
+
+	return nil
+}
+
+// deleteBlob удаляет содержимое файла. Ошибка только логируется: запись о файле уже
+// удалена, и оставшийся в хранилище файл никому не отдается.
+func (s *Service) deleteBlob(ctx context.Context, key string) {
+	if err := s.blobs.Delete(ctx, key); err != nil {
+		s.logger.WarnContext(ctx, "Не удалось удалить файл из хранилища",
+			slog.String("key", key),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+func validate(upload Upload) error {
+	maxSize := int64(maxPhotoSize)
+	switch upload.Kind {
+	case models.MediaCover, models.MediaScreenshot:
+	case models.MediaSample:
+		maxSize = maxDocumentSize
+	default:
+		return fmt.Errorf("%w: вид файла cover, screenshot или sample", ErrInvalidMedia)
+	}
+
+	switch {
+	case upload.Size <= 0:
+		return fmt.Errorf("%w: файл пустой", ErrInvalidMedia)
+	case upload.Size > maxSize:
+		return fmt.Errorf("%w: файл больше %d МБ", ErrInvalidMedia, maxSize>>20)
+	case upload.FileName == "" || len(upload.FileName) > maxFileNameLength:
+		return fmt.Errorf("%w: длина имени файла от 1 до %d символов", ErrInvalidMedia, maxFileNameLength)
+	case upload.Position < 0:
+		return fmt.Errorf("%w: позиция не может быть отрицательной", ErrInvalidMedia)
+	}
+
+	return nil
+}
+
+func allowed(kind, contentType string) bool {
+	for _, t := range allowedTypes[kind] {
+		if t == contentType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func countKind(media []*models.ProductMedia, kind string) int {
+	n := 0
+	for _, m := range media {
+		if m.Kind == kind {
+			n++
+		}
+	}
+
+	return n
+}
+
+// randomName имя файла в хранилище: по нему нельзя угадать соседние файлы
+func randomName() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,200 @@
+package promo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+)
+
+const maxCodeLength = 32
+
+var (
+	ErrUserNotFound    = errors.New("пользователь не зарегистрирован")
+	ErrProductNotFound = errors.New("продукт не найден")
+	ErrInvalidPromo    = errors.New("неверные параметры промокода")
+	ErrCodeTaken       = errors.New("такой промокод уже существует")
+
+	ErrPromoNotFound     = errors.New("промокод не найден")
+	ErrPromoNotStarted   = errors.New("промокод еще не действует")
+	ErrPromoExpired      = errors.New("срок действия промокода истек")
+	ErrPromoWrongProduct = errors.New("промокод не действует на этот продукт")
+	ErrPromoWrongGroup   = errors.New("промокод действует только для другой группы")
+	ErrPromoExhausted    = errors.New("промокод больше нельзя использовать")
+)
+
+type Repository interface {
+	UserByTelegramID(ctx context.Context, telegramID int64) (*models.User, error)
+	ProductByID(ctx context.Context, productID int64) (*models.Product, error)
+	CreatePromoCode(ctx context.Context, promo *models.PromoCode) error
+	PromoCodeByCode(ctx context.Context, code string) (*models.PromoCode, error)
+	PromoUsage(ctx context.Context, promoID, userID int64) (*models.PromoUsage, error)
+}
+
+// Auditor журнал аудита
+type Auditor interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+type Service struct {
+	repo   Repository
+	audit  Auditor
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+func NewService(repo Repository, audit Auditor, logger *slog.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		audit:  audit,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// Create заводит новый промокод. Код хранится в верхнем регистре,
+// поэтому пользователь может вводить его в любом.
+func (s *Service) Create(ctx context.Context, promo *models.PromoCode) error {
+	const op = "promo.Create"
+
+	promo.Code = NormalizeCode(promo.Code)
+	if err := validate(promo); err != nil {
+		return err
+	}
+
+	if promo.ValidFrom.IsZero() {
+		promo.ValidFrom = s.now()
+	}
+
+	err := s.repo.CreatePromoCode(ctx, promo)
+	switch {
+	case errors.Is(err, repository.ErrConflict):
+		return ErrCodeTaken
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrProductNotFound
+	case err != nil:
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.logger.InfoContext(ctx, "Создан промокод", slog.String("op", op), slog.String("code", promo.Code))
+
+	details := map[string]any{
+		"code":              promo.Code,
+		"discount_type":     promo.DiscountType,
+		"discount_value":    promo.DiscountValue,
+		"max_uses_per_user": promo.MaxUsesPerUser,
+	}
+	if promo.ProductID != nil {
+		details["product_id"] = *promo.ProductID
+	}
+	if promo.MaxUses != nil {
+		details["max_uses"] = *promo.MaxUses
+	}
+	s.audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditPromoCreated,
+		TargetType: models.TargetPromo,
+		TargetID:   strconv.FormatInt(promo.ID, 10),
+		Details:    details,
+	})
+
+	return nil
+}
+
+// Quote считает цену продукта для пользователя с промокодом code
+func (s *Service) Quote(ctx context.Context, telegramID, productID int64, code string) (*models.PriceQuote, error) {
+	const op = "promo.Quote"
+
+	user, err := s.repo.UserByTelegramID(ctx, telegramID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	product, err := s.repo.ProductByID(ctx, productID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.Apply(ctx, user, product, code)
+}
+
+// Apply проверяет, что пользователь может применить промокод к продукту, и считает цену.
+// Лимиты использований окончательно проверяются при записи покупки.
+func (s *Service) Apply(ctx context.Context, user *models.User, product *models.Product, code string) (*models.PriceQuote, error) {
+	const op = "promo.Apply"
+
+	promo, err := s.repo.PromoCodeByCode(ctx, NormalizeCode(code))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrPromoNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := s.now()
+	switch {
+	case promo.ValidFrom.After(now):
+		return nil, ErrPromoNotStarted
+	case promo.ValidUntil != nil && !promo.ValidUntil.After(now):
+		return nil, ErrPromoExpired
+	case promo.ProductID != nil && *promo.ProductID != product.ID:
+		return nil, ErrPromoWrongProduct
+	case promo.Group != "" && !strings.EqualFold(promo.Group, user.Group):
+		return nil, ErrPromoWrongGroup
+	}
+
+	usage, err := s.repo.PromoUsage(ctx, promo.ID, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if (promo.MaxUses != nil && usage.Total >= *promo.MaxUses) || usage.ByUser >= promo.MaxUsesPerUser {
+		return nil, ErrPromoExhausted
+	}
+
+	discount := promo.Discount(product.Price)
+
+	return &models.PriceQuote{
+		ProductID:     product.ID,
+		OriginalPrice: product.Price,
+		Discount:      discount,
+		Price:         product.Price - discount,
+		PromoCode:     promo,
+	}, nil
+}
+
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func validate(promo *models.PromoCode) error {
+	switch {
+	case promo.Code == "" || len(promo.Code) > maxCodeLength:
+		return fmt.Errorf("%w: длина кода от 1 до %d символов", ErrInvalidPromo, maxCodeLength)
+	case promo.DiscountType != models.DiscountPercent && promo.DiscountType != models.DiscountFixed:
+		return fmt.Errorf("%w: тип скидки percent или fixed", ErrInvalidPromo)
+	case promo.DiscountValue <= 0:
+		return fmt.Errorf("%w: размер скидки должен быть положительным", ErrInvalidPromo)
+	case promo.DiscountType == models.DiscountPercent && promo.DiscountValue > 100:
+		return fmt.Errorf("%w: скидка в процентах не больше 100", ErrInvalidPromo)
+	case promo.MaxUses != nil && *promo.MaxUses <= 0:
+		return fmt.Errorf("%w: общий лимит использований должен быть положительным", ErrInvalidPromo)
+	case promo.MaxUsesPerUser <= 0:
+		return fmt.Errorf("%w: лимит на пользователя должен быть положительным", ErrInvalidPromo)
+	case promo.ValidUntil != nil && !promo.ValidFrom.IsZero() && !promo.ValidUntil.After(promo.ValidFrom):
+		return fmt.Errorf("%w: окончание действия раньше начала", ErrInvalidPromo)
+	}
+
+	return nil
+}
@@ -0,0 +1,477 @@
+package purchases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/payments"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/promo"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/seats"
+)
+
+var (
+	ErrUserNotFound     = errors.New("пользователь не зарегистрирован")
+	ErrProductNotFound  = errors.New("продукт не найден")
+	ErrPurchaseNotFound = errors.New("покупка не найдена")
+	ErrPaymentPending   = errors.New("оплата еще не поступила")
+	ErrPaymentCanceled  = errors.New("платеж отменен, оформите покупку заново")
+	ErrNotLeader        = errors.New("групповые лицензии покупают только старосты")
+	ErrAlreadyOwned     = errors.New("продукт уже куплен, лицензия активна")
+)
+
+type Repository interface {
+	UserByTelegramID(ctx context.Context, telegramID int64) (*models.User, error)
+	ProductByID(ctx context.Context, productID int64) (*models.Product, error)
+	CreatePurchase(ctx context.Context, purchase *models.Purchase, useBalance bool) error
+	PurchaseByID(ctx context.Context, purchaseID int64) (*models.Purchase, error)
+	CreateSeatPurchase(ctx context.Context, purchase *models.Purchase, pool *models.SeatPool) error
+	PendingPurchase(ctx context.Context, userID, productID int64, seats int) (*models.Purchase, error)
+	SetPurchasePayment(ctx context.Context, purchaseID int64, paymentID, paymentURL string) error
+	ConfirmPurchase(ctx context.Context, purchase *models.Purchase) error
+	CancelPurchase(ctx context.Context, purchase *models.Purchase) error
+	LicenseByUser(ctx context.Context, userID, productID int64) (*models.License, error)
+}
+
+// PaymentProvider принимает оплату части покупки сверх баланса
+type PaymentProvider interface {
+	CreatePayment(ctx context.Context, purchaseID, amount int64, description string) (*payments.Payment, error)
+	PaymentStatus(ctx context.Context, paymentID string) (string, error)
+}
+
+type PromoApplier interface {
+	Apply(ctx context.Context, user *models.User, product *models.Product, code string) (*models.PriceQuote, error)
+}
+
+// PaidHook вызывается после того, как покупка оплачена: провайдер подтвердил платеж
+// или вся сумма списана с баланса
+type PaidHook interface {
+	OnPurchasePaid(ctx context.Context, purchase *models.Purchase) error
+}
+
+// Auditor журнал аудита
+type Auditor interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+type Service struct {
+	repo     Repository
+	provider PaymentProvider
+	promo    PromoApplier
+	audit    Auditor
+	hooks    []PaidHook
+	logger   *slog.Logger
+}
+
+func NewService(repo Repository, provider PaymentProvider, promo PromoApplier, audit Auditor, logger *slog.Logger, hooks ...PaidHook) *Service {
+	return &Service{
+		repo:     repo,
+		provider: provider,
+		promo:    promo,
+		audit:    audit,
+		hooks:    hooks,
+		logger:   logger,
+	}
+}
+
+// Buy оформляет покупку продукта с необязательным промокодом. Если useBalance, сумма частично
+// или полностью списывается с внутреннего баланса. Покупка, которую баланс покрывает целиком,
+// сразу оплачена и выдает лицензию. Иначе на остаток создается платеж у провайдера, и покупка
+// ждет подтверждения оплаты в Confirm. Если неоплаченная покупка продукта уже есть, возвращается она.
+// Разовый продукт с активной лицензией повторно не продается: ErrAlreadyOwned.
+func (s *Service) Buy(ctx context.Context, telegramID, productID int64, promoCode string, useBalance bool) (*models.Purchase, error) {
+	const op = "purchases.Buy"
+	logger := s.logger.With(slog.String("op", op))
+
+	user, err := s.repo.UserByTelegramID(ctx, telegramID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	product, err := s.repo.ProductByID(ctx, productID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Подписку можно продлевать, а разовая покупка второй раз ничего не дает
+	if !product.IsSubscription() {
+		license, err := s.repo.LicenseByUser(ctx, user.ID, product.ID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if err == nil && license.Status == models.LicenseActive {
+			return nil, ErrAlreadyOwned
+		}
+	}
+
+	// Начатая покупка продолжается до промокода: одноразовый промокод уже погашен ею,
+	// и повторное применение вернуло бы ErrPromoExhausted
+	_, err = s.repo.PendingPurchase(ctx, user.ID, product.ID, 0)
+	if err == nil {
+		return s.resumePending(ctx, user, product, 0)
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	purchase := &models.Purchase{
+		UserID:        user.ID,
+		ProductID:     product.ID,
+		OriginalPrice: product.Price,
+		Amount:        product.Price,
+	}
+
+	if promoCode != "" {
+		quote, err := s.promo.Apply(ctx, user, product, promoCode)
+		if err != nil {
+			return nil, err
+		}
+
+		purchase.Discount = quote.Discount
+		purchase.Amount = quote.Price
+		purchase.PromoCodeID = &quote.PromoCode.ID
+	}
+
+	err = s.repo.CreatePurchase(ctx, purchase, useBalance)
+	if errors.Is(err, repository.ErrConflict) {
+		return s.resumePending(ctx, user, product, 0)
+	}
+	if errors.Is(err, repository.ErrLimitExceeded) {
+		return nil, promo.ErrPromoExhausted
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if purchase.Status == models.PurchasePaid {
+		logger.InfoContext(ctx, "Покупка оплачена с баланса",
+			slog.Int64("purchase_id", purchase.ID),
+			slog.Int64("product_id", product.ID),
+			slog.Int64("amount", purchase.Amount),
+		)
+
+		s.recordPurchase(ctx, telegramID, purchase)
+		s.runPaidHooks(ctx, purchase)
+
+		return purchase, nil
+	}
+
+	if err := s.createPayment(ctx, purchase, product); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	logger.InfoContext(ctx, "Покупка ожидает оплаты",
+		slog.Int64("purchase_id", purchase.ID),
+		slog.Int64("product_id", product.ID),
+		slog.Int64("amount", purchase.Amount),
+		slog.Int64("balance_used", purchase.BalanceUsed),
+		slog.String("payment_id", purchase.ProviderPaymentID),
+	)
+
+	return purchase, nil
+}
+
+// BuySeats оформляет покупку групповой лицензии на seats мест. Покупать может только
+// староста, назначенный администратором. Групповая лицензия открывается для студентов
+// после того, как провайдер подтвердит оплату в Confirm. Если restrictGroup, занять
+// место смогут только студенты из группы старосты.
+func (s *Service) BuySeats(ctx context.Context, telegramID, productID int64, seatCount int, restrictGroup bool) (*models.Purchase, error) {
+	const op = "purchases.BuySeats"
+
+	if seatCount <= 0 || seatCount > seats.MaxSeats {
+		return nil, seats.ErrInvalidSeats
+	}
+
+	user, err := s.repo.UserByTelegramID(ctx, telegramID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !user.IsLeader {
+		return nil, ErrNotLeader
+	}
+
+	product, err := s.repo.ProductByID(ctx, productID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	code, err := seats.NewInviteCode()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	price := product.Price * int64(seatCount)
+	purchase := &models.Purchase{
+		UserID:        user.ID,
+		ProductID:     product.ID,
+		OriginalPrice: price,
+		Amount:        price,
+		Seats:         seatCount,
+	}
+	pool := &models.SeatPool{
+		ProductID:   product.ID,
+		OwnerUserID: user.ID,
+		Seats:       seatCount,
+		InviteCode:  code,
+	}
+	if restrictGroup {
+		pool.Group = user.Group
+	}
+
+	err = s.repo.CreateSeatPurchase(ctx, purchase, pool)
+	if errors.Is(err, repository.ErrConflict) {
+		return s.resumePending(ctx, user, product, seatCount)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Бесплатный продукт оплачивать нечем, пул открывается сразу
+	if purchase.Status == models.PurchasePaid {
+		s.recordPurchase(ctx, telegramID, purchase)
+		s.runPaidHooks(ctx, purchase)
+
+		return purchase, nil
+	}
+
+	if err := s.createPayment(ctx, purchase, product); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.logger.InfoContext(ctx, "Покупка мест ожидает оплаты",
+		slog.String("op", op),
+		slog.Int64("purchase_id", purchase.ID),
+		slog.Int64("product_id", product.ID),
+		slog.Int("seats", seatCount),
+		slog.Int64("amount", purchase.Amount),
+		slog.String("payment_id", purchase.ProviderPaymentID),
+	)
+
+	return purchase, nil
+}
+
+// Confirm проверяет у провайдера оплату покупки пользователя. Покупка становится оплаченной
+// и выдает лицензию только после того, как провайдер подтвердил платеж.
+// Пока платеж не прошел, возвращает ErrPaymentPending.
+func (s *Service) Confirm(ctx context.Context, telegramID, purchaseID int64) (*models.Purchase, error) {
+	const op = "purchases.Confirm"
+
+	user, err := s.repo.UserByTelegramID(ctx, telegramID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	purchase, err := s.repo.PurchaseByID(ctx, purchaseID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrPurchaseNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Чужая покупка для пользователя не существует
+	if purchase.UserID != user.ID {
+		return nil, ErrPurchaseNotFound
+	}
+
+	switch purchase.Status {
+	case models.PurchasePending:
+	case models.PurchaseCanceled:
+		return nil, ErrPaymentCanceled
+	default:
+		return purchase, nil
+	}
+
+	if err := s.settle(ctx, telegramID, purchase); err != nil {
+		return nil, err
+	}
+
+	return purchase, nil
+}
+
+// resumePending возвращает уже начатую покупку продукта (seats мест для групповой лицензии).
+// Если ее платеж отменен, покупка отменяется с ErrPaymentCanceled, и следующая попытка
+// оформит новую.
+func (s *Service) resumePending(ctx context.Context, user *models.User, product *models.Product, seats int) (*models.Purchase, error) {
+	const op = "purchases.resumePending"
+
+	purchase, err := s.repo.PendingPurchase(ctx, user.ID, product.ID, seats)
+	if errors.Is(err, repository.ErrNotFound) {
+		// Покупку успели оплатить или отменить между попытками
+		return nil, ErrPaymentPending
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Прошлая попытка не дошла до провайдера
+	if purchase.ProviderPaymentID == "" {
+		if err := s.createPayment(ctx, purchase, product); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return purchase, nil
+	}
+
+	err = s.settle(ctx, user.TelegramID, purchase)
+	if errors.Is(err, ErrPaymentPending) {
+		return purchase, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return purchase, nil
+}
+
+// createPayment создает у провайдера платеж на часть суммы сверх баланса
+func (s *Service) createPayment(ctx context.Context, purchase *models.Purchase, product *models.Product) error {
+	payment, err := s.provider.CreatePayment(ctx, purchase.ID, purchase.Amount-purchase.BalanceUsed, product.Name)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.SetPurchasePayment(ctx, purchase.ID, payment.ID, payment.URL); err != nil {
+		return err
+	}
+
+	purchase.ProviderPaymentID = payment.ID
+	purchase.PaymentURL = payment.URL
+
+	return nil
+}
+
+// settle сверяет неоплаченную покупку со статусом платежа у провайдера:
+// подтвержденный платеж завершает покупку, отмененный отменяет ее
+func (s *Service) settle(ctx context.Context, telegramID int64, purchase *models.Purchase) error {
+	const op = "purchases.settle"
+	logger := s.logger.With(slog.String("op", op), slog.Int64("purchase_id", purchase.ID))
+
+	if purchase.ProviderPaymentID == "" {
+		return ErrPaymentPending
+	}
+
+	status, err := s.provider.PaymentStatus(ctx, purchase.ProviderPaymentID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	switch status {
+	case payments.PaymentSucceeded:
+		err := s.repo.ConfirmPurchase(ctx, purchase)
+		if errors.Is(err, repository.ErrConflict) {
+			// Параллельный запрос уже завершил покупку и запустил хуки
+			current, err := s.repo.PurchaseByID(ctx, purchase.ID)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			*purchase = *current
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		logger.InfoContext(ctx, "Покупка оплачена",
+			slog.Int64("product_id", purchase.ProductID),
+			slog.Int64("amount", purchase.Amount),
+			slog.Int64("balance_used", purchase.BalanceUsed),
+		)
+
+		s.recordPurchase(ctx, telegramID, purchase)
+		s.runPaidHooks(ctx, purchase)
+
+		return nil
+	case payments.PaymentCanceled:
+		err := s.repo.CancelPurchase(ctx, purchase)
+		if err != nil && !errors.Is(err, repository.ErrConflict) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		logger.InfoContext(ctx, "Платеж отменен, покупка отменена")
+
+		return ErrPaymentCanceled
+	}
+
+	return ErrPaymentPending
+}
+
+func (s *Service) recordPurchase(ctx context.Context, telegramID int64, purchase *models.Purchase) {
+	actorID := strconv.FormatInt(telegramID, 10)
+
+	s.audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditPurchaseCompleted,
+		ActorType:  models.ActorUser,
+		ActorID:    actorID,
+		TargetType: models.TargetPurchase,
+		TargetID:   strconv.FormatInt(purchase.ID, 10),
+		Details: map[string]any{
+			"product_id":   purchase.ProductID,
+			"amount":       purchase.Amount,
+			"discount":     purchase.Discount,
+			"balance_used": purchase.BalanceUsed,
+			"payment_id":   purchase.ProviderPaymentID,
+		},
+	})
+
+	if purchase.SeatPoolID != nil {
+		s.audit.Record(ctx, models.AuditEvent{
+			Action:     models.AuditSeatPoolCreated,
+			ActorType:  models.ActorUser,
+			ActorID:    actorID,
+			TargetType: models.TargetSeatPool,
+			TargetID:   strconv.FormatInt(*purchase.SeatPoolID, 10),
+			Details: map[string]any{
+				"product_id":  purchase.ProductID,
+				"seats":       purchase.Seats,
+				"purchase_id": purchase.ID,
+			},
+		})
+	}
+
+	if purchase.LicenseID != nil {
+		s.audit.Record(ctx, models.AuditEvent{
+			Action:     models.AuditLicenseIssued,
+			ActorType:  models.ActorUser,
+			ActorID:    actorID,
+			TargetType: models.TargetLicense,
+			TargetID:   strconv.FormatInt(*purchase.LicenseID, 10),
+			Details: map[string]any{
+				"user_id":     purchase.UserID,
+				"product_id":  purchase.ProductID,
+				"purchase_id": purchase.ID,
+			},
+		})
+	}
+}
+
+// runPaidHooks ошибки хуков не отменяют уже оплаченную покупку, поэтому только логируем их
+func (s *Service) runPaidHooks(ctx context.Context, purchase *models.Purchase) {
+	for _, hook := range s.hooks {
+		if err := hook.OnPurchasePaid(ctx, purchase); err != nil {
+			s.logger.ErrorContext(ctx, "Ошибка обработки оплаченной покупки",
+				slog.Int64("purchase_id", purchase.ID),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+}
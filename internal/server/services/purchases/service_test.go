@@ -0,0 +1,497 @@
+package purchases
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/payments"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/promo"
+	"github.com/GeorgeTyupin/labguard/internal/server/services/seats"
+)
+
+const (
+	testTelegramID = 100
+	testUserID     = 1
+	leaderTelegram = 200 // Староста той же группы, может покупать места
+	leaderUserID   = 2
+	testProductID  = 7
+	testPrice      = 50000
+)
+
+// fakeRepo покупки в памяти, баланс кошелька списывается так же, как в Postgres:
+// целиком покрытая покупка сразу оплачена, иначе остается pending
+type fakeRepo struct {
+	balance   int64
+	purchases map[int64]*models.Purchase
+	pools     []*models.SeatPool
+	licenses  map[int64]*models.License // Лицензии на testProductID по id пользователя
+}
+
+func newFakeRepo(balance int64) *fakeRepo {
+	return &fakeRepo{
+		balance:   balance,
+		purchases: make(map[int64]*models.Purchase),
+		licenses:  make(map[int64]*models.License),
+	}
+}
+
+func (r *fakeRepo) UserByTelegramID(_ context.Context, telegramID int64) (*models.User, error) {
+	switch telegramID {
+	case testTelegramID:
+		return &models.User{ID: testUserID, TelegramID: testTelegramID, Group: "ИУ7-31"}, nil
+	case leaderTelegram:
+		return &models.User{ID: leaderUserID, TelegramID: leaderTelegram, Group: "ИУ7-31", IsLeader: true}, nil
+	}
+
+	return &models.User{ID: telegramID, TelegramID: telegramID}, nil
+}
+
+func (r *fakeRepo) ProductByID(_ context.Context, productID int64) (*models.Product, error) {
+	if productID != testProductID {
+		return nil, repository.ErrNotFound
+	}
+
+	return &models.Product{ID: testProductID, Name: "Лабораторная", Price: testPrice}, nil
+}
+
+func (r *fakeRepo) CreatePurchase(_ context.Context, purchase *models.Purchase, useBalance bool) error {
+	for _, p := range r.purchases {
+		if p.UserID == purchase.UserID && p.ProductID == purchase.ProductID && p.Seats == purchase.Seats &&
+			p.Status == models.PurchasePending {
+			return repository.ErrConflict
+		}
+	}
+
+	if useBalance {
+		purchase.BalanceUsed = min(r.balance, purchase.Amount)
+	}
+	r.balance -= purchase.BalanceUsed
+
+	purchase.ID = int64(len(r.purchases) + 1)
+	purchase.Status = models.PurchasePending
+	if purchase.BalanceUsed == purchase.Amount {
+		purchase.Status = models.PurchasePaid
+	}
+
+	stored := *purchase
+	r.purchases[purchase.ID] = &stored
+
+	return nil
+}
+
+func (r *fakeRepo) CreateSeatPurchase(ctx context.Context, purchase *models.Purchase, pool *models.SeatPool) error {
+	if err := r.CreatePurchase(ctx, purchase, false); err != nil {
+		return err
+	}
+
+	pool.ID = purchase.ID * 10
+	purchase.SeatPoolID = &pool.ID
+	r.purchases[purchase.ID].SeatPoolID = &pool.ID
+	r.pools = append(r.pools, pool)
+
+	return nil
+}
+
+func (r *fakeRepo) PurchaseByID(_ context.Context, purchaseID int64) (*models.Purchase, error) {
+	p, ok := r.purchases[purchaseID]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	purchase := *p
+	return &purchase, nil
+}
+
+func (r *fakeRepo) PendingPurchase(_ context.Context, userID, productID int64, seats int) (*models.Purchase, error) {
+	for _, p := range r.purchases {
+		if p.UserID == userID && p.ProductID == productID && p.Seats == seats && p.Status == models.PurchasePending {
+			purchase := *p
+			return &purchase, nil
+		}
+	}
+
+	return nil, repository.ErrNotFound
+}
+
+func (r *fakeRepo) SetPurchasePayment(_ context.Context, purchaseID int64, paymentID, paymentURL string) error {
+	p := r.purchases[purchaseID]
+	p.ProviderPaymentID, p.PaymentURL = paymentID, paymentURL
+
+	return nil
+}
+
+func (r *fakeRepo) ConfirmPurchase(_ context.Context, purchase *models.Purchase) error {
+	p := r.purchases[purchase.ID]
+	if p.Status != models.PurchasePending {
+		return repository.ErrConflict
+	}
+
+	p.Status = models.PurchasePaid
+	purchase.Status = models.PurchasePaid
+
+	return nil
+}
+
+func (r *fakeRepo) CancelPurchase(_ context.Context, purchase *models.Purchase) error {
+	p := r.purchases[purchase.ID]
+	if p.Status != models.PurchasePending {
+		return repository.ErrConflict
+	}
+
+	p.Status = models.PurchaseCanceled
+	purchase.Status = models.PurchaseCanceled
+	r.balance += p.BalanceUsed
+
+	return nil
+}
+
+func (r *fakeRepo) LicenseByUser(_ context.Context, userID, productID int64) (*models.License, error) {
+	license, ok := r.licenses[userID]
+	if !ok || productID != testProductID {
+		return nil, repository.ErrNotFound
+	}
+
+	return license, nil
+}
+
+// fakeProvider отдает для всех платежей статус status
+type fakeProvider struct {
+	status  string
+	created []int64 // Суммы созданных платежей
+}
+
+func (p *fakeProvider) CreatePayment(_ context.Context, purchaseID, amount int64, _ string) (*payments.Payment, error) {
+	p.created = append(p.created, amount)
+
+	return &payments.Payment{ID: "pay-1", URL: "https://pay.example/1"}, nil
+}
+
+func (p *fakeProvider) PaymentStatus(context.Context, string) (string, error) {
+	return p.status, nil
+}
+
+type countingHook struct {
+	paid []*models.Purchase
+}
+
+func (h *countingHook) OnPurchasePaid(_ context.Context, purchase *models.Purchase) error {
+	h.paid = append(h.paid, purchase)
+	return nil
+}
+
+type nopPromo struct{}
+
+func (nopPromo) Apply(context.Context, *models.User, *models.Product, string) (*models.PriceQuote, error) {
+	return nil, errors.New("промокоды в тесте не используются")
+}
+
+// singleUsePromo одноразовый промокод: второе применение возвращает ErrPromoExhausted
+type singleUsePromo struct {
+	used int
+}
+
+func (p *singleUsePromo) Apply(_ context.Context, _ *models.User, product *models.Product, _ string) (*models.PriceQuote, error) {
+	p.used++
+	if p.used > 1 {
+		return nil, promo.ErrPromoExhausted
+	}
+
+	return &models.PriceQuote{
+		ProductID:     product.ID,
+		OriginalPrice: product.Price,
+		Discount:      product.Price / 2,
+		Price:         product.Price - product.Price/2,
+		PromoCode:     &models.PromoCode{ID: 1},
+	}, nil
+}
+
+type nopAuditor struct{}
+
+func (nopAuditor) Record(context.Context, models.AuditEvent) {}
+
+func newTestService(balance int64, status string) (*Service, *fakeRepo, *fakeProvider, *countingHook) {
+	repo := newFakeRepo(balance)
+	provider := &fakeProvider{status: status}
+	hook := &countingHook{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	return NewService(repo, provider, nopPromo{}, nopAuditor{}, logger, hook), repo, provider, hook
+}
+
+func TestBuy(t *testing.T) {
+	tests := []struct {
+		name        string
+		balance     int64
+		useBalance  bool
+		status      string
+		balanceUsed int64
+		payment     int64 // Сумма платежа у провайдера, 0 — платеж не создается
+		hooks       int
+	}{
+		{
+			name:    "без баланса ждет оплаты",
+			balance: 100000,
+			status:  models.PurchasePending,
+			payment: testPrice,
+		},
+		{
+			name:        "баланса не хватает",
+			balance:     20000,
+			useBalance:  true,
+			status:      models.PurchasePending,
+			balanceUsed: 20000,
+			payment:     testPrice - 20000,
+		},
+		{
+			name:        "баланс покрывает всю сумму",
+			balance:     100000,
+			useBalance:  true,
+			status:      models.PurchasePaid,
+			balanceUsed: testPrice,
+			hooks:       1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, provider, hook := newTestService(tt.balance, payments.PaymentPending)
+
+			purchase, err := s.Buy(context.Background(), testTelegramID, testProductID, "", tt.useBalance)
+			if err != nil {
+				t.Fatalf("Buy: %v", err)
+			}
+
+			if purchase.Status != tt.status || purchase.BalanceUsed != tt.balanceUsed {
+				t.Fatalf("получено status=%s balance_used=%d, ожидалось status=%s balance_used=%d",
+					purchase.Status, purchase.BalanceUsed, tt.status, tt.balanceUsed)
+			}
+
+			var created []int64
+			if tt.payment > 0 {
+				created = []int64{tt.payment}
+			}
+			if len(provider.created) != len(created) || (len(created) > 0 && provider.created[0] != created[0]) {
+				t.Fatalf("платежи у провайдера %v, ожидалось %v", provider.created, created)
+			}
+
+			if len(hook.paid) != tt.hooks {
+				t.Fatalf("хуки оплаты вызваны %d раз, ожидалось %d", len(hook.paid), tt.hooks)
+			}
+		})
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string // Статус платежа у провайдера
+		err      error
+		purchase string // Статус покупки после подтверждения
+		hooks    int
+	}{
+		{name: "платеж не прошел", status: payments.PaymentPending, err: ErrPaymentPending, purchase: models.PurchasePending},
+		{name: "платеж отменен", status: payments.PaymentCanceled, err: ErrPaymentCanceled, purchase: models.PurchaseCanceled},
+		{name: "платеж подтвержден", status: payments.PaymentSucceeded, purchase: models.PurchasePaid, hooks: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo, provider, hook := newTestService(20000, payments.PaymentPending)
+			ctx := context.Background()
+
+			purchase, err := s.Buy(ctx, testTelegramID, testProductID, "", true)
+			if err != nil {
+				t.Fatalf("Buy: %v", err)
+			}
+
+			provider.status = tt.status
+			if _, err := s.Confirm(ctx, testTelegramID, purchase.ID); !errors.Is(err, tt.err) {
+				t.Fatalf("Confirm: ошибка %v, ожидалась %v", err, tt.err)
+			}
+
+			// Повторное подтверждение не запускает хуки второй раз
+			s.Confirm(ctx, testTelegramID, purchase.ID)
+
+			if got := repo.purchases[purchase.ID].Status; got != tt.purchase {
+				t.Fatalf("статус покупки %s, ожидался %s", got, tt.purchase)
+			}
+			if len(hook.paid) != tt.hooks {
+				t.Fatalf("хуки оплаты вызваны %d раз, ожидалось %d", len(hook.paid), tt.hooks)
+			}
+			if tt.status == payments.PaymentCanceled && repo.balance != 20000 {
+				t.Fatalf("после отмены баланс %d, ожидалось возвращение резерва 20000", repo.balance)
+			}
+		})
+	}
+}
+
+func TestConfirmForeignPurchase(t *testing.T) {
+	s, _, _, _ := newTestService(0, payments.PaymentSucceeded)
+	ctx := context.Background()
+
+	purchase, err := s.Buy(ctx, testTelegramID, testProductID, "", false)
+	if err != nil {
+		t.Fatalf("Buy: %v", err)
+	}
+
+	if _, err := s.Confirm(ctx, testTelegramID+1, purchase.ID); !errors.Is(err, ErrPurchaseNotFound) {
+		t.Fatalf("ожидалась ErrPurchaseNotFound, получено %v", err)
+	}
+}
+
+func TestBuyResumesPending(t *testing.T) {
+	s, repo, provider, _ := newTestService(0, payments.PaymentPending)
+	ctx := context.Background()
+
+	first, err := s.Buy(ctx, testTelegramID, testProductID, "", false)
+	if err != nil {
+		t.Fatalf("Buy: %v", err)
+	}
+
+	second, err := s.Buy(ctx, testTelegramID, testProductID, "", false)
+	if err != nil {
+		t.Fatalf("повторный Buy: %v", err)
+	}
+
+	if second.ID != first.ID || len(repo.purchases) != 1 || len(provider.created) != 1 {
+		t.Fatalf("повторная покупка создала новую: покупок %d, платежей %d", len(repo.purchases), len(provider.created))
+	}
+}
+
+func TestBuyResumesPendingWithPromo(t *testing.T) {
+	s, repo, provider, _ := newTestService(0, payments.PaymentPending)
+	s.promo = &singleUsePromo{}
+	ctx := context.Background()
+
+	first, err := s.Buy(ctx, testTelegramID, testProductID, "HALF", false)
+	if err != nil {
+		t.Fatalf("Buy: %v", err)
+	}
+
+	// Промокод уже погашен первой попыткой, повторная продолжает ее со скидкой
+	second, err := s.Buy(ctx, testTelegramID, testProductID, "HALF", false)
+	if err != nil {
+		t.Fatalf("повторный Buy: %v", err)
+	}
+
+	if second.ID != first.ID || second.Amount != testPrice/2 || len(repo.purchases) != 1 || len(provider.created) != 1 {
+		t.Fatalf("повторная покупка %+v, ожидалась прежняя %d за %d", second, first.ID, testPrice/2)
+	}
+}
+
+func TestBuyAlreadyOwned(t *testing.T) {
+	tests := []struct {
+		name    string
+		license string // Статус лицензии пользователя, пусто — лицензии нет
+		err     error
+	}{
+		{name: "активная лицензия", license: models.LicenseActive, err: ErrAlreadyOwned},
+		{name: "отозванная лицензия", license: models.LicenseRevoked},
+		{name: "лицензии нет"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo, provider, _ := newTestService(0, payments.PaymentPending)
+			if tt.license != "" {
+				repo.licenses[testUserID] = &models.License{ID: 1, UserID: testUserID, ProductID: testProductID, Status: tt.license}
+			}
+
+			_, err := s.Buy(context.Background(), testTelegramID, testProductID, "", false)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Buy: ошибка %v, ожидалась %v", err, tt.err)
+			}
+
+			if tt.err != nil && (len(repo.purchases) != 0 || len(provider.created) != 0) {
+				t.Fatalf("отказанная покупка записана: покупок %d, платежей %d", len(repo.purchases), len(provider.created))
+			}
+		})
+	}
+}
+
+func TestBuySeats(t *testing.T) {
+	tests := []struct {
+		name          string
+		telegramID    int64
+		seats         int
+		restrictGroup bool
+		err           error
+		group         string
+	}{
+		{name: "староста", telegramID: leaderTelegram, seats: 25},
+		{name: "только своя группа", telegramID: leaderTelegram, seats: 25, restrictGroup: true, group: "ИУ7-31"},
+		{name: "не староста", telegramID: testTelegramID, seats: 25, err: ErrNotLeader},
+		{name: "ноль мест", telegramID: leaderTelegram, seats: 0, err: seats.ErrInvalidSeats},
+		{name: "слишком много мест", telegramID: leaderTelegram, seats: seats.MaxSeats + 1, err: seats.ErrInvalidSeats},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo, provider, hook := newTestService(0, payments.PaymentPending)
+
+			purchase, err := s.BuySeats(context.Background(), tt.telegramID, testProductID, tt.seats, tt.restrictGroup)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("BuySeats: ошибка %v, ожидалась %v", err, tt.err)
+			}
+
+			if tt.err != nil {
+				if len(repo.purchases) != 0 || len(repo.pools) != 0 || len(provider.created) != 0 {
+					t.Fatalf("отказанная покупка мест записана: покупок %d, пулов %d, платежей %d",
+						len(repo.purchases), len(repo.pools), len(provider.created))
+				}
+				return
+			}
+
+			amount := int64(testPrice * tt.seats)
+			if purchase.Status != models.PurchasePending || purchase.Amount != amount || purchase.Seats != tt.seats {
+				t.Fatalf("покупка %+v, ожидалась pending на %d мест за %d", purchase, tt.seats, amount)
+			}
+			if len(provider.created) != 1 || provider.created[0] != amount {
+				t.Fatalf("платежи у провайдера %v, ожидался один на %d", provider.created, amount)
+			}
+			if len(hook.paid) != 0 {
+				t.Fatal("хуки оплаты вызваны до оплаты")
+			}
+
+			if len(repo.pools) != 1 {
+				t.Fatalf("создано пулов %d, ожидался один", len(repo.pools))
+			}
+			pool := repo.pools[0]
+			if pool.OwnerUserID != leaderUserID || pool.Seats != tt.seats || pool.Group != tt.group || pool.InviteCode == "" {
+				t.Fatalf("пул %+v, ожидался на %d мест для группы %q", pool, tt.seats, tt.group)
+			}
+			if purchase.SeatPoolID == nil || *purchase.SeatPoolID != pool.ID {
+				t.Fatalf("покупка ссылается на пул %v, ожидался %d", purchase.SeatPoolID, pool.ID)
+			}
+		})
+	}
+}
+
+func TestBuySeatsResumesPending(t *testing.T) {
+	s, repo, provider, _ := newTestService(0, payments.PaymentPending)
+	ctx := context.Background()
+
+	// Личная покупка того же продукта не мешает покупке мест
+	if _, err := s.Buy(ctx, leaderTelegram, testProductID, "", false); err != nil {
+		t.Fatalf("Buy: %v", err)
+	}
+
+	first, err := s.BuySeats(ctx, leaderTelegram, testProductID, 10, false)
+	if err != nil {
+		t.Fatalf("BuySeats: %v", err)
+	}
+
+	second, err := s.BuySeats(ctx, leaderTelegram, testProductID, 10, false)
+	if err != nil {
+		t.Fatalf("повторный BuySeats: %v", err)
+	}
+
+	if second.ID != first.ID || len(repo.pools) != 1 || len(provider.created) != 2 {
+		t.Fatalf("повторная покупка мест создала новую: пулов %d, платежей %d", len(repo.pools), len(provider.created))
+	}
+}
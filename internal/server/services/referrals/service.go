@@ -0,0 +1,140 @@
+package referrals
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+)
+
+var ErrUserNotFound = errors.New("пользователь не зарегистрирован")
+
+type Repository interface {
+	UserByID(ctx context.Context, userID int64) (*models.User, error)
+	UserByTelegramID(ctx context.Context, telegramID int64) (*models.User, error)
+	IsFirstPaidPurchase(ctx context.Context, userID, purchaseID int64) (bool, error)
+	CreateReferralReward(ctx context.Context, reward *models.ReferralReward) error
+	ReferralStats(ctx context.Context, userID int64) (*models.ReferralStats, error)
+	CreateNotification(ctx context.Context, telegramID int64, text string) error
+}
+
+type Service struct {
+	repo          Repository
+	logger        *slog.Logger
+	rewardPercent atomic.Int64
+}
+
+func NewService(repo Repository, logger *slog.Logger, rewardPercent int64) *Service {
+	s := &Service{
+		repo:   repo,
+		logger: logger,
+	}
+	s.rewardPercent.Store(rewardPercent)
+
+	return s
+}
+
+// SetRewardPercent меняет процент бонуса при перезагрузке конфига
+func (s *Service) SetRewardPercent(percent int64) {
+	s.rewardPercent.Store(percent)
+}
+
+// OnPurchasePaid начисляет бонус пригласившему, если это первая оплаченная покупка приглашенного
+func (s *Service) OnPurchasePaid(ctx context.Context, purchase *models.Purchase) error {
+	const op = "referrals.OnPurchasePaid"
+	logger := s.logger.With(slog.String("op", op), slog.Int64("purchase_id", purchase.ID))
+
+	amount := rewardAmount(purchase, s.rewardPercent.Load())
+	if amount <= 0 {
+		return nil
+	}
+
+	referee, err := s.repo.UserByID(ctx, purchase.UserID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if referee.ReferredBy == nil {
+		return nil
+	}
+
+	first, err := s.repo.IsFirstPaidPurchase(ctx, referee.ID, purchase.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !first {
+		return nil
+	}
+
+	reward := &models.ReferralReward{
+		ReferrerID: *referee.ReferredBy,
+		RefereeID:  referee.ID,
+		PurchaseID: purchase.ID,
+		Amount:     amount,
+	}
+
+	err = s.repo.CreateReferralReward(ctx, reward)
+	if errors.Is(err, repository.ErrConflict) {
+		logger.InfoContext(ctx, "Бонус за этого приглашенного уже начислен")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	referrer, err := s.repo.UserByID(ctx, reward.ReferrerID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	text := fmt.Sprintf("🎉 Приглашенный вами пользователь совершил первую покупку. На баланс начислен бонус %s, им можно оплатить покупки.", models.FormatRubles(reward.Amount))
+	if err := s.repo.CreateNotification(ctx, referrer.TelegramID, text); err != nil {
+		logger.ErrorContext(ctx, "Не удалось уведомить пригласившего", slog.String("error", err.Error()))
+	}
+
+	logger.InfoContext(ctx, "Начислен реферальный бонус",
+		slog.Int64("referrer_id", reward.ReferrerID),
+		slog.Int64("amount", reward.Amount),
+	)
+
+	return nil
+}
+
+func (s *Service) Stats(ctx context.Context, telegramID int64) (*models.ReferralStats, error) {
+	const op = "referrals.Stats"
+
+	user, err := s.repo.UserByTelegramID(ctx, telegramID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stats, err := s.repo.ReferralStats(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+// rewardAmount бонус за покупку. Он считается только от денег, списание которых подтвердил
+// платежный провайдер: бесплатные покупки, неподтвержденные платежи и оплата бонусным
+// балансом его не приносят, иначе бонусы можно было бы крутить по кругу.
+func rewardAmount(purchase *models.Purchase, percent int64) int64 {
+	if purchase.Status != models.PurchasePaid || purchase.ProviderPaymentID == "" {
+		return 0
+	}
+
+	charged := purchase.Amount - purchase.BalanceUsed
+	if charged <= 0 {
+		return 0
+	}
+
+	return charged * percent / 100
+}
@@ -0,0 +1,138 @@
+package referrals
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+)
+
+const (
+	referrerID = 1
+	refereeID  = 2
+)
+
+type fakeRepo struct {
+	first   bool
+	rewards []*models.ReferralReward
+}
+
+func (r *fakeRepo) UserByID(_ context.Context, userID int64) (*models.User, error) {
+	user := &models.User{ID: userID, TelegramID: userID * 100}
+	if userID == refereeID {
+		referredBy := int64(referrerID)
+		user.ReferredBy = &referredBy
+	}
+
+	return user, nil
+}
+
+func (r *fakeRepo) UserByTelegramID(context.Context, int64) (*models.User, error) {
+	return nil, repository.ErrNotFound
+}
+
+func (r *fakeRepo) IsFirstPaidPurchase(context.Context, int64, int64) (bool, error) {
+	return r.first, nil
+}
+
+func (r *fakeRepo) CreateReferralReward(_ context.Context, reward *models.ReferralReward) error {
+	r.rewards = append(r.rewards, reward)
+	return nil
+}
+
+func (r *fakeRepo) ReferralStats(context.Context, int64) (*models.ReferralStats, error) {
+	return &models.ReferralStats{}, nil
+}
+
+func (r *fakeRepo) CreateNotification(context.Context, int64, string) error {
+	return nil
+}
+
+func TestOnPurchasePaid(t *testing.T) {
+	confirmed := func(amount, balanceUsed int64) models.Purchase {
+		return models.Purchase{
+			ID:                10,
+			UserID:            refereeID,
+			Amount:            amount,
+			BalanceUsed:       balanceUsed,
+			Status:            models.PurchasePaid,
+			ProviderPaymentID: "pay-10",
+		}
+	}
+
+	tests := []struct {
+		name     string
+		purchase models.Purchase
+		first    bool
+		reward   int64 // 0 — бонус не начисляется
+	}{
+		{
+			name:     "оплата картой",
+			purchase: confirmed(50000, 0),
+			first:    true,
+			reward:   5000,
+		},
+		{
+			name:     "бонус только от части, оплаченной картой",
+			purchase: confirmed(50000, 20000),
+			first:    true,
+			reward:   3000,
+		},
+		{
+			name:     "вся сумма с баланса",
+			purchase: models.Purchase{ID: 10, UserID: refereeID, Amount: 50000, BalanceUsed: 50000, Status: models.PurchasePaid},
+			first:    true,
+		},
+		{
+			name:     "платеж не подтвержден",
+			purchase: models.Purchase{ID: 10, UserID: refereeID, Amount: 50000, Status: models.PurchasePending, ProviderPaymentID: "pay-10"},
+			first:    true,
+		},
+		{
+			name:     "нет платежа у провайдера",
+			purchase: models.Purchase{ID: 10, UserID: refereeID, Amount: 50000, Status: models.PurchasePaid},
+			first:    true,
+		},
+		{
+			name:     "не первая покупка",
+			purchase: confirmed(50000, 0),
+		},
+		{
+			name: "приглашенного нет",
+			purchase: func() models.Purchase {
+				p := confirmed(50000, 0)
+				p.UserID = referrerID
+				return p
+			}(),
+			first: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepo{first: tt.first}
+			s := NewService(repo, slog.New(slog.NewTextHandler(io.Discard, nil)), 10)
+
+			if err := s.OnPurchasePaid(context.Background(), &tt.purchase); err != nil {
+				t.Fatalf("OnPurchasePaid: %v", err)
+			}
+
+			if tt.reward == 0 {
+				if len(repo.rewards) != 0 {
+					t.Fatalf("начислен бонус %d, ожидалось без бонуса", repo.rewards[0].Amount)
+				}
+				return
+			}
+
+			if len(repo.rewards) != 1 {
+				t.Fatalf("начислено бонусов %d, ожидался один", len(repo.rewards))
+			}
+			if got := repo.rewards[0]; got.Amount != tt.reward || got.ReferrerID != referrerID || got.PurchaseID != tt.purchase.ID {
+				t.Fatalf("бонус %+v, ожидалось %d пригласившему %d", got, tt.reward, referrerID)
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+package refunds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+)
+
+const DefaultListLimit = 50
+
+var (
+	ErrUserNotFound     = errors.New("пользователь не зарегистрирован")
+	ErrPurchaseNotFound = errors.New("оплаченная покупка не найдена")
+	ErrRefundNotFound   = errors.New("заявка на возврат не найдена")
+	ErrAlreadyRequested = errors.New("возврат по этой покупке уже запрошен")
+	ErrNotPending       = errors.New("заявка уже обработана")
+	ErrProviderFailed   = errors.New("платежный провайдер не провел возврат")
+	ErrNoPayment        = errors.New("у покупки нет платежа у провайдера, деньги на карту вернуть нельзя")
+)
+
+type Repository interface {
+	UserByTelegramID(ctx context.Context, telegramID int64) (*models.User, error)
+	LatestPaidPurchase(ctx context.Context, userID, productID int64) (*models.Purchase, error)
+	CreateRefund(ctx context.Context, refund *models.Refund) error
+	RefundByID(ctx context.Context, refundID int64) (*models.RefundDetails, error)
+	Refunds(ctx context.Context, status string, limit int) ([]*models.RefundDetails, error)
+	StartRefundProcessing(ctx context.Context, refundID int64) error
+	CompleteRefund(ctx context.Context, refund *models.RefundDetails, providerRefundID string) error
+	FailRefund(ctx context.Context, refundID int64, note string) error
+	RejectRefund(ctx context.Context, refundID int64, note string) error
+	CreateNotification(ctx context.Context, telegramID int64, text string) error
+}
+
+// PaymentProvider возвращает покупателю деньги, списанные не с бонусного баланса
+type PaymentProvider interface {
+	Refund(ctx context.Context, paymentID string, amount int64) (string, error)
+}
+
+// AccessManager отзывает доступ к материалам продукта вне сервера (репозитории и т.п.)
+type AccessManager interface {
+	RevokeAccess(ctx context.Context, userID, productID int64) error
+}
+
+// Auditor журнал аудита
+type Auditor interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+type Service struct {
+	repo     Repository
+	provider PaymentProvider
+	access   AccessManager
+	audit    Auditor
+	logger   *slog.Logger
+}
+
+func NewService(repo Repository, provider PaymentProvider, access AccessManager, audit Auditor, logger *slog.Logger) *Service {
+	return &Service{
+		repo:     repo,
+		provider: provider,
+		access:   access,
+		audit:    audit,
+		logger:   logger,
+	}
+}
+
+// Request создает заявку пользователя на возврат последней покупки продукта.
+// Заявка ждет решения администратора.
+func (s *Service) Request(ctx context.Context, telegramID, productID int64, reason string) (*models.Refund, error) {
+	const op = "refunds.Request"
+
+	user, err := s.repo.UserByTelegramID(ctx, telegramID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	purchase, err := s.repo.LatestPaidPurchase(ctx, user.ID, productID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrPurchaseNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := cardRefund(purchase); err != nil {
+		return nil, err
+	}
+
+	refund, err := s.create(ctx, purchase.ID, models.RefundByUser, reason)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.logger.InfoContext(ctx, "Пользователь запросил возврат",
+		slog.String("op", op),
+		slog.Int64("refund_id", refund.ID),
+		slog.Int64("purchase_id", purchase.ID),
+	)
+
+	s.audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditRefundRequested,
+		ActorType:  models.ActorUser,
+		ActorID:    strconv.FormatInt(telegramID, 10),
+		TargetType: models.TargetRefund,
+		TargetID:   strconv.FormatInt(refund.ID, 10),
+		Details:    map[string]any{"purchase_id": purchase.ID, "reason": refund.Reason},
+	})
+
+	return refund, nil
+}
+
+// RefundPurchase возврат по инициативе администратора: заявка создается и сразу проводится
+func (s *Service) RefundPurchase(ctx context.Context, purchaseID int64, reason string) (*models.RefundDetails, error) {
+	const op = "refunds.RefundPurchase"
+
+	refund, err := s.create(ctx, purchaseID, models.RefundByAdmin, reason)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.Approve(ctx, refund.ID)
+}
+
+// Approve проводит возврат: деньги через провайдера, бонусы обратно на баланс,
+// затем отзыв лицензии и доступа. Неудачную попытку можно повторить.
+func (s *Service) Approve(ctx context.Context, refundID int64) (*models.RefundDetails, error) {
+	const op = "refunds.Approve"
+	logger := s.logger.With(slog.String("op", op), slog.Int64("refund_id", refundID))
+
+	refund, err := s.get(ctx, refundID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	card, err := cardRefund(&refund.Purchase)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.StartRefundProcessing(ctx, refundID)
+	if errors.Is(err, repository.ErrConflict) {
+		return nil, ErrNotPending
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var providerRefundID string
+	if card > 0 {
+		providerRefundID, err = s.provider.Refund(ctx, refund.Purchase.ProviderPaymentID, card)
+		if err != nil {
+			logger.ErrorContext(ctx, "Провайдер не провел возврат", slog.String("error", err.Error()))
+			if err := s.repo.FailRefund(ctx, refundID, err.Error()); err != nil {
+				logger.ErrorContext(ctx, "Не удалось пометить возврат неудачным", slog.String("error", err.Error()))
+			}
+			return nil, ErrProviderFailed
+		}
+	}
+
+	if err := s.repo.CompleteRefund(ctx, refund, providerRefundID); err != nil {
+		// Деньги уже ушли покупателю, поэтому заявку оставляем в processing для ручного разбора
+		logger.ErrorContext(ctx, "Возврат проведен провайдером, но не сохранен",
+			slog.String("provider_refund_id", providerRefundID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Покупка мест не давала старосте доступа: его личный доступ к продукту, если он
+	// есть, куплен отдельно, а лицензии группы отозваны вместе с возвратом
+	revoked := "Лицензия на продукт отозвана."
+	if refund.Purchase.Seats > 0 {
+		revoked = "Места группы освобождены, лицензии участников отозваны."
+	} else if err := s.access.RevokeAccess(ctx, refund.Purchase.UserID, refund.Purchase.ProductID); err != nil {
+		logger.ErrorContext(ctx, "Не удалось отозвать доступ к материалам", slog.String("error", err.Error()))
+	}
+
+	text := fmt.Sprintf("↩️ Возврат за «%s» оформлен: %s. %s", refund.ProductName, refundSummary(&refund.Purchase), revoked)
+	if err := s.repo.CreateNotification(ctx, refund.TelegramID, text); err != nil {
+		logger.ErrorContext(ctx, "Не удалось уведомить пользователя о возврате", slog.String("error", err.Error()))
+	}
+
+	logger.InfoContext(ctx, "Возврат проведен", slog.Int64("purchase_id", refund.PurchaseID), slog.Int64("amount", refund.Amount))
+
+	s.recordApproved(ctx, refund, providerRefundID)
+
+	return s.get(ctx, refundID)
+}
+
+// Reject отклоняет заявку, note показывается пользователю
+func (s *Service) Reject(ctx context.Context, refundID int64, note string) (*models.RefundDetails, error) {
+	const op = "refunds.Reject"
+
+	refund, err := s.get(ctx, refundID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	note = strings.TrimSpace(note)
+	err = s.repo.RejectRefund(ctx, refundID, note)
+	if errors.Is(err, repository.ErrConflict) {
+		return nil, ErrNotPending
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditRefundRejected,
+		TargetType: models.TargetRefund,
+		TargetID:   strconv.FormatInt(refundID, 10),
+		Details:    map[string]any{"purchase_id": refund.PurchaseID, "note": note},
+	})
+
+	text := fmt.Sprintf("Заявка на возврат за «%s» отклонена.", refund.ProductName)
+	if note != "" {
+		text += "\nКомментарий: " + note
+	}
+	if err := s.repo.CreateNotification(ctx, refund.TelegramID, text); err != nil {
+		s.logger.ErrorContext(ctx, "Не удалось уведомить пользователя об отказе",
+			slog.String("op", op),
+			slog.String("error", err.Error()),
+		)
+	}
+
+	return s.get(ctx, refundID)
+}
+
+func (s *Service) List(ctx context.Context, status string, limit int) ([]*models.RefundDetails, error) {
+	const op = "refunds.List"
+
+	if limit <= 0 || limit > DefaultListLimit {
+		limit = DefaultListLimit
+	}
+
+	refunds, err := s.repo.Refunds(ctx, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return refunds, nil
+}
+
+// recordApproved пишет в журнал проведенный возврат и отзыв лицензии вместе со сбросом устройства.
+// Действующее лицо не указываем: это администратор, одобривший заявку, его берет журнал из токена.
+func (s *Service) recordApproved(ctx context.Context, refund *models.RefundDetails, providerRefundID string) {
+	s.audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditRefundApproved,
+		TargetType: models.TargetRefund,
+		TargetID:   strconv.FormatInt(refund.ID, 10),
+		Details: map[string]any{
+			"purchase_id":        refund.PurchaseID,
+			"initiator":          refund.Initiator,
+			"amount":             refund.Purchase.Amount,
+			"provider_refund_id": providerRefundID,
+		},
+	})
+
+	if refund.Purchase.LicenseID == nil {
+		return
+	}
+
+	for _, action := range []string{models.AuditLicenseRevoked, models.AuditDeviceReset} {
+		s.audit.Record(ctx, models.AuditEvent{
+			Action:     action,
+			TargetType: models.TargetLicense,
+			TargetID:   strconv.FormatInt(*refund.Purchase.LicenseID, 10),
+			Details: map[string]any{
+				"user_id":   refund.Purchase.UserID,
+				"refund_id": refund.ID,
+			},
+		})
+	}
+}
+
+func (s *Service) create(ctx context.Context, purchaseID int64, initiator, reason string) (*models.Refund, error) {
+	refund := &models.Refund{
+		PurchaseID: purchaseID,
+		Initiator:  initiator,
+		Reason:     strings.TrimSpace(reason),
+	}
+
+	err := s.repo.CreateRefund(ctx, refund)
+	switch {
+	case errors.Is(err, repository.ErrConflict):
+		return nil, ErrAlreadyRequested
+	case errors.Is(err, repository.ErrNotFound):
+		return nil, ErrPurchaseNotFound
+	case err != nil:
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+func (s *Service) get(ctx context.Context, refundID int64) (*models.RefundDetails, error) {
+	refund, err := s.repo.RefundByID(ctx, refundID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrRefundNotFound
+	}
+
+	return refund, err
+}
+
+// cardRefund сумма, которую провайдер вернет на карту, остальное возвращается на бонусный
+// баланс. Деньги на карту возвращаются только по платежу, через который они пришли:
+// покупку без платежа у провайдера вернуть на карту нельзя.
+func cardRefund(purchase *models.Purchase) (int64, error) {
+	card := purchase.Amount - purchase.BalanceUsed
+	if card > 0 && purchase.ProviderPaymentID == "" {
+		return 0, ErrNoPayment
+	}
+
+	return card, nil
+}
+
+func refundSummary(purchase *models.Purchase) string {
+	external := purchase.Amount - purchase.BalanceUsed
+
+	switch {
+	case purchase.Amount == 0:
+		return "покупка была бесплатной"
+	case purchase.BalanceUsed == 0:
+		return models.FormatRubles(external) + " вернутся на карту"
+	case external == 0:
+		return models.FormatRubles(purchase.BalanceUsed) + " возвращены на бонусный баланс"
+	}
+
+	return fmt.Sprintf("%s вернутся на карту, %s возвращены на бонусный баланс",
+		models.FormatRubles(external), models.FormatRubles(purchase.BalanceUsed))
+}
@@ -0,0 +1,195 @@
+package refunds
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+)
+
+// fakeRepo одна заявка на возврат refund
+type fakeRepo struct {
+	refund    models.RefundDetails
+	completed bool
+}
+
+func (r *fakeRepo) UserByTelegramID(context.Context, int64) (*models.User, error) {
+	return &models.User{ID: r.refund.Purchase.UserID}, nil
+}
+
+func (r *fakeRepo) LatestPaidPurchase(context.Context, int64, int64) (*models.Purchase, error) {
+	purchase := r.refund.Purchase
+	return &purchase, nil
+}
+
+func (r *fakeRepo) CreateRefund(_ context.Context, refund *models.Refund) error {
+	refund.ID = r.refund.ID
+	return nil
+}
+
+func (r *fakeRepo) RefundByID(context.Context, int64) (*models.RefundDetails, error) {
+	refund := r.refund
+	return &refund, nil
+}
+
+func (r *fakeRepo) Refunds(context.Context, string, int) ([]*models.RefundDetails, error) {
+	return nil, nil
+}
+
+func (r *fakeRepo) StartRefundProcessing(context.Context, int64) error {
+	r.refund.Status = models.RefundProcessing
+	return nil
+}
+
+func (r *fakeRepo) CompleteRefund(context.Context, *models.RefundDetails, string) error {
+	r.completed = true
+	return nil
+}
+
+func (r *fakeRepo) FailRefund(context.Context, int64, string) error         { return nil }
+func (r *fakeRepo) RejectRefund(context.Context, int64, string) error       { return nil }
+func (r *fakeRepo) CreateNotification(context.Context, int64, string) error { return nil }
+
+type refundCall struct {
+	paymentID string
+	amount    int64
+}
+
+type fakeProvider struct {
+	calls []refundCall
+}
+
+func (p *fakeProvider) Refund(_ context.Context, paymentID string, amount int64) (string, error) {
+	p.calls = append(p.calls, refundCall{paymentID: paymentID, amount: amount})
+	return "refund-1", nil
+}
+
+type nopAccess struct{}
+
+func (nopAccess) RevokeAccess(context.Context, int64, int64) error { return nil }
+
+type nopAuditor struct{}
+
+func (nopAuditor) Record(context.Context, models.AuditEvent) {}
+
+func TestApprove(t *testing.T) {
+	tests := []struct {
+		name        string
+		amount      int64
+		balanceUsed int64
+		paymentID   string
+		err         error
+		card        int64 // Сумма возврата на карту, 0 — провайдер не вызывается
+	}{
+		{name: "оплата картой", amount: 50000, paymentID: "pay-1", card: 50000},
+		{name: "карта и баланс", amount: 50000, balanceUsed: 20000, paymentID: "pay-1", card: 30000},
+		{name: "только баланс", amount: 50000, balanceUsed: 50000},
+		{name: "бесплатная покупка", amount: 0},
+		{name: "карта без платежа у провайдера", amount: 50000, balanceUsed: 20000, err: ErrNoPayment},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepo{refund: models.RefundDetails{
+				Refund: models.Refund{ID: 1, PurchaseID: 10, Status: models.RefundRequested},
+				Purchase: models.Purchase{
+					ID:                10,
+					UserID:            1,
+					Amount:            tt.amount,
+					BalanceUsed:       tt.balanceUsed,
+					Status:            models.PurchasePaid,
+					ProviderPaymentID: tt.paymentID,
+				},
+			}}
+			provider := &fakeProvider{}
+			s := NewService(repo, provider, nopAccess{}, nopAuditor{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			_, err := s.Approve(context.Background(), 1)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Approve: ошибка %v, ожидалась %v", err, tt.err)
+			}
+
+			if tt.err != nil {
+				if repo.refund.Status != models.RefundRequested || len(provider.calls) != 0 || repo.completed {
+					t.Fatalf("отказанный возврат дошел до провайдера или базы: статус %s, вызовов %d",
+						repo.refund.Status, len(provider.calls))
+				}
+				return
+			}
+
+			if !repo.completed {
+				t.Fatal("возврат не завершен")
+			}
+
+			var want []refundCall
+			if tt.card > 0 {
+				want = []refundCall{{paymentID: tt.paymentID, amount: tt.card}}
+			}
+			if len(provider.calls) != len(want) || (len(want) > 0 && provider.calls[0] != want[0]) {
+				t.Fatalf("возвраты у провайдера %v, ожидалось %v", provider.calls, want)
+			}
+		})
+	}
+}
+
+func TestRequestWithoutPayment(t *testing.T) {
+	repo := &fakeRepo{refund: models.RefundDetails{
+		Refund:   models.Refund{ID: 1, PurchaseID: 10},
+		Purchase: models.Purchase{ID: 10, UserID: 1, Amount: 50000, Status: models.PurchasePaid},
+	}}
+	s := NewService(repo, &fakeProvider{}, nopAccess{}, nopAuditor{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if _, err := s.Request(context.Background(), 100, 7, "не подошло"); !errors.Is(err, ErrNoPayment) {
+		t.Fatalf("ожидалась ErrNoPayment, получено %v", err)
+	}
+}
+
+// recordingAccess запоминает, у кого отозван доступ
+type recordingAccess struct {
+	revoked []int64
+}
+
+func (a *recordingAccess) RevokeAccess(_ context.Context, userID, _ int64) error {
+	a.revoked = append(a.revoked, userID)
+	return nil
+}
+
+func TestApproveRevokesAccess(t *testing.T) {
+	poolID := int64(5)
+
+	tests := []struct {
+		name     string
+		purchase models.Purchase
+		revoked  bool
+	}{
+		{name: "личная покупка", purchase: models.Purchase{ID: 10, UserID: 1, Status: models.PurchasePaid}, revoked: true},
+		{
+			name:     "покупка мест не трогает личный доступ старосты",
+			purchase: models.Purchase{ID: 10, UserID: 1, Status: models.PurchasePaid, Seats: 3, SeatPoolID: &poolID},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepo{refund: models.RefundDetails{
+				Refund:   models.Refund{ID: 1, PurchaseID: 10, Status: models.RefundRequested},
+				Purchase: tt.purchase,
+			}}
+			access := &recordingAccess{}
+			s := NewService(repo, &fakeProvider{}, access, nopAuditor{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			if _, err := s.Approve(context.Background(), 1); err != nil {
+				t.Fatalf("Approve: %v", err)
+			}
+			if !repo.completed {
+				t.Fatal("возврат не завершен")
+			}
+			if got := len(access.revoked) > 0; got != tt.revoked {
+				t.Fatalf("доступ отозван: %v, ожидалось %v", got, tt.revoked)
+			}
+		})
+	}
+}
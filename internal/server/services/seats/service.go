@@ -0,0 +1,256 @@
+package seats
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+)
+
+const (
+	MaxSeats = 500
+
+	inviteCodeBytes = 6
+)
+
+var (
+	ErrUserNotFound    = errors.New("пользователь не зарегистрирован")
+	ErrPoolNotFound    = errors.New("групповая лицензия не найдена")
+	ErrSeatNotFound    = errors.New("пользователь не занимает место в групповой лицензии")
+	ErrNotOwner        = errors.New("групповой лицензией управляет другой пользователь")
+	ErrInvalidSeats    = fmt.Errorf("число мест должно быть от 1 до %d", MaxSeats)
+	ErrNoFreeSeats     = errors.New("в групповой лицензии не осталось свободных мест")
+	ErrGroupMismatch   = errors.New("групповая лицензия выдана для другой учебной группы")
+	ErrAlreadyLicensed = errors.New("у пользователя уже есть лицензия на этот продукт")
+)
+
+type Repository interface {
+	UserByTelegramID(ctx context.Context, telegramID int64) (*models.User, error)
+	SeatPoolByCode(ctx context.Context, inviteCode string) (*models.SeatPool, error)
+	SeatPoolByID(ctx context.Context, poolID int64) (*models.SeatPool, error)
+	SeatPoolsByOwner(ctx context.Context, ownerUserID int64) ([]*models.SeatPool, error)
+	SeatAssignments(ctx context.Context, poolID int64) ([]*models.SeatAssignment, error)
+	AssignSeat(ctx context.Context, poolID, userID int64) (*models.License, error)
+	ReleaseSeat(ctx context.Context, poolID, userID int64) (int64, error)
+	ReassignSeat(ctx context.Context, poolID, fromUserID, toUserID int64) (int64, *models.License, error)
+}
+
+// Auditor журнал аудита
+type Auditor interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+type Service struct {
+	repo   Repository
+	audit  Auditor
+	logger *slog.Logger
+}
+
+func NewService(repo Repository, audit Auditor, logger *slog.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		audit:  audit,
+		logger: logger,
+	}
+}
+
+// Join занимает место в групповой лицензии по коду приглашения
+func (s *Service) Join(ctx context.Context, telegramID int64, inviteCode string) (*models.SeatPool, error) {
+	const op = "seats.Join"
+
+	user, err := s.user(ctx, telegramID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	pool, err := s.repo.SeatPoolByCode(ctx, strings.TrimSpace(inviteCode))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrPoolNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if pool.Group != "" && !strings.EqualFold(pool.Group, user.Group) {
+		return nil, ErrGroupMismatch
+	}
+
+	license, err := s.repo.AssignSeat(ctx, pool.ID, user.ID)
+	switch {
+	case errors.Is(err, repository.ErrNoFreeSeats):
+		return nil, ErrNoFreeSeats
+	case errors.Is(err, repository.ErrConflict):
+		return nil, ErrAlreadyLicensed
+	case err != nil:
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	pool.Used++
+
+	s.recordSeatLicense(ctx, models.AuditLicenseIssued, telegramID, license.ID, pool.ID, user.ID)
+
+	return pool, nil
+}
+
+// OwnedPools возвращает групповые лицензии пользователя с их заполненностью
+func (s *Service) OwnedPools(ctx context.Context, ownerTelegramID int64) ([]*models.SeatPool, error) {
+	const op = "seats.OwnedPools"
+
+	owner, err := s.user(ctx, ownerTelegramID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	pools, err := s.repo.SeatPoolsByOwner(ctx, owner.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pools, nil
+}
+
+// Members возвращает пул и список занявших места студентов
+func (s *Service) Members(ctx context.Context, ownerTelegramID, poolID int64) (*models.SeatPool, []*models.SeatAssignment, error) {
+	const op = "seats.Members"
+
+	pool, err := s.ownedPool(ctx, ownerTelegramID, poolID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	members, err := s.repo.SeatAssignments(ctx, poolID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pool, members, nil
+}
+
+// Release освобождает место студента memberTelegramID. Его лицензия отзывается,
+// а место можно снова занять по коду приглашения.
+func (s *Service) Release(ctx context.Context, ownerTelegramID, poolID, memberTelegramID int64) error {
+	const op = "seats.Release"
+
+	if _, err := s.ownedPool(ctx, ownerTelegramID, poolID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	member, err := s.user(ctx, memberTelegramID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	licenseID, err := s.repo.ReleaseSeat(ctx, poolID, member.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrSeatNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.recordSeatLicense(ctx, models.AuditLicenseRevoked, ownerTelegramID, licenseID, poolID, member.ID)
+
+	return nil
+}
+
+// Reassign передает место студента fromTelegramID студенту toTelegramID
+func (s *Service) Reassign(ctx context.Context, ownerTelegramID, poolID, fromTelegramID, toTelegramID int64) error {
+	const op = "seats.Reassign"
+
+	pool, err := s.ownedPool(ctx, ownerTelegramID, poolID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	from, err := s.user(ctx, fromTelegramID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	to, err := s.user(ctx, toTelegramID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if pool.Group != "" && !strings.EqualFold(pool.Group, to.Group) {
+		return ErrGroupMismatch
+	}
+
+	revokedID, license, err := s.repo.ReassignSeat(ctx, poolID, from.ID, to.ID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrSeatNotFound
+	case errors.Is(err, repository.ErrConflict):
+		return ErrAlreadyLicensed
+	case err != nil:
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.recordSeatLicense(ctx, models.AuditLicenseRevoked, ownerTelegramID, revokedID, poolID, from.ID)
+	s.recordSeatLicense(ctx, models.AuditLicenseIssued, ownerTelegramID, license.ID, poolID, to.ID)
+
+	return nil
+}
+
+// recordSeatLicense пишет в журнал выдачу или отзыв лицензии по месту в пуле
+func (s *Service) recordSeatLicense(ctx context.Context, action string, actorTelegramID, licenseID, poolID, userID int64) {
+	s.audit.Record(ctx, models.AuditEvent{
+		Action:     action,
+		ActorType:  models.ActorUser,
+		ActorID:    strconv.FormatInt(actorTelegramID, 10),
+		TargetType: models.TargetLicense,
+		TargetID:   strconv.FormatInt(licenseID, 10),
+		Details: map[string]any{
+			"user_id": userID,
+			"pool_id": poolID,
+		},
+	})
+}
+
+func (s *Service) user(ctx context.Context, telegramID int64) (*models.User, error) {
+	user, err := s.repo.UserByTelegramID(ctx, telegramID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+
+	return user, err
+}
+
+func (s *Service) ownedPool(ctx context.Context, ownerTelegramID, poolID int64) (*models.SeatPool, error) {
+	owner, err := s.user(ctx, ownerTelegramID)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := s.repo.SeatPoolByID(ctx, poolID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrPoolNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if pool.OwnerUserID != owner.ID {
+		return nil, ErrNotOwner
+	}
+
+	return pool, nil
+}
+
+// NewInviteCode код приглашения в групповую лицензию. Сама лицензия оформляется
+// покупкой мест в purchases.BuySeats и открывается после оплаты.
+func NewInviteCode() (string, error) {
+	buf := make([]byte, inviteCodeBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("не удалось сгенерировать код приглашения: %w", err)
+	}
+
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,99 @@
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+)
+
+type Repository interface {
+	ExpiringSubscriptions(ctx context.Context, before time.Time) ([]*models.ExpiringSubscription, error)
+	RemindSubscription(ctx context.Context, subscriptionID, telegramID int64, text string) error
+	ExpireSubscriptions(ctx context.Context, now time.Time) ([]*models.ExpiringSubscription, error)
+	CreateNotification(ctx context.Context, telegramID int64, text string) error
+}
+
+type Service struct {
+	repo         Repository
+	logger       *slog.Logger
+	remindBefore atomic.Int64 // time.Duration
+	now          func() time.Time
+}
+
+func NewService(repo Repository, logger *slog.Logger, remindBefore time.Duration) *Service {
+	s := &Service{
+		repo:   repo,
+		logger: logger,
+		now:    time.Now,
+	}
+	s.remindBefore.Store(int64(remindBefore))
+
+	return s
+}
+
+// SetRemindBefore меняет срок напоминания при перезагрузке конфига
+func (s *Service) SetRemindBefore(d time.Duration) {
+	s.remindBefore.Store(int64(d))
+}
+
+// RemindExpiring ставит в очередь бота напоминания о продлении для подписок,
+// которые закончатся в ближайшие remindBefore
+func (s *Service) RemindExpiring(ctx context.Context) error {
+	const op = "subscriptions.RemindExpiring"
+	logger := s.logger.With(slog.String("op", op))
+
+	subs, err := s.repo.ExpiringSubscriptions(ctx, s.now().Add(time.Duration(s.remindBefore.Load())))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, sub := range subs {
+		text := fmt.Sprintf(
+			"⏰ Подписка на «%s» закончится %s.\n\nПродлите её в /catalog, чтобы не потерять доступ.",
+			sub.ProductName, sub.CurrentPeriodEnd.Format("02.01.2006 15:04"),
+		)
+
+		if err := s.repo.RemindSubscription(ctx, sub.ID, sub.TelegramID, text); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if len(subs) > 0 {
+		logger.InfoContext(ctx, "Отправлены напоминания о продлении", slog.Int("count", len(subs)))
+	}
+
+	return nil
+}
+
+// ExpireOverdue переводит лицензии с закончившейся подпиской в статус expired
+// и уведомляет пользователей
+func (s *Service) ExpireOverdue(ctx context.Context) error {
+	const op = "subscriptions.ExpireOverdue"
+	logger := s.logger.With(slog.String("op", op))
+
+	subs, err := s.repo.ExpireSubscriptions(ctx, s.now())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, sub := range subs {
+		text := fmt.Sprintf("⌛️ Подписка на «%s» закончилась. Доступ к продукту приостановлен.", sub.ProductName)
+
+		if err := s.repo.CreateNotification(ctx, sub.TelegramID, text); err != nil {
+			logger.ErrorContext(ctx, "Не удалось уведомить об окончании подписки",
+				slog.Int64("subscription_id", sub.ID),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+
+	if len(subs) > 0 {
+		logger.InfoContext(ctx, "Истекшие подписки переведены в expired", slog.Int("count", len(subs)))
+	}
+
+	return nil
+}
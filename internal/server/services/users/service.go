@@ -0,0 +1,221 @@
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/licensekey"
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+)
+
+const referralCodeBytes = 5
+
+var (
+	ErrInvalidUser   = errors.New("не указаны telegram_id, ФИО или группа")
+	ErrAlreadyExists = errors.New("пользователь уже зарегистрирован")
+	ErrUserNotFound  = errors.New("пользователь не зарегистрирован")
+)
+
+type Repository interface {
+	CreateUser(ctx context.Context, user *models.User) error
+	UserByTelegramID(ctx context.Context, telegramID int64) (*models.User, error)
+	UserByReferralCode(ctx context.Context, code string) (*models.User, error)
+	RotateLicenseKey(ctx context.Context, userID int64, keyHash, keyHint string) error
+	SetLeader(ctx context.Context, userID int64, leader bool) error
+}
+
+// Auditor журнал аудита
+type Auditor interface {
+	Record(ctx context.Context, event models.AuditEvent)
+}
+
+type Service struct {
+	repo   Repository
+	audit  Auditor
+	logger *slog.Logger
+}
+
+func NewService(repo Repository, audit Auditor, logger *slog.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		audit:  audit,
+		logger: logger,
+	}
+}
+
+// Register регистрирует пользователя. Если указан referralCode, пользователь
+// закрепляется за пригласившим. Неизвестный код и приглашение самого себя
+// не мешают регистрации и просто игнорируются.
+func (s *Service) Register(ctx context.Context, telegramID int64, name, group, referralCode string) (*models.User, error) {
+	const op = "users.Register"
+	logger := s.logger.With(slog.String("op", op), slog.Int64("telegram_id", telegramID))
+
+	name, group = strings.TrimSpace(name), strings.TrimSpace(group)
+	if telegramID == 0 || name == "" || group == "" {
+		return nil, ErrInvalidUser
+	}
+
+	key, err := licensekey.Generate()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ownCode, err := randomHex(referralCodeBytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user := &models.User{
+		TelegramID:   telegramID,
+		Name:         name,
+		Group:        group,
+		LicenseKey:   key.Plain,
+		KeyHash:      key.Hash,
+		KeyHint:      key.Hint,
+		ReferralCode: ownCode,
+	}
+
+	if referralCode != "" {
+		referrer, err := s.repo.UserByReferralCode(ctx, referralCode)
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			logger.InfoContext(ctx, "Неизвестный реферальный код", slog.String("code", referralCode))
+		case err != nil:
+			return nil, fmt.Errorf("%s: %w", op, err)
+		case referrer.TelegramID == telegramID:
+			logger.WarnContext(ctx, "Попытка пригласить самого себя")
+		default:
+			user.ReferredBy = &referrer.ID
+		}
+	}
+
+	err = s.repo.CreateUser(ctx, user)
+	if errors.Is(err, repository.ErrConflict) {
+		return nil, ErrAlreadyExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	logger.InfoContext(ctx, "Пользователь зарегистрирован", slog.Bool("referred", user.ReferredBy != nil))
+
+	details := map[string]any{"key_hint": user.KeyHint}
+	if user.ReferredBy != nil {
+		details["referred_by"] = *user.ReferredBy
+	}
+	s.audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditUserRegistered,
+		ActorType:  models.ActorUser,
+		ActorID:    strconv.FormatInt(telegramID, 10),
+		TargetType: models.TargetUser,
+		TargetID:   strconv.FormatInt(user.ID, 10),
+		Details:    details,
+	})
+
+	return user, nil
+}
+
+func (s *Service) Exists(ctx context.Context, telegramID int64) (bool, error) {
+	const op = "users.Exists"
+
+	_, err := s.repo.UserByTelegramID(ctx, telegramID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return true, nil
+}
+
+// RotateLicenseKey выпускает пользователю новый лицензионный ключ вместо старого
+// и возвращает его. Старый ключ перестает проходить проверку сразу.
+func (s *Service) RotateLicenseKey(ctx context.Context, telegramID int64) (string, error) {
+	const op = "users.RotateLicenseKey"
+
+	user, err := s.repo.UserByTelegramID(ctx, telegramID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return "", ErrUserNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	key, err := licensekey.Generate()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.repo.RotateLicenseKey(ctx, user.ID, key.Hash, key.Hint); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.logger.InfoContext(ctx, "Лицензионный ключ перевыпущен",
+		slog.String("op", op),
+		slog.Int64("user_id", user.ID),
+		slog.String("old_hint", user.KeyHint),
+	)
+
+	s.audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditLicenseKeyRotated,
+		ActorType:  models.ActorUser,
+		ActorID:    strconv.FormatInt(telegramID, 10),
+		TargetType: models.TargetUser,
+		TargetID:   strconv.FormatInt(user.ID, 10),
+		Details:    map[string]any{"old_hint": user.KeyHint, "new_hint": key.Hint},
+	})
+
+	return key.Plain, nil
+}
+
+// SetLeader назначает пользователя старостой, который может покупать групповые лицензии,
+// или снимает назначение. Уже купленные групповые лицензии остаются у владельца.
+func (s *Service) SetLeader(ctx context.Context, telegramID int64, leader bool) (*models.User, error) {
+	const op = "users.SetLeader"
+
+	user, err := s.repo.UserByTelegramID(ctx, telegramID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.repo.SetLeader(ctx, user.ID, leader); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	user.IsLeader = leader
+
+	s.logger.InfoContext(ctx, "Изменено назначение старосты",
+		slog.String("op", op),
+		slog.Int64("user_id", user.ID),
+		slog.Bool("leader", leader),
+	)
+
+	// Действующее лицо — администратор, его берет журнал из токена
+	s.audit.Record(ctx, models.AuditEvent{
+		Action:     models.AuditUserLeaderChanged,
+		TargetType: models.TargetUser,
+		TargetID:   strconv.FormatInt(user.ID, 10),
+		Details:    map[string]any{"telegram_id": telegramID, "leader": leader},
+	})
+
+	return user, nil
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("не удалось сгенерировать случайное значение: %w", err)
+	}
+
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,58 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/GeorgeTyupin/labguard/internal/server/models"
+	"github.com/GeorgeTyupin/labguard/internal/server/repository"
+)
+
+const DefaultHistoryLimit = 10
+
+var ErrUserNotFound = errors.New("пользователь не зарегистрирован")
+
+type Repository interface {
+	UserByTelegramID(ctx context.Context, telegramID int64) (*models.User, error)
+	WalletBalance(ctx context.Context, userID int64) (int64, error)
+	WalletHistory(ctx context.Context, userID int64, limit int) ([]*models.LedgerEntry, error)
+}
+
+type Service struct {
+	repo   Repository
+	logger *slog.Logger
+}
+
+func NewService(repo Repository, logger *slog.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Wallet возвращает баланс пользователя и последние historyLimit операций по кошельку
+func (s *Service) Wallet(ctx context.Context, telegramID int64, historyLimit int) (*models.Wallet, error) {
+	const op = "wallet.Wallet"
+
+	user, err := s.repo.UserByTelegramID(ctx, telegramID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	balance, err := s.repo.WalletBalance(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	history, err := s.repo.WalletHistory(ctx, user.ID, historyLimit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &models.Wallet{Balance: balance, History: history}, nil
+}
@@ -0,0 +1,154 @@
+package watermark
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// scanChunk по сколько байт читается файл, который целиком в память не загружается
+const scanChunk = 1 << 20
+
+var markerRe = regexp.MustCompile(Prefix + `([A-Z2-7]{16})`)
+
+// Места, где находится метка, кроме файлов внутри архива
+const (
+	PlaceArchiveComment = "комментарий архива"
+	PlaceDocument       = "свойства PDF"
+	PlaceFile           = "содержимое файла"
+)
+
+// Mark метка, найденная в копии материалов
+type Mark struct {
+	Code string
+	// Place где найдена: одно из Place* или путь файла в архиве
+	Place string
+	// Hidden метка восстановлена из пробелов в концах строк исходного кода
+	Hidden bool
+}
+
+// Extract все метки в файле: архиве, PDF или отдельном файле из архива, например
+// исходнике, выложенном без README. Пустой результат — меток нет.
+func Extract(src io.ReaderAt, size int64) ([]Mark, error) {
+	const op = "watermark.Extract"
+
+	var (
+		marks []Mark
+		err   error
+	)
+	if zr, zipErr := zip.NewReader(src, size); zipErr == nil {
+		marks, err = extractZIP(zr)
+	} else {
+		place := PlaceFile
+		if head, _ := readAt(src, size, 0, 5); string(head) == "%PDF-" {
+			place = PlaceDocument
+		}
+		marks, err = extractFile(io.NewSectionReader(src, 0, size), size, place)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return dedupMarks(marks), nil
+}
+
+func extractZIP(zr *zip.Reader) ([]Mark, error) {
+	var marks []Mark
+	for _, m := range markerRe.FindAllStringSubmatch(zr.Comment, -1) {
+		marks = append(marks, Mark{Code: m[1], Place: PlaceArchiveComment})
+	}
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		found, err := extractFile(rc, int64(f.UncompressedSize64), f.Name)
+		rc.Close()
+		if err != nil {
+			// Поврежденная запись не мешает искать метку в остальных
+			if errors.Is(err, zip.ErrChecksum) || errors.Is(err, zip.ErrFormat) || errors.Is(err, zip.ErrAlgorithm) {
+				continue
+			}
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		marks = append(marks, found...)
+	}
+
+	return marks, nil
+}
+
+// extractFile метки в тексте файла, а в файлах до maxSourceSize — еще и спрятанные
+// в концах строк. Большие файлы, например PDF, читаются частями.
+func extractFile(r io.Reader, size int64, place string) ([]Mark, error) {
+	if size > maxSourceSize {
+		return scanMarkers(r, place)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(r, maxSourceSize))
+	if err != nil {
+		return nil, err
+	}
+
+	var marks []Mark
+	for _, m := range markerRe.FindAllSubmatch(content, -1) {
+		marks = append(marks, Mark{Code: string(m[1]), Place: place})
+	}
+	for _, code := range sourceCodes(content) {
+		marks = append(marks, Mark{Code: code, Place: place, Hidden: true})
+	}
+
+	return marks, nil
+}
+
+// scanMarkers ищет метки по частям, части перекрываются на длину метки, чтобы не
+// пропустить метку на границе
+func scanMarkers(r io.Reader, place string) ([]Mark, error) {
+	overlap := len(Prefix) + codeEncoding.EncodedLen(codeBytes)
+
+	var (
+		marks []Mark
+		tail  []byte
+	)
+	buf := make([]byte, scanChunk)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			chunk := append(tail, buf[:n]...)
+			for _, loc := range markerRe.FindAllSubmatchIndex(chunk, -1) {
+				// Метка целиком в перекрытии уже найдена в прошлой части
+				if loc[1] <= len(tail) {
+					continue
+				}
+				marks = append(marks, Mark{Code: string(chunk[loc[2]:loc[3]]), Place: place})
+			}
+			tail = bytes.Clone(chunk[max(len(chunk)-overlap, 0):])
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return marks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func dedupMarks(marks []Mark) []Mark {
+	seen := make(map[Mark]bool, len(marks))
+	unique := marks[:0]
+	for _, m := range marks {
+		if !seen[m] {
+			seen[m] = true
+			unique = append(unique, m)
+		}
+	}
+
+	return unique
+}
@@ -0,0 +1,256 @@
+package watermark
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testCode код выдачи для проверок: base32 без дополнения, 16 символов
+const testCode = "MFRGGZDFMZTWQ2LK"
+
+// goSource исходник с литералами и комментариями, которые метка менять не должна
+func goSource() string {
+	var b strings.Builder
+	b.WriteString("package main\n\nimport (\n\t\"fmt\"\n)\n\nconst query = `\nSELECT id, name,\n  email\nFROM users;\n`\n\n/*\nпример(1);\n*/\nfunc main() {\n")
+	for i := range 30 {
+		fmt.Fprintf(&b, "\tfmt.Println(%d)\n", i)
+	}
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// pySource исходник Python с многострочной строкой в тройных кавычках
+func pySource() string {
+	var b strings.Builder
+	b.WriteString("HELP = \"\"\"\nкоманды:\n  run,\n  stop;\n\"\"\"\n\n")
+	for i := range 30 {
+		fmt.Fprintf(&b, "print(%d)\n", i)
+	}
+
+	return b.String()
+}
+
+// jsSource исходник JavaScript с шаблонной строкой
+func jsSource() string {
+	var b strings.Builder
+	b.WriteString("const page = `\n<ul>\n  ${items.map(render)},\n</ul>;\n`;\n")
+	for i := range 30 {
+		fmt.Fprintf(&b, "console.log(%d);\n", i)
+	}
+
+	return b.String()
+}
+
+func makeZIP(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip: %v", err)
+		}
+		if _, err := w.Write([]byte(files[name])); err != nil {
+			t.Fatalf("zip: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func readZIP(t *testing.T, content []byte) map[string]string {
+	t.Helper()
+
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("zip: %v", err)
+		}
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(rc); err != nil {
+			t.Fatalf("zip: %v", err)
+		}
+		rc.Close()
+		files[f.Name] = buf.String()
+	}
+
+	return files
+}
+
+// makePDF минимальный документ с таблицей ссылок и словарем Info
+func makePDF() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+		"<< /Title (Отчет) /Author (labguard) >>",
+	}
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f\r\n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n\r\n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 4 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return buf.Bytes()
+}
+
+func extract(t *testing.T, content []byte) []Mark {
+	t.Helper()
+
+	marks, err := Extract(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+
+	return marks
+}
+
+func TestApplyExtractZIP(t *testing.T) {
+	original := map[string]string{
+		"project/README.md": "# Проект\n",
+		"project/main.go":   goSource(),
+		"project/app.py":    pySource(),
+		"project/web.js":    jsSource(),
+		"project/short.go":  "package main\n\nfunc f() {}\n",
+		"project/notes.txt": "заметки:\n",
+	}
+	src := makeZIP(t, original)
+
+	var dst bytes.Buffer
+	if err := Apply(&dst, bytes.NewReader(src), int64(len(src)), FormatZIP, testCode); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	marked := readZIP(t, dst.Bytes())
+
+	tests := []struct {
+		name     string
+		file     string
+		literals []string // строки внутри литералов и комментариев, которые не меняются
+	}{
+		{name: "сырая строка и комментарий Go", file: "project/main.go", literals: []string{"SELECT id, name,\n", "пример(1);\n"}},
+		{name: "тройные кавычки Python", file: "project/app.py", literals: []string{"  run,\n", "  stop;\n"}},
+		{name: "шаблонная строка JavaScript", file: "project/web.js", literals: []string{"  ${items.map(render)},\n", "</ul>;\n"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := marked[tt.file]
+			if content == original[tt.file] {
+				t.Fatal("метка не спрятана в исходник")
+			}
+			for _, literal := range tt.literals {
+				if !strings.Contains(content, "\n"+literal) {
+					t.Fatalf("строка литерала %q изменена", literal)
+				}
+			}
+
+			// Исходник, выложенный отдельно от архива, все равно ведет к покупателю
+			want := []Mark{{Code: testCode, Place: PlaceFile, Hidden: true}}
+			if got := extract(t, []byte(content)); !slices.Equal(got, want) {
+				t.Fatalf("Extract = %v, ожидалось %v", got, want)
+			}
+		})
+	}
+
+	for _, name := range []string{"project/short.go", "project/notes.txt"} {
+		if marked[name] != original[name] {
+			t.Fatalf("%s изменен, хотя метка в него не ставится", name)
+		}
+	}
+
+	marks := extract(t, dst.Bytes())
+	for _, want := range []Mark{
+		{Code: testCode, Place: PlaceArchiveComment},
+		{Code: testCode, Place: "project/README.md"},
+		{Code: testCode, Place: "project/main.go", Hidden: true},
+		{Code: testCode, Place: "project/app.py", Hidden: true},
+		{Code: testCode, Place: "project/web.js", Hidden: true},
+	} {
+		if !slices.Contains(marks, want) {
+			t.Fatalf("нет метки %v среди %v", want, marks)
+		}
+	}
+}
+
+func TestApplyExtractPDF(t *testing.T) {
+	src := makePDF()
+
+	var dst bytes.Buffer
+	if err := Apply(&dst, bytes.NewReader(src), int64(len(src)), FormatPDF, testCode); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if !bytes.HasPrefix(dst.Bytes(), src) {
+		t.Fatal("исходные байты документа изменены")
+	}
+	if !bytes.Contains(dst.Bytes()[len(src):], []byte("/Title (Отчет)")) {
+		t.Fatal("прежние свойства документа потеряны")
+	}
+
+	want := []Mark{{Code: testCode, Place: PlaceDocument}}
+	if got := extract(t, dst.Bytes()); !slices.Equal(got, want) {
+		t.Fatalf("Extract = %v, ожидалось %v", got, want)
+	}
+
+	// Повторная метка заменяет прежнюю, а не добавляется к ней
+	var again bytes.Buffer
+	const otherCode = "ONSWG4TFOQQGG33E"
+	if err := Apply(&again, bytes.NewReader(dst.Bytes()), int64(dst.Len()), FormatPDF, otherCode); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	info := again.Bytes()[dst.Len():]
+	if bytes.Contains(info, []byte(testCode)) || !bytes.Contains(info, []byte(otherCode)) {
+		t.Fatalf("в новом словаре Info не та метка: %s", info)
+	}
+}
+
+func TestApplyMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		content []byte
+	}{
+		{name: "не архив", format: FormatZIP, content: []byte("просто текст")},
+		{name: "не PDF", format: FormatPDF, content: []byte("просто текст")},
+		{name: "PDF без startxref", format: FormatPDF, content: []byte("%PDF-1.4\n%%EOF\n")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(bytes.NewReader(tt.content), int64(len(tt.content)), tt.format)
+			if err == nil {
+				t.Fatal("Check не вернул ошибку")
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+package watermark
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+const (
+	// tailSize сколько байт с конца файла читается в поисках startxref
+	tailSize = 1024
+	// dictReadSize предел чтения словаря: трейлера, xref-потока или Info
+	dictReadSize = 64 << 10
+	// maxSections сколько предыдущих таблиц ссылок просматривать в поисках Info
+	maxSections = 32
+)
+
+var (
+	startxrefRe = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF`)
+	sizeRe      = regexp.MustCompile(`/Size\s+(\d+)`)
+	rootRe      = regexp.MustCompile(`/Root\s+(\d+\s+\d+\s+R)`)
+	infoRe      = regexp.MustCompile(`/Info\s+(\d+)\s+(\d+)\s+R`)
+	prevRe      = regexp.MustCompile(`/Prev\s+(\d+)`)
+	idRe        = regexp.MustCompile(`/ID\s*\[[^\]]*\]`)
+	encryptRe   = regexp.MustCompile(`/Encrypt\b`)
+	xrefTypeRe  = regexp.MustCompile(`/Type\s*/XRef\b`)
+	objectRe    = regexp.MustCompile(`^\s*\d+\s+\d+\s+obj\s*$`)
+	labguardRe  = regexp.MustCompile(`/LabGuard\s*\([^)]*\)`)
+)
+
+// applyPDF дописывает к документу инкрементное обновление с новым словарем Info:
+// прежние свойства документа и ключ /LabGuard с меткой. Исходные байты не меняются,
+// поэтому документ открывается так же, как оригинал.
+func applyPDF(dst io.Writer, src io.ReaderAt, size int64, code string) error {
+	head := make([]byte, 5)
+	if _, err := src.ReadAt(head, 0); err != nil || string(head) != "%PDF-" {
+		return fmt.Errorf("%w: нет заголовка PDF", ErrMalformed)
+	}
+
+	xrefOffset, err := lastXref(src, size)
+	if err != nil {
+		return err
+	}
+
+	trailer, err := readTrailer(src, size, xrefOffset)
+	if err != nil {
+		return err
+	}
+	if encryptRe.Match(trailer) {
+		return fmt.Errorf("%w: зашифрованный PDF", ErrUnsupported)
+	}
+
+	sizeMatch, rootMatch := sizeRe.FindSubmatch(trailer), rootRe.FindSubmatch(trailer)
+	if sizeMatch == nil || rootMatch == nil {
+		return fmt.Errorf("%w: в трейлере нет /Size или /Root", ErrMalformed)
+	}
+	infoNum, err := strconv.ParseInt(string(sizeMatch[1]), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	// Без прежних свойств документ все равно откроется, поэтому ошибка чтения не фатальна
+	var entries []byte
+	if m := infoRe.FindSubmatch(trailer); m != nil {
+		if info, err := findInfo(src, size, xrefOffset, string(m[1]), string(m[2])); err == nil {
+			entries = labguardRe.ReplaceAll(info[2:len(info)-2], nil)
+		}
+	}
+
+	if _, err := io.Copy(dst, io.NewSectionReader(src, 0, size)); err != nil {
+		return err
+	}
+
+	var update bytes.Buffer
+	last := make([]byte, 1)
+	if _, err := src.ReadAt(last, size-1); err != nil {
+		return err
+	}
+	if last[0] != '\n' && last[0] != '\r' {
+		update.WriteByte('\n')
+	}
+
+	infoOffset := size + int64(update.Len())
+	fmt.Fprintf(&update, "%d 0 obj\n<< %s /LabGuard (%s) >>\nendobj\n", infoNum, bytes.TrimSpace(entries), marker(code))
+
+	updateXref := size + int64(update.Len())
+	fmt.Fprintf(&update, "xref\n%d 1\n%010d 00000 n\r\n", infoNum, infoOffset)
+	fmt.Fprintf(&update, "trailer\n<< /Size %d /Root %s /Info %d 0 R /Prev %d", infoNum+1, rootMatch[1], infoNum, xrefOffset)
+	if id := idRe.Find(trailer); id != nil {
+		update.WriteByte(' ')
+		update.Write(id)
+	}
+	fmt.Fprintf(&update, " >>\nstartxref\n%d\n%%%%EOF\n", updateXref)
+
+	_, err = dst.Write(update.Bytes())
+
+	return err
+}
+
+// lastXref смещение последней таблицы ссылок из startxref в конце файла
+func lastXref(src io.ReaderAt, size int64) (int64, error) {
+	tail, err := readAt(src, size, max(size-tailSize, 0), tailSize)
+	if err != nil {
+		return 0, err
+	}
+
+	matches := startxrefRe.FindAllSubmatch(tail, -1)
+	if matches == nil {
+		return 0, fmt.Errorf("%w: нет startxref", ErrMalformed)
+	}
+
+	offset, err := strconv.ParseInt(string(matches[len(matches)-1][1]), 10, 64)
+	if err != nil || offset <= 0 || offset >= size {
+		return 0, fmt.Errorf("%w: неверный startxref", ErrMalformed)
+	}
+
+	return offset, nil
+}
+
+// readTrailer словарь трейлера таблицы ссылок по смещению offset: после классической
+// таблицы или словарь xref-потока
+func readTrailer(src io.ReaderAt, size, offset int64) ([]byte, error) {
+	classic, err := isClassicXref(src, size, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	if classic {
+		trailerAt, _, err := scanXref(src, size, offset, "", "")
+		if err != nil {
+			return nil, err
+		}
+		return dictAt(src, size, trailerAt)
+	}
+
+	dict, err := objectDict(src, size, offset)
+	if err != nil {
+		return nil, err
+	}
+	if !xrefTypeRe.Match(dict) {
+		return nil, fmt.Errorf("%w: по startxref нет таблицы ссылок", ErrMalformed)
+	}
+
+	return dict, nil
+}
+
+// findInfo словарь Info по таблицам ссылок, начиная с последней. Объекты из
+// xref-потоков могут лежать сжатыми в потоках объектов, такие не ищем.
+func findInfo(src io.ReaderAt, size, offset int64, num, gen string) ([]byte, error) {
+	for range maxSections {
+		classic, err := isClassicXref(src, size, offset)
+		if err != nil {
+			return nil, err
+		}
+		if !classic {
+			return nil, ErrUnsupported
+		}
+
+		trailerAt, objectAt, err := scanXref(src, size, offset, num, gen)
+		if err != nil {
+			return nil, err
+		}
+		if objectAt > 0 {
+			return objectDict(src, size, objectAt)
+		}
+
+		trailer, err := dictAt(src, size, trailerAt)
+		if err != nil {
+			return nil, err
+		}
+		prev := prevRe.FindSubmatch(trailer)
+		if prev == nil {
+			break
+		}
+		if offset, err = strconv.ParseInt(string(prev[1]), 10, 64); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+		}
+	}
+
+	return nil, fmt.Errorf("%w: нет объекта Info", ErrMalformed)
+}
+
+func isClassicXref(src io.ReaderAt, size, offset int64) (bool, error) {
+	head, err := readAt(src, size, offset, 64)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.HasPrefix(bytes.TrimLeft(head, " \t\r\n"), []byte("xref")), nil
+}
+
+// scanXref разбирает классическую таблицу ссылок: возвращает смещение словаря после
+// trailer и смещение объекта num gen, если он есть в таблице
+func scanXref(src io.ReaderAt, size, offset int64, num, gen string) (int64, int64, error) {
+	consumed := int64(0)
+	scanner := bufio.NewScanner(io.NewSectionReader(src, offset, size-offset))
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanWords(data, atEOF)
+		consumed += int64(advance)
+		return advance, token, err
+	})
+
+	next := func() string {
+		if !scanner.Scan() {
+			return ""
+		}
+		return scanner.Text()
+	}
+
+	if next() != "xref" {
+		return 0, 0, fmt.Errorf("%w: нет таблицы ссылок", ErrMalformed)
+	}
+
+	var objectAt int64
+	for {
+		token := next()
+		switch token {
+		case "":
+			return 0, 0, fmt.Errorf("%w: таблица ссылок без trailer", ErrMalformed)
+		case "trailer":
+			return offset + consumed, objectAt, nil
+		}
+
+		start, err := strconv.ParseInt(token, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%w: неверная таблица ссылок", ErrMalformed)
+		}
+		count, err := strconv.ParseInt(next(), 10, 64)
+		if err != nil || count < 0 {
+			return 0, 0, fmt.Errorf("%w: неверная таблица ссылок", ErrMalformed)
+		}
+
+		for i := range count {
+			entryOffset, entryGen, kind := next(), next(), next()
+			if kind != "n" && kind != "f" {
+				return 0, 0, fmt.Errorf("%w: неверная таблица ссылок", ErrMalformed)
+			}
+			if objectAt == 0 && kind == "n" && strconv.FormatInt(start+i, 10) == num && trimZeros(entryGen) == gen {
+				objectAt, _ = strconv.ParseInt(entryOffset, 10, 64)
+			}
+		}
+	}
+}
+
+// objectDict словарь объекта "N G obj << ... >>" по смещению offset
+func objectDict(src io.ReaderAt, size, offset int64) ([]byte, error) {
+	buf, err := readAt(src, size, offset, dictReadSize)
+	if err != nil {
+		return nil, err
+	}
+
+	start := bytes.Index(buf, []byte("<<"))
+	if start < 0 || !objectRe.Match(buf[:start]) {
+		return nil, fmt.Errorf("%w: по смещению %d нет объекта", ErrMalformed, offset)
+	}
+
+	return balancedDict(buf[start:])
+}
+
+// dictAt первый словарь после offset
+func dictAt(src io.ReaderAt, size, offset int64) ([]byte, error) {
+	buf, err := readAt(src, size, offset, dictReadSize)
+	if err != nil {
+		return nil, err
+	}
+
+	start := bytes.Index(buf, []byte("<<"))
+	if start < 0 {
+		return nil, fmt.Errorf("%w: нет словаря по смещению %d", ErrMalformed, offset)
+	}
+
+	return balancedDict(buf[start:])
+}
+
+// balancedDict словарь от открывающих << до парных им >> с учетом вложенных
+// словарей и строк, где могут встречаться такие же символы
+func balancedDict(buf []byte) ([]byte, error) {
+	depth, parens := 0, 0
+	for i := 0; i < len(buf); i++ {
+		c := buf[i]
+		switch {
+		case parens > 0:
+			switch c {
+			case '\\':
+				i++
+			case '(':
+				parens++
+			case ')':
+				parens--
+			}
+		case c == '(':
+			parens = 1
+		case c == '<' && i+1 < len(buf) && buf[i+1] == '<':
+			depth++
+			i++
+		case c == '<':
+			// Шестнадцатеричная строка <FEFF...>, ее > не закрывает словарь
+			end := bytes.IndexByte(buf[i:], '>')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: незакрытая строка", ErrMalformed)
+			}
+			i += end
+		case c == '>' && i+1 < len(buf) && buf[i+1] == '>':
+			depth--
+			i++
+			if depth == 0 {
+				return buf[:i+1], nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("%w: незакрытый словарь", ErrMalformed)
+}
+
+func readAt(src io.ReaderAt, size, offset, n int64) ([]byte, error) {
+	n = min(n, size-offset)
+	if offset < 0 || n <= 0 {
+		return nil, fmt.Errorf("%w: смещение %d за пределами файла", ErrMalformed, offset)
+	}
+
+	buf := make([]byte, n)
+	if _, err := src.ReadAt(buf, offset); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+func trimZeros(s string) string {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return s
+	}
+
+	return strconv.FormatInt(n, 10)
+}
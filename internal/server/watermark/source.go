@@ -0,0 +1,161 @@
+package watermark
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"strings"
+)
+
+const (
+	// bitsPerLine сколько бит кода прячется в конце одной строки: по символу на бит,
+	// пробел — 0, табуляция — 1
+	bitsPerLine   = 4
+	checksumBytes = 2
+	// frameLines строк на код выдачи с контрольной суммой
+	frameLines = (codeBytes + checksumBytes) * 8 / bitsPerLine
+	// maxSourceSize файлы больше не помечаются и не разбираются при поиске метки
+	maxSourceSize = 4 << 20
+)
+
+// lineEnds символы, после которых в конец строки можно дописать пробелы: строка
+// заканчивает оператор или блок и точно не продолжается на следующей строке
+const lineEnds = "{}()[];,:"
+
+func isSource(name string) bool {
+	return syntaxOf(name) != nil
+}
+
+// markSource прячет код выдачи в концы строк: подходящие строки по очереди получают
+// по bitsPerLine бит кода и контрольной суммы, код повторяется до конца файла. В
+// редакторе и при просмотре разница не видна, а по одному утекшему файлу код
+// восстанавливается. Строки, которые заканчиваются внутри строкового литерала или
+// комментария, не меняются. Файлы, где подходящих строк меньше frameLines, тоже.
+func markSource(name string, content, payload []byte) ([]byte, bool) {
+	syntax := syntaxOf(name)
+	if syntax == nil {
+		return nil, false
+	}
+
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	code := syntax.codeLines(lines)
+
+	eligible := 0
+	for i, line := range lines {
+		if code[i] && canMark(line) {
+			eligible++
+		}
+	}
+	if eligible < frameLines {
+		return nil, false
+	}
+
+	frame := frameOf(payload)
+	marked := make([]byte, 0, len(content)+eligible*bitsPerLine)
+	n := 0
+	for i, line := range lines {
+		if !code[i] || !canMark(line) {
+			marked = append(marked, line...)
+			continue
+		}
+
+		body, ending := splitEnding(line)
+		marked = append(marked, body...)
+		marked = append(marked, encodeNibble(frame, n%frameLines)...)
+		marked = append(marked, ending...)
+		n++
+	}
+
+	return marked, true
+}
+
+// sourceCodes коды выдачи, спрятанные markSource. Строки с другими пробелами в
+// конце пропускаются, код засчитывается, только если сошлась контрольная сумма.
+func sourceCodes(content []byte) []string {
+	var nibbles []byte
+	for _, line := range bytes.SplitAfter(content, []byte("\n")) {
+		if nibble, ok := decodeNibble(line); ok {
+			nibbles = append(nibbles, nibble)
+		}
+	}
+
+	var codes []string
+	seen := make(map[string]bool)
+	for start := 0; start+frameLines <= len(nibbles); start++ {
+		frame := make([]byte, frameLines/2)
+		for i := range frame {
+			frame[i] = nibbles[start+2*i]<<4 | nibbles[start+2*i+1]
+		}
+
+		payload := frame[:codeBytes]
+		if !bytes.Equal(frame[codeBytes:], checksum(payload)) {
+			continue
+		}
+
+		code := codeEncoding.EncodeToString(payload)
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+		start += frameLines - 1
+	}
+
+	return codes
+}
+
+func canMark(line []byte) bool {
+	body, _ := splitEnding(line)
+	return len(body) > 0 && strings.IndexByte(lineEnds, body[len(body)-1]) >= 0
+}
+
+// splitEnding отделяет перевод строки, \n или \r\n
+func splitEnding(line []byte) ([]byte, []byte) {
+	body := bytes.TrimRight(line, "\r\n")
+	return body, line[len(body):]
+}
+
+func frameOf(payload []byte) []byte {
+	return append(append([]byte{}, payload...), checksum(payload)...)
+}
+
+func checksum(payload []byte) []byte {
+	return binary.BigEndian.AppendUint16(nil, uint16(crc32.ChecksumIEEE(payload)))
+}
+
+// encodeNibble i-я четверка бит кадра пробелами и табуляциями, старший бит первым
+func encodeNibble(frame []byte, i int) []byte {
+	nibble := frame[i/2] >> 4
+	if i%2 == 1 {
+		nibble = frame[i/2] & 0x0f
+	}
+
+	out := make([]byte, bitsPerLine)
+	for bit := range bitsPerLine {
+		out[bit] = ' '
+		if nibble&(1<<(bitsPerLine-1-bit)) != 0 {
+			out[bit] = '\t'
+		}
+	}
+
+	return out
+}
+
+// decodeNibble четверка бит из конца строки: ровно bitsPerLine пробелов и табуляций
+// после значащего символа
+func decodeNibble(line []byte) (byte, bool) {
+	body, _ := splitEnding(line)
+	text := bytes.TrimRight(body, " \t")
+	if len(text) == 0 || len(body)-len(text) != bitsPerLine {
+		return 0, false
+	}
+
+	var nibble byte
+	for _, c := range body[len(text):] {
+		nibble <<= 1
+		if c == '\t' {
+			nibble |= 1
+		}
+	}
+
+	return nibble, true
+}
@@ -0,0 +1,327 @@
+package watermark
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// span строковый литерал или комментарий: пробелы, дописанные в конец строки
+// внутри литерала, меняют его значение, поэтому такие строки не помечаются
+type span struct {
+	open  string
+	close string // пусто — до конца строки
+	// escape обратная косая черта экранирует следующий символ
+	escape bool
+	// doubled закрытие, повторенное дважды, — символ внутри литерала, а не конец
+	doubled bool
+	// multiline литерал может продолжаться на следующих строках, иначе он
+	// закрывается вместе со строкой
+	multiline bool
+}
+
+// special литералы, которые не описать постоянной парой открытие-закрытие: сырые
+// строки с разделителем, символы в кавычках рядом со временами жизни. Возвращает
+// длину открытия и литерал с уже известным закрытием.
+type special func(text []byte, prev byte) (int, span, bool)
+
+// syntax строки и комментарии языка. В spans длинные открытия идут раньше коротких.
+type syntax struct {
+	spans   []span
+	special special
+}
+
+var (
+	lineComment    = span{open: "//"}
+	blockComment   = span{open: "/*", close: "*/", multiline: true}
+	doubleQuoted   = span{open: `"`, close: `"`, escape: true}
+	singleQuoted   = span{open: "'", close: "'", escape: true}
+	tripleDouble   = span{open: `"""`, close: `"""`, escape: true, multiline: true}
+	rawTripleQuote = span{open: `"""`, close: `"""`, multiline: true}
+)
+
+var (
+	cSyntax = &syntax{spans: []span{lineComment, blockComment, doubleQuoted, singleQuoted}}
+
+	cppSyntax = &syntax{
+		spans:   []span{lineComment, blockComment, doubleQuoted, singleQuoted},
+		special: cppRawString,
+	}
+
+	goSyntax = &syntax{spans: []span{
+		lineComment, blockComment, doubleQuoted, singleQuoted,
+		{open: "`", close: "`", multiline: true},
+	}}
+
+	pythonSyntax = &syntax{spans: []span{
+		{open: "#"},
+		tripleDouble,
+		{open: "'''", close: "'''", escape: true, multiline: true},
+		doubleQuoted, singleQuoted,
+	}}
+
+	jsSyntax = &syntax{spans: []span{
+		lineComment, blockComment, doubleQuoted, singleQuoted,
+		{open: "`", close: "`", escape: true, multiline: true},
+	}}
+
+	javaSyntax = &syntax{spans: []span{lineComment, blockComment, tripleDouble, doubleQuoted, singleQuoted}}
+
+	kotlinSyntax = &syntax{spans: []span{lineComment, blockComment, rawTripleQuote, doubleQuoted, singleQuoted}}
+
+	csharpSyntax = &syntax{spans: []span{
+		lineComment, blockComment, rawTripleQuote,
+		{open: `@"`, close: `"`, doubled: true, multiline: true},
+		doubleQuoted, singleQuoted,
+	}}
+
+	rustSyntax = &syntax{
+		spans: []span{
+			lineComment, blockComment,
+			{open: `"`, close: `"`, escape: true, multiline: true},
+		},
+		special: rustLiteral,
+	}
+
+	swiftSyntax = &syntax{
+		spans:   []span{lineComment, blockComment, tripleDouble, doubleQuoted},
+		special: swiftRawString,
+	}
+
+	scalaSyntax = &syntax{
+		spans:   []span{lineComment, blockComment, rawTripleQuote, doubleQuoted},
+		special: charLiteral,
+	}
+
+	dartSyntax = &syntax{spans: []span{
+		lineComment, blockComment, tripleDouble,
+		{open: "'''", close: "'''", escape: true, multiline: true},
+		doubleQuoted, singleQuoted,
+	}}
+
+	luaSyntax = &syntax{
+		spans:   []span{{open: "--"}, doubleQuoted, singleQuoted},
+		special: luaLongBracket,
+	}
+
+	// В SQL кавычка внутри строки удваивается, а не экранируется
+	sqlSyntax = &syntax{
+		spans: []span{
+			{open: "--"}, blockComment,
+			{open: "'", close: "'", doubled: true, multiline: true},
+			{open: `"`, close: `"`, doubled: true, multiline: true},
+		},
+		special: dollarQuoted,
+	}
+
+	pascalSyntax = &syntax{spans: []span{
+		lineComment,
+		{open: "{", close: "}", multiline: true},
+		{open: "(*", close: "*)", multiline: true},
+		{open: "'", close: "'", doubled: true},
+	}}
+)
+
+// syntaxes исходный код, в который прячется метка. Нет Markdown, YAML и Makefile: в
+// них пробелы в конце строки меняют смысл. Нет Ruby и PHP: строки в них разбираются
+// только вместе с heredoc.
+var syntaxes = map[string]*syntax{
+	".go": goSyntax, ".py": pythonSyntax,
+	".js": jsSyntax, ".jsx": jsSyntax, ".ts": jsSyntax, ".tsx": jsSyntax,
+	".java": javaSyntax, ".kt": kotlinSyntax, ".cs": csharpSyntax,
+	".c": cSyntax, ".h": cSyntax, ".cc": cppSyntax, ".cpp": cppSyntax, ".hpp": cppSyntax,
+	".rs": rustSyntax, ".swift": swiftSyntax, ".scala": scalaSyntax, ".dart": dartSyntax,
+	".lua": luaSyntax, ".sql": sqlSyntax, ".pas": pascalSyntax,
+}
+
+func syntaxOf(name string) *syntax {
+	return syntaxes[strings.ToLower(path.Ext(name))]
+}
+
+// codeLines для каждой строки, заканчивается ли она в коде, а не внутри литерала или
+// комментария. Разбор приблизительный, но ошибается в сторону литерала: строка,
+// где литерал не закрыт, в код не засчитывается.
+func (s *syntax) codeLines(lines [][]byte) []bool {
+	code := make([]bool, len(lines))
+
+	var open *span
+	var current span
+	for i, line := range lines {
+		body, _ := splitEnding(line)
+		for j := 0; j < len(body); {
+			if open != nil {
+				switch {
+				case open.escape && body[j] == '\\':
+					j += 2
+				case open.doubled && bytes.HasPrefix(body[j:], []byte(open.close+open.close)):
+					j += 2 * len(open.close)
+				case open.close != "" && bytes.HasPrefix(body[j:], []byte(open.close)):
+					j += len(open.close)
+					open = nil
+				default:
+					j++
+				}
+				continue
+			}
+
+			var prev byte
+			if j > 0 {
+				prev = body[j-1]
+			}
+			n, sp, ok := s.open(body[j:], prev)
+			if !ok {
+				j++
+				continue
+			}
+			current = sp
+			open = &current
+			j += n
+		}
+
+		code[i] = open == nil
+		if open != nil && !open.multiline {
+			open = nil
+		}
+	}
+
+	return code
+}
+
+func (s *syntax) open(text []byte, prev byte) (int, span, bool) {
+	if s.special != nil {
+		if n, sp, ok := s.special(text, prev); ok {
+			return n, sp, true
+		}
+	}
+	for _, sp := range s.spans {
+		if bytes.HasPrefix(text, []byte(sp.open)) {
+			return len(sp.open), sp, true
+		}
+	}
+
+	return 0, span{}, false
+}
+
+// cppRawString R"разделитель( ... )разделитель"
+func cppRawString(text []byte, prev byte) (int, span, bool) {
+	if !bytes.HasPrefix(text, []byte(`R"`)) || isIdent(prev) && prev != '8' && prev != 'u' && prev != 'U' && prev != 'L' {
+		return 0, span{}, false
+	}
+
+	// Разделитель не длиннее 16 символов и без пробелов, скобок и косой черты
+	delim := text[2:]
+	end := bytes.IndexByte(delim, '(')
+	if end < 0 || end > 16 || bytes.ContainsAny(delim[:end], " \t)\\\"") {
+		return 0, span{}, false
+	}
+
+	return 2 + end + 1, span{close: ")" + string(delim[:end]) + `"`, multiline: true}, true
+}
+
+// rustLiteral сырые строки r#"..."# и символы 'x': одиночная кавычка без пары —
+// время жизни, а не начало литерала
+func rustLiteral(text []byte, prev byte) (int, span, bool) {
+	if isIdent(prev) {
+		return 0, span{}, false
+	}
+
+	raw := text
+	if bytes.HasPrefix(raw, []byte("br")) {
+		raw = raw[1:]
+	}
+	if len(raw) > 0 && raw[0] == 'r' {
+		hashes := 0
+		for 1+hashes < len(raw) && raw[1+hashes] == '#' {
+			hashes++
+		}
+		if 1+hashes < len(raw) && raw[1+hashes] == '"' {
+			n := len(text) - len(raw) + 1 + hashes + 1
+			return n, span{close: `"` + strings.Repeat("#", hashes), multiline: true}, true
+		}
+	}
+
+	return charLiteral(text, prev)
+}
+
+// charLiteral символ в одиночных кавычках: 'x' или '\n'
+func charLiteral(text []byte, _ byte) (int, span, bool) {
+	if len(text) < 3 || text[0] != '\'' {
+		return 0, span{}, false
+	}
+	if text[1] != '\\' {
+		_, size := utf8.DecodeRune(text[1:])
+		if 1+size >= len(text) || text[1+size] != '\'' {
+			return 0, span{}, false
+		}
+	}
+
+	return 1, singleQuoted, true
+}
+
+// swiftRawString строки с расширенным разделителем #"..."# и #"""..."""#
+func swiftRawString(text []byte, _ byte) (int, span, bool) {
+	hashes := 0
+	for hashes < len(text) && text[hashes] == '#' {
+		hashes++
+	}
+	if hashes == 0 {
+		return 0, span{}, false
+	}
+
+	rest, suffix := text[hashes:], strings.Repeat("#", hashes)
+	switch {
+	case bytes.HasPrefix(rest, []byte(`"""`)):
+		return hashes + 3, span{close: `"""` + suffix, multiline: true}, true
+	case bytes.HasPrefix(rest, []byte(`"`)):
+		return hashes + 1, span{close: `"` + suffix}, true
+	}
+
+	return 0, span{}, false
+}
+
+// luaLongBracket длинные строки [[...]], [==[...]==] и такие же комментарии --[[...]]
+func luaLongBracket(text []byte, _ byte) (int, span, bool) {
+	offset := 0
+	if bytes.HasPrefix(text, []byte("--")) {
+		offset = 2
+	}
+
+	rest := text[offset:]
+	if len(rest) < 2 || rest[0] != '[' {
+		return 0, span{}, false
+	}
+	level := 0
+	for 1+level < len(rest) && rest[1+level] == '=' {
+		level++
+	}
+	if 1+level >= len(rest) || rest[1+level] != '[' {
+		return 0, span{}, false
+	}
+
+	return offset + level + 2, span{close: "]" + strings.Repeat("=", level) + "]", multiline: true}, true
+}
+
+// dollarQuoted строки PostgreSQL $$...$$ и $тег$...$тег$
+func dollarQuoted(text []byte, prev byte) (int, span, bool) {
+	if len(text) < 2 || text[0] != '$' || isIdent(prev) || prev == '$' {
+		return 0, span{}, false
+	}
+
+	end := bytes.IndexByte(text[1:], '$')
+	if end < 0 {
+		return 0, span{}, false
+	}
+	tag := text[1 : 1+end]
+	for i, c := range tag {
+		if !isIdent(c) || i == 0 && c >= '0' && c <= '9' {
+			return 0, span{}, false
+		}
+	}
+
+	return end + 2, span{close: "$" + string(tag) + "$", multiline: true}, true
+}
+
+func isIdent(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,139 @@
+package watermark
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestCodeLines(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		src  string
+		want []bool
+	}{
+		{
+			name: "сырая строка Go",
+			file: "main.go",
+			src:  "var q = `\nSELECT a,\n  b;\n`\nf(x)\n",
+			want: []bool{false, false, false, true, true, true},
+		},
+		{
+			name: "блочный и строчный комментарий Go",
+			file: "main.go",
+			src:  "/* начало {\nконец */ f(x)\ng(y) // h(z)\ns := \"// не комментарий\"\n",
+			want: []bool{false, true, false, true, true},
+		},
+		{
+			name: "руна с кавычкой Go",
+			file: "main.go",
+			src:  "c := '\\''\nd := '`'\n",
+			want: []bool{true, true, true},
+		},
+		{
+			name: "тройные кавычки Python",
+			file: "app.py",
+			src:  "doc = \"\"\"\nпункты:\n  a, b,\n\"\"\"\nx = '''{\n'''\nf(x)  # вызов:\n",
+			want: []bool{false, false, false, true, false, true, false, true},
+		},
+		{
+			name: "шаблонная строка JavaScript",
+			file: "app.ts",
+			src:  "const t = `\n  ${a},\n  \\` {\n`;\nf(x);\n",
+			want: []bool{false, false, false, true, true, true},
+		},
+		{
+			name: "незакрытая строка в кавычках не тянется дальше строки",
+			file: "app.js",
+			src:  "s = \"abc\nf(x);\n",
+			want: []bool{false, true, true},
+		},
+		{
+			name: "текстовый блок Java",
+			file: "App.java",
+			src:  "String s = \"\"\"\n  {\n  \"\"\";\n",
+			want: []bool{false, false, true, true},
+		},
+		{
+			name: "сырая строка C++",
+			file: "a.cpp",
+			src:  "auto s = R\"sql(\n  a, \")\"\n)sql\";\nf(x);\n",
+			want: []bool{false, false, true, true, true},
+		},
+		{
+			name: "сырая строка и время жизни Rust",
+			file: "lib.rs",
+			src:  "fn f<'a>(s: &'a str) {\nlet r = r#\"\n  \"{\n\"#;\nlet c = '\"';\nlet m = \"a,\nb\";\n",
+			want: []bool{true, false, false, true, true, false, true, true},
+		},
+		{
+			name: "расширенная строка Swift",
+			file: "a.swift",
+			src:  "let s = #\"\"\"\n  \"\"\" {\n\"\"\"#\nf(x)\n",
+			want: []bool{false, false, true, true, true},
+		},
+		{
+			name: "verbatim-строка C#",
+			file: "A.cs",
+			src:  "var s = @\"\n  \"\"a\"\", {\n\";\n",
+			want: []bool{false, false, true, true},
+		},
+		{
+			name: "длинные скобки Lua",
+			file: "a.lua",
+			src:  "local s = [==[\n  ]] {\n]==]\n--[[ f(x)\n]] g(y)\n",
+			want: []bool{false, false, true, false, true, true},
+		},
+		{
+			name: "строки SQL",
+			file: "q.sql",
+			src:  "CREATE FUNCTION f() AS $body$\n  SELECT 1;\n$body$;\nINSERT INTO t VALUES ('it''s,\nb', $1);\n",
+			want: []bool{false, false, true, false, true, true},
+		},
+		{
+			name: "комментарии Pascal",
+			file: "a.pas",
+			src:  "{ начало\n  f(x);\n}\nwriteln('it''s (');\n",
+			want: []bool{false, false, true, true, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			syntax := syntaxOf(tt.file)
+			if syntax == nil {
+				t.Fatalf("нет синтаксиса для %s", tt.file)
+			}
+
+			got := syntax.codeLines(bytes.SplitAfter([]byte(tt.src), []byte("\n")))
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("codeLines = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyntaxOf(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "cmd/main.go", want: true},
+		{name: "SRC/APP.PY", want: true},
+		{name: "web/index.tsx", want: true},
+		{name: "README.md", want: false},
+		{name: "config.yaml", want: false},
+		{name: "Makefile", want: false},
+		{name: "app.rb", want: false},
+		{name: "index.php", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := syntaxOf(tt.name) != nil; got != tt.want {
+				t.Fatalf("syntaxOf(%q) = %v, ожидалось %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Package watermark метки в копиях материалов, которые получает покупатель. Метка —
+// код выдачи, по нему в журнале выдач находится покупатель, от которого утекла копия.
+//
+// В архив метка попадает комментарием в README, в комментарий самого архива и
+// невидимыми пробелами в концы строк исходного кода, в PDF — в свойства документа.
+// Extract достает метки из утекшей копии, в том числе из отдельных файлов архива.
+package watermark
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Форматы материалов, в которые умеем ставить метку
+const (
+	FormatZIP = "zip"
+	FormatPDF = "pdf"
+)
+
+// Prefix начало метки в файле: labguard:<код выдачи>
+const Prefix = "labguard:"
+
+const (
+	// codeBytes длина кода выдачи, в base32 это 16 символов
+	codeBytes = 10
+	// nonceBytes случайная часть кода, остальное — подпись лицензии
+	nonceBytes = 4
+)
+
+// checkCode код, с которым Check пробует поставить метку
+const checkCode = "AAAAAAAAAAAAAAAA"
+
+var codeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+var (
+	ErrUnsupported = errors.New("формат файла не поддерживается")
+	ErrMalformed   = errors.New("файл поврежден")
+)
+
+var formats = map[string]string{
+	"application/zip": FormatZIP,
+	"application/pdf": FormatPDF,
+}
+
+// FormatOf формат по типу содержимого
+func FormatOf(contentType string) (string, bool) {
+	format, ok := formats[contentType]
+	return format, ok
+}
+
+// NewCode код выдачи по лицензии: случайная часть и подпись ключом key от id
+// лицензии вместе с этой частью. Копии одной лицензии получают разные коды, по
+// коду без ключа нельзя узнать лицензию и нельзя подделать метку чужой лицензии,
+// а с ключом Bound подтверждает, что код выдан именно по ней.
+func NewCode(key []byte, licenseID int64) (string, error) {
+	payload := make([]byte, codeBytes)
+	if _, err := rand.Read(payload[:nonceBytes]); err != nil {
+		return "", err
+	}
+	copy(payload[nonceBytes:], codeTag(key, licenseID, payload[:nonceBytes]))
+
+	return codeEncoding.EncodeToString(payload), nil
+}
+
+// Bound проверяет, что код выдан по лицензии licenseID с ключом key
+func Bound(key []byte, code string, licenseID int64) bool {
+	payload, err := decodeCode(code)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal(payload[nonceBytes:], codeTag(key, licenseID, payload[:nonceBytes]))
+}
+
+func codeTag(key []byte, licenseID int64, nonce []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(licenseID)))
+	mac.Write(nonce)
+
+	return mac.Sum(nil)[:codeBytes-nonceBytes]
+}
+
+// Apply пишет в dst копию src с меткой code
+func Apply(dst io.Writer, src io.ReaderAt, size int64, format, code string) error {
+	const op = "watermark.Apply"
+
+	if err := apply(dst, src, size, format, code); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Check проверяет, что в файл получится поставить метку. Вызывается при загрузке,
+// чтобы покупатель не получил ошибку вместо материалов. Текст ошибки можно
+// показать тому, кто загружает файл.
+func Check(src io.ReaderAt, size int64, format string) error {
+	return apply(io.Discard, src, size, format, checkCode)
+}
+
+func apply(dst io.Writer, src io.ReaderAt, size int64, format, code string) error {
+	switch format {
+	case FormatZIP:
+		return applyZIP(dst, src, size, code)
+	case FormatPDF:
+		return applyPDF(dst, src, size, code)
+	}
+
+	return ErrUnsupported
+}
+
+func marker(code string) string {
+	return Prefix + code
+}
+
+// decodeCode байты кода выдачи, которые прячутся в исходный код
+func decodeCode(code string) ([]byte, error) {
+	payload, err := codeEncoding.DecodeString(code)
+	if err != nil || len(payload) != codeBytes {
+		return nil, fmt.Errorf("неверный код метки %q", code)
+	}
+
+	return payload, nil
+}
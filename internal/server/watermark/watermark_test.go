@@ -0,0 +1,56 @@
+package watermark
+
+import (
+	"strings"
+	"testing"
+)
+
+const codeAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+
+func TestNewCodeBound(t *testing.T) {
+	key := []byte("ключ подписи меток для тестов")
+
+	code, err := NewCode(key, 42)
+	if err != nil {
+		t.Fatalf("NewCode: %v", err)
+	}
+	if !markerRe.MatchString(marker(code)) {
+		t.Fatalf("код %q не похож на метку", code)
+	}
+
+	other, err := NewCode(key, 42)
+	if err != nil {
+		t.Fatalf("NewCode: %v", err)
+	}
+	if other == code {
+		t.Fatalf("две выдачи одной лицензии получили один код %q", code)
+	}
+
+	// Последний символ кода меняется на соседний в алфавите base32
+	tampered := []byte(code)
+	tampered[len(tampered)-1] = codeAlphabet[(strings.IndexByte(codeAlphabet, code[len(code)-1])+1)%len(codeAlphabet)]
+
+	tests := []struct {
+		name      string
+		key       []byte
+		code      string
+		licenseID int64
+		want      bool
+	}{
+		{name: "своя лицензия", key: key, code: code, licenseID: 42, want: true},
+		{name: "вторая выдача своей лицензии", key: key, code: other, licenseID: 42, want: true},
+		{name: "чужая лицензия", key: key, code: code, licenseID: 43, want: false},
+		{name: "другой ключ", key: []byte("другой ключ"), code: code, licenseID: 42, want: false},
+		{name: "испорченный код", key: key, code: string(tampered), licenseID: 42, want: false},
+		{name: "не base32", key: key, code: "labguard-1234567", licenseID: 42, want: false},
+		{name: "короткий код", key: key, code: code[:8], licenseID: 42, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Bound(tt.key, tt.code, tt.licenseID); got != tt.want {
+				t.Fatalf("Bound = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
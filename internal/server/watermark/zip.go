@@ -0,0 +1,171 @@
+package watermark
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// noticeName файл с меткой для архивов без README
+const noticeName = "LABGUARD.txt"
+
+// readmeNames имена README, в которые ставится метка, в порядке предпочтения
+var readmeNames = []string{"readme.md", "readme.txt", "readme"}
+
+// maxReadmeDepth README ищется в корне архива и в одной папке под ним:
+// архивы репозиториев обычно лежат в папке с именем проекта
+const maxReadmeDepth = 2
+
+// applyZIP копирует записи архива без перепаковки. В README дописывается метка
+// (в .md — HTML-комментарием, который не виден при просмотре), если README нет —
+// добавляется LABGUARD.txt. Метка также ставится в комментарий архива и прячется
+// в исходный код: README и комментарий легко удалить, а код выкладывают целиком.
+func applyZIP(dst io.Writer, src io.ReaderAt, size int64, code string) error {
+	payload, err := decodeCode(code)
+	if err != nil {
+		return err
+	}
+
+	zr, err := zip.NewReader(src, size)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	readme := findReadme(zr.File)
+
+	zw := zip.NewWriter(dst)
+	for _, f := range zr.File {
+		switch {
+		case f == readme:
+			err = writeReadme(zw, f, code)
+		case isSource(f.Name) && f.UncompressedSize64 <= maxSourceSize:
+			err = writeSource(zw, f, payload)
+		default:
+			err = zw.Copy(f)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+	}
+
+	if readme == nil {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: noticeName, Method: zip.Deflate})
+		if err != nil {
+			return err
+		}
+		notice := "Эта копия выдана лично покупателю и помечена.\n" + marker(code) + "\n"
+		if _, err := io.WriteString(w, notice); err != nil {
+			return err
+		}
+	}
+
+	if err := zw.SetComment(marker(code)); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// findReadme README ближе всего к корню архива
+func findReadme(files []*zip.File) *zip.File {
+	var (
+		found     *zip.File
+		bestDepth int
+		bestRank  int
+	)
+	for _, f := range files {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		depth := strings.Count(strings.Trim(f.Name, "/"), "/") + 1
+		if depth > maxReadmeDepth {
+			continue
+		}
+
+		rank := -1
+		for i, name := range readmeNames {
+			if strings.EqualFold(path.Base(f.Name), name) {
+				rank = i
+				break
+			}
+		}
+		if rank < 0 {
+			continue
+		}
+
+		if found == nil || depth < bestDepth || depth == bestDepth && rank < bestRank {
+			found, bestDepth, bestRank = f, depth, rank
+		}
+	}
+
+	return found
+}
+
+func writeReadme(zw *zip.Writer, f *zip.File, code string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	w, err := zw.CreateHeader(rewriteHeader(f))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, rc); err != nil {
+		return err
+	}
+
+	mark := "\n\n" + marker(code) + "\n"
+	if strings.EqualFold(path.Ext(f.Name), ".md") {
+		mark = "\n\n<!-- " + marker(code) + " -->\n"
+	}
+	_, err = io.WriteString(w, mark)
+
+	return err
+}
+
+// writeSource пишет файл с кодом выдачи в концах строк. Если строк для метки мало,
+// файл копируется как есть.
+func writeSource(zw *zip.Writer, f *zip.File, payload []byte) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	content, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return err
+	}
+
+	marked, ok := markSource(f.Name, content, payload)
+	if !ok {
+		return zw.Copy(f)
+	}
+
+	w, err := zw.CreateHeader(rewriteHeader(f))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(marked)
+
+	return err
+}
+
+// rewriteHeader заголовок для записи с новым содержимым. Размеры и контрольная сумма
+// пересчитываются при записи, дополнительные поля могут хранить старые размеры zip64.
+func rewriteHeader(f *zip.File) *zip.FileHeader {
+	header := &zip.FileHeader{
+		Name:     f.Name,
+		Comment:  f.Comment,
+		NonUTF8:  f.NonUTF8,
+		Method:   zip.Deflate,
+		Modified: f.Modified,
+	}
+	header.SetMode(f.Mode())
+
+	return header
+}
@@ -0,0 +1,269 @@
+// Package appconfig загрузка конфигов бота и сервера: путь к файлу из флагов или
+// окружения, профиль окружения, значения по умолчанию для профиля и проверка
+// конфига целиком.
+//
+// Порядок источников, каждый следующий важнее предыдущего: значения по умолчанию
+// из тегов env-default, значения по умолчанию профиля, yaml файл, переменные окружения.
+// .env файл дополняет окружение, но не заменяет уже заданные в нем переменные.
+// Профиль берется из флага -env, переменной APP_ENV или поля env в файле.
+package appconfig
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/joho/godotenv"
+)
+
+// Переменные окружения, которые заменяют флаги
+const (
+	ConfigPathEnv = "CONFIG_PATH"
+	EnvFileEnv    = "ENV_FILE"
+	ProfileEnv    = "APP_ENV"
+)
+
+// MinSecretLength минимальная длина общих секретов в prod
+const MinSecretLength = 32
+
+// Profile окружение, в котором запущен компонент
+type Profile string
+
+const (
+	ProfileLocal Profile = "local" // Машина разработчика: логи текстом, все включено
+	ProfileDev   Profile = "dev"   // Тестовый стенд
+	ProfileProd  Profile = "prod"  // Боевое окружение, проверки конфига строже
+)
+
+func (p Profile) Valid() bool {
+	switch p {
+	case ProfileLocal, ProfileDev, ProfileProd:
+		return true
+	}
+
+	return false
+}
+
+// Base общие поля конфигов компонентов. Встраивается в конфиг с тегом yaml:",inline".
+type Base struct {
+	Env    Profile    `yaml:"env"` // local — логи текстом, dev и prod — JSON
+	Reload ReloadConf `yaml:"reload"`
+}
+
+// ReloadConf перезагрузка конфига без перезапуска процесса. По SIGHUP конфиг
+// перечитывается всегда, Watch добавляет проверку времени изменения файла.
+type ReloadConf struct {
+	Watch    bool          `yaml:"watch"`
+	Interval time.Duration `yaml:"interval" env-default:"5s"`
+}
+
+func (b *Base) base() *Base {
+	return b
+}
+
+// Config конфиг компонента. Реализуется встраиванием Base.
+type Config interface {
+	base() *Base
+}
+
+// ProfileDefaults конфиг, у которого значения по умолчанию зависят от профиля.
+// ApplyProfile вызывается после чтения файла и заполняет только пустые поля.
+type ProfileDefaults interface {
+	ApplyProfile(p Profile)
+}
+
+// Validator конфиг с проверкой связей между полями. Все найденные ошибки
+// собираются в Problems, чтобы их можно было исправить за один раз.
+type Validator interface {
+	Validate(p *Problems)
+}
+
+// Options где компонент ищет конфиг, если путь не передан флагом или переменной
+type Options struct {
+	Name       string // Имя программы в справке по флагам
+	ConfigPath string
+	EnvFile    string // Отсутствие этого файла не ошибка, переменные могут прийти из окружения
+}
+
+// Source откуда был загружен конфиг. Хранит флаги, чтобы перезагрузка читала те же файлы.
+type Source struct {
+	ConfigPath  string
+	EnvFile     string
+	PrintConfig bool // Передан -print-config: вывести конфиг и завершиться
+
+	profile         string // Профиль из флага -env
+	envFileExplicit bool
+	envFileLoaded   bool
+}
+
+// Load разбирает флаги args, читает .env и yaml файл один раз и заполняет cfg
+func Load(args []string, opts Options, cfg Config) (*Source, error) {
+	flags := flag.NewFlagSet(opts.Name, flag.ContinueOnError)
+	configPath := flags.String("config", "", "путь к yaml конфигу, по умолчанию "+opts.ConfigPath+" или $"+ConfigPathEnv)
+	envFile := flags.String("env-file", "", "путь к .env файлу, по умолчанию "+opts.EnvFile+" или $"+EnvFileEnv)
+	profile := flags.String("env", "", "профиль окружения local, dev или prod, заменяет env из конфига и $"+ProfileEnv)
+	printConfig := flags.Bool("print-config", false, "вывести итоговый конфиг без секретов и завершиться")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	src := &Source{PrintConfig: *printConfig, profile: *profile}
+	src.ConfigPath, _ = pick(*configPath, os.Getenv(ConfigPathEnv), opts.ConfigPath)
+	src.EnvFile, src.envFileExplicit = pick(*envFile, os.Getenv(EnvFileEnv), opts.EnvFile)
+
+	return src, src.read(cfg)
+}
+
+// read читает .env и yaml файл один раз и заполняет cfg. Вызывается при старте
+// и при каждой перезагрузке конфига.
+func (src *Source) read(cfg Config) error {
+	const op = "appconfig.read"
+
+	// Файл, указанный явно, обязан существовать, файл по умолчанию — нет
+	if src.EnvFile != "" {
+		err := godotenv.Load(src.EnvFile)
+		switch {
+		case err == nil:
+			src.envFileLoaded = true
+		case errors.Is(err, fs.ErrNotExist) && !src.envFileExplicit:
+		default:
+			return fmt.Errorf("%s: не удалось прочитать %s: %w", op, src.EnvFile, err)
+		}
+	}
+
+	data, err := os.ReadFile(src.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("%s: не удалось открыть конфиг: %w", op, err)
+	}
+
+	if err := cleanenv.ParseYAML(bytes.NewReader(data), cfg); err != nil {
+		return fmt.Errorf("%s: не удалось разобрать %s: %w", op, src.ConfigPath, err)
+	}
+
+	base := cfg.base()
+	if p, _ := pick(src.profile, os.Getenv(ProfileEnv), string(base.Env)); p != "" {
+		base.Env = Profile(p)
+	} else {
+		base.Env = ProfileLocal
+	}
+	if !base.Env.Valid() {
+		return fmt.Errorf("%s: неизвестный профиль %q, ожидается local, dev или prod", op, base.Env)
+	}
+
+	if defaults, ok := cfg.(ProfileDefaults); ok {
+		defaults.ApplyProfile(base.Env)
+	}
+
+	if err := cleanenv.ReadEnv(cfg); err != nil {
+		return fmt.Errorf("%s: не удалось прочитать переменные окружения: %w", op, err)
+	}
+
+	problems := &Problems{profile: base.Env}
+	if base.Reload.Watch && base.Reload.Interval <= 0 {
+		problems.Addf("reload.interval", "интервал проверки файла должен быть положительным")
+	}
+	if validator, ok := cfg.(Validator); ok {
+		validator.Validate(problems)
+	}
+	if err := problems.Err(); err != nil {
+		return fmt.Errorf("%s: %s: %w", op, src.ConfigPath, err)
+	}
+
+	return nil
+}
+
+// MustLoad как Load, но завершает процесс при ошибке. С флагом -print-config
+// выводит конфиг в stdout и завершает процесс без ошибки.
+func MustLoad(logger *slog.Logger, opts Options, cfg Config) *Source {
+	const op = "appconfig.MustLoad"
+	logger = logger.With(slog.String("op", op))
+
+	src, err := Load(os.Args[1:], opts, cfg)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		var invalid *ValidationError
+		if errors.As(err, &invalid) {
+			logger.Error("Конфиг содержит ошибки", slog.String("path", src.ConfigPath), slog.String("problems", strings.Join(invalid.Problems, "; ")))
+		} else {
+			logger.Error("Ошибка загрузки конфига", slog.String("error", err.Error()))
+		}
+		os.Exit(1)
+	}
+
+	if src.PrintConfig {
+		if err := Print(os.Stdout, cfg); err != nil {
+			logger.Error("Не удалось вывести конфиг", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if src.EnvFile != "" && !src.envFileLoaded {
+		logger.Info("Файл .env не найден, переменные берутся из окружения", slog.String("path", src.EnvFile))
+	}
+	logger.Info("Конфиг загружен", slog.String("path", src.ConfigPath), slog.String("env", string(cfg.base().Env)))
+
+	return src
+}
+
+// pick первое непустое значение и признак того, что оно задано явно, а не по умолчанию
+func pick(flagValue, envValue, fallback string) (string, bool) {
+	if flagValue != "" {
+		return flagValue, true
+	}
+	if envValue != "" {
+		return envValue, true
+	}
+
+	return fallback, false
+}
+
+// Problems ошибки проверки конфига
+type Problems struct {
+	profile Profile
+	list    []string
+}
+
+// Profile профиль, для которого проверяется конфиг
+func (p *Problems) Profile() Profile {
+	return p.profile
+}
+
+// Addf добавляет ошибку. field — путь к полю в yaml или имя переменной окружения.
+func (p *Problems) Addf(field, format string, args ...any) {
+	p.list = append(p.list, field+": "+fmt.Sprintf(format, args...))
+}
+
+// Check добавляет ошибку, если err не nil
+func (p *Problems) Check(field string, err error) {
+	if err != nil {
+		p.list = append(p.list, field+": "+err.Error())
+	}
+}
+
+// Err ValidationError со всеми ошибками или nil, если их нет
+func (p *Problems) Err() error {
+	if len(p.list) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Problems: p.list}
+}
+
+// ValidationError конфиг прочитан, но его значения противоречат друг другу
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "неверный конфиг: " + strings.Join(e.Problems, "; ")
+}
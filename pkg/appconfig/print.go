@@ -0,0 +1,118 @@
+package appconfig
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted чем заменяется значение поля с тегом secret:"true"
+const redacted = "***"
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// Print выводит cfg в yaml. Значения полей с тегом secret:"true" заменяются на ***,
+// у полей, которые читаются из окружения, в комментарии указана переменная.
+func Print(w io.Writer, cfg Config) error {
+	const op = "appconfig.Print"
+
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{valueNode(reflect.ValueOf(cfg))}}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return enc.Close()
+}
+
+func valueNode(v reflect.Value) *yaml.Node {
+	if v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+		}
+		return valueNode(v.Elem())
+	}
+
+	if v.Type() == durationType {
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: v.Interface().(time.Duration).String()}
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		appendFields(node, v)
+		return node
+
+	case reflect.Slice, reflect.Array:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for i := range v.Len() {
+			node.Content = append(node.Content, valueNode(v.Index(i)))
+		}
+		return node
+
+	case reflect.Map:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(key.Interface())},
+				valueNode(v.MapIndex(key)),
+			)
+		}
+		return node
+	}
+
+	node := &yaml.Node{}
+	if err := node.Encode(v.Interface()); err != nil {
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(v.Interface())}
+	}
+
+	return node
+}
+
+// appendFields добавляет в node поля структуры в порядке объявления.
+// Встроенные структуры без имени в yaml раскрываются на том же уровне, как при чтении.
+func appendFields(node *yaml.Node, v reflect.Value) {
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" || opts == "inline" {
+			appendFields(node, reflect.Indirect(v.Field(i)))
+			continue
+		}
+		if name == "" {
+			// Так поле без тега называет yaml при чтении
+			name = strings.ToLower(field.Name)
+		}
+
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: name}
+		if env := field.Tag.Get("env"); env != "" {
+			key.LineComment = "$" + env
+		}
+
+		value := valueNode(v.Field(i))
+		if field.Tag.Get("secret") == "true" && !v.Field(i).IsZero() {
+			value = &yaml.Node{Kind: yaml.ScalarNode, Value: redacted}
+		}
+
+		node.Content = append(node.Content, key, value)
+	}
+}
@@ -0,0 +1,305 @@
+package appconfig
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Поля с тегом reload:"live" компоненты умеют применять на ходу. Тег на структуре
+// относится ко всем ее полям. Изменение любого другого поля требует перезапуска,
+// и такая перезагрузка отклоняется целиком.
+
+// Change изменение одного поля конфига
+type Change struct {
+	Field string // Путь к полю в yaml
+	Old   string
+	New   string
+	Live  bool // Поле применяется без перезапуска
+}
+
+// RestartRequiredError в новом конфиге изменены поля, которые применяются только при запуске
+type RestartRequiredError struct {
+	Fields []string
+}
+
+func (e *RestartRequiredError) Error() string {
+	return "изменения требуют перезапуска: " + strings.Join(e.Fields, ", ")
+}
+
+// Diff изменения между двумя конфигами одного типа. Значения секретов не раскрываются.
+func Diff(old, new Config) []Change {
+	var changes []Change
+	diffValues(&changes, "", reflect.ValueOf(old), reflect.ValueOf(new), false, false)
+
+	return changes
+}
+
+func diffValues(changes *[]Change, path string, a, b reflect.Value, live, secret bool) {
+	if a.Kind() == reflect.Pointer || a.Kind() == reflect.Interface {
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				addChange(changes, path, a, b, live, secret)
+			}
+			return
+		}
+		diffValues(changes, path, a.Elem(), b.Elem(), live, secret)
+		return
+	}
+
+	switch {
+	case a.Kind() == reflect.Struct && a.Type() != durationType:
+		diffFields(changes, path, a, b, live)
+
+	case a.Kind() == reflect.Map && !secret:
+		keys := map[string]reflect.Value{}
+		for _, key := range append(a.MapKeys(), b.MapKeys()...) {
+			keys[fmt.Sprint(key.Interface())] = key
+		}
+		names := make([]string, 0, len(keys))
+		for name := range keys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			av, bv := a.MapIndex(keys[name]), b.MapIndex(keys[name])
+			if !av.IsValid() || !bv.IsValid() {
+				addChange(changes, joinPath(path, name), av, bv, live, secret)
+				continue
+			}
+			diffValues(changes, joinPath(path, name), av, bv, live, secret)
+		}
+
+	default:
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			addChange(changes, path, a, b, live, secret)
+		}
+	}
+}
+
+func diffFields(changes *[]Change, path string, a, b reflect.Value, live bool) {
+	t := a.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+
+		fieldLive := live || field.Tag.Get("reload") == "live"
+		secret := field.Tag.Get("secret") == "true"
+
+		if field.Anonymous && name == "" || opts == "inline" {
+			diffValues(changes, path, a.Field(i), b.Field(i), fieldLive, secret)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		diffValues(changes, joinPath(path, name), a.Field(i), b.Field(i), fieldLive, secret)
+	}
+}
+
+func addChange(changes *[]Change, path string, a, b reflect.Value, live, secret bool) {
+	change := Change{Field: path, Old: formatValue(a), New: formatValue(b), Live: live}
+	// Секреты приходят из окружения и меняются только с перезапуском
+	if secret {
+		change.Old, change.New, change.Live = redacted, redacted, false
+	}
+
+	*changes = append(*changes, change)
+}
+
+// formatValue значение в одну строку в виде yaml
+func formatValue(v reflect.Value) string {
+	if !v.IsValid() {
+		return "<нет>"
+	}
+
+	node := valueNode(v)
+	setFlowStyle(node)
+
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return fmt.Sprint(v.Interface())
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+func setFlowStyle(node *yaml.Node) {
+	node.Style |= yaml.FlowStyle
+	for _, child := range node.Content {
+		setFlowStyle(child)
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
+
+// Reloader перечитывает конфиг по SIGHUP, а если включено reload.watch, то и при
+// изменении файла. Новый конфиг проверяется так же, как при запуске, и передается
+// в apply, только если все изменения применяются без перезапуска.
+type Reloader struct {
+	src       *Source
+	newConfig func() Config
+	apply     func(Config)
+	logger    *slog.Logger
+
+	mu      sync.Mutex
+	current Config
+
+	done chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+// NewReloader newConfig создает пустой конфиг того же типа, что current
+func NewReloader(src *Source, current Config, newConfig func() Config, apply func(Config), logger *slog.Logger) *Reloader {
+	return &Reloader{
+		src:       src,
+		newConfig: newConfig,
+		apply:     apply,
+		logger:    logger.With(slog.String("component", "config_reloader")),
+		current:   current,
+		done:      make(chan struct{}),
+	}
+}
+
+// Reload перечитывает конфиг и применяет изменения. При ошибке продолжает работать старый конфиг.
+func (r *Reloader) Reload() error {
+	const op = "appconfig.Reloader.Reload"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fresh := r.newConfig()
+	if err := r.src.read(fresh); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	changes := Diff(r.current, fresh)
+	if len(changes) == 0 {
+		r.logger.Info("Конфиг перечитан, изменений нет", slog.String("path", r.src.ConfigPath))
+		return nil
+	}
+
+	var restart []string
+	for _, change := range changes {
+		if !change.Live {
+			restart = append(restart, change.Field)
+		}
+	}
+	if len(restart) > 0 {
+		return fmt.Errorf("%s: %w", op, &RestartRequiredError{Fields: restart})
+	}
+
+	r.apply(fresh)
+	r.current = fresh
+
+	for _, change := range changes {
+		r.logger.Info("Поле конфига изменено",
+			slog.String("field", change.Field),
+			slog.String("old", change.Old),
+			slog.String("new", change.New),
+		)
+	}
+	r.logger.Info("Конфиг применен", slog.String("path", r.src.ConfigPath), slog.Int("changes", len(changes)))
+
+	return nil
+}
+
+// Start начинает ждать SIGHUP и следить за файлом, если это включено в конфиге
+func (r *Reloader) Start() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	// Без reload.watch канал тикера остается nil и никогда не срабатывает
+	var ticker *time.Ticker
+	var tick <-chan time.Time
+	if conf := r.current.base().Reload; conf.Watch {
+		ticker = time.NewTicker(conf.Interval)
+		tick = ticker.C
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer signal.Stop(signals)
+		if ticker != nil {
+			defer ticker.Stop()
+		}
+
+		modTime := r.modTime()
+		for {
+			select {
+			case <-signals:
+				r.reloadAndLog("SIGHUP")
+				modTime = r.modTime()
+			case <-tick:
+				if changed := r.modTime(); !changed.Equal(modTime) {
+					modTime = changed
+					r.reloadAndLog("файл изменен")
+				}
+			case <-r.done:
+				return
+			}
+		}
+	}()
+}
+
+func (r *Reloader) Stop() {
+	r.once.Do(func() {
+		close(r.done)
+	})
+	r.wg.Wait()
+}
+
+func (r *Reloader) reloadAndLog(reason string) {
+	err := r.Reload()
+	if err == nil {
+		return
+	}
+
+	var restart *RestartRequiredError
+	if errors.As(err, &restart) {
+		r.logger.Warn("Новый конфиг не применен: изменены поля, которые читаются только при запуске",
+			slog.String("reason", reason),
+			slog.String("fields", strings.Join(restart.Fields, ", ")),
+		)
+		return
+	}
+
+	r.logger.Error("Новый конфиг не применен", slog.String("reason", reason), slog.String("error", err.Error()))
+}
+
+// modTime время изменения файла конфига, нулевое, если файл сейчас недоступен
+func (r *Reloader) modTime() time.Time {
+	info, err := os.Stat(r.src.ConfigPath)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
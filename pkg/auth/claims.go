@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"slices"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Роли владельцев токенов
+const (
+	RoleBot    = "bot"
+	RoleAdmin  = "admin"
+	RoleClient = "client"
+)
+
+// Права, которые проверяются на маршрутах сервера
+const (
+	ScopeUsersRead      = "users:read"
+	ScopeUsersWrite     = "users:write"
+	ScopePurchases      = "purchases"
+	ScopeWalletRead     = "wallet:read"
+	ScopeNotifications  = "notifications"
+	ScopeSeats          = "seats"
+	ScopeRefunds        = "refunds"
+	ScopeProducts       = "products:read"
+	ScopeArtifacts      = "artifacts"
+	ScopePromoAdmin     = "promo:admin"
+	ScopeRefundsAdmin   = "refunds:admin"
+	ScopeAuditAdmin     = "audit:admin"
+	ScopeProductsAdmin  = "products:admin"
+	ScopeArtifactsAdmin = "artifacts:admin"
+	ScopeUsersAdmin     = "users:admin"
+	ScopeLicensesVerify = "licenses:verify"
+)
+
+const (
+	IssuerBot   = "labguard-bot"
+	IssuerAdmin = "labguard-admin"
+
+	DefaultAudience = "labguard-api"
+)
+
+// issuerRoles роли, которые может выдавать каждый издатель. Проверка отсекает
+// ошибки выпуска, но разделяет компоненты только с асимметричными ключами: ключ
+// бота не подпишет токен администратора. Общий секрет HS256 есть у всех
+// компонентов, и бот с ним может выпустить токен с iss=labguard-admin.
+var issuerRoles = map[string][]string{
+	IssuerBot:   {RoleBot},
+	IssuerAdmin: {RoleAdmin, RoleClient},
+}
+
+// roleScopes права, которые может получить токен каждой роли. Права сверх этого
+// списка отбрасываются при проверке, даже если токен подписан корректно.
+var roleScopes = map[string][]string{
+	RoleBot: {
+		ScopeUsersRead,
+		ScopeUsersWrite,
+		ScopePurchases,
+		ScopeWalletRead,
+		ScopeNotifications,
+		ScopeSeats,
+		ScopeRefunds,
+		ScopeProducts,
+		ScopeArtifacts,
+	},
+	RoleAdmin: {
+		ScopePromoAdmin,
+		ScopeRefundsAdmin,
+		ScopeAuditAdmin,
+		ScopeProductsAdmin,
+		ScopeArtifactsAdmin,
+		ScopeUsersAdmin,
+	},
+	RoleClient: {
+		ScopeLicensesVerify,
+	},
+}
+
+// Claims типизированные claims токенов labguard
+type Claims struct {
+	Role   string   `json:"role"`
+	Scopes []string `json:"scopes,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// DefaultScopes возвращает все права роли, пустой список для неизвестной роли
+func DefaultScopes(role string) []string {
+	return slices.Clone(roleScopes[role])
+}
+
+// KnownRole проверяет, что роль входит в список поддерживаемых
+func KnownRole(role string) bool {
+	_, ok := roleScopes[role]
+	return ok
+}
+
+// IssuerAllowsRole проверяет, что издатель может выдавать токены с ролью role
+func IssuerAllowsRole(issuer, role string) bool {
+	return slices.Contains(issuerRoles[issuer], role)
+}
+
+// AllowedScopes оставляет из scopes только те права, которые разрешены роли
+func AllowedScopes(role string, scopes []string) []string {
+	allowed := roleScopes[role]
+
+	result := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if slices.Contains(allowed, scope) && !slices.Contains(result, scope) {
+			result = append(result, scope)
+		}
+	}
+
+	return result
+}
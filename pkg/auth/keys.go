@@ -0,0 +1,282 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Режимы подписи токенов
+const (
+	AlgorithmHS256      = "hs256"      // Общий секрет, запасной режим для локальной разработки
+	AlgorithmAsymmetric = "asymmetric" // EdDSA или RS256, тип определяется по ключу
+)
+
+var (
+	ErrUnknownKey        = errors.New("неизвестный ключ подписи")
+	ErrUnsupportedKey    = errors.New("поддерживаются только ключи Ed25519 и RSA")
+	ErrAlgorithmMismatch = errors.New("алгоритм токена не совпадает с алгоритмом ключа")
+	ErrKeyNotAllowed     = errors.New("ключ не может подписывать токены этого издателя или роли")
+)
+
+// SigningKey закрытый ключ, которым компонент подписывает свои токены
+type SigningKey struct {
+	ID     string
+	method jwt.SigningMethod
+	key    any
+}
+
+// NewHMACSigningKey ключ для запасного режима HS256, kid в токен не пишется
+func NewHMACSigningKey(secret string) *SigningKey {
+	return &SigningKey{method: jwt.SigningMethodHS256, key: []byte(secret)}
+}
+
+// LoadSigningKey читает закрытый ключ PKCS#8 в PEM. Алгоритм выбирается по типу ключа.
+func LoadSigningKey(id, path string) (*SigningKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось разобрать закрытый ключ %s: %w", path, err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+
+	method, err := methodForKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{ID: id, method: method, key: key}, nil
+}
+
+// Sign подписывает claims, добавляя kid в заголовок токена
+func (k *SigningKey) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.method, claims)
+	if k.ID != "" {
+		token.Header["kid"] = k.ID
+	}
+
+	return token.SignedString(k.key)
+}
+
+// KeyBinding издатели и роли токенов, которые вправе подписывать ключ. Каждая пара
+// издатель — роль должна быть допустима, см. IssuerAllowsRole.
+type KeyBinding struct {
+	Issuers []string
+	Roles   []string
+}
+
+func (b KeyBinding) validate() error {
+	if len(b.Issuers) == 0 || len(b.Roles) == 0 {
+		return fmt.Errorf("у ключа не указаны издатели и роли")
+	}
+
+	for _, issuer := range b.Issuers {
+		for _, role := range b.Roles {
+			if !IssuerAllowsRole(issuer, role) {
+				return fmt.Errorf("издатель %q не может выдавать токены с ролью %q", issuer, role)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (b KeyBinding) allows(issuer, role string) bool {
+	return slices.Contains(b.Issuers, issuer) && slices.Contains(b.Roles, role)
+}
+
+type verificationKey struct {
+	method  jwt.SigningMethod
+	key     any
+	binding KeyBinding
+}
+
+// KeyRing набор ключей, которыми проверяются токены. Во время ротации в нем
+// одновременно лежат старый и новый открытые ключи, токен выбирает ключ по kid.
+type KeyRing struct {
+	hmacSecret []byte
+	keys       map[string]verificationKey
+	order      []string
+}
+
+// NewHMACKeyRing кольцо для запасного режима HS256
+func NewHMACKeyRing(secret string) *KeyRing {
+	return &KeyRing{hmacSecret: []byte(secret), keys: make(map[string]verificationKey)}
+}
+
+func NewKeyRing() *KeyRing {
+	return &KeyRing{keys: make(map[string]verificationKey)}
+}
+
+// AddPublicKeyFile добавляет открытый ключ PKIX в PEM под идентификатором id.
+// Ключ проверяет только токены издателей и ролей из binding.
+func (r *KeyRing) AddPublicKeyFile(id, path string, binding KeyBinding) error {
+	block, err := readPEM(path)
+	if err != nil {
+		return err
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("не удалось разобрать открытый ключ %s: %w", path, err)
+	}
+
+	return r.AddPublicKey(id, key, binding)
+}
+
+func (r *KeyRing) AddPublicKey(id string, key crypto.PublicKey, binding KeyBinding) error {
+	if id == "" {
+		return fmt.Errorf("у ключа не указан kid")
+	}
+
+	if err := binding.validate(); err != nil {
+		return fmt.Errorf("ключ %q: %w", id, err)
+	}
+
+	if _, ok := r.keys[id]; ok {
+		return fmt.Errorf("ключ с kid %q уже добавлен", id)
+	}
+
+	method, err := methodForKey(key)
+	if err != nil {
+		return err
+	}
+
+	r.keys[id] = verificationKey{method: method, key: key, binding: binding}
+	r.order = append(r.order, id)
+
+	return nil
+}
+
+// Methods алгоритмы, которые принимает кольцо
+func (r *KeyRing) Methods() []string {
+	var methods []string
+	if r.hmacSecret != nil {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+
+	for _, id := range r.order {
+		if alg := r.keys[id].method.Alg(); !slices.Contains(methods, alg) {
+			methods = append(methods, alg)
+		}
+	}
+
+	return methods
+}
+
+// Keyfunc выбирает ключ проверки для jwt.ParseWithClaims с *Claims. Токен отклоняется,
+// если его издатель не может выдавать такую роль или ключ не привязан к его издателю и роли.
+// У общего секрета HS256 привязки нет: им подписывают все компоненты.
+func (r *KeyRing) Keyfunc(t *jwt.Token) (any, error) {
+	claims, ok := t.Claims.(*Claims)
+	if !ok || !IssuerAllowsRole(claims.Issuer, claims.Role) {
+		return nil, ErrKeyNotAllowed
+	}
+
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+		if r.hmacSecret == nil {
+			return nil, ErrAlgorithmMismatch
+		}
+		return r.hmacSecret, nil
+	}
+
+	kid, _ := t.Header["kid"].(string)
+	key, ok := r.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+
+	if key.method.Alg() != t.Method.Alg() {
+		return nil, ErrAlgorithmMismatch
+	}
+
+	if !key.binding.allows(claims.Issuer, claims.Role) {
+		return nil, fmt.Errorf("%w: %q", ErrKeyNotAllowed, kid)
+	}
+
+	return key.key, nil
+}
+
+// JWK открытый ключ в формате RFC 7517
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS открытые ключи кольца. Секрет HS256 никогда не публикуется.
+func (r *KeyRing) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(r.order))}
+
+	for _, id := range r.order {
+		key := r.keys[id]
+		jwk := JWK{KeyID: id, Algorithm: key.method.Alg(), Use: "sig"}
+
+		switch pub := key.key.(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+func methodForKey(key crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch key.(type) {
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	}
+
+	return nil, ErrUnsupportedKey
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать ключ: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("файл %s не содержит PEM блок", path)
+	}
+
+	return block, nil
+}
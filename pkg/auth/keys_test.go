@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testKey(t *testing.T, ring *KeyRing, id string, binding KeyBinding) *SigningKey {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("генерация ключа: %v", err)
+	}
+	if err := ring.AddPublicKey(id, pub, binding); err != nil {
+		t.Fatalf("AddPublicKey: %v", err)
+	}
+
+	return &SigningKey{ID: id, method: jwt.SigningMethodEdDSA, key: priv}
+}
+
+func testToken(t *testing.T, key *SigningKey, issuer, role string) string {
+	t.Helper()
+
+	token, err := key.Sign(Claims{
+		Role: role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "test",
+			Issuer:    issuer,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	return token
+}
+
+func parse(ring *KeyRing, token string) error {
+	_, err := jwt.NewParser(jwt.WithValidMethods(ring.Methods())).ParseWithClaims(token, &Claims{}, ring.Keyfunc)
+	return err
+}
+
+func TestKeyfuncBinding(t *testing.T) {
+	ring := NewKeyRing()
+	botKey := testKey(t, ring, "bot-2026-10", KeyBinding{Issuers: []string{IssuerBot}, Roles: []string{RoleBot}})
+	adminKey := testKey(t, ring, "admin-2026-10", KeyBinding{Issuers: []string{IssuerAdmin}, Roles: []string{RoleAdmin}})
+
+	tests := []struct {
+		name   string
+		key    *SigningKey
+		issuer string
+		role   string
+		err    error
+	}{
+		{name: "токен бота", key: botKey, issuer: IssuerBot, role: RoleBot},
+		{name: "токен администратора", key: adminKey, issuer: IssuerAdmin, role: RoleAdmin},
+		{name: "ключ бота подписал роль администратора", key: botKey, issuer: IssuerBot, role: RoleAdmin, err: ErrKeyNotAllowed},
+		{name: "ключ бота от имени администратора", key: botKey, issuer: IssuerAdmin, role: RoleAdmin, err: ErrKeyNotAllowed},
+		{name: "ключ администратора от имени бота", key: adminKey, issuer: IssuerBot, role: RoleBot, err: ErrKeyNotAllowed},
+		{name: "роль не привязана к ключу", key: adminKey, issuer: IssuerAdmin, role: RoleClient, err: ErrKeyNotAllowed},
+		{name: "неизвестный издатель", key: botKey, issuer: "attacker", role: RoleBot, err: ErrKeyNotAllowed},
+		{
+			name:   "неизвестный kid",
+			key:    &SigningKey{ID: "missing", method: botKey.method, key: botKey.key},
+			issuer: IssuerBot,
+			role:   RoleBot,
+			err:    ErrUnknownKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := parse(ring, testToken(t, tt.key, tt.issuer, tt.role)); !errors.Is(err, tt.err) {
+				t.Fatalf("ошибка %v, ожидалась %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestKeyfuncHMAC(t *testing.T) {
+	ring := NewHMACKeyRing("secret")
+	key := NewHMACSigningKey("secret")
+
+	tests := []struct {
+		name   string
+		issuer string
+		role   string
+		err    error
+	}{
+		{name: "токен бота", issuer: IssuerBot, role: RoleBot},
+		{name: "токен администратора", issuer: IssuerAdmin, role: RoleAdmin},
+		{name: "токен клиента", issuer: IssuerAdmin, role: RoleClient},
+		{name: "бот с ролью администратора", issuer: IssuerBot, role: RoleAdmin, err: ErrKeyNotAllowed},
+		{name: "администратор с ролью бота", issuer: IssuerAdmin, role: RoleBot, err: ErrKeyNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := parse(ring, testToken(t, key, tt.issuer, tt.role)); !errors.Is(err, tt.err) {
+				t.Fatalf("ошибка %v, ожидалась %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestAddPublicKeyBinding(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("генерация ключа: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		binding KeyBinding
+		ok      bool
+	}{
+		{name: "ключ бота", binding: KeyBinding{Issuers: []string{IssuerBot}, Roles: []string{RoleBot}}, ok: true},
+		{name: "ключ администратора", binding: KeyBinding{Issuers: []string{IssuerAdmin}, Roles: []string{RoleAdmin, RoleClient}}, ok: true},
+		{name: "без привязки", binding: KeyBinding{}},
+		{name: "без ролей", binding: KeyBinding{Issuers: []string{IssuerBot}}},
+		{name: "ключ бота с ролью администратора", binding: KeyBinding{Issuers: []string{IssuerBot}, Roles: []string{RoleBot, RoleAdmin}}},
+		{name: "общий ключ бота и администратора", binding: KeyBinding{Issuers: []string{IssuerBot, IssuerAdmin}, Roles: []string{RoleAdmin}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewKeyRing().AddPublicKey("key", pub, tt.binding)
+			if (err == nil) != tt.ok {
+				t.Fatalf("AddPublicKey: ошибка %v, ожидался успех %v", err, tt.ok)
+			}
+		})
+	}
+}
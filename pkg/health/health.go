@@ -0,0 +1,126 @@
+// Package health проверки живости и готовности, общие для бота и сервера.
+// Живость говорит только о том, что процесс отвечает. Готовность проверяет
+// зависимости, без которых запросы обслуживать нельзя.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Статусы проверок и отчета в целом
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// CheckFunc проверяет одну зависимость. Ошибка означает, что зависимость недоступна.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	run  CheckFunc
+}
+
+// CheckResult результат проверки одной зависимости
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report результат всех проверок. Status ok, только если прошли все проверки.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Checker набор проверок готовности. Проверки запускаются параллельно,
+// каждая ограничена общим timeout.
+type Checker struct {
+	timeout time.Duration
+	checks  []check
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add регистрирует проверку. Вызывается до того, как Checker начал обслуживать запросы.
+func (c *Checker) Add(name string, run CheckFunc) {
+	c.checks = append(c.checks, check{name: name, run: run})
+}
+
+// Run запускает все проверки и собирает отчет
+func (c *Checker) Run(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(c.checks))}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, ch := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result := runCheck(ctx, ch.run)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[ch.name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+func runCheck(ctx context.Context, run CheckFunc) CheckResult {
+	start := time.Now()
+	err := run(ctx)
+	latency := float64(time.Since(start).Microseconds()) / 1000
+
+	if err != nil {
+		return CheckResult{Status: StatusFail, LatencyMs: latency, Error: err.Error()}
+	}
+
+	return CheckResult{Status: StatusOK, LatencyMs: latency}
+}
+
+// ReadyHandler отдает отчет проверок: 200, если все зависимости доступны, иначе 503
+func (c *Checker) ReadyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := c.Run(r.Context())
+
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+
+		writeJSON(w, status, report)
+	}
+}
+
+// LiveHandler отвечает 200, пока процесс способен обрабатывать HTTP запросы.
+// Зависимости не проверяются, чтобы их сбой не приводил к перезапуску процесса.
+func LiveHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": StatusOK})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(body)
+}
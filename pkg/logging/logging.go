@@ -0,0 +1,85 @@
+// Package logging настройка slog для бота и сервера: формат по окружению
+// и id запроса, который попадает в каждую запись через контекст.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// RequestIDHeader заголовок, в котором бот передает серверу id запроса
+	RequestIDHeader = "X-Request-Id"
+
+	// EnvLocal окружение разработчика: логи текстом, в остальных окружениях JSON
+	EnvLocal = "local"
+
+	requestIDBytes = 8
+)
+
+type requestIDKey struct{}
+
+// New логгер для окружения env. Записи, сделанные методами *Context, получают request_id из контекста.
+func New(env string, w io.Writer) *slog.Logger {
+	var handler slog.Handler
+	if env == EnvLocal {
+		handler = slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
+	} else {
+		handler = slog.NewJSONHandler(w, nil)
+	}
+
+	return slog.New(NewContextHandler(handler))
+}
+
+// ContextHandler дописывает в запись id запроса и id трассировки из контекста
+type ContextHandler struct {
+	slog.Handler
+}
+
+func NewContextHandler(next slog.Handler) *ContextHandler {
+	return &ContextHandler{Handler: next}
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID id запроса из контекста, пустая строка если его нет
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID случайный id запроса
+func NewRequestID() string {
+	buf := make([]byte, requestIDBytes)
+	// rand.Read не возвращает ошибок начиная с Go 1.24
+	rand.Read(buf)
+
+	return hex.EncodeToString(buf)
+}
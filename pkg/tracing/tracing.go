@@ -0,0 +1,108 @@
+// Package tracing настройка OpenTelemetry для бота и сервера
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/GeorgeTyupin/labguard/pkg/appconfig"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Экспортеры спанов
+const (
+	ExporterNone   = "none"   // Спаны не собираются
+	ExporterStdout = "stdout" // Спаны печатаются в stdout, для локальной разработки
+	ExporterOTLP   = "otlp"   // Спаны отправляются в коллектор по OTLP/HTTP
+)
+
+// Config настройки трассировки, общие для бота и сервера
+type Config struct {
+	Exporter    string  `yaml:"exporter" env:"OTEL_EXPORTER" env-default:"none"`
+	Endpoint    string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"` // host:port коллектора для otlp
+	Insecure    bool    `yaml:"insecure"`                                   // Без TLS, для коллектора в той же сети
+	SampleRatio float64 `yaml:"sample_ratio" env-default:"1"`               // Доля запросов, для которых пишутся спаны, в prod по умолчанию 0.1
+}
+
+// ApplyProfile в prod по умолчанию пишется каждая десятая трасса
+func (c *Config) ApplyProfile(p appconfig.Profile) {
+	if c.SampleRatio == 0 && p == appconfig.ProfileProd {
+		c.SampleRatio = 0.1
+	}
+}
+
+// Validate проверяет настройки экспортера. field — путь к секции в yaml.
+func (c Config) Validate(p *appconfig.Problems, field string) {
+	switch c.Exporter {
+	case ExporterNone, "":
+	case ExporterStdout:
+		if p.Profile() == appconfig.ProfileProd {
+			p.Addf(field+".exporter", "экспортер %s не подходит для prod", ExporterStdout)
+		}
+	case ExporterOTLP:
+		if c.Endpoint == "" {
+			p.Addf(field+".endpoint", "для экспортера %s нужен адрес коллектора", ExporterOTLP)
+		}
+	default:
+		p.Addf(field+".exporter", "неизвестный экспортер %q, ожидается none, stdout или otlp", c.Exporter)
+	}
+
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		p.Addf(field+".sample_ratio", "доля %v вне диапазона 0-1", c.SampleRatio)
+	}
+}
+
+// Setup настраивает глобальный TracerProvider и передачу контекста трассировки в заголовках.
+// Возвращает функцию, которая отправляет оставшиеся спаны при завершении.
+func Setup(ctx context.Context, cfg Config, serviceName string) (func(context.Context) error, error) {
+	const op = "tracing.Setup"
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("%s: неизвестный экспортер %q, ожидается none, stdout или otlp", op, cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer трейсер компонента из глобального провайдера
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer(name)
+}